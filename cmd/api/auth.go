@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+const basicAuthRealm = "EBook"
+
+// basicAuthUser 读取 HTTP Basic 认证(邮箱、密码)的用户，没有提供返回nil，账号密码错误返回 ErrUnauthorized；
+// 阅读器等客户端只支持 Basic 认证
+func (app *Application) basicAuthUser(r *http.Request) (*models.User, error) {
+	email, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+
+	user, err := app.Db.UserRepo.GetByUqField(r.Context(), dbrepo.UserUq{Email: email})
+	if err != nil {
+		if errors.Is(err, dbrepo.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if err := user.MatchesPassword(password); err != nil {
+		return nil, errs.ErrUnauthorized
+	}
+
+	return user, nil
+}
+
+// requireBasicAuth 获取 Basic 认证的用户，没有提供或者账号密码错误时写入401响应并返回false
+func (app *Application) requireBasicAuth(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := app.basicAuthUser(r)
+	if err != nil && !errors.Is(err, errs.ErrUnauthorized) {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return nil, false
+	}
+	if user == nil {
+		app.unauthorized(w, r)
+		return nil, false
+	}
+	return user, true
+}
+
+// unauthorized 要求客户端提供账号密码
+func (app *Application) unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", basicAuthRealm))
+	app.FAIL(w, r, errs.ErrUnauthorized.WithMessage("请使用邮箱和密码登录"))
+}
@@ -13,7 +13,7 @@ func (app *Application) PostAuthorHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	newID, err := app.Db.AuthorRepo.Create(r.Context(), author.AuthorName)
+	newID, err := app.Db.AuthorRepo.Create(r.Context(), &author)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -50,7 +50,9 @@ func (app *Application) PutAuthorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := app.Db.AuthorRepo.Update(r.Context(), id, author.AuthorName)
+	author.ID = id
+
+	err := app.Db.AuthorRepo.Update(r.Context(), &author)
 	if err != nil {
 		a = dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -59,6 +61,36 @@ func (app *Application) PutAuthorHandler(w http.ResponseWriter, r *http.Request)
 
 	app.SUCC(w, r, id)
 }
+
+// PatchAuthorHandler 部分更新作者，请求体为 JSON Merge Patch(RFC 7396)
+func (app *Application) PatchAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := app.Db.AuthorRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var author models.Author
+	if ok := app.ShouldBindMergePatchAndCheck(w, r, old, &author); !ok {
+		return
+	}
+
+	err = app.Db.AuthorRepo.Patch(r.Context(), old, &author)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
 func (app *Application) DeleteAuthorHandler(w http.ResponseWriter, r *http.Request) {
 	id, a := app.readIntParam(r, "id")
 	if a != nil {
@@ -92,3 +124,32 @@ func (app *Application) ListAuthorHandler(w http.ResponseWriter, r *http.Request
 
 	app.SUCC(w, r, data)
 }
+
+// ListAuthorBooksHandler 分页查询作者的图书，作者是图书任意角色的贡献者即可
+func (app *Application) ListAuthorBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if _, err := app.Db.AuthorRepo.Get(r.Context(), id); err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	dataVo, err := app.Db.BookRepo.ListByAuthor(r.Context(), id, app.readPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if list, ok := dataVo.List.([]*models.Book); ok {
+		hideSourceUrl(list...)
+		app.withCoverSrcset(r.Context(), list...)
+	}
+
+	app.SUCC(w, r, dataVo)
+}
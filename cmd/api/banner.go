@@ -39,6 +39,7 @@ func (app *Application) GetBannerHandler(w http.ResponseWriter, r *http.Request)
 		app.FAIL(w, r, a)
 		return
 	}
+	app.withImageSrcset(r.Context(), data)
 	app.SUCC(w, r, data)
 }
 
@@ -80,6 +81,36 @@ func (app *Application) PutBannerHandler(w http.ResponseWriter, r *http.Request)
 	app.SUCC(w, r, id)
 }
 
+// PatchBannerHandler 部分更新banner，请求体为 JSON Merge Patch(RFC 7396)
+func (app *Application) PatchBannerHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := app.Db.BannerRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var banner models.Banner
+	if ok := app.ShouldBindMergePatchAndCheck(w, r, old, &banner); !ok {
+		return
+	}
+
+	err = app.Db.BannerRepo.Patch(r.Context(), old, &banner)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
 // DeleteBannerHandler
 func (app *Application) DeleteBannerHandler(w http.ResponseWriter, r *http.Request) {
 	id, a := app.readIntParam(r, "id")
@@ -107,6 +138,7 @@ func (app *Application) ListBannerHandler(w http.ResponseWriter, r *http.Request
 		app.FAIL(w, r, a)
 		return
 	}
+	app.withImageSrcset(r.Context(), list...)
 
 	app.SUCC(w, r, list)
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/lightsaid/ebook/internal/dbrepo"
 	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
 )
 
 // PostBookHandler godoc
@@ -53,10 +55,17 @@ func (app *Application) GetBookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hideSourceUrl(book)
+	app.withCoverSrcset(r.Context(), book)
+	if book.Series != nil && book.Series.Next != nil {
+		app.withCoverSrcset(r.Context(), book.Series.Next.Book)
+	}
+	app.setETag(w, book.Version)
 	app.SUCC(w, r, book)
 }
 
-// PutBookHandler
+// PutBookHandler 更新图书，需要提供读取时的版本号(If-Match请求头或body的version)，
+// 版本号不一致说明已被他人修改，返回409和最新的图书数据
 func (app *Application) PutBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, a := app.readIntParam(r, "id")
 	if a != nil {
@@ -71,18 +80,82 @@ func (app *Application) PutBookHandler(w http.ResponseWriter, r *http.Request) {
 
 	book.ID = id
 
-	// TODO: 获取/赋值才更新
+	// If-Match 优先于body的version
+	if version, ok := app.readIfMatch(r); ok {
+		book.Version = version
+	}
+	if book.Version <= 0 {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请提供版本号(If-Match 或 version)"))
+		return
+	}
 
 	err := app.Db.BookRepo.UpdateTx(r.Context(), &book)
+	if errors.Is(err, dbrepo.ErrVersionConflict) {
+		app.bookVersionConflict(w, r, id)
+		return
+	}
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.setETag(w, book.Version)
+	app.SUCC(w, r, id)
+}
+
+// PatchBookHandler 部分更新图书，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段；
+// 与PUT一样需要提供读取时的版本号(If-Match请求头或body的version)，版本不一致返回409和最新的图书数据
+func (app *Application) PatchBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	// 版本号不从当前数据合并，必须由 If-Match 或 patch 中的 version 提供
+	base := *old
+	base.Version = 0
+
+	var book models.Book
+	if ok := app.ShouldBindMergePatchAndCheck(w, r, &base, &book); !ok {
+		return
+	}
+
+	book.ID = id
+
+	// If-Match 优先于body的version
+	if version, ok := app.readIfMatch(r); ok {
+		book.Version = version
+	}
+	if book.Version <= 0 {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请提供版本号(If-Match 或 version)"))
+		return
+	}
+
+	err = app.Db.BookRepo.Patch(r.Context(), old, &book)
+	if errors.Is(err, dbrepo.ErrVersionConflict) {
+		app.bookVersionConflict(w, r, id)
+		return
+	}
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
 		return
 	}
 
+	app.setETag(w, book.Version)
+	app.SUCC(w, r, id)
 }
 
-// DeleteBookHandler
+// DeleteBookHandler 删除图书，如果提供了If-Match，版本号不一致返回409和最新的图书数据
 func (app *Application) DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, a := app.readIntParam(r, "id")
 	if a != nil {
@@ -90,6 +163,21 @@ func (app *Application) DeleteBookHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if version, ok := app.readIfMatch(r); ok {
+		book, err := app.Db.BookRepo.Get(r.Context(), id)
+		if err != nil {
+			a = dbrepo.ConvertToApiError(err)
+			app.FAIL(w, r, a)
+			return
+		}
+		if book.Version != version {
+			hideSourceUrl(book)
+			app.setETag(w, book.Version)
+			app.FAILWithData(w, r, errs.ErrVersionConflict, book)
+			return
+		}
+	}
+
 	err := app.Db.BookRepo.Delete(r.Context(), id)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
@@ -110,5 +198,31 @@ func (app *Application) ListBookHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if list, ok := dataVo.List.([]*models.Book); ok {
+		hideSourceUrl(list...)
+		app.withCoverSrcset(r.Context(), list...)
+	}
+
 	app.SUCC(w, r, dataVo)
 }
+
+// bookVersionConflict 版本冲突时返回409和最新的图书数据
+func (app *Application) bookVersionConflict(w http.ResponseWriter, r *http.Request, id uint64) {
+	current, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	hideSourceUrl(current)
+	app.setETag(w, current.Version)
+	app.FAILWithData(w, r, errs.ErrVersionConflict, current)
+}
+
+// hideSourceUrl 公开接口不返回电子书文件地址，下载需通过签名的下载地址
+func hideSourceUrl(books ...*models.Book) {
+	for _, b := range books {
+		b.SourceUrl = ""
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/lightsaid/ebook/internal/dbrepo"
 	"github.com/lightsaid/ebook/internal/models"
@@ -41,7 +42,7 @@ func (app *Application) GetCategoryHandler(w http.ResponseWriter, r *http.Reques
 
 	category, err2 := app.Db.CategoryRepo.Get(r.Context(), id)
 	if err2 != nil {
-		a := dbrepo.ConvertToApiError(err)
+		a := dbrepo.ConvertToApiError(err2)
 		app.FAIL(w, r, a)
 		return
 	}
@@ -73,9 +74,8 @@ func (app *Application) PutCategoryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 赋值
+	// 赋值，层级和排序通过移动接口调整
 	category.CategoryName = c.CategoryName
-	category.Sort = c.Sort
 	if c.Icon != "" {
 		category.Icon = c.Icon
 	}
@@ -92,6 +92,36 @@ func (app *Application) PutCategoryHandler(w http.ResponseWriter, r *http.Reques
 
 }
 
+// PatchCategoryHandler 部分更新分类，请求体为 JSON Merge Patch(RFC 7396)
+func (app *Application) PatchCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := app.Db.CategoryRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var category models.Category
+	if ok := app.ShouldBindMergePatchAndCheck(w, r, old, &category); !ok {
+		return
+	}
+
+	err = app.Db.CategoryRepo.Patch(r.Context(), old, &category)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
 // DeleteCategoryHandler 删除一个分类
 func (app *Application) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	id, aerr := app.readIntParam(r, "id")
@@ -129,3 +159,46 @@ func (app *Application) ListCategoryHandler(w http.ResponseWriter, r *http.Reque
 
 	app.SUCC(w, r, list)
 }
+
+// TreeCategoryHandler 获取分类树，同级分类按 sort 排序
+func (app *Application) TreeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	tree, err := app.Db.CategoryRepo.Tree(r.Context())
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, tree)
+}
+
+// ListCategoryBooksHandler 分页查询分类的图书，descendants=true 时包括子孙分类的图书
+func (app *Application) ListCategoryBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if _, err := app.Db.CategoryRepo.Get(r.Context(), id); err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	descendants, _ := strconv.ParseBool(r.URL.Query().Get("descendants"))
+
+	dataVo, err := app.Db.BookRepo.ListByCategory(r.Context(), id, descendants, app.readPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if list, ok := dataVo.List.([]*models.Book); ok {
+		hideSourceUrl(list...)
+		app.withCoverSrcset(r.Context(), list...)
+	}
+
+	app.SUCC(w, r, dataVo)
+}
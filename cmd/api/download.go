@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/watermark"
+	"github.com/lightsaid/ebook/pkg/errs"
+	"github.com/lightsaid/gotk"
+)
+
+// DownloadURL 签名的下载地址
+type DownloadURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// signedDownloadURL 签发下载地址
+func (app *Application) signedDownloadURL(bookID, userID uint64) DownloadURL {
+	q, expiresAt := app.signer.Sign(bookID, userID, time.Now())
+	return DownloadURL{
+		URL:       fmt.Sprintf("/api/v1/download/%d?%s", bookID, q.Encode()),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// deliveryApiError 转换下载相关的错误
+func deliveryApiError(err error) *gotk.ApiError {
+	switch {
+	case errors.Is(err, delivery.ErrNoEbook):
+		return errs.ErrNotFound.WithMessage(err.Error())
+	case errors.Is(err, delivery.ErrNotEntitled):
+		return errs.ErrForbidden.WithMessage(err.Error())
+	case errors.Is(err, delivery.ErrInvalidSignature), errors.Is(err, delivery.ErrURLExpired):
+		return errs.ErrForbidden.WithMessage(err.Error())
+	}
+	return dbrepo.ConvertToApiError(err)
+}
+
+// PostDownloadURLHandler godoc
+//
+//	@Summary		获取下载地址
+//	@Description	校验购买后签发短时有效的下载地址，需要 Basic 认证；免费图书登录即可下载
+//	@Tags			book
+//	@Produce		json
+//	@Param			id	path		int	true	"图书id"
+//	@Success		200	{object}	DownloadURL
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Security		BasicAuth
+//	@Router			/v1/book/{id}/download [post]
+func (app *Application) PostDownloadURLHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	book, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	if err := delivery.Entitled(r.Context(), app.Db.OrderRepo, user.ID, book); err != nil {
+		app.FAIL(w, r, deliveryApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, app.signedDownloadURL(book.ID, user.ID))
+}
+
+// DownloadHandler godoc
+//
+//	@Summary		下载电子书
+//	@Description	使用签名的下载地址下载电子书，支持Range分段下载，每次请求记录下载日志；付费的EPUB、PDF添加买家水印
+//	@Tags			book
+//	@Produce		octet-stream
+//	@Param			id		path	int		true	"图书id"
+//	@Param			uid		query	int		true	"用户id"
+//	@Param			exp		query	int		true	"过期时间戳"
+//	@Param			sig		query	string	true	"签名"
+//	@Param			Range	header	string	false	"分段下载，如 bytes=0-1023"
+//	@Success		200		{file}	file
+//	@Success		206		{file}	file
+//	@Failure		403		{object}	error
+//	@Router			/v1/download/{id} [get]
+func (app *Application) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	userID, err := app.signer.Verify(id, r.URL.Query(), time.Now())
+	if err != nil {
+		app.FAIL(w, r, deliveryApiError(err))
+		return
+	}
+
+	book, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	// 签发后可能已退款或免费图书已下架，再次校验
+	if err := delivery.Entitled(r.Context(), app.Db.OrderRepo, userID, book); err != nil {
+		app.FAIL(w, r, deliveryApiError(err))
+		return
+	}
+
+	result, err := app.serveEbook(w, r, book, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "download", "bookID", id, "userID", userID, "error", err)
+		// 还没有写入响应时返回错误
+		if result.Status == 0 {
+			result.Status = http.StatusInternalServerError
+			app.FAIL(w, r, errs.ErrServerError.WithMessage("文件下载失败，请稍后重试"))
+		}
+	}
+
+	// 请求可能已被客户端取消，日志仍需写入
+	log := &models.DownloadLog{
+		UserID:      userID,
+		BookID:      id,
+		IP:          r.RemoteAddr,
+		UserAgent:   truncate(r.UserAgent(), 255),
+		RangeHeader: truncate(r.Header.Get("Range"), 128),
+		Status:      result.Status,
+		Bytes:       result.Bytes,
+	}
+	if _, err := app.Db.DownloadLogRepo.Create(context.WithoutCancel(r.Context()), log); err != nil {
+		slog.ErrorContext(r.Context(), "create download log", "bookID", id, "userID", userID, "error", err)
+	}
+}
+
+// serveEbook 输出电子书：付费图书添加订单号和买家邮箱哈希的水印，免费图书输出原文件
+func (app *Application) serveEbook(w http.ResponseWriter, r *http.Request, book *models.Book, userID uint64) (delivery.Result, error) {
+	if book.Price == 0 {
+		return delivery.Serve(w, r, book.SourceUrl, app.ebooks)
+	}
+
+	user, err := app.Db.UserRepo.Get(r.Context(), userID)
+	if err != nil {
+		return delivery.Result{}, err
+	}
+	orderNo, err := app.Db.OrderRepo.PaidOrderNo(r.Context(), userID, book.ID)
+	if err != nil {
+		return delivery.Result{}, err
+	}
+
+	mark := watermark.NewMark(orderNo, user.Email)
+	return delivery.ServeWatermarked(w, r, book.SourceUrl, app.ebooks, app.marks, book.ID, mark)
+}
+
+// truncate 截断字符串到最多n个字节，不截断半个字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && (s[n]&0xC0) == 0x80 {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// maxFavoriteLookup 批量查询是否已收藏的最大图书数量
+const maxFavoriteLookup = 100
+
+// PutFavoriteHandler 收藏图书，已收藏则保留首次收藏时的价格，只刷新是否可购买
+func (app *Application) PutFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	favoriteID, err := app.Db.FavoriteRepo.Add(r.Context(), user.ID, id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, favoriteID)
+}
+
+// DeleteFavoriteHandler 取消收藏，没有收藏返回404
+func (app *Application) DeleteFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Db.FavoriteRepo.Remove(r.Context(), user.ID, id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// ListFavoriteHandler 分页获取收藏的图书，默认按收藏时间倒序；
+// onSale 表示降价，backInStock 表示收藏时不可购买现在可购买，可用 where=on_sale:eq:1 筛选
+func (app *Application) ListFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	filter := app.readPageQuery(r)
+	if len(filter.SortFields) == 0 {
+		filter.SortFields = []string{"-created_at", "-id"}
+	}
+
+	dataVo, err := app.Db.FavoriteRepo.List(r.Context(), user.ID, filter)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	if list, ok := dataVo.List.([]*models.Favorite); ok {
+		books := make([]*models.Book, 0, len(list))
+		for _, f := range list {
+			books = append(books, f.Book)
+		}
+		app.withCoverSrcset(r.Context(), books...)
+	}
+
+	app.SUCC(w, r, dataVo)
+}
+
+// CheckFavoriteHandler 批量查询图书是否已收藏，bookIds=1,2,3，返回其中已收藏的图书id
+func (app *Application) CheckFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var bookIDs []uint64
+	for _, raw := range strings.Split(r.URL.Query().Get("bookIds"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			app.FAIL(w, r, errs.ErrBadRequest.WithMessage("bookIds 必须是以逗号分隔的图书id"))
+			return
+		}
+		bookIDs = append(bookIDs, id)
+	}
+	if len(bookIDs) > maxFavoriteLookup {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(fmt.Sprintf("bookIds 最多%d个", maxFavoriteLookup)))
+		return
+	}
+
+	list, err := app.Db.FavoriteRepo.FavoritedBookIDs(r.Context(), user.ID, bookIDs)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, list)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -9,7 +11,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
 	"github.com/lightsaid/ebook/pkg/errs"
+	"github.com/lightsaid/ebook/pkg/mergepatch"
 	"github.com/lightsaid/gotk"
 )
 
@@ -32,6 +36,21 @@ func (app *Application) readPageQuery(r *http.Request) dbrepo.Filters {
 	filter.PageNum, _ = strconv.Atoi(pageNumText)
 	filter.PageSize, _ = strconv.Atoi(pageSizeText)
 
+	// 查询条件，格式为 field:op:value，如：where=status:eq:1&where=price:range:100,500
+	// 字段和操作符在构建sql时校验，不合法的返回入参错误
+	for _, raw := range r.URL.Query()["where"] {
+		if raw != "" {
+			filter.Conditions = append(filter.Conditions, dbrepo.ParseCondition(raw))
+		}
+	}
+
+	// 游标分页，传了cursor参数即使用游标分页代替页码分页，首页传空值：cursor=
+	// 之后使用返回的 metadata.nextCursor/prevCursor 翻页
+	if cursor, ok := r.URL.Query()["cursor"]; ok {
+		filter.UseCursor = true
+		filter.Cursor = cursor[0]
+	}
+
 	// 需要支持两种传参数方式：
 	// 1: sortFields=field1&sortFields=field2&sortFields=field3
 	// 2: sortFields=field1,field2,field3
@@ -67,6 +86,28 @@ func (app *Application) readPageQuery(r *http.Request) dbrepo.Filters {
 	return filter
 }
 
+// setETag 设置ETag响应头，值为数据的版本号，配合 If-Match 实现乐观锁
+func (app *Application) setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// readIfMatch 读取 If-Match 请求头中的版本号，支持 "1"、W/"1" 格式，没有或格式不对返回false
+func (app *Application) readIfMatch(r *http.Request) (int, bool) {
+	etag := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	if etag == "" {
+		return 0, false
+	}
+	text, err := strconv.Unquote(etag)
+	if err != nil {
+		text = etag
+	}
+	version, err := strconv.Atoi(text)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
 func (app *Application) ShouldBindJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
 	err := gotk.ReadJSON(w, r, dst)
 	if err != nil {
@@ -99,6 +140,31 @@ func (app *Application) ShouldBindJSONAndCheck(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// ShouldBindMergePatchAndCheck 读取 JSON Merge Patch(RFC 7396) 请求体，合并到 original 后绑定到 dst 上并校验，
+// original 一般是从数据库查出的数据，dst 是与之同类型的零值指针，只校验合并后的结果
+func (app *Application) ShouldBindMergePatchAndCheck(w http.ResponseWriter, r *http.Request, original any, dst gotk.Verifiyer) bool {
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return false
+	}
+
+	if err := mergepatch.ApplyTo(original, patch, dst); err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return false
+	}
+
+	slog.InfoContext(r.Context(), "arg", slog.Any("arg", dst))
+
+	// 校验
+	if v, ok := gotk.DoVerifiy(dst); !ok {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(v.GetOne()))
+		return false
+	}
+
+	return true
+}
+
 // FAIL 请求失败
 func (app *Application) FAIL(w http.ResponseWriter, r *http.Request, a *gotk.ApiError) {
 	if a == nil {
@@ -108,6 +174,15 @@ func (app *Application) FAIL(w http.ResponseWriter, r *http.Request, a *gotk.Api
 	app.write(w, r, a, nil)
 }
 
+// FAILWithData 请求失败，同时返回数据，比如版本冲突时返回最新的数据
+func (app *Application) FAILWithData(w http.ResponseWriter, r *http.Request, a *gotk.ApiError, data any) {
+	if a == nil {
+		slog.InfoContext(r.Context(), "由于(a *gotk.ApiError)没值,设为ErrServerError")
+		a = errs.ErrServerError
+	}
+	app.write(w, r, a, data)
+}
+
 // SUCC 请求成功
 func (app *Application) SUCC(w http.ResponseWriter, r *http.Request, data any) {
 	app.write(w, r, errs.ErrOK, data)
@@ -165,3 +240,37 @@ func (app *Application) write(
 		)
 	}
 }
+
+// withCoverSrcset 填充图书封面的各尺寸地址，查询失败只记录日志，前台使用原图
+func (app *Application) withCoverSrcset(ctx context.Context, books ...*models.Book) {
+	urls := make([]string, 0, len(books))
+	for _, b := range books {
+		if b.CoverUrl != "" {
+			urls = append(urls, b.CoverUrl)
+		}
+	}
+	srcsets, err := app.Db.ImageVariantRepo.Srcsets(ctx, urls...)
+	if err != nil {
+		slog.WarnContext(ctx, "query cover srcset", "error", err)
+		return
+	}
+	for _, b := range books {
+		b.CoverSrcset = srcsets[b.CoverUrl]
+	}
+}
+
+// withImageSrcset 填充轮播图的各尺寸地址
+func (app *Application) withImageSrcset(ctx context.Context, banners ...*models.Banner) {
+	urls := make([]string, 0, len(banners))
+	for _, b := range banners {
+		urls = append(urls, b.ImageUrl)
+	}
+	srcsets, err := app.Db.ImageVariantRepo.Srcsets(ctx, urls...)
+	if err != nil {
+		slog.WarnContext(ctx, "query banner srcset", "error", err)
+		return
+	}
+	for _, b := range banners {
+		b.ImageSrcset = srcsets[b.ImageUrl]
+	}
+}
@@ -8,15 +8,23 @@ import (
 
 	"github.com/lightsaid/ebook/internal/config"
 	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/fileupload"
 	"github.com/lightsaid/ebook/internal/types"
 	"github.com/lightsaid/ebook/pkg/logger"
 	"github.com/lightsaid/gotk"
 )
 
 type Application struct {
-	Db     dbrepo.Repository
-	config struct {
+	Db       dbrepo.Repository
+	signer   *delivery.Signer
+	ebooks   fileupload.FileUploader // 电子书文件，只读取
+	marks    delivery.Cache
+	previews delivery.Cache
+	config   struct {
+		config.APIConfig
 		config.DbConfig
+		config.StorageConfig
 	}
 }
 
@@ -43,6 +51,11 @@ func main() {
 
 	app := Application{}
 
+	// 解析配置数据到app.config
+	if err := config.Load(&app.config, envFiles...); err != nil {
+		log.Fatalln(err)
+	}
+
 	instance := logger.NewLogger(os.Stdout, "DEBUG", gotk.TextType)
 	slog.SetDefault(instance)
 
@@ -53,6 +66,48 @@ func main() {
 
 	app.Db = dbrepo.NewRepository(conn)
 
+	// 下载地址签名，没有配置密钥时随机生成，重启后之前签发的下载地址失效
+	if app.config.DownloadSignKey == "" {
+		slog.Warn("未配置 DOWNLOAD_SIGN_KEY，使用随机密钥")
+	}
+	app.signer, err = delivery.NewSigner(app.config.DownloadSignKey, app.config.DownloadURLExpires)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// 电子书文件，由后台服务上传，需要与后台服务使用相同的存储
+	downloadDir := app.config.DownloadDir
+	if downloadDir == "" {
+		downloadDir = "./ebooks"
+	}
+	app.ebooks, err = fileupload.New(app.config.StorageConfig, downloadDir, "ebooks", "", nil, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// 付费电子书下载时添加买家水印，首次下载生成后缓存，与电子书使用相同的存储，
+	// 对象存储时保存在存储桶的 watermark 目录
+	marksDir := app.config.WatermarkCacheDir
+	if marksDir == "" {
+		marksDir = "./watermarks"
+	}
+	marks, err := fileupload.New(app.config.StorageConfig, marksDir, "", "", nil, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	app.marks = delivery.NewStoreCache(marks)
+
+	// 试读文件，首次访问或后台重新生成时写入，对象存储时保存在存储桶的 preview 目录
+	previewDir := app.config.PreviewDir
+	if previewDir == "" {
+		previewDir = "./previews"
+	}
+	previews, err := fileupload.New(app.config.StorageConfig, previewDir, "", "", nil, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	app.previews = delivery.NewStoreCache(previews)
+
 	if err := app.serve(instance); err != nil {
 		log.Fatalln(err)
 	}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/opds"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// OPDS 目录，供阅读器浏览和下载电子书，同一套路由分别以 1.2(Atom) 和 2.0(JSON) 输出：
+//
+//	/api/opds/1.2/...
+//	/api/opds/2.0/...
+//
+// 获取链接使用 HTTP Basic 认证(邮箱、密码)，阅读器收到401后会提示输入账号，
+// 认证并校验购买后跳转到签名的下载地址
+
+const (
+	opdsPrefix   = "/api/opds/"
+	opdsPageSize = 20
+	opdsCurrency = "CNY"
+)
+
+type opdsCtxKey struct{}
+
+// opdsRoutes 注册一个版本的 OPDS 路由
+func (app *Application) opdsRoutes(v opds.Version) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), opdsCtxKey{}, v)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+
+		r.Get("/", app.OPDSRootHandler)
+		r.Get("/opensearch.xml", app.OPDSOpenSearchHandler)
+		r.Get("/search", app.OPDSSearchHandler)
+		r.Get("/books", app.OPDSBooksHandler)
+		r.Get("/books/{id:[0-9]+}/download", app.OPDSDownloadHandler)
+		r.Get("/categories", app.OPDSCategoriesHandler)
+		r.Get("/categories/{id:[0-9]+}", app.OPDSCategoryBooksHandler)
+		r.Get("/authors", app.OPDSAuthorsHandler)
+		r.Get("/authors/{id:[0-9]+}", app.OPDSAuthorBooksHandler)
+		r.Get("/publishers", app.OPDSPublishersHandler)
+		r.Get("/publishers/{id:[0-9]+}", app.OPDSPublisherBooksHandler)
+	}
+}
+
+// opdsVersion 当前请求的 OPDS 版本
+func opdsVersion(r *http.Request) opds.Version {
+	if v, ok := r.Context().Value(opdsCtxKey{}).(opds.Version); ok {
+		return v
+	}
+	return opds.V1
+}
+
+// opdsHref 当前版本下的地址
+func opdsHref(r *http.Request, path string) string {
+	return opdsPrefix + string(opdsVersion(r)) + path
+}
+
+// newOPDSFeed 创建包含 self、start、search 链接的目录
+func newOPDSFeed(r *http.Request, id, title string) *opds.Feed {
+	return &opds.Feed{
+		ID:    "urn:ebook:opds:" + id,
+		Title: title,
+		Links: []opds.Link{
+			{Rel: opds.RelSelf, Href: r.URL.RequestURI()},
+			{Rel: opds.RelStart, Href: opdsHref(r, "/"), Type: opds.TypeNavigation},
+			{Rel: opds.RelSearch, Href: opdsHref(r, "/opensearch.xml"), Type: opds.TypeOpenSearch},
+		},
+	}
+}
+
+// writeOPDS 按请求的版本写出目录
+func (app *Application) writeOPDS(w http.ResponseWriter, r *http.Request, feed *opds.Feed) {
+	v := opdsVersion(r)
+	if v == opds.V2 {
+		// 2.0 的搜索链接直接使用模板地址
+		for i, l := range feed.Links {
+			if l.Rel == opds.RelSearch {
+				feed.Links[i].Href = opdsHref(r, "/search{?query}")
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", feed.ContentType(v))
+	if err := feed.Write(w, v); err != nil {
+		slog.ErrorContext(r.Context(), "write opds feed", "url", r.RequestURI, "error", err)
+	}
+}
+
+// opdsFilters 只查询上架的电子书，按页码分页
+func opdsFilters(r *http.Request) dbrepo.Filters {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	return dbrepo.Filters{
+		PageNum:    max(page, 1),
+		PageSize:   opdsPageSize,
+		SortFields: []string{"-created_at", "-id"},
+		Conditions: []dbrepo.Condition{
+			dbrepo.Eq("status", 1),
+			dbrepo.In("type", 1, 3),
+		},
+	}
+}
+
+// OPDSRootHandler godoc
+//
+//	@Summary		OPDS 根目录
+//	@Description	导航目录：最新图书、分类、作者、出版社，version 为 1.2(Atom) 或 2.0(JSON)
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Success		200
+//	@Router			/opds/{version}/ [get]
+func (app *Application) OPDSRootHandler(w http.ResponseWriter, r *http.Request) {
+	feed := newOPDSFeed(r, "root", "EBook 书库")
+	feed.Navigation = []opds.Navigation{
+		{ID: "urn:ebook:opds:new", Title: "最新上架", Href: opdsHref(r, "/books"), Kind: opds.TypeAcquisition, Content: "按上架时间浏览所有电子书"},
+		{ID: "urn:ebook:opds:categories", Title: "分类", Href: opdsHref(r, "/categories"), Content: "按分类浏览"},
+		{ID: "urn:ebook:opds:authors", Title: "作者", Href: opdsHref(r, "/authors"), Content: "按作者浏览"},
+		{ID: "urn:ebook:opds:publishers", Title: "出版社", Href: opdsHref(r, "/publishers"), Content: "按出版社浏览"},
+	}
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelNew, Href: opdsHref(r, "/books"), Type: opds.TypeAcquisition, Title: "最新上架"})
+
+	app.writeOPDS(w, r, feed)
+}
+
+// OPDSOpenSearchHandler godoc
+//
+//	@Summary		OPDS 搜索描述
+//	@Description	OpenSearch 描述文档
+//	@Tags			opds
+//	@Produce		xml
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Success		200
+//	@Router			/opds/{version}/opensearch.xml [get]
+func (app *Application) OPDSOpenSearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", opds.TypeOpenSearch)
+	err := opds.OpenSearch(w, "EBook", "按书名搜索电子书", opdsHref(r, "/search?q={searchTerms}"), opds.TypeAcquisition)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "write opensearch", "error", err)
+	}
+}
+
+// OPDSSearchHandler godoc
+//
+//	@Summary		OPDS 搜索
+//	@Description	按书名搜索电子书，1.2 使用参数 q，2.0 使用参数 query
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			q		query	string	false	"关键字"
+//	@Param			query	query	string	false	"关键字"
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/search [get]
+func (app *Application) OPDSSearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		q = strings.TrimSpace(r.URL.Query().Get("query"))
+	}
+	if q == "" {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请输入搜索关键字"))
+		return
+	}
+
+	f := opdsFilters(r)
+	f.Conditions = append(f.Conditions, dbrepo.Like("title", q))
+
+	feed := newOPDSFeed(r, "search:"+q, "搜索: "+q)
+	app.opdsBooks(w, r, feed, "/search?q="+url.QueryEscape(q), func(ctx context.Context) (*dbrepo.PageQueryVo, error) {
+		return app.Db.BookRepo.ListWithCategory(ctx, f)
+	})
+}
+
+// OPDSBooksHandler godoc
+//
+//	@Summary		OPDS 最新图书
+//	@Description	按上架时间倒序的电子书获取目录
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/books [get]
+func (app *Application) OPDSBooksHandler(w http.ResponseWriter, r *http.Request) {
+	f := opdsFilters(r)
+	feed := newOPDSFeed(r, "books", "最新上架")
+	app.opdsBooks(w, r, feed, "/books", func(ctx context.Context) (*dbrepo.PageQueryVo, error) {
+		return app.Db.BookRepo.ListWithCategory(ctx, f)
+	})
+}
+
+// OPDSCategoriesHandler godoc
+//
+//	@Summary		OPDS 分类
+//	@Description	分类导航目录
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Success		200
+//	@Router			/opds/{version}/categories [get]
+func (app *Application) OPDSCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.Db.CategoryRepo.List(r.Context())
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	feed := newOPDSFeed(r, "categories", "分类")
+	for _, c := range list {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			ID:      fmt.Sprintf("urn:ebook:opds:category:%d", c.ID),
+			Title:   c.CategoryName,
+			Href:    opdsHref(r, fmt.Sprintf("/categories/%d", c.ID)),
+			Updated: c.UpdatedAt.Time,
+			Kind:    opds.TypeAcquisition,
+		})
+	}
+
+	app.writeOPDS(w, r, feed)
+}
+
+// OPDSCategoryBooksHandler godoc
+//
+//	@Summary		OPDS 分类下的图书
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			id		path	int		true	"分类id"
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/categories/{id} [get]
+func (app *Application) OPDSCategoryBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	category, err := app.Db.CategoryRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	f := opdsFilters(r)
+	feed := newOPDSFeed(r, fmt.Sprintf("category:%d", id), category.CategoryName)
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelUp, Href: opdsHref(r, "/categories"), Type: opds.TypeNavigation})
+	app.opdsBooks(w, r, feed, fmt.Sprintf("/categories/%d", id), func(ctx context.Context) (*dbrepo.PageQueryVo, error) {
+		return app.Db.BookRepo.ListByCategory(ctx, id, true, f)
+	})
+}
+
+// OPDSAuthorsHandler godoc
+//
+//	@Summary		OPDS 作者
+//	@Description	作者导航目录，按页码分页
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/authors [get]
+func (app *Application) OPDSAuthorsHandler(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	f := dbrepo.Filters{PageNum: max(page, 1), PageSize: opdsPageSize, SortFields: []string{"id"}}
+
+	vo, err := app.Db.AuthorRepo.List(r.Context(), f)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	feed := newOPDSFeed(r, "authors", "作者")
+	list, _ := vo.List.([]*models.Author)
+	for _, author := range list {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			ID:      fmt.Sprintf("urn:ebook:opds:author:%d", author.ID),
+			Title:   author.AuthorName,
+			Href:    opdsHref(r, fmt.Sprintf("/authors/%d", author.ID)),
+			Updated: author.UpdatedAt.Time,
+			Kind:    opds.TypeAcquisition,
+		})
+	}
+	opdsPaginate(r, feed, "/authors", vo.Metadata, opds.TypeNavigation)
+
+	app.writeOPDS(w, r, feed)
+}
+
+// OPDSAuthorBooksHandler godoc
+//
+//	@Summary		OPDS 作者的图书
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			id		path	int		true	"作者id"
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/authors/{id} [get]
+func (app *Application) OPDSAuthorBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	author, err := app.Db.AuthorRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	f := opdsFilters(r)
+	feed := newOPDSFeed(r, fmt.Sprintf("author:%d", id), author.AuthorName)
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelUp, Href: opdsHref(r, "/authors"), Type: opds.TypeNavigation})
+	app.opdsBooks(w, r, feed, fmt.Sprintf("/authors/%d", id), func(ctx context.Context) (*dbrepo.PageQueryVo, error) {
+		return app.Db.BookRepo.ListByAuthor(ctx, id, f)
+	})
+}
+
+// OPDSPublishersHandler godoc
+//
+//	@Summary		OPDS 出版社
+//	@Description	出版社导航目录
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Success		200
+//	@Router			/opds/{version}/publishers [get]
+func (app *Application) OPDSPublishersHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.Db.PublisherRepo.List(r.Context())
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	feed := newOPDSFeed(r, "publishers", "出版社")
+	for _, p := range list {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			ID:      fmt.Sprintf("urn:ebook:opds:publisher:%d", p.ID),
+			Title:   p.PublisherName,
+			Href:    opdsHref(r, fmt.Sprintf("/publishers/%d", p.ID)),
+			Updated: p.UpdatedAt.Time,
+			Kind:    opds.TypeAcquisition,
+		})
+	}
+
+	app.writeOPDS(w, r, feed)
+}
+
+// OPDSPublisherBooksHandler godoc
+//
+//	@Summary		OPDS 出版社的图书
+//	@Tags			opds
+//	@Produce		xml
+//	@Produce		json
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			id		path	int		true	"出版社id"
+//	@Param			page	query	int		false	"页码"
+//	@Success		200
+//	@Router			/opds/{version}/publishers/{id} [get]
+func (app *Application) OPDSPublisherBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	publisher, err := app.Db.PublisherRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	f := opdsFilters(r)
+	feed := newOPDSFeed(r, fmt.Sprintf("publisher:%d", id), publisher.PublisherName)
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelUp, Href: opdsHref(r, "/publishers"), Type: opds.TypeNavigation})
+	app.opdsBooks(w, r, feed, fmt.Sprintf("/publishers/%d", id), func(ctx context.Context) (*dbrepo.PageQueryVo, error) {
+		return app.Db.BookRepo.ListByPublisher(ctx, id, f)
+	})
+}
+
+// OPDSDownloadHandler godoc
+//
+//	@Summary		OPDS 下载电子书
+//	@Description	免费图书直接下载，付费图书需要 Basic 认证并且已支付购买，跳转到签名的下载地址
+//	@Tags			opds
+//	@Param			version	path	string	true	"OPDS版本"	Enums(1.2, 2.0)
+//	@Param			id		path	int		true	"图书id"
+//	@Success		302
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Router			/opds/{version}/books/{id}/download [get]
+func (app *Application) OPDSDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	book, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	// 免费图书不需要登录
+	var userID uint64
+	if book.Price > 0 {
+		user, ok := app.requireBasicAuth(w, r)
+		if !ok {
+			return
+		}
+		userID = user.ID
+	}
+
+	if err := delivery.Entitled(r.Context(), app.Db.OrderRepo, userID, book); err != nil {
+		app.FAIL(w, r, deliveryApiError(err))
+		return
+	}
+
+	http.Redirect(w, r, app.signedDownloadURL(book.ID, userID).URL, http.StatusFound)
+}
+
+// opdsBooks 查询图书并写出获取目录，提供了 Basic 认证时标记已购买的图书
+func (app *Application) opdsBooks(
+	w http.ResponseWriter,
+	r *http.Request,
+	feed *opds.Feed,
+	path string,
+	list func(ctx context.Context) (*dbrepo.PageQueryVo, error),
+) {
+	user, err := app.basicAuthUser(r)
+	if errors.Is(err, errs.ErrUnauthorized) {
+		app.unauthorized(w, r)
+		return
+	}
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	vo, err := list(r.Context())
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+	books, _ := vo.List.([]*models.Book)
+
+	// 已购买的图书
+	owned := make(map[uint64]bool)
+	if user != nil && len(books) > 0 {
+		ids := make([]uint64, 0, len(books))
+		for _, b := range books {
+			ids = append(ids, b.ID)
+		}
+		paid, err := app.Db.OrderRepo.PaidBookIDs(r.Context(), user.ID, ids)
+		if err != nil {
+			app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+			return
+		}
+		for _, id := range paid {
+			owned[id] = true
+		}
+	}
+
+	for _, b := range books {
+		feed.Publications = append(feed.Publications, app.opdsPublication(r, b, owned[b.ID]))
+	}
+	opdsPaginate(r, feed, path, vo.Metadata, opds.TypeAcquisition)
+
+	app.writeOPDS(w, r, feed)
+}
+
+// opdsPublication 图书转为出版物条目：免费或已购买的提供获取链接，否则提供带价格的书城购买页链接和获取链接
+func (app *Application) opdsPublication(r *http.Request, b *models.Book, owned bool) opds.Publication {
+	pub := opds.Publication{
+		ID:          fmt.Sprintf("urn:ebook:book:%d", b.ID),
+		Title:       b.Title,
+		Subtitle:    b.Subtitle,
+		Description: b.Description,
+		Language:    "zh",
+		Issued:      b.Pubdate.Time,
+		Updated:     b.UpdatedAt.Time,
+		Image:       b.CoverUrl,
+	}
+	if b.ISBN != "" {
+		pub.ID = "urn:isbn:" + b.ISBN
+	}
+	if b.Author != nil {
+		pub.Authors = []string{b.Author.AuthorName}
+	}
+	if b.Publisher != nil {
+		pub.Publisher = b.Publisher.PublisherName
+	}
+	for _, c := range b.Categories {
+		pub.Categories = append(pub.Categories, c.CategoryName)
+	}
+
+	download := opds.Link{
+		Rel:  opds.RelAcquisition,
+		Href: opdsHref(r, fmt.Sprintf("/books/%d/download", b.ID)),
+		Type: opds.FileType(b.SourceUrl),
+	}
+	if b.Price == 0 || owned {
+		pub.Links = append(pub.Links, download)
+		return pub
+	}
+
+	// 阅读器打开购买链接时需要HTML页面，没有配置书城购买页时不提供
+	if store := app.config.StoreBookURL; store != "" {
+		pub.Links = append(pub.Links, opds.Link{
+			Rel:   opds.RelBuy,
+			Href:  strings.ReplaceAll(store, "{id}", strconv.FormatUint(b.ID, 10)),
+			Type:  opds.TypeHTML,
+			Price: &opds.Price{Value: float64(b.Price) / 100, Currency: opdsCurrency},
+		})
+	}
+	pub.Links = append(pub.Links, download)
+	return pub
+}
+
+// opdsPaginate 添加 first、previous、next、last 分页链接
+func opdsPaginate(r *http.Request, feed *opds.Feed, path string, m dbrepo.Metadata, kind string) {
+	feed.TotalResults = m.TotalCount
+	feed.ItemsPerPage = m.PageSize
+	feed.CurrentPage = m.PageNum
+
+	if m.LastPage <= 1 {
+		return
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	page := func(n int) string {
+		return opdsHref(r, fmt.Sprintf("%s%spage=%d", path, sep, n))
+	}
+
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelFirst, Href: page(1), Type: kind})
+	if m.PageNum > 1 {
+		feed.Links = append(feed.Links, opds.Link{Rel: opds.RelPrev, Href: page(m.PageNum - 1), Type: kind})
+	}
+	if m.PageNum < m.LastPage {
+		feed.Links = append(feed.Links, opds.Link{Rel: opds.RelNext, Href: page(m.PageNum + 1), Type: kind})
+	}
+	feed.Links = append(feed.Links, opds.Link{Rel: opds.RelLast, Href: page(m.LastPage), Type: kind})
+}
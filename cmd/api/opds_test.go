@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/opds"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPDSPublicationBuyLink(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/opds/v1.2/new", nil)
+	book := &models.Book{ID: 7, Title: "呐喊", Price: 1990, SourceUrl: "ebooks/a.epub"}
+
+	rels := func(pub opds.Publication) []string {
+		var list []string
+		for _, l := range pub.Links {
+			list = append(list, l.Rel)
+		}
+		return list
+	}
+
+	// 没有配置书城购买页时不提供购买链接
+	app := &Application{}
+	require.Equal(t, []string{opds.RelAcquisition}, rels(app.opdsPublication(r, book, false)))
+
+	app.config.StoreBookURL = "https://shop.example.com/book/{id}"
+	pub := app.opdsPublication(r, book, false)
+	require.Equal(t, []string{opds.RelBuy, opds.RelAcquisition}, rels(pub))
+	buy := pub.Links[0]
+	require.Equal(t, "https://shop.example.com/book/7", buy.Href)
+	require.Equal(t, opds.TypeHTML, buy.Type)
+	require.Equal(t, 19.9, buy.Price.Value)
+
+	// 已购买只提供获取链接
+	require.Equal(t, []string{opds.RelAcquisition}, rels(app.opdsPublication(r, book, true)))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/ebook"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// 试读文件的浏览器缓存时间，过期后通过 Last-Modified 校验，重新生成后文件修改时间变化
+const previewMaxAge = 3600
+
+// PreviewHandler godoc
+//
+//	@Summary		试读电子书
+//	@Description	下载图书的试读版EPUB(前N章或前N%)，无需登录；只有后台配置了试读的EPUB电子书提供试读
+//	@Tags			book
+//	@Produce		application/epub+zip
+//	@Param			id	path	int	true	"图书id"
+//	@Success		200	{file}	file
+//	@Success		304	"未修改"
+//	@Failure		404	{object}	error
+//	@Router			/v1/book/{id}/preview [get]
+func (app *Application) PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	book, err := app.Db.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+	if book.Status != 1 || !delivery.HasEbook(book) {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage(delivery.ErrNoEbook.Error()))
+		return
+	}
+
+	preview, err := app.Db.BookPreviewRepo.Get(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage("该图书不提供试读"))
+		return
+	}
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	opt := ebook.PreviewOptions{Mode: preview.Mode, Value: int(preview.Value)}
+	key, generated, err := delivery.EnsurePreview(r.Context(), book.SourceUrl, app.ebooks, app.previews, id, opt, false)
+	if errors.Is(err, ebook.ErrPreviewUnsupported) {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate preview", "bookID", id, "error", err)
+		app.FAIL(w, r, errs.ErrServerError.WithMessage("试读生成失败，请稍后重试"))
+		return
+	}
+	if generated {
+		if err := app.Db.BookPreviewRepo.Generated(r.Context(), id, book.SourceUrl); err != nil {
+			slog.ErrorContext(r.Context(), "update preview", "bookID", id, "error", err)
+		}
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", previewMaxAge))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fmt.Sprintf("preview-%d.epub", id)))
+
+	if _, err := delivery.ServeCached(w, r, app.previews, key); err != nil {
+		slog.ErrorContext(r.Context(), "serve preview", "bookID", id, "error", err)
+		app.FAIL(w, r, errs.ErrServerError)
+	}
+}
@@ -14,7 +14,7 @@ func (app *Application) PostPublisherHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	id, err := app.Db.PublisherRepo.Create(r.Context(), p.PublisherName)
+	id, err := app.Db.PublisherRepo.Create(r.Context(), &p)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -31,7 +31,15 @@ func (app *Application) GetPublisherHandler(w http.ResponseWriter, r *http.Reque
 		app.FAIL(w, r, a)
 		return
 	}
-	app.SUCC(w, r, id)
+
+	publisher, err := app.Db.PublisherRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, publisher)
 }
 
 // PutPublisherHandler
@@ -47,9 +55,39 @@ func (app *Application) PutPublisherHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// TODO: 获取/赋值才更新
+	p.ID = id
 
-	err := app.Db.PublisherRepo.Update(r.Context(), p.PublisherName)
+	err := app.Db.PublisherRepo.Update(r.Context(), &p)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// PatchPublisherHandler 部分更新出版社，请求体为 JSON Merge Patch(RFC 7396)
+func (app *Application) PatchPublisherHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := app.Db.PublisherRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var publisher models.Publisher
+	if ok := app.ShouldBindMergePatchAndCheck(w, r, old, &publisher); !ok {
+		return
+	}
+
+	err = app.Db.PublisherRepo.Patch(r.Context(), old, &publisher)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -89,3 +127,32 @@ func (app *Application) ListPublisherHandler(w http.ResponseWriter, r *http.Requ
 
 	app.SUCC(w, r, list)
 }
+
+// ListPublisherBooksHandler 分页查询出版社的图书
+func (app *Application) ListPublisherBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if _, err := app.Db.PublisherRepo.Get(r.Context(), id); err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	dataVo, err := app.Db.BookRepo.ListByPublisher(r.Context(), id, app.readPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if list, ok := dataVo.List.([]*models.Book); ok {
+		hideSourceUrl(list...)
+		app.withCoverSrcset(r.Context(), list...)
+	}
+
+	app.SUCC(w, r, dataVo)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// 拉取阅读同步变更的默认条数和最大条数
+const (
+	defaultReadingChanges = 200
+	maxReadingChanges     = 500
+)
+
+// PushReadingHandler 推送阅读进度和书签、高亮，只能同步已购买(或免费)的电子书；
+// 按客户端修改时间(updatedAt，毫秒)最后写入为准，不晚于服务器数据的被忽略
+func (app *Application) PushReadingHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var s models.ReadingSync
+	if ok := app.ShouldBindJSONAndCheck(w, r, &s); !ok {
+		return
+	}
+
+	for _, id := range s.BookIDs() {
+		book, err := app.Db.BookRepo.Get(r.Context(), id)
+		if err != nil {
+			app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+			return
+		}
+		if err := delivery.Entitled(r.Context(), app.Db.OrderRepo, user.ID, book); err != nil {
+			app.FAIL(w, r, deliveryApiError(err))
+			return
+		}
+	}
+
+	result, err := app.Db.ReadingRepo.Push(r.Context(), user.ID, &s)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, result)
+}
+
+// PullReadingHandler 拉取同步序号 since 之后的变更，首次同步不传 since；
+// 返回的 next 作为下次的 since，hasMore 为true时继续拉取
+func (app *Application) PullReadingHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		since, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			app.FAIL(w, r, errs.ErrBadRequest.WithMessage("无效的since"))
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = defaultReadingChanges
+	}
+	limit = min(limit, maxReadingChanges)
+
+	changes, err := app.Db.ReadingRepo.Changes(r.Context(), user.ID, since, limit)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, changes)
+}
+
+// GetReadingHandler 获取一本书的阅读进度和书签、高亮，打开图书时使用
+func (app *Application) GetReadingHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := app.Db.ReadingRepo.State(r.Context(), user.ID, id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, state)
+}
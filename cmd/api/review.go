@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// PostReviewHandler 评价图书，只有已支付订单包含该图书的用户才能评价；
+// 同一用户重复提交会修改原来的评价，修改后重新进入待审核
+func (app *Application) PostReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var review models.Review
+	if ok := app.ShouldBindJSONAndCheck(w, r, &review); !ok {
+		return
+	}
+
+	if _, err := app.Db.BookRepo.Get(r.Context(), id); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	_, err := app.Db.OrderRepo.PaidOrderNo(r.Context(), user.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.FAIL(w, r, errs.ErrForbidden.WithMessage("购买后才能评价"))
+		return
+	}
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	review.UserID, review.BookID = user.ID, id
+
+	reviewID, err := app.Db.ReviewRepo.Save(r.Context(), &review)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, reviewID)
+}
+
+// ListBookReviewsHandler 分页获取图书已通过的评价，sort=helpful 按有用数(默认)，sort=recent 按时间倒序
+func (app *Application) ListBookReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if _, err := app.Db.BookRepo.Get(r.Context(), id); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	filter := app.readPageQuery(r)
+	switch r.URL.Query().Get("sort") {
+	case "recent":
+		filter.SortFields = []string{"-created_at", "-id"}
+	default:
+		filter.SortFields = []string{"-helpful_count", "-id"}
+	}
+
+	dataVo, err := app.Db.ReviewRepo.ListByBook(r.Context(), id, filter)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, dataVo)
+}
+
+// PostReviewHelpfulHandler 投票评价有用，重复投票忽略
+func (app *Application) PostReviewHelpfulHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	user, ok := app.requireBasicAuth(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Db.ReviewRepo.Vote(r.Context(), id, user.ID)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/lightsaid/gotk"
 
 	docs "github.com/lightsaid/ebook/docs/api"
+	"github.com/lightsaid/ebook/internal/opds"
 
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
@@ -30,8 +31,19 @@ func (app *Application) routes() http.Handler {
 		router.Post("/v1/book", app.PostBookHandler)
 		router.Get("/v1/book/{id:[0-9]+}", app.GetBookHandler)
 		router.Put("/v1/book/{id:[0-9]+}", app.PutBookHandler)
+		router.Patch("/v1/book/{id:[0-9]+}", app.PatchBookHandler)
 		router.Delete("/v1/book/{id:[0-9]+}", app.DeleteBookHandler)
 		router.Get("/v1/books", app.ListBookHandler)
+		router.Post("/v1/book/{id:[0-9]+}/download", app.PostDownloadURLHandler)
+		router.Get("/v1/download/{id:[0-9]+}", app.DownloadHandler)
+		router.Get("/v1/book/{id:[0-9]+}/preview", app.PreviewHandler)
+	}
+
+	{
+		// 评价api
+		router.Post("/v1/book/{id:[0-9]+}/reviews", app.PostReviewHandler)
+		router.Get("/v1/book/{id:[0-9]+}/reviews", app.ListBookReviewsHandler)
+		router.Post("/v1/review/{id:[0-9]+}/helpful", app.PostReviewHelpfulHandler)
 	}
 
 	{
@@ -39,8 +51,10 @@ func (app *Application) routes() http.Handler {
 		router.Post("/v1/author", app.PostAuthorHandler)
 		router.Get("/v1/author/{id:[0-9]+}", app.GetAuthorHandler)
 		router.Put("/v1/author/{id:[0-9]+}", app.PutAuthorHandler)
+		router.Patch("/v1/author/{id:[0-9]+}", app.PatchAuthorHandler)
 		router.Delete("/v1/author/{id:[0-9]+}", app.DeleteAuthorHandler)
 		router.Get("/v1/authors", app.ListAuthorHandler)
+		router.Get("/v1/author/{id:[0-9]+}/books", app.ListAuthorBooksHandler)
 	}
 
 	{
@@ -48,8 +62,11 @@ func (app *Application) routes() http.Handler {
 		router.Post("/v1/category", app.PostCategoryHandler)
 		router.Get("/v1/category/{id:[0-9]+}", app.GetCategoryHandler)
 		router.Put("/v1/category/{id:[0-9]+}", app.PutCategoryHandler)
+		router.Patch("/v1/category/{id:[0-9]+}", app.PatchCategoryHandler)
 		router.Delete("/v1/category/{id:[0-9]+}", app.DeleteCategoryHandler)
 		router.Get("/v1/categories", app.ListCategoryHandler)
+		router.Get("/v1/categories/tree", app.TreeCategoryHandler)
+		router.Get("/v1/category/{id:[0-9]+}/books", app.ListCategoryBooksHandler)
 	}
 
 	{
@@ -57,8 +74,10 @@ func (app *Application) routes() http.Handler {
 		router.Post("/v1/publisher", app.PostPublisherHandler)
 		router.Get("/v1/publisher/{id:[0-9]+}", app.GetPublisherHandler)
 		router.Put("/v1/publisher/{id:[0-9]+}", app.PutPublisherHandler)
+		router.Patch("/v1/publisher/{id:[0-9]+}", app.PatchPublisherHandler)
 		router.Delete("/v1/publisher/{id:[0-9]+}", app.DeletePublisherHandler)
 		router.Get("/v1/publishers", app.ListPublisherHandler)
+		router.Get("/v1/publisher/{id:[0-9]+}/books", app.ListPublisherBooksHandler)
 	}
 
 	{
@@ -66,10 +85,17 @@ func (app *Application) routes() http.Handler {
 		router.Post("/v1/banner", app.PostBannerHandler)
 		router.Get("/v1/banner/{id:[0-9]+}", app.GetBannerHandler)
 		router.Put("/v1/banner/{id:[0-9]+}", app.PutBannerHandler)
+		router.Patch("/v1/banner/{id:[0-9]+}", app.PatchBannerHandler)
 		router.Delete("/v1/banner/{id:[0-9]+}", app.DeleteBannerHandler)
 		router.Get("/v1/banners", app.ListBannerHandler)
 	}
 
+	{
+		// 系列api
+		router.Get("/v1/series/{id:[0-9]+}", app.GetSeriesHandler)
+		router.Get("/v1/series", app.ListSeriesHandler)
+	}
+
 	{
 		// 用户api
 		router.Post("/v1/user/register", app.UserRegisterHandler)
@@ -96,6 +122,27 @@ func (app *Application) routes() http.Handler {
 		router.Get("/v1/shopping/carts", app.ListShoppingCartHandler)
 	}
 
+	{
+		// 收藏api
+		router.Put("/v1/favorite/{id:[0-9]+}", app.PutFavoriteHandler)
+		router.Delete("/v1/favorite/{id:[0-9]+}", app.DeleteFavoriteHandler)
+		router.Get("/v1/favorites", app.ListFavoriteHandler)
+		router.Get("/v1/favorites/check", app.CheckFavoriteHandler)
+	}
+
+	{
+		// 阅读同步api：阅读进度、书签、高亮
+		router.Get("/v1/sync/reading", app.PullReadingHandler)
+		router.Post("/v1/sync/reading", app.PushReadingHandler)
+		router.Get("/v1/book/{id:[0-9]+}/reading", app.GetReadingHandler)
+	}
+
+	{
+		// OPDS 目录，供阅读器浏览和下载电子书
+		router.Route("/opds/1.2", app.opdsRoutes(opds.V1))
+		router.Route("/opds/2.0", app.opdsRoutes(opds.V2))
+	}
+
 	mux := chi.NewRouter()
 	mux.Mount("/api", router)
 
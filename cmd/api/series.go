@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+// GetSeriesHandler 获取系列，volumes 为按卷号排序的图书
+func (app *Application) GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.readIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	series, err := app.Db.SeriesRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	series.Volumes, err = app.Db.SeriesRepo.ListVolumes(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	books := make([]*models.Book, 0, len(series.Volumes))
+	for _, v := range series.Volumes {
+		books = append(books, v.Book)
+	}
+	hideSourceUrl(books...)
+	app.withCoverSrcset(r.Context(), books...)
+
+	app.SUCC(w, r, series)
+}
+
+// ListSeriesHandler 分页获取系列
+func (app *Application) ListSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	dataVo, err := app.Db.SeriesRepo.List(r.Context(), app.readPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, dataVo)
+}
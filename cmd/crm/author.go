@@ -5,17 +5,8 @@ import (
 
 	"github.com/lightsaid/ebook/internal/dbrepo"
 	"github.com/lightsaid/ebook/internal/models"
-	"github.com/lightsaid/gotk"
 )
 
-type AuthorNameReq struct {
-	AuthorName string `json:"authorName"`
-}
-
-func (p AuthorNameReq) Verifiy(v *gotk.Validator) {
-	v.Check(p.AuthorName != "", "authorName", "作者名称称必填")
-}
-
 // PostAuthorHandler godoc
 //
 //	@Summary		添加作者
@@ -23,16 +14,16 @@ func (p AuthorNameReq) Verifiy(v *gotk.Validator) {
 //	@Tags			Author
 //	@Accept			json
 //	@Produce		json
-//	@Param			author	body		AuthorNameReq	true	"添加作者请求体"
+//	@Param			author	body		models.Author	true	"添加作者请求体"
 //	@Success		200		{object}	ApiResponse{data=int}
 //	@Router			/v1/author [post]
 func (app *Application) PostAuthorHandler(w http.ResponseWriter, r *http.Request) {
-	var author AuthorNameReq
+	var author models.Author
 	if ok := app.ReadJSONAndCheck(w, r, &author); !ok {
 		return
 	}
 
-	newID, err := store.AuthorRepo.Create(r.Context(), author.AuthorName)
+	newID, err := store.AuthorRepo.Create(r.Context(), &author)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -72,11 +63,11 @@ func (app *Application) GetAuthorHandler(w http.ResponseWriter, r *http.Request)
 // PutAuthorHandler godoc
 //
 //	@Summary		更新作者
-//	@Description	添加一个作者到管理系统
+//	@Description	根据id更新作者资料
 //	@Tags			Author
 //	@Accept			json
 //	@Produce		json
-//	@Param			author	body		AuthorNameReq	true	"添加作者请求体"
+//	@Param			author	body		models.Author	true	"更新作者请求体"
 //	@Param			id		path		int				true	"作者id"
 //	@Success		200		{object}	ApiResponse{data=int}
 //	@Router			/v1/author/{id} [put]
@@ -92,7 +83,9 @@ func (app *Application) PutAuthorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := store.AuthorRepo.Update(r.Context(), id, author.AuthorName)
+	author.ID = id
+
+	err := store.AuthorRepo.Update(r.Context(), &author)
 	if err != nil {
 		a = dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -102,6 +95,46 @@ func (app *Application) PutAuthorHandler(w http.ResponseWriter, r *http.Request)
 	app.SUCC(w, r, id)
 }
 
+// PatchAuthorHandler godoc
+//
+//	@Summary		部分更新作者
+//	@Description	根据id部分更新作者，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段
+//	@Tags			Author
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"作者id"
+//	@Param			patch	body		models.Author	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/author/{id} [patch]
+func (app *Application) PatchAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.AuthorRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var author models.Author
+	if ok := app.ReadMergePatchAndCheck(w, r, old, &author); !ok {
+		return
+	}
+
+	err = store.AuthorRepo.Patch(r.Context(), old, &author)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
 // DeleteAuthorHandler godoc
 //
 //	@Summary		删除作者
@@ -141,6 +174,8 @@ func (app *Application) DeleteAuthorHandler(w http.ResponseWriter, r *http.Reque
 //	@Param			pageNum		query		int			false	"页码"
 //	@Param			pageSize	query		int			false	"每页多少条"
 //	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件，如：author_name:like:张"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
 //	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Author}}
 //	@Router			/v1/authors [get]
 func (app *Application) ListAuthorHandler(w http.ResponseWriter, r *http.Request) {
@@ -155,3 +190,41 @@ func (app *Application) ListAuthorHandler(w http.ResponseWriter, r *http.Request
 
 	app.SUCC(w, r, data)
 }
+
+// ListAuthorBooksHandler godoc
+//
+//	@Summary		获取作者的图书
+//	@Description	分页获取作者的图书，作者是图书任意角色的贡献者即可(作者、译者、插画等)
+//	@Tags			Author
+//	@Produce		json
+//	@Param			id			path		int			true	"作者id"
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件，如：contributor_role:eq:translator"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Book}}
+//	@Router			/v1/author/{id}/books [get]
+func (app *Application) ListAuthorBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	// 作者不存在返回404
+	if _, err := store.AuthorRepo.Get(r.Context(), id); err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	data, err := store.BookRepo.ListByAuthor(r.Context(), id, app.ReadPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
@@ -109,6 +109,46 @@ func (app *Application) PutBannerHandler(w http.ResponseWriter, r *http.Request)
 	app.SUCC(w, r, id)
 }
 
+// PatchBannerHandler godoc
+//
+//	@Summary		部分更新banner
+//	@Description	根据id部分更新banner，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段
+//	@Tags			Banner
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"bannerid"
+//	@Param			patch	body		models.Banner	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/banner/{id} [patch]
+func (app *Application) PatchBannerHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.BannerRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var banner models.Banner
+	if ok := app.ReadMergePatchAndCheck(w, r, old, &banner); !ok {
+		return
+	}
+
+	err = store.BannerRepo.Patch(r.Context(), old, &banner)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
 // DeleteBannerHandler godoc
 //
 //	@Summary		删除banner
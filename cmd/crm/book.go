@@ -1 +1,254 @@
 package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// PostBookHandler godoc
+//
+//	@Summary		添加图书
+//	@Description	添加一本图书到管理系统
+//	@Tags			Book
+//	@Accept			json
+//	@Produce		json
+//	@Param			book	body		models.Book	true	"添加图书请求体"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/book [post]
+func (app *Application) PostBookHandler(w http.ResponseWriter, r *http.Request) {
+	var book models.Book
+	if ok := app.ReadJSONAndCheck(w, r, &book); !ok {
+		return
+	}
+
+	newID, err := store.BookRepo.CreateTx(r.Context(), &book)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, newID)
+}
+
+// GetBookHandler godoc
+//
+//	@Summary		获取图书
+//	@Description	根据id获取一本图书，响应头ETag为当前版本号，更新时通过If-Match带回
+//	@Tags			Book
+//	@Produce		json
+//	@Param			id	path		int	true	"图书id"
+//	@Success		200	{object}	ApiResponse{data=models.Book}
+//	@Header			200	{string}	ETag	"图书版本号"
+//	@Router			/v1/book/{id} [get]
+func (app *Application) GetBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	book, err := store.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SetETag(w, book.Version)
+	app.SUCC(w, r, book)
+}
+
+// PutBookHandler godoc
+//
+//	@Summary		更新图书
+//	@Description	根据id更新图书，需提供读取时的版本号(If-Match请求头或请求体version)，版本不一致返回409和最新数据
+//	@Tags			Book
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"图书id"
+//	@Param			If-Match	header		string		false	"读取时的ETag"
+//	@Param			book		body		models.Book	true	"更新图书请求体"
+//	@Success		200			{object}	ApiResponse{data=int}
+//	@Failure		409			{object}	ApiResponse{data=models.Book}
+//	@Router			/v1/book/{id} [put]
+func (app *Application) PutBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var book models.Book
+	if ok := app.ReadJSONAndCheck(w, r, &book); !ok {
+		return
+	}
+
+	book.ID = id
+
+	// If-Match 优先于请求体的version
+	if version, ok := app.ReadIfMatch(r); ok {
+		book.Version = version
+	}
+	if book.Version <= 0 {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请提供版本号(If-Match 或 version)"))
+		return
+	}
+
+	err := store.BookRepo.UpdateTx(r.Context(), &book)
+	if errors.Is(err, dbrepo.ErrVersionConflict) {
+		app.bookVersionConflict(w, r, id)
+		return
+	}
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SetETag(w, book.Version)
+	app.SUCC(w, r, id)
+}
+
+// PatchBookHandler godoc
+//
+//	@Summary		部分更新图书
+//	@Description	根据id部分更新图书，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段；
+//	@Description	与PUT一样需提供读取时的版本号(If-Match请求头或请求体version)，没有提供返回400，版本不一致返回409和最新数据；分类请使用PUT更新
+//	@Tags			Book
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"图书id"
+//	@Param			If-Match	header		string		false	"读取时的ETag"
+//	@Param			patch		body		models.Book	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200			{object}	ApiResponse{data=int}
+//	@Failure		409			{object}	ApiResponse{data=models.Book}
+//	@Router			/v1/book/{id} [patch]
+func (app *Application) PatchBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	// 版本号不从当前数据合并，必须由 If-Match 或 patch 中的 version 提供
+	base := *old
+	base.Version = 0
+
+	var book models.Book
+	if ok := app.ReadMergePatchAndCheck(w, r, &base, &book); !ok {
+		return
+	}
+
+	book.ID = id
+
+	// If-Match 优先于请求体的version
+	if version, ok := app.ReadIfMatch(r); ok {
+		book.Version = version
+	}
+	if book.Version <= 0 {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请提供版本号(If-Match 或 version)"))
+		return
+	}
+
+	err = store.BookRepo.Patch(r.Context(), old, &book)
+	if errors.Is(err, dbrepo.ErrVersionConflict) {
+		app.bookVersionConflict(w, r, id)
+		return
+	}
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SetETag(w, book.Version)
+	app.SUCC(w, r, id)
+}
+
+// DeleteBookHandler godoc
+//
+//	@Summary		删除图书
+//	@Description	根据id删除一本图书，如果提供了If-Match，版本不一致返回409和最新数据
+//	@Tags			Book
+//	@Produce		json
+//	@Param			id			path		int		true	"图书id"
+//	@Param			If-Match	header		string	false	"读取时的ETag"
+//	@Success		200			{object}	ApiResponse{data=int}
+//	@Failure		409			{object}	ApiResponse{data=models.Book}
+//	@Router			/v1/book/{id} [delete]
+func (app *Application) DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var err error
+	if version, ok := app.ReadIfMatch(r); ok {
+		err = store.BookRepo.DeleteVersion(r.Context(), id, version)
+	} else {
+		err = store.BookRepo.Delete(r.Context(), id)
+	}
+	if errors.Is(err, dbrepo.ErrVersionConflict) {
+		app.bookVersionConflict(w, r, id)
+		return
+	}
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// ListBookHandler godoc
+//
+//	@Summary		获取图书列表
+//	@Description	分页获取图书列表，包含作者、出版社和分类
+//	@Tags			Book
+//	@Produce		json
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件，如：title:like:Go"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Book}}
+//	@Router			/v1/books [get]
+func (app *Application) ListBookHandler(w http.ResponseWriter, r *http.Request) {
+	filter := app.ReadPageQuery(r)
+
+	data, err := store.BookRepo.ListWithCategory(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
+
+// bookVersionConflict 版本冲突时返回409和最新的图书数据
+func (app *Application) bookVersionConflict(w http.ResponseWriter, r *http.Request, id uint64) {
+	current, err := store.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SetETag(w, current.Version)
+	app.FAILWithData(w, r, errs.ErrVersionConflict, current)
+}
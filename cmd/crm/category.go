@@ -60,7 +60,7 @@ func (app *Application) GetCategoryHandler(w http.ResponseWriter, r *http.Reques
 
 	category, err2 := store.CategoryRepo.Get(r.Context(), id)
 	if err2 != nil {
-		a := dbrepo.ConvertToApiError(err)
+		a := dbrepo.ConvertToApiError(err2)
 		app.FAIL(w, r, a)
 		return
 	}
@@ -102,9 +102,8 @@ func (app *Application) PutCategoryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 赋值
+	// 赋值，层级和排序通过移动接口调整
 	category.CategoryName = c.CategoryName
-	category.Sort = c.Sort
 	if c.Icon != "" {
 		category.Icon = c.Icon
 	}
@@ -121,6 +120,46 @@ func (app *Application) PutCategoryHandler(w http.ResponseWriter, r *http.Reques
 
 }
 
+// PatchCategoryHandler godoc
+//
+//	@Summary		部分更新分类
+//	@Description	根据id部分更新分类，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段
+//	@Tags			Category
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"分类id"
+//	@Param			patch	body		models.Category	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/category/{id} [patch]
+func (app *Application) PatchCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.CategoryRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var category models.Category
+	if ok := app.ReadMergePatchAndCheck(w, r, old, &category); !ok {
+		return
+	}
+
+	err = store.CategoryRepo.Patch(r.Context(), old, &category)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
 // DeleteCategoryHandler godoc
 //
 //	@Summary		删除一个分类
@@ -174,3 +213,56 @@ func (app *Application) ListCategoryHandler(w http.ResponseWriter, r *http.Reque
 
 	app.SUCC(w, r, list)
 }
+
+// TreeCategoryHandler godoc
+//
+//	@Summary		获取分类树
+//	@Description	获取全部分类，按层级组装为树，同级分类按 sort 排序
+//	@Tags			Category
+//	@Produce		json
+//	@Success		200	{object}	ApiResponse{data=[]models.Category}
+//	@Router			/v1/categories/tree [get]
+func (app *Application) TreeCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	tree, err := store.CategoryRepo.Tree(r.Context())
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, tree)
+}
+
+// MoveCategoryHandler godoc
+//
+//	@Summary		移动分类
+//	@Description	把分类移动到 parentId 下的 sort 位置(从0开始，小于0或超出时排在最后)，parentId 不变即调整同级排序；
+//	@Description	不能移动到自身或子分类下，层级最多5级
+//	@Tags			Category
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"分类id"
+//	@Param			payload	body		models.CategoryMove	true	"新的父分类和位置"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/category/{id}/move [post]
+func (app *Application) MoveCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var move models.CategoryMove
+	if ok := app.ReadJSON(w, r, &move); !ok {
+		return
+	}
+
+	err := store.CategoryRepo.Move(r.Context(), id, move.ParentID, move.Sort)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+)
+
+// ListDownloadLogHandler godoc
+//
+//	@Summary		获取下载记录
+//	@Description	分页获取电子书下载记录，每次下载请求(包括Range分段请求)一条
+//	@Tags			Book
+//	@Produce		json
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段，支持 id、created_at"
+//	@Param			where		query		[]string	false	"查询条件，支持 id、user_id、book_id、status、created_at"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo}
+//	@Router			/v1/download/logs [get]
+func (app *Application) ListDownloadLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter := app.ReadPageQuery(r)
+	if len(filter.SortFields) == 0 {
+		filter.SortFields = []string{"-id"}
+	}
+
+	data, err := store.DownloadLogRepo.List(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/ebook"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// BookDraft 上传电子书后预填的图书草稿，作者、出版社按名称匹配已有的记录，
+// 没有匹配到时 authorId、publisherId 为0，可根据 metadata 中的名称新建
+type BookDraft struct {
+	Book     *models.Book    `json:"book"`
+	Metadata *ebook.Metadata `json:"metadata"`
+}
+
+// UploadEbookHandler godoc
+//
+//	@Summary		上传电子书
+//	@Description	上传EPUB或PDF文件，解析标题、作者、ISBN、出版社、页数、简介和封面，返回预填的图书草稿(未保存)；
+//	@Description	book.sourceUrl 为电子书文件的 key(不公开，通过签名地址下载)，book.coverUrl 为提取的封面图片访问地址
+//	@Tags			Book
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"EPUB或PDF文件"
+//	@Success		200		{object}	ApiResponse{data=BookDraft}
+//	@Router			/v1/book/upload [post]
+func (app *Application) UploadEbookHandler(w http.ResponseWriter, r *http.Request) {
+	kind := uploadKinds[uploadEbook]
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(kind.readTimeout))
+	r.Body = http.MaxBytesReader(w, r.Body, kind.maxBytes+(1<<20))
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.FAIL(w, r, formFileError(err, "请上传EPUB或PDF文件"))
+		return
+	}
+	defer file.Close()
+
+	meta, err := ebook.Parse(file, header.Size, header.Filename)
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.FAIL(w, r, errs.ErrServerError)
+		return
+	}
+	ebooks, covers := app.uploaders[uploadEbook], app.uploaders[uploadCover]
+	sourceKey, err := ebooks.SaveFile(file, header)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+	sourceUrl, err := app.fileURL(uploadEbook, sourceKey)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+	registerFile(r.Context(), uploadEbook, sourceKey, sourceUrl, "")
+
+	book := &models.Book{
+		ISBN:        meta.ISBN,
+		Title:       meta.Title,
+		Subtitle:    meta.Subtitle,
+		SourceUrl:   sourceUrl,
+		Description: meta.Description,
+		Type:        1,
+	}
+	if meta.Pubdate != nil {
+		book.Pubdate = types.GxTime{Time: *meta.Pubdate}
+	}
+
+	// 封面提取失败不影响上传，由用户另行上传
+	if cover := meta.Cover; cover != nil && cover.Ext() != "" {
+		coverKey, err := covers.Save("cover"+cover.Ext(), bytes.NewReader(cover.Data))
+		if err == nil {
+			book.CoverUrl, err = covers.URL(coverKey)
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "save ebook cover", "file", header.Filename, "error", err)
+		} else {
+			registerFile(r.Context(), uploadCover, coverKey, book.CoverUrl, "")
+			book.CoverSrcset = app.saveVariants(r.Context(), uploadCover, coverKey, book.CoverUrl)
+		}
+	}
+
+	// 草稿没有保存时，上传的文件没有引用，由回收任务删除
+	if err := matchBookDraft(r, book, meta); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, BookDraft{Book: book, Metadata: meta})
+}
+
+// matchBookDraft 按名称匹配已有的作者、出版社，匹配到的作者按顺序作为贡献者，第一个为第一作者
+func matchBookDraft(r *http.Request, book *models.Book, meta *ebook.Metadata) error {
+	for _, name := range meta.Authors {
+		author, err := store.AuthorRepo.GetByName(r.Context(), name)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if book.AuthorID == 0 {
+			book.AuthorID, book.Author = author.ID, author
+		}
+		book.Contributors = append(book.Contributors, &models.BookContributor{
+			AuthorID: author.ID,
+			Role:     models.RoleAuthor,
+			Sort:     len(book.Contributors),
+			Author:   author,
+		})
+	}
+
+	if meta.Publisher != "" {
+		publisher, err := store.PublisherRepo.GetByName(r.Context(), meta.Publisher)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			book.PublisherID, book.Publisher = publisher.ID, publisher
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/export"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// 超过该条数的导出转为后台任务
+const maxStreamExportRows = 10000
+
+// 流式导出时，每写完一页延长的写超时时间
+const exportWriteTimeout = 30 * time.Second
+
+// exportParams 读取导出的数据表、格式和查询条件
+func (app *Application) exportParams(w http.ResponseWriter, r *http.Request) (string, export.Exporter, string, dbrepo.Filters, bool) {
+	entity := chi.URLParam(r, "entity")
+	exporter, ok := export.Tables(store)[entity]
+	if !ok {
+		app.FAIL(w, r, errs.ErrNotFound)
+		return "", nil, "", dbrepo.Filters{}, false
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.Supported(format) {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(export.ErrUnsupportedFormat.Error()))
+		return "", nil, "", dbrepo.Filters{}, false
+	}
+
+	return entity, exporter, format, app.ReadPageQuery(r), true
+}
+
+// ExportHandler godoc
+//
+//	@Summary		导出数据
+//	@Description	按查询条件导出图书、用户、订单，直接下载文件；
+//	@Description	数据超过10000条时转为后台任务，返回任务信息，通过任务接口查询进度和下载
+//	@Tags			Export
+//	@Produce		octet-stream
+//	@Produce		json
+//	@Param			entity		path		string		true	"数据类型"	Enums(books, users, orders)
+//	@Param			format		query		string		false	"导出格式，默认csv"	Enums(csv, xlsx, jsonl)
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件"
+//	@Success		200			{file}		file
+//	@Success		200			{object}	ApiResponse{data=export.Job}
+//	@Router			/v1/export/{entity} [get]
+func (app *Application) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	entity, exporter, format, filter, ok := app.exportParams(w, r)
+	if !ok {
+		return
+	}
+
+	total, err := exporter.Count(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if total > maxStreamExportRows {
+		app.startExportJob(w, r, entity, exporter, format, filter, total)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", entity, time.Now().Format("20060102150405"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// 服务的 WriteTimeout 较短，每写完一页延长写超时
+	rc := http.NewResponseController(w)
+	extend := func(int) {
+		rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	}
+	extend(0)
+
+	// 响应头已经写出，出错只能记录日志并中断
+	if err := exporter.Export(r.Context(), w, format, filter, extend); err != nil {
+		slog.ErrorContext(r.Context(), "export stream", "entity", entity, "error", err)
+	}
+}
+
+// PostExportJobHandler godoc
+//
+//	@Summary		创建后台导出任务
+//	@Description	按查询条件在后台导出图书、用户、订单，返回任务信息
+//	@Tags			Export
+//	@Produce		json
+//	@Param			entity		path		string		true	"数据类型"	Enums(books, users, orders)
+//	@Param			format		query		string		false	"导出格式，默认csv"	Enums(csv, xlsx, jsonl)
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件"
+//	@Success		200			{object}	ApiResponse{data=export.Job}
+//	@Router			/v1/export/{entity}/jobs [post]
+func (app *Application) PostExportJobHandler(w http.ResponseWriter, r *http.Request) {
+	entity, exporter, format, filter, ok := app.exportParams(w, r)
+	if !ok {
+		return
+	}
+
+	total, err := exporter.Count(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.startExportJob(w, r, entity, exporter, format, filter, total)
+}
+
+func (app *Application) startExportJob(
+	w http.ResponseWriter,
+	r *http.Request,
+	entity string,
+	exporter export.Exporter,
+	format string,
+	filter dbrepo.Filters,
+	total int,
+) {
+	job, err := app.exports.Start(entity, format, exporter, filter, total)
+	if err != nil {
+		app.FAIL(w, r, errs.ErrServerError.WithError(err))
+		return
+	}
+
+	app.SUCC(w, r, job)
+}
+
+// GetExportJobHandler godoc
+//
+//	@Summary		查询导出任务
+//	@Description	查询后台导出任务的状态和进度
+//	@Tags			Export
+//	@Produce		json
+//	@Param			id	path		string	true	"任务id"
+//	@Success		200	{object}	ApiResponse{data=export.Job}
+//	@Router			/v1/export/jobs/{id} [get]
+func (app *Application) GetExportJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := app.exports.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+
+	app.SUCC(w, r, job)
+}
+
+// DownloadExportJobHandler godoc
+//
+//	@Summary		下载导出文件
+//	@Description	下载已完成的后台导出任务的文件
+//	@Tags			Export
+//	@Produce		octet-stream
+//	@Param			id	path	string	true	"任务id"
+//	@Success		200	{file}	file
+//	@Router			/v1/export/jobs/{id}/file [get]
+func (app *Application) DownloadExportJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := app.exports.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+
+	if job.Status != export.JobDone {
+		app.FAIL(w, r, errs.ErrUnprocessableEntity.WithMessage(fmt.Sprintf("导出任务未完成，当前状态: %s", job.Status)))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	w.Header().Set("Content-Type", export.ContentType(job.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Filename()))
+	http.ServeFile(w, r, job.Path())
+}
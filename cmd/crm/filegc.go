@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+)
+
+// FileGCHandler godoc
+//
+//	@Summary		回收上传文件
+//	@Description	重新统计上传文件的引用数，删除没有引用超过保留期(FILE_GC_GRACE，默认7天)的文件，一次最多处理1000个；
+//	@Description	dryRun=true 只列出待删除的文件
+//	@Tags			Upload
+//	@Produce		json
+//	@Param			dryRun	query		bool	false	"只列出不删除"
+//	@Success		200		{object}	ApiResponse{data=filegc.Report}
+//	@Router			/v1/files/gc [post]
+func (app *Application) FileGCHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	report, err := app.files.Run(r.Context(), dryRun)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, report)
+}
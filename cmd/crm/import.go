@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/lightsaid/ebook/internal/bookimport"
+	"github.com/lightsaid/ebook/internal/config"
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// 导入文件最大 32MB
+const maxImportFileSize = 32 << 20
+
+// ImportBookHandler godoc
+//
+//	@Summary		批量导入图书
+//	@Description	上传csv或xlsx批量导入图书，按名称关联作者、出版社、分类，不存在则新建；
+//	@Description	表头：isbn,title,subtitle,author,publisher,categories(多个用|分隔),cover_url,pubdate,price(分),status,type,stock,source_url,description；
+//	@Description	dryRun=true 只校验不写入，返回逐行报告
+//	@Tags			Book
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file		formData	file	true	"csv或xlsx文件"
+//	@Param			dryRun		query		bool	false	"只校验不写入"
+//	@Param			batchSize	query		int		false	"每个事务提交的行数，默认100"
+//	@Success		200			{object}	ApiResponse{data=bookimport.Report}
+//	@Router			/v1/books/import [post]
+func (app *Application) ImportBookHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请上传csv或xlsx文件"))
+		return
+	}
+	defer file.Close()
+
+	format, err := bookimport.FormatOf(header.Filename)
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	batchSize, _ := strconv.Atoi(r.URL.Query().Get("batchSize"))
+
+	rows, err := bookimport.ReadRows(file, format)
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return
+	}
+
+	report, err := bookimport.New(store, batchSize).Import(r.Context(), rows, dryRun)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, report)
+}
+
+// runImport 命令行导入图书，如：
+//
+//	go run ./cmd/crm import -env crm.develop.env -file books.xlsx -dry-run
+func runImport(args []string) {
+	var (
+		envFiles  types.ArrayString
+		filename  string
+		dryRun    bool
+		batchSize int
+	)
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Var(&envFiles, "env", "配置文件，支持指定多个")
+	fs.StringVar(&filename, "file", "", "csv或xlsx文件")
+	fs.BoolVar(&dryRun, "dry-run", false, "只校验不写入")
+	fs.IntVar(&batchSize, "batch", bookimport.DefaultBatchSize, "每个事务提交的行数")
+	fs.Parse(args)
+
+	format, err := bookimport.FormatOf(filename)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var conf config.DbConfig
+	if err := config.Load(&conf, envFiles...); err != nil {
+		log.Fatalln(err)
+	}
+
+	conn, err := dbrepo.Open(conf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer conn.Close()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer file.Close()
+
+	rows, err := bookimport.ReadRows(file, format)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	report, err := bookimport.New(dbrepo.NewRepository(conn), batchSize).Import(context.Background(), rows, dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	buf, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(buf))
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/lightsaid/ebook/internal/config"
 	"github.com/lightsaid/ebook/internal/dbcache"
 	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/export"
+	"github.com/lightsaid/ebook/internal/filegc"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/resumable"
+	"github.com/lightsaid/ebook/internal/thumbnail"
 	"github.com/lightsaid/ebook/internal/types"
 	"github.com/lightsaid/ebook/pkg/apptk"
 	"github.com/lightsaid/ebook/pkg/logger"
@@ -24,13 +32,20 @@ var (
 
 type Application struct {
 	apptk.AppToolkit
-	jwt      gotk.TokenMaker
-	envFiles types.ArrayString
-	config   struct {
+	jwt        gotk.TokenMaker
+	exports    *export.Jobs
+	uploaders  map[string]fileupload.FileUploader // 按上传类型区分，见 uploadKinds
+	files      *filegc.Collector                  // 回收没有引用的上传文件
+	resumable  *resumable.Store                   // 断点续传
+	previews   delivery.Cache                     // 试读文件，与前台接口服务共用存储
+	thumbnails thumbnail.Options                  // 封面、轮播图生成的缩略图
+	envFiles   types.ArrayString
+	config     struct {
 		config.CRMConfig
 		config.DbConfig
 		config.JWTConfig
 		config.RedisConfig
+		config.StorageConfig
 	}
 }
 
@@ -46,6 +61,12 @@ type Application struct {
 // @host			localhost:4567
 // @BasePath		/api
 func main() {
+	// 子命令：import 批量导入图书
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
 	// 解析命令行参数获取配置文件
 	var envFiles types.ArrayString
 	flag.Var(&envFiles, "env", "配置文件，支持指定多个")
@@ -95,6 +116,59 @@ func main() {
 	// redis crud实例
 	cache = dbcache.NewRepository(rdb)
 
+	// 后台导出任务，导出文件保存在 ExportDir
+	exportDir := app.config.ExportDir
+	if exportDir == "" {
+		exportDir = "./exports"
+	}
+	app.exports = export.NewJobs(exportDir)
+
+	// 文件上传，每种文件保存在单独的目录，可以保存到对象存储
+	app.uploaders, err = newUploaders(app.config.CRMConfig, app.config.StorageConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	resumableDir := app.config.ResumableDir
+	if resumableDir == "" {
+		resumableDir = "./resumable"
+	}
+	app.resumable, err = resumable.NewStore(resumableDir, resumable.DefaultTTL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// 定时清理过期未完成的上传
+	go app.resumable.Schedule(context.Background(), time.Hour)
+
+	app.files = filegc.New(store, app.uploaders, app.config.FileGCGrace)
+	if interval := app.config.FileGCInterval; interval >= 0 {
+		if interval == 0 {
+			interval = 24 * time.Hour
+		}
+		go app.files.Schedule(context.Background(), interval)
+	}
+
+	app.thumbnails.Widths, err = thumbnail.ParseWidths(app.config.ImageWidths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	app.thumbnails.Quality = app.config.ImageQuality
+	app.thumbnails.Format, err = thumbnail.ParseFormat(app.config.ImageFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// 试读文件，与前台接口服务使用相同的存储
+	previewDir := app.config.PreviewDir
+	if previewDir == "" {
+		previewDir = "./previews"
+	}
+	previews, err := fileupload.New(app.config.StorageConfig, previewDir, "", "", nil, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	app.previews = delivery.NewStoreCache(previews)
+
 	// 启动接口服务
 	if err := app.serve(instance); err != nil {
 		log.Fatalln(err)
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/delivery"
+	"github.com/lightsaid/ebook/internal/ebook"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// BookPreviewStatus 试读配置和生成状态
+type BookPreviewStatus struct {
+	*models.BookPreview
+	// 图书的 source_url 与生成试读时不同，需要重新生成
+	Stale bool `json:"stale"`
+}
+
+// GetBookPreviewHandler godoc
+//
+//	@Summary		获取试读配置
+//	@Description	获取图书的试读配置，stale=true 表示电子书文件已更换，需要重新生成试读
+//	@Tags			Book
+//	@Produce		json
+//	@Param			id	path		int	true	"图书id"
+//	@Success		200	{object}	ApiResponse{data=BookPreviewStatus}
+//	@Router			/v1/book/{id}/preview [get]
+func (app *Application) GetBookPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	book, err := store.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	preview, err := store.BookPreviewRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, BookPreviewStatus{
+		BookPreview: preview,
+		Stale:       preview.GeneratedAt != nil && preview.SourceUrl != book.SourceUrl,
+	})
+}
+
+// PutBookPreviewHandler godoc
+//
+//	@Summary		设置试读配置
+//	@Description	设置图书的试读范围：mode=chapters 前N章(1~20)，mode=percent 前N%(1~50)；只支持EPUB电子书，访问试读时按新配置生成
+//	@Tags			Book
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"图书id"
+//	@Param			preview	body		models.BookPreview	true	"试读配置，只需 mode、value"
+//	@Success		200		{object}	ApiResponse{data=models.BookPreview}
+//	@Router			/v1/book/{id}/preview [put]
+func (app *Application) PutBookPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var preview models.BookPreview
+	if ok := app.ReadJSONAndCheck(w, r, &preview); !ok {
+		return
+	}
+	preview.BookID = id
+
+	if _, err := store.BookRepo.Get(r.Context(), id); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	if err := store.BookPreviewRepo.Save(r.Context(), &preview); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	saved, err := store.BookPreviewRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, saved)
+}
+
+// DeleteBookPreviewHandler godoc
+//
+//	@Summary		关闭试读
+//	@Description	删除图书的试读配置，前台不再提供试读
+//	@Tags			Book
+//	@Produce		json
+//	@Param			id	path		int	true	"图书id"
+//	@Success		200	{object}	ApiResponse{data=string}
+//	@Router			/v1/book/{id}/preview [delete]
+func (app *Application) DeleteBookPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	if err := store.BookPreviewRepo.Delete(r.Context(), id); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, "ok")
+}
+
+// RegenerateBookPreviewHandler godoc
+//
+//	@Summary		重新生成试读
+//	@Description	从图书当前的电子书文件(source_url)重新生成试读，更换电子书文件后调用
+//	@Tags			Book
+//	@Produce		json
+//	@Param			id	path		int	true	"图书id"
+//	@Success		200	{object}	ApiResponse{data=models.BookPreview}
+//	@Router			/v1/book/{id}/preview/regenerate [post]
+func (app *Application) RegenerateBookPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	book, err := store.BookRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+	if !delivery.HasEbook(book) {
+		app.FAIL(w, r, errs.ErrUnprocessableEntity.WithMessage(delivery.ErrNoEbook.Error()))
+		return
+	}
+
+	preview, err := store.BookPreviewRepo.Get(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.FAIL(w, r, errs.ErrUnprocessableEntity.WithMessage("请先设置试读配置"))
+		return
+	}
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	opt := ebook.PreviewOptions{Mode: preview.Mode, Value: int(preview.Value)}
+	_, _, err = delivery.EnsurePreview(r.Context(), book.SourceUrl, app.uploaders[uploadEbook], app.previews, id, opt, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate preview", "bookID", id, "error", err)
+		app.FAIL(w, r, errs.ErrUnprocessableEntity.WithMessage("试读生成失败: "+err.Error()))
+		return
+	}
+
+	if err := store.BookPreviewRepo.Generated(r.Context(), id, book.SourceUrl); err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	preview, err = store.BookPreviewRepo.Get(r.Context(), id)
+	if err != nil {
+		app.FAIL(w, r, dbrepo.ConvertToApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, preview)
+}
@@ -23,7 +23,7 @@ func (app *Application) PostPublisherHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	id, err := store.PublisherRepo.Create(r.Context(), p.PublisherName)
+	id, err := store.PublisherRepo.Create(r.Context(), &p)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -50,7 +50,15 @@ func (app *Application) GetPublisherHandler(w http.ResponseWriter, r *http.Reque
 		app.FAIL(w, r, a)
 		return
 	}
-	app.SUCC(w, r, id)
+
+	publisher, err := store.PublisherRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, publisher)
 }
 
 // PutPublisherHandler godoc
@@ -76,9 +84,49 @@ func (app *Application) PutPublisherHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// TODO: 获取/赋值才更新
+	p.ID = id
 
-	err := store.PublisherRepo.Update(r.Context(), p.PublisherName)
+	err := store.PublisherRepo.Update(r.Context(), &p)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// PatchPublisherHandler godoc
+//
+//	@Summary		部分更新出版社
+//	@Description	根据id部分更新出版社，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段
+//	@Tags			Publisher
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"出版社id"
+//	@Param			patch	body		models.Publisher	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/publisher/{id} [patch]
+func (app *Application) PatchPublisherHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.PublisherRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var publisher models.Publisher
+	if ok := app.ReadMergePatchAndCheck(w, r, old, &publisher); !ok {
+		return
+	}
+
+	err = store.PublisherRepo.Patch(r.Context(), old, &publisher)
 	if err != nil {
 		a := dbrepo.ConvertToApiError(err)
 		app.FAIL(w, r, a)
@@ -133,3 +181,41 @@ func (app *Application) ListPublisherHandler(w http.ResponseWriter, r *http.Requ
 
 	app.SUCC(w, r, list)
 }
+
+// ListPublisherBooksHandler godoc
+//
+//	@Summary		获取出版社的图书
+//	@Description	分页获取出版社出版的图书
+//	@Tags			Publisher
+//	@Produce		json
+//	@Param			id			path		int			true	"出版社id"
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件，如：title:like:Go"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Book}}
+//	@Router			/v1/publisher/{id}/books [get]
+func (app *Application) ListPublisherBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	// 出版社不存在返回404
+	if _, err := store.PublisherRepo.Get(r.Context(), id); err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	data, err := store.BookRepo.ListByPublisher(r.Context(), id, app.ReadPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
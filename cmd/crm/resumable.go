@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/resumable"
+	"github.com/lightsaid/ebook/pkg/errs"
+	"github.com/lightsaid/gotk"
+)
+
+const (
+	maxChunkSize           = 64 << 20        // 每个分片最大 64MB
+	chunkReadTimeout       = 2 * time.Minute // 读取一个分片的超时时间
+	finishResumableTimeout = 5 * time.Minute // 校验和保存文件的超时时间
+)
+
+// CreateResumableRequest 创建断点续传
+type CreateResumableRequest struct {
+	Kind     string `json:"kind"`     // 上传类型，同 /v1/upload/{kind}
+	Filename string `json:"filename"` // 原文件名，用于校验文件类型
+	Length   int64  `json:"length"`   // 文件总大小
+}
+
+func (req *CreateResumableRequest) Verifiy(v *gotk.Validator) {
+	kind, ok := uploadKinds[req.Kind]
+	v.Check(ok, "kind", "不支持的上传类型")
+	v.Check(req.Filename != "", "filename", "请提供文件名")
+	v.Check(req.Length > 0, "length", "文件大小必须大于0")
+	if ok {
+		ext := strings.ToLower(path.Ext(req.Filename))
+		v.Check(slices.Contains(kind.exts, ext), "filename", "不支持的文件类型")
+		v.Check(req.Length <= kind.maxBytes, "length", "文件太大")
+	}
+}
+
+// FinishResumableRequest 完成断点续传
+type FinishResumableRequest struct {
+	Checksum string `json:"checksum"` // 整个文件的 SHA-256，十六进制
+}
+
+func (req *FinishResumableRequest) Verifiy(v *gotk.Validator) {
+	v.Check(len(req.Checksum) == 64, "checksum", "请提供文件的SHA-256")
+}
+
+// CreateResumableHandler godoc
+//
+//	@Summary		创建断点续传
+//	@Description	上传大文件(如电子书)时先创建上传，再通过 PATCH 分片上传，中断后查询已上传的大小继续，最后校验完成；
+//	@Description	未完成的上传24小时后过期
+//	@Tags			Upload
+//	@Accept			json
+//	@Produce		json
+//	@Param			body	body		CreateResumableRequest	true	"上传类型、文件名和大小"
+//	@Success		200		{object}	ApiResponse{data=resumable.Upload}
+//	@Router			/v1/resumable [post]
+func (app *Application) CreateResumableHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateResumableRequest
+	if ok := app.ReadJSONAndCheck(w, r, &req); !ok {
+		return
+	}
+
+	upload, err := app.resumable.Create(req.Kind, req.Filename, req.Length)
+	if err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/resumable/"+upload.ID)
+	setUploadHeaders(w, upload)
+	app.SUCC(w, r, upload)
+}
+
+// GetResumableHandler godoc
+//
+//	@Summary		查询断点续传
+//	@Description	返回已上传的大小 offset，同时设置 Upload-Offset、Upload-Length 响应头，支持 HEAD 请求
+//	@Tags			Upload
+//	@Produce		json
+//	@Param			id	path		string	true	"上传id"
+//	@Success		200	{object}	ApiResponse{data=resumable.Upload}
+//	@Router			/v1/resumable/{id} [get]
+func (app *Application) GetResumableHandler(w http.ResponseWriter, r *http.Request) {
+	upload, err := app.resumable.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	setUploadHeaders(w, upload)
+	app.SUCC(w, r, upload)
+}
+
+// PatchResumableHandler godoc
+//
+//	@Summary		上传分片
+//	@Description	body 为分片的原始数据，Upload-Offset 请求头为分片在文件中的偏移量，必须等于已上传的大小，否则返回409；
+//	@Description	每个分片最大64MB，返回新的 offset
+//	@Tags			Upload
+//	@Accept			application/offset+octet-stream
+//	@Produce		json
+//	@Param			id				path		string	true	"上传id"
+//	@Param			Upload-Offset	header		int		true	"分片的偏移量"
+//	@Success		200				{object}	ApiResponse{data=resumable.Upload}
+//	@Router			/v1/resumable/{id} [patch]
+func (app *Application) PatchResumableHandler(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage("请提供 Upload-Offset 请求头"))
+		return
+	}
+
+	// 服务默认的读写超时不足以传输一个分片
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(chunkReadTimeout))
+	rc.SetWriteDeadline(time.Now().Add(chunkReadTimeout + 15*time.Second))
+	r.Body = http.MaxBytesReader(w, r.Body, maxChunkSize)
+
+	upload, err := app.resumable.Append(chi.URLParam(r, "id"), offset, r.Body)
+	if upload != nil {
+		setUploadHeaders(w, upload)
+	}
+	if err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, upload)
+}
+
+// FinishResumableHandler godoc
+//
+//	@Summary		完成断点续传
+//	@Description	校验整个文件的SHA-256，通过后按上传类型保存，返回结果与 /v1/upload/{kind} 相同
+//	@Tags			Upload
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"上传id"
+//	@Param			body	body		FinishResumableRequest	true	"文件的SHA-256"
+//	@Success		200		{object}	ApiResponse{data=UploadResult}
+//	@Router			/v1/resumable/{id}/finish [post]
+func (app *Application) FinishResumableHandler(w http.ResponseWriter, r *http.Request) {
+	var req FinishResumableRequest
+	if ok := app.ReadJSONAndCheck(w, r, &req); !ok {
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(finishResumableTimeout))
+
+	id := chi.URLParam(r, "id")
+	upload, file, err := app.resumable.Complete(id, req.Checksum)
+	if err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+	defer file.Close()
+
+	uploader := app.uploaders[upload.Kind]
+	key, err := uploader.Save(upload.Filename, file)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+	url, err := app.fileURL(upload.Kind, key)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+	registerFile(r.Context(), upload.Kind, key, url, "")
+
+	result := UploadResult{Key: key, URL: url, Name: upload.Filename, Size: upload.Length}
+	if uploadKinds[upload.Kind].variants {
+		result.Srcset = app.saveVariants(r.Context(), upload.Kind, key, url)
+	}
+
+	file.Close()
+	if err := app.resumable.Remove(id); err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, result)
+}
+
+// DeleteResumableHandler godoc
+//
+//	@Summary		取消断点续传
+//	@Description	删除已上传的数据
+//	@Tags			Upload
+//	@Produce		json
+//	@Param			id	path		string	true	"上传id"
+//	@Success		200	{object}	ApiResponse
+//	@Router			/v1/resumable/{id} [delete]
+func (app *Application) DeleteResumableHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.resumable.Remove(chi.URLParam(r, "id")); err != nil {
+		app.FAIL(w, r, resumableApiError(err))
+		return
+	}
+
+	app.SUCC(w, r, nil)
+}
+
+// setUploadHeaders 设置 tus 协议的进度响应头
+func setUploadHeaders(w http.ResponseWriter, upload *resumable.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// resumableApiError 转换断点续传的错误
+func resumableApiError(err error) *gotk.ApiError {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, resumable.ErrNotFound):
+		return errs.ErrNotFound.WithMessage(err.Error())
+	case errors.Is(err, resumable.ErrOffsetMismatch):
+		return errs.ErrOffsetConflict
+	case errors.Is(err, resumable.ErrTooLarge), errors.As(err, &maxBytesErr):
+		return errs.ErrEntityTooLarge.WithMessage(err.Error())
+	case errors.Is(err, resumable.ErrIncomplete), errors.Is(err, resumable.ErrChecksum):
+		return errs.ErrUnprocessableEntity.WithMessage(err.Error())
+	}
+	return uploadApiError(err)
+}
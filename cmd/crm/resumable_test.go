@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/resumable"
+	"github.com/stretchr/testify/require"
+)
+
+// zeros 无限的0字节
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestPatchResumableHandler(t *testing.T) {
+	s, err := resumable.NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+	app := &Application{resumable: s}
+
+	router := chi.NewRouter()
+	router.Patch("/v1/resumable/{id}", app.PatchResumableHandler)
+
+	patch := func(id string, offset int64, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/v1/resumable/"+id, body)
+		req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	u, err := s.Create(uploadEbook, "book.epub", 2*maxChunkSize)
+	require.NoError(t, err)
+
+	w := patch(u.ID, 0, strings.NewReader("abc"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "3", w.Header().Get("Upload-Offset"))
+
+	// 偏移量不一致返回409和当前的偏移量
+	w = patch(u.ID, 0, strings.NewReader("abc"))
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, "3", w.Header().Get("Upload-Offset"))
+
+	// 分片超过 maxChunkSize
+	w = patch(u.ID, 3, io.LimitReader(zeros{}, maxChunkSize+1))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = patch("00000000000000000000000000000000", 0, strings.NewReader("abc"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/resumable/"+u.ID, strings.NewReader("abc"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
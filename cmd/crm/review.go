@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+// ListReviewHandler godoc
+//
+//	@Summary		获取评价列表
+//	@Description	分页获取全部评价，status: 0-待审核，1-已通过，2-已驳回，3-已隐藏
+//	@Tags			Review
+//	@Produce		json
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段，支持 id、rating、helpful_count、created_at、updated_at"
+//	@Param			where		query		[]string	false	"查询条件，如：status:eq:1、book_id:eq:10"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Review}}
+//	@Router			/v1/reviews [get]
+func (app *Application) ListReviewHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := store.ReviewRepo.List(r.Context(), app.ReadPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
+
+// ListReviewQueueHandler godoc
+//
+//	@Summary		获取待审核评价
+//	@Description	分页获取待审核的评价，按提交时间先后排序
+//	@Tags			Review
+//	@Produce		json
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			where		query		[]string	false	"查询条件，如：book_id:eq:10"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Review}}
+//	@Router			/v1/reviews/queue [get]
+func (app *Application) ListReviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	filter := app.ReadPageQuery(r)
+	filter.Conditions = append(filter.Conditions, dbrepo.Eq("status", models.ReviewPending))
+	filter.SortFields = []string{"updated_at", "id"}
+
+	data, err := store.ReviewRepo.List(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
+
+// GetReviewHandler godoc
+//
+//	@Summary		获取一条评价
+//	@Description	根据id获取评价
+//	@Tags			Review
+//	@Produce		json
+//	@Param			id	path		int	true	"评价id"
+//	@Success		200	{object}	ApiResponse{data=models.Review}
+//	@Router			/v1/review/{id} [get]
+func (app *Application) GetReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	review, err := store.ReviewRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, review)
+}
+
+// ModerateReviewHandler godoc
+//
+//	@Summary		审核评价
+//	@Description	通过、驳回或隐藏评价，驳回和隐藏必须填写原因；只有已通过的评价公开并计入图书评分，审核后重新计算图书的平均分和评价数
+//	@Tags			Review
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"评价id"
+//	@Param			payload	body		models.ReviewModeration	true	"status: 1-通过，2-驳回，3-隐藏"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/review/{id}/moderate [post]
+func (app *Application) ModerateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var m models.ReviewModeration
+	if ok := app.ReadJSONAndCheck(w, r, &m); !ok {
+		return
+	}
+
+	err := store.ReviewRepo.Moderate(r.Context(), id, &m)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
@@ -45,43 +45,109 @@ func (app *Application) routes() http.Handler {
 		}
 
 		{ // book api
-
+			r.Post("/v1/book", app.PostBookHandler)
+			r.Get("/v1/book/{id:[0-9]+}", app.GetBookHandler)
+			r.Put("/v1/book/{id:[0-9]+}", app.PutBookHandler)
+			r.Patch("/v1/book/{id:[0-9]+}", app.PatchBookHandler)
+			r.Delete("/v1/book/{id:[0-9]+}", app.DeleteBookHandler)
+			r.Get("/v1/books", app.ListBookHandler)
+			r.Post("/v1/books/import", app.ImportBookHandler)
+			r.Post("/v1/book/upload", app.UploadEbookHandler)
+			r.Get("/v1/book/{id:[0-9]+}/preview", app.GetBookPreviewHandler)
+			r.Put("/v1/book/{id:[0-9]+}/preview", app.PutBookPreviewHandler)
+			r.Delete("/v1/book/{id:[0-9]+}/preview", app.DeleteBookPreviewHandler)
+			r.Post("/v1/book/{id:[0-9]+}/preview/regenerate", app.RegenerateBookPreviewHandler)
+			r.Get("/v1/download/logs", app.ListDownloadLogHandler)
 		}
 
 		{
 			// 作者api
-			router.Post("/v1/author", app.PostAuthorHandler)
-			router.Get("/v1/author/{id:[0-9]+}", app.GetAuthorHandler)
-			router.Put("/v1/author/{id:[0-9]+}", app.PutAuthorHandler)
-			router.Delete("/v1/author/{id:[0-9]+}", app.DeleteAuthorHandler)
-			router.Get("/v1/authors", app.ListAuthorHandler)
+			r.Post("/v1/author", app.PostAuthorHandler)
+			r.Get("/v1/author/{id:[0-9]+}", app.GetAuthorHandler)
+			r.Put("/v1/author/{id:[0-9]+}", app.PutAuthorHandler)
+			r.Patch("/v1/author/{id:[0-9]+}", app.PatchAuthorHandler)
+			r.Delete("/v1/author/{id:[0-9]+}", app.DeleteAuthorHandler)
+			r.Get("/v1/authors", app.ListAuthorHandler)
+			r.Get("/v1/author/{id:[0-9]+}/books", app.ListAuthorBooksHandler)
 		}
 
 		{
 			// 分类api
-			router.Post("/v1/category", app.PostCategoryHandler)
-			router.Get("/v1/category/{id:[0-9]+}", app.GetCategoryHandler)
-			router.Put("/v1/category/{id:[0-9]+}", app.PutCategoryHandler)
-			router.Delete("/v1/category/{id:[0-9]+}", app.DeleteCategoryHandler)
-			router.Get("/v1/categories", app.ListCategoryHandler)
+			r.Post("/v1/category", app.PostCategoryHandler)
+			r.Get("/v1/category/{id:[0-9]+}", app.GetCategoryHandler)
+			r.Put("/v1/category/{id:[0-9]+}", app.PutCategoryHandler)
+			r.Patch("/v1/category/{id:[0-9]+}", app.PatchCategoryHandler)
+			r.Delete("/v1/category/{id:[0-9]+}", app.DeleteCategoryHandler)
+			r.Get("/v1/categories", app.ListCategoryHandler)
+			r.Get("/v1/categories/tree", app.TreeCategoryHandler)
+			r.Post("/v1/category/{id:[0-9]+}/move", app.MoveCategoryHandler)
 		}
 
 		{
 			// 出版社api
-			router.Post("/v1/publisher", app.PostPublisherHandler)
-			router.Get("/v1/publisher/{id:[0-9]+}", app.GetPublisherHandler)
-			router.Put("/v1/publisher/{id:[0-9]+}", app.PutPublisherHandler)
-			router.Delete("/v1/publisher/{id:[0-9]+}", app.DeletePublisherHandler)
-			router.Get("/v1/publishers", app.ListPublisherHandler)
+			r.Post("/v1/publisher", app.PostPublisherHandler)
+			r.Get("/v1/publisher/{id:[0-9]+}", app.GetPublisherHandler)
+			r.Put("/v1/publisher/{id:[0-9]+}", app.PutPublisherHandler)
+			r.Patch("/v1/publisher/{id:[0-9]+}", app.PatchPublisherHandler)
+			r.Delete("/v1/publisher/{id:[0-9]+}", app.DeletePublisherHandler)
+			r.Get("/v1/publishers", app.ListPublisherHandler)
+			r.Get("/v1/publisher/{id:[0-9]+}/books", app.ListPublisherBooksHandler)
 		}
 
 		{
 			// banner api
-			router.Post("/v1/banner", app.PostBannerHandler)
-			router.Get("/v1/banner/{id:[0-9]+}", app.GetBannerHandler)
-			router.Put("/v1/banner/{id:[0-9]+}", app.PutBannerHandler)
-			router.Delete("/v1/banner/{id:[0-9]+}", app.DeleteBannerHandler)
-			router.Get("/v1/banners", app.ListBannerHandler)
+			r.Post("/v1/banner", app.PostBannerHandler)
+			r.Get("/v1/banner/{id:[0-9]+}", app.GetBannerHandler)
+			r.Put("/v1/banner/{id:[0-9]+}", app.PutBannerHandler)
+			r.Patch("/v1/banner/{id:[0-9]+}", app.PatchBannerHandler)
+			r.Delete("/v1/banner/{id:[0-9]+}", app.DeleteBannerHandler)
+			r.Get("/v1/banners", app.ListBannerHandler)
+		}
+
+		{
+			// series api
+			r.Post("/v1/series", app.PostSeriesHandler)
+			r.Get("/v1/series/{id:[0-9]+}", app.GetSeriesHandler)
+			r.Put("/v1/series/{id:[0-9]+}", app.PutSeriesHandler)
+			r.Patch("/v1/series/{id:[0-9]+}", app.PatchSeriesHandler)
+			r.Delete("/v1/series/{id:[0-9]+}", app.DeleteSeriesHandler)
+			r.Get("/v1/series", app.ListSeriesHandler)
+			r.Put("/v1/series/{id:[0-9]+}/books", app.PutSeriesBookHandler)
+			r.Delete("/v1/series/{id:[0-9]+}/books/{bookId:[0-9]+}", app.DeleteSeriesBookHandler)
+		}
+
+		{
+			// review api
+			r.Get("/v1/reviews", app.ListReviewHandler)
+			r.Get("/v1/reviews/queue", app.ListReviewQueueHandler)
+			r.Get("/v1/review/{id:[0-9]+}", app.GetReviewHandler)
+			r.Post("/v1/review/{id:[0-9]+}/moderate", app.ModerateReviewHandler)
+		}
+
+		{ // 回收站api，entity: books、authors、publishers、categories、banners、series
+			r.Get("/v1/trash/{entity}", app.ListTrashHandler)
+			r.Post("/v1/trash/{entity}/{id:[0-9]+}/restore", app.RestoreTrashHandler)
+			r.Delete("/v1/trash/{entity}/{id:[0-9]+}", app.PurgeTrashHandler)
+		}
+
+		{ // 上传api，kind: cover、banner、icon、avatar、ebook
+			r.Post("/v1/upload/{kind}", app.UploadHandler)
+			r.Post("/v1/files/gc", app.FileGCHandler)
+
+			// 断点续传
+			r.Post("/v1/resumable", app.CreateResumableHandler)
+			r.Get("/v1/resumable/{id}", app.GetResumableHandler)
+			r.Head("/v1/resumable/{id}", app.GetResumableHandler)
+			r.Patch("/v1/resumable/{id}", app.PatchResumableHandler)
+			r.Post("/v1/resumable/{id}/finish", app.FinishResumableHandler)
+			r.Delete("/v1/resumable/{id}", app.DeleteResumableHandler)
+		}
+
+		{ // 导出api，entity: books、users、orders
+			r.Get("/v1/export/{entity}", app.ExportHandler)
+			r.Post("/v1/export/{entity}/jobs", app.PostExportJobHandler)
+			r.Get("/v1/export/jobs/{id}", app.GetExportJobHandler)
+			r.Get("/v1/export/jobs/{id}/file", app.DownloadExportJobHandler)
 		}
 
 		{ // shoppingCart api
@@ -95,6 +161,7 @@ func (app *Application) routes() http.Handler {
 
 	mux := chi.NewRouter()
 	mux.Mount("/api", router)
+	mux.Handle(uploadPath+"/*", app.uploadFileServer())
 
 	app.setupSwaggerDoc(mux)
 
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+// PostSeriesHandler godoc
+//
+//	@Summary		添加系列
+//	@Description	添加一个图书系列，coverUrl 为上传 cover 类型返回的地址
+//	@Tags			Series
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		models.Series	true	"添加系列请求体"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/series [post]
+func (app *Application) PostSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	var series models.Series
+	if ok := app.ReadJSONAndCheck(w, r, &series); !ok {
+		return
+	}
+
+	id, err := store.SeriesRepo.Create(r.Context(), &series)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// GetSeriesHandler godoc
+//
+//	@Summary		获取一个系列
+//	@Description	根据id获取系列，volumes 为按卷号排序的图书
+//	@Tags			Series
+//	@Produce		json
+//	@Param			id	path		int	true	"系列id"
+//	@Success		200	{object}	ApiResponse{data=models.Series}
+//	@Router			/v1/series/{id} [get]
+func (app *Application) GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	series, err := store.SeriesRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	series.Volumes, err = store.SeriesRepo.ListVolumes(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, series)
+}
+
+// PutSeriesHandler godoc
+//
+//	@Summary		更新系列
+//	@Description	根据id更新系列
+//	@Tags			Series
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"系列id"
+//	@Param			payload	body		models.Series	true	"更新系列请求体"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/series/{id} [put]
+func (app *Application) PutSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	var series models.Series
+	if ok := app.ReadJSONAndCheck(w, r, &series); !ok {
+		return
+	}
+
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	series.ID = id
+
+	err := store.SeriesRepo.Update(r.Context(), &series)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// PatchSeriesHandler godoc
+//
+//	@Summary		部分更新系列
+//	@Description	根据id部分更新系列，请求体为 JSON Merge Patch(RFC 7396)，只更新有变化的字段
+//	@Tags			Series
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"系列id"
+//	@Param			patch	body		models.Series	true	"merge patch 请求体，值为null表示清空"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/series/{id} [patch]
+func (app *Application) PatchSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	old, err := store.SeriesRepo.Get(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var series models.Series
+	if ok := app.ReadMergePatchAndCheck(w, r, old, &series); !ok {
+		return
+	}
+
+	err = store.SeriesRepo.Patch(r.Context(), old, &series)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// DeleteSeriesHandler godoc
+//
+//	@Summary		删除一个系列
+//	@Description	根据id删除系列，图书与系列的关联保留，可在回收站恢复
+//	@Tags			Series
+//	@Produce		json
+//	@Param			id	path		int	true	"系列id"
+//	@Success		200	{object}	ApiResponse{data=int}
+//	@Router			/v1/series/{id} [delete]
+func (app *Application) DeleteSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	err := store.SeriesRepo.Delete(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// ListSeriesHandler godoc
+//
+//	@Summary		获取系列列表
+//	@Description	分页获取系列列表
+//	@Tags			Series
+//	@Produce		json
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段"
+//	@Param			where		query		[]string	false	"查询条件，如：series_name:like:三体"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo{list=[]models.Series}}
+//	@Router			/v1/series [get]
+func (app *Application) ListSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := store.SeriesRepo.List(r.Context(), app.ReadPageQuery(r))
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
+
+// PutSeriesBookHandler godoc
+//
+//	@Summary		把图书加入系列
+//	@Description	把图书加入系列或修改卷号，一本书只属于一个系列，已在其他系列中则移动过来；
+//	@Description	系列或图书不存在返回404，卷号已被同系列的其他图书占用返回409
+//	@Tags			Series
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"系列id"
+//	@Param			payload	body		models.SeriesBook	true	"图书id和卷号，seriesId 以路径为准"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/series/{id}/books [put]
+func (app *Application) PutSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	var sb models.SeriesBook
+	if ok := app.ReadJSONAndCheck(w, r, &sb); !ok {
+		return
+	}
+
+	sb.SeriesID = id
+
+	err := store.SeriesRepo.SaveBook(r.Context(), &sb)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// DeleteSeriesBookHandler godoc
+//
+//	@Summary		把图书移出系列
+//	@Description	把图书移出系列，图书不在该系列中返回404
+//	@Tags			Series
+//	@Produce		json
+//	@Param			id		path		int	true	"系列id"
+//	@Param			bookId	path		int	true	"图书id"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/series/{id}/books/{bookId} [delete]
+func (app *Application) DeleteSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	bookID, a := app.ReadIntParam(r, "bookId")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	err := store.SeriesRepo.RemoveBook(r.Context(), id, bookID)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/pkg/errs"
+)
+
+// trashRepo 根据路径参数 entity 获取对应的回收站
+func (app *Application) trashRepo(r *http.Request) (dbrepo.TrashRepo, bool) {
+	switch chi.URLParam(r, "entity") {
+	case "books":
+		return store.BookRepo, true
+	case "authors":
+		return store.AuthorRepo, true
+	case "publishers":
+		return store.PublisherRepo, true
+	case "categories":
+		return store.CategoryRepo, true
+	case "banners":
+		return store.BannerRepo, true
+	case "series":
+		return store.SeriesRepo, true
+	}
+	return nil, false
+}
+
+// ListTrashHandler godoc
+//
+//	@Summary		获取回收站列表
+//	@Description	分页获取已删除的数据，默认按删除时间倒序
+//	@Tags			Trash
+//	@Produce		json
+//	@Param			entity		path		string		true	"数据类型"	Enums(books, authors, publishers, categories, banners, series)
+//	@Param			pageNum		query		int			false	"页码"
+//	@Param			pageSize	query		int			false	"每页多少条"
+//	@Param			sortFields	query		[]string	false	"排序字段，支持 id、deleted_at"
+//	@Param			where		query		[]string	false	"查询条件，支持 id、deleted_at"
+//	@Param			cursor		query		string		false	"游标分页游标，首页传空值"
+//	@Success		200			{object}	ApiResponse{data=dbrepo.PageQueryVo}
+//	@Router			/v1/trash/{entity} [get]
+func (app *Application) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	repo, ok := app.trashRepo(r)
+	if !ok {
+		app.FAIL(w, r, errs.ErrNotFound)
+		return
+	}
+
+	filter := app.ReadPageQuery(r)
+
+	data, err := repo.ListDeleted(r.Context(), filter)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, data)
+}
+
+// RestoreTrashHandler godoc
+//
+//	@Summary		恢复已删除的数据
+//	@Description	根据id恢复回收站中的数据，唯一字段(如isbn、分类名称)已被占用返回409
+//	@Tags			Trash
+//	@Produce		json
+//	@Param			entity	path		string	true	"数据类型"	Enums(books, authors, publishers, categories, banners, series)
+//	@Param			id		path		int		true	"数据id"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/trash/{entity}/{id}/restore [post]
+func (app *Application) RestoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	repo, ok := app.trashRepo(r)
+	if !ok {
+		app.FAIL(w, r, errs.ErrNotFound)
+		return
+	}
+
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	err := repo.Restore(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
+
+// PurgeTrashHandler godoc
+//
+//	@Summary		彻底删除数据
+//	@Description	根据id彻底删除回收站中的数据，仍被引用(如图书已有订单)返回409
+//	@Tags			Trash
+//	@Produce		json
+//	@Param			entity	path		string	true	"数据类型"	Enums(books, authors, publishers, categories, banners, series)
+//	@Param			id		path		int		true	"数据id"
+//	@Success		200		{object}	ApiResponse{data=int}
+//	@Router			/v1/trash/{entity}/{id} [delete]
+func (app *Application) PurgeTrashHandler(w http.ResponseWriter, r *http.Request) {
+	repo, ok := app.trashRepo(r)
+	if !ok {
+		app.FAIL(w, r, errs.ErrNotFound)
+		return
+	}
+
+	id, a := app.ReadIntParam(r, "id")
+	if a != nil {
+		app.FAIL(w, r, a)
+		return
+	}
+
+	err := repo.Purge(r.Context(), id)
+	if err != nil {
+		a := dbrepo.ConvertToApiError(err)
+		app.FAIL(w, r, a)
+		return
+	}
+
+	app.SUCC(w, r, id)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lightsaid/ebook/internal/config"
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/thumbnail"
+	"github.com/lightsaid/ebook/pkg/errs"
+	"github.com/lightsaid/gotk"
+)
+
+// 上传类型
+const (
+	uploadCover  = "cover"  // 图书封面
+	uploadBanner = "banner" // 轮播图
+	uploadIcon   = "icon"   // 分类图标、出版社logo
+	uploadAvatar = "avatar" // 用户头像、作者照片
+	uploadEbook  = "ebook"  // 电子书文件
+)
+
+// 上传文件的访问路径，图片由后台服务直接提供
+const uploadPath = "/uploads"
+
+var imageExts = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// uploadKind 一种上传类型的限制
+type uploadKind struct {
+	dir         string // 保存目录，相对于 UploadDir，使用对象存储时为 key 的前缀目录
+	private     bool   // 不公开访问，本地存储时保存在 EbookDir，返回文件的 key，下载时通过 uploader 读取
+	exts        []string
+	maxBytes    int64
+	readTimeout time.Duration // 服务默认的 ReadTimeout 不足以上传大文件
+	variants    bool          // 生成缩略图和响应式尺寸
+}
+
+var uploadKinds = map[string]uploadKind{
+	uploadCover:  {dir: "covers", exts: imageExts, maxBytes: 5 << 20, readTimeout: time.Minute, variants: true},
+	uploadBanner: {dir: "banners", exts: imageExts, maxBytes: 5 << 20, readTimeout: time.Minute, variants: true},
+	uploadIcon:   {dir: "icons", exts: []string{".png", ".jpg", ".jpeg", ".webp"}, maxBytes: 1 << 20, readTimeout: time.Minute},
+	uploadAvatar: {dir: "avatars", exts: imageExts, maxBytes: 2 << 20, readTimeout: time.Minute},
+	uploadEbook:  {dir: "ebooks", private: true, exts: []string{".epub", ".pdf"}, maxBytes: 200 << 20, readTimeout: 5 * time.Minute},
+}
+
+// newUploaders 创建每种上传类型的 FileUploader，storage.Driver 为 s3 时保存到对象存储，
+// 电子书保存在存储桶的 ebooks 目录(不公开)或本地的 EbookDir，前台接口服务使用相同的存储读取
+func newUploaders(conf config.CRMConfig, storage config.StorageConfig) (map[string]fileupload.FileUploader, error) {
+	uploadDir, uploadURL, ebookDir := conf.UploadDir, conf.UploadURL, conf.EbookDir
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+	if uploadURL == "" {
+		uploadURL = uploadPath
+	}
+	if ebookDir == "" {
+		ebookDir = "./ebooks"
+	}
+
+	uploaders := make(map[string]fileupload.FileUploader, len(uploadKinds))
+	for name, kind := range uploadKinds {
+		dir, baseURL := filepath.Join(uploadDir, kind.dir), strings.TrimSuffix(uploadURL, "/")+"/"+kind.dir
+		if kind.private {
+			// 电子书通过签名的下载地址获取，不提供访问地址，文件的 key 作为 source_url
+			dir, baseURL = ebookDir, ""
+		}
+		uploader, err := fileupload.New(storage, dir, kind.dir, baseURL, kind.exts, kind.maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		uploaders[name] = uploader
+	}
+	return uploaders, nil
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	Key  string `json:"key"`  // 文件标识
+	URL  string `json:"url"`  // 访问地址，电子书为文件的 key，用作 source_url
+	Name string `json:"name"` // 原文件名
+	Size int64  `json:"size"`
+
+	Srcset models.Srcset `json:"srcset,omitempty"` // 封面、轮播图的各尺寸地址，包括原图
+}
+
+// UploadHandler godoc
+//
+//	@Summary		上传文件
+//	@Description	按类型上传文件，返回访问地址：
+//	@Description	cover 图书封面、系列封面(5MB)、banner 轮播图(5MB)、avatar 头像(2MB) 支持 jpg/jpeg/png/gif/webp；icon 分类图标(1MB) 支持 png/jpg/jpeg/webp；
+//	@Description	ebook 电子书(200MB) 支持 epub/pdf，不公开访问，返回值用作图书的 sourceUrl；
+//	@Description	cover、banner 同时生成 IMAGE_WIDTHS 配置的各宽度图片，返回 srcset
+//	@Tags			Upload
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			kind	path		string	true	"上传类型"	Enums(cover, banner, icon, avatar, ebook)
+//	@Param			file	formData	file	true	"文件"
+//	@Success		200		{object}	ApiResponse{data=UploadResult}
+//	@Router			/v1/upload/{kind} [post]
+func (app *Application) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "kind")
+	kind, ok := uploadKinds[name]
+	if !ok {
+		app.FAIL(w, r, errs.ErrNotFound.WithMessage("不支持的上传类型"))
+		return
+	}
+
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(kind.readTimeout))
+	// multipart 的边界和其他字段需要额外的空间
+	r.Body = http.MaxBytesReader(w, r.Body, kind.maxBytes+(1<<20))
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.FAIL(w, r, formFileError(err, "请选择上传的文件"))
+		return
+	}
+	defer file.Close()
+
+	uploader := app.uploaders[name]
+	key, err := uploader.SaveFile(file, header)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+	url, err := app.fileURL(name, key)
+	if err != nil {
+		app.FAIL(w, r, uploadApiError(err))
+		return
+	}
+
+	registerFile(r.Context(), name, key, url, "")
+
+	result := UploadResult{Key: key, URL: url, Name: header.Filename, Size: header.Size}
+	if kind.variants {
+		result.Srcset = app.saveVariants(r.Context(), name, key, url)
+	}
+
+	app.SUCC(w, r, result)
+}
+
+// fileURL 上传文件保存到数据库的地址，不公开访问的类型为文件的 key，其他为访问地址
+func (app *Application) fileURL(kind, key string) (string, error) {
+	if uploadKinds[kind].private {
+		return key, nil
+	}
+	return app.uploaders[kind].URL(key)
+}
+
+// saveVariants 生成图片的各尺寸，通过 uploader 保存在原图旁边，记录 srcset 并返回；
+// 生成失败时只记录日志返回 nil，前台使用原图
+func (app *Application) saveVariants(ctx context.Context, kind, key, url string) models.Srcset {
+	uploader := app.uploaders[kind]
+	rc, err := uploader.Open(key)
+	if err != nil {
+		slog.WarnContext(ctx, "open image for variants", "key", key, "error", err)
+		return nil
+	}
+	width, variants, err := thumbnail.Generate(rc, app.thumbnails)
+	rc.Close()
+	if err != nil {
+		slog.WarnContext(ctx, "generate image variants", "key", key, "error", err)
+		return nil
+	}
+
+	srcset := models.Srcset{fmt.Sprintf("%dw", width): url}
+	for _, v := range variants {
+		vkey, err := uploader.Save(key+v.Ext, bytes.NewReader(v.Data))
+		var vurl string
+		if err == nil {
+			vurl, err = uploader.URL(vkey)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "save image variant", "key", key, "width", v.Width, "error", err)
+			return nil
+		}
+		registerFile(ctx, kind, vkey, vurl, url)
+		srcset[fmt.Sprintf("%dw", v.Width)] = vurl
+	}
+
+	if err := store.ImageVariantRepo.Save(ctx, url, srcset); err != nil {
+		slog.WarnContext(ctx, "save image srcset", "url", url, "error", err)
+		return nil
+	}
+	return srcset
+}
+
+// registerFile 登记上传的文件，内容相同的文件共用同一个 key，由回收任务统计引用后删除；
+// parentURL 为缩略图对应的原图地址。登记失败只记录日志，文件不会被回收
+func registerFile(ctx context.Context, kind, key, url, parentURL string) {
+	file := &models.File{Kind: kind, FileKey: key, URL: url, ParentURL: parentURL}
+	if err := store.FileRepo.Register(ctx, file); err != nil {
+		slog.WarnContext(ctx, "register uploaded file", "kind", kind, "key", key, "error", err)
+	}
+}
+
+// uploadFileServer 提供上传图片的访问，不列出目录
+func (app *Application) uploadFileServer() http.Handler {
+	dir := app.config.UploadDir
+	if dir == "" {
+		dir = "./uploads"
+	}
+	fs := http.StripPrefix(uploadPath, http.FileServer(http.Dir(dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// formFileError 读取表单文件的错误，请求体超过大小限制时返回 ErrEntityTooLarge
+func formFileError(err error, msg string) *gotk.ApiError {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errs.ErrEntityTooLarge
+	}
+	return errs.ErrBadRequest.WithMessage(msg)
+}
+
+// uploadApiError 转换上传相关的错误，文件不符合要求时返回对应的错误码
+func uploadApiError(err error) *gotk.ApiError {
+	switch {
+	case errors.Is(err, fileupload.ErrFileTooLarge):
+		return errs.ErrEntityTooLarge
+	case errors.Is(err, fileupload.ErrrNotAllowExt), errors.Is(err, fileupload.ErrSVGNotAllowed),
+		errors.Is(err, fileupload.ErrContentMismatch):
+		return errs.ErrUnsupportedMedia.WithMessage(err.Error())
+	case fileupload.IsUploaderError(err):
+		return errs.ErrUnprocessableEntity.WithMessage(err.Error())
+	}
+	return dbrepo.ConvertToApiError(err)
+}
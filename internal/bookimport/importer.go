@@ -0,0 +1,355 @@
+// Package bookimport 从csv、xlsx批量导入图书，按名称关联(或新建)作者、出版社和分类，
+// 支持只校验不写入的 dry-run 模式，返回逐行的导入报告
+package bookimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/gotk"
+)
+
+// DefaultBatchSize 默认每个事务提交的行数
+const DefaultBatchSize = 100
+
+// Row 导入文件中的一行
+type Row struct {
+	Line       int               // 文件中的行号，包含表头，从1开始
+	Book       models.Book       // 图书数据，作者、出版社、分类的id在提交时根据名称填充
+	Author     string            // 作者名称
+	Publisher  string            // 出版社名称
+	Categories []string          // 分类名称
+	Errors     map[string]string // 字段 => 错误信息
+}
+
+// RowResult 一行的导入结果
+type RowResult struct {
+	Line    int               `json:"line"`
+	ISBN    string            `json:"isbn"`
+	Title   string            `json:"title"`
+	BookID  uint64            `json:"bookId,omitempty"`  // 导入成功的图书id
+	Creates []string          `json:"creates,omitempty"` // 需要新建的作者、出版社、分类
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// Report 导入报告
+type Report struct {
+	DryRun   bool         `json:"dryRun"`
+	Total    int          `json:"total"`    // 数据行数
+	Valid    int          `json:"valid"`    // 校验通过的行数
+	Imported int          `json:"imported"` // 成功导入的行数，dry-run 为0
+	Failed   int          `json:"failed"`   // 校验失败或提交失败的行数
+	Rows     []*RowResult `json:"rows"`
+}
+
+// Importer 图书导入器
+type Importer struct {
+	store     dbrepo.Repository
+	batchSize int
+
+	// 名称 => id 的缓存，只保存已提交的数据
+	authors    map[string]uint64
+	publishers map[string]uint64
+	categories map[string]uint64
+}
+
+// New 创建导入器，batchSize <= 0 时使用 DefaultBatchSize
+func New(store dbrepo.Repository, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Importer{
+		store:      store,
+		batchSize:  batchSize,
+		authors:    make(map[string]uint64),
+		publishers: make(map[string]uint64),
+		categories: make(map[string]uint64),
+	}
+}
+
+// Import 校验所有行，dryRun 为 true 时只返回校验报告；
+// 否则按 batchSize 分批在事务中提交校验通过的行，某一批失败只回滚该批
+func (im *Importer) Import(ctx context.Context, rows []*Row, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Total: len(rows), Rows: make([]*RowResult, 0, len(rows))}
+
+	valid, err := im.validate(ctx, rows, report)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Valid = len(valid)
+	report.Failed = report.Total - report.Valid
+
+	if dryRun {
+		return report, nil
+	}
+
+	for start := 0; start < len(valid); start += im.batchSize {
+		batch := valid[start:min(start+im.batchSize, len(valid))]
+		if err := im.commit(ctx, batch); err != nil {
+			slog.ErrorContext(ctx, "bookimport commit batch", "line", batch[0].row.Line, "error", err)
+			report.Failed += len(batch)
+			continue
+		}
+		report.Imported += len(batch)
+	}
+
+	return report, nil
+}
+
+// pending 校验通过待提交的行
+type pending struct {
+	row    *Row
+	result *RowResult
+}
+
+// validate 校验每一行：解析错误、名称是否存在、Book.Verifiy、isbn是否重复，返回校验通过的行
+func (im *Importer) validate(ctx context.Context, rows []*Row, report *Report) ([]*pending, error) {
+	// 文件内 isbn 出现的行号，以及数据库中已存在的 isbn
+	seen := make(map[string]int)
+	isbns := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Book.ISBN != "" {
+			isbns = append(isbns, row.Book.ISBN)
+		}
+	}
+	list, err := im.store.BookRepo.ExistingISBNs(ctx, isbns)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(list))
+	for _, isbn := range list {
+		existing[isbn] = true
+	}
+
+	valid := make([]*pending, 0, len(rows))
+	for _, row := range rows {
+		result := &RowResult{Line: row.Line, ISBN: row.Book.ISBN, Title: row.Book.Title, Errors: row.Errors}
+		if result.Errors == nil {
+			result.Errors = make(map[string]string)
+		}
+		report.Rows = append(report.Rows, result)
+
+		if err := im.check(ctx, row, result); err != nil {
+			return nil, err
+		}
+
+		isbn := row.Book.ISBN
+		if line, ok := seen[isbn]; ok && isbn != "" {
+			result.Errors["isbn"] = fmt.Sprintf("isbn与第%d行重复", line)
+		} else {
+			seen[isbn] = row.Line
+		}
+		if existing[isbn] {
+			result.Errors["isbn"] = "isbn已存在"
+		}
+
+		if len(result.Errors) > 0 {
+			continue
+		}
+		result.Errors = nil
+		valid = append(valid, &pending{row: row, result: result})
+	}
+
+	return valid, nil
+}
+
+// check 查找作者、出版社、分类是否已存在，不存在的记录到 Creates，然后使用 Book.Verifiy 校验
+func (im *Importer) check(ctx context.Context, row *Row, result *RowResult) error {
+	book := row.Book
+
+	if row.Author != "" {
+		id, err := im.lookup(ctx, im.authors, row.Author, func(ctx context.Context, name string) (uint64, error) {
+			author, err := im.store.AuthorRepo.GetByName(ctx, name)
+			return author.ID, err
+		})
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			result.Creates = append(result.Creates, "作者: "+row.Author)
+		}
+		// 新建的作者提交时才有id，这里仅用于通过校验
+		book.AuthorID = max(id, 1)
+	}
+
+	if row.Publisher != "" {
+		id, err := im.lookup(ctx, im.publishers, row.Publisher, func(ctx context.Context, name string) (uint64, error) {
+			publisher, err := im.store.PublisherRepo.GetByName(ctx, name)
+			return publisher.ID, err
+		})
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			result.Creates = append(result.Creates, "出版社: "+row.Publisher)
+		}
+		book.PublisherID = max(id, 1)
+	}
+
+	for _, name := range row.Categories {
+		id, err := im.lookup(ctx, im.categories, name, func(ctx context.Context, name string) (uint64, error) {
+			category, err := im.store.CategoryRepo.GetByName(ctx, name)
+			return category.ID, err
+		})
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			result.Creates = append(result.Creates, "分类: "+name)
+		}
+	}
+
+	v, _ := gotk.DoVerifiy(book)
+	for field, msg := range v.Errors {
+		// 作者、出版社在文件中是名称
+		switch field {
+		case "authorId":
+			field = "author"
+		case "publisherID":
+			field = "publisher"
+		case "coverUrl":
+			field = "cover_url"
+		}
+		if _, ok := result.Errors[field]; !ok {
+			result.Errors[field] = msg
+		}
+	}
+
+	return nil
+}
+
+// lookup 根据名称查找id，先查缓存再查数据库，不存在返回0
+func (im *Importer) lookup(
+	ctx context.Context,
+	cache map[string]uint64,
+	name string,
+	get func(ctx context.Context, name string) (uint64, error),
+) (uint64, error) {
+	if id, ok := cache[name]; ok {
+		return id, nil
+	}
+
+	id, err := get(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cache[name] = id
+	return id, nil
+}
+
+// commit 在一个事务中提交一批数据，不存在的作者、出版社、分类会被创建；
+// 失败时整批回滚，每行都记录错误，出错的行记录具体原因
+func (im *Importer) commit(ctx context.Context, batch []*pending) error {
+	// 本批新建的数据，提交成功后才写入缓存
+	created := map[string]map[string]uint64{
+		"author":    make(map[string]uint64),
+		"publisher": make(map[string]uint64),
+		"category":  make(map[string]uint64),
+	}
+
+	// 返回已存在或本批新建的id，不存在则调用 create 新建
+	resolve := func(cache map[string]uint64, kind, name string, create func() (uint64, error)) (uint64, error) {
+		if id, ok := cache[name]; ok {
+			return id, nil
+		}
+		if id, ok := created[kind][name]; ok {
+			return id, nil
+		}
+		id, err := create()
+		if err != nil {
+			return 0, err
+		}
+		created[kind][name] = id
+		return id, nil
+	}
+
+	var failed *pending
+	err := im.store.ExecTx(ctx, func(tx dbrepo.Repository) error {
+		for _, p := range batch {
+			failed = p
+			row, book := p.row, p.row.Book
+
+			var err error
+			book.AuthorID, err = resolve(im.authors, "author", row.Author, func() (uint64, error) {
+				return tx.AuthorRepo.Create(ctx, &models.Author{AuthorName: row.Author})
+			})
+			if err != nil {
+				return err
+			}
+
+			book.PublisherID, err = resolve(im.publishers, "publisher", row.Publisher, func() (uint64, error) {
+				return tx.PublisherRepo.Create(ctx, &models.Publisher{PublisherName: row.Publisher})
+			})
+			if err != nil {
+				return err
+			}
+
+			bookID, err := tx.BookRepo.Create(ctx, &book)
+			if err != nil {
+				return err
+			}
+
+			contributor := &models.BookContributor{AuthorID: book.AuthorID, Role: models.RoleAuthor}
+			if err := tx.BookRepo.SaveContributors(ctx, bookID, []*models.BookContributor{contributor}); err != nil {
+				return err
+			}
+
+			list := make([]models.BookCategory, 0, len(row.Categories))
+			for _, name := range row.Categories {
+				categoryID, err := resolve(im.categories, "category", name, func() (uint64, error) {
+					return tx.CategoryRepo.Create(ctx, models.Category{CategoryName: name})
+				})
+				if err != nil {
+					return err
+				}
+				list = append(list, models.BookCategory{BookID: bookID, CategoryID: categoryID})
+			}
+			if len(list) > 0 {
+				if err := tx.BookCategoryRepo.BatchInsert(ctx, list); err != nil {
+					return err
+				}
+			}
+
+			p.result.BookID = bookID
+		}
+		return nil
+	})
+
+	if err != nil {
+		// failed 为 nil 时事务没有开始(如数据库连接失败)，每行都记录该错误
+		msg := "同批次数据提交失败，已回滚"
+		if failed == nil {
+			msg = err.Error()
+		}
+		for _, p := range batch {
+			p.result.BookID = 0
+			p.result.Errors = map[string]string{"row": msg}
+		}
+		if failed != nil {
+			failed.result.Errors = map[string]string{"row": err.Error()}
+		}
+		return err
+	}
+
+	for name, id := range created["author"] {
+		im.authors[name] = id
+	}
+	for name, id := range created["publisher"] {
+		im.publishers[name] = id
+	}
+	for name, id := range created["category"] {
+		im.categories[name] = id
+	}
+
+	return nil
+}
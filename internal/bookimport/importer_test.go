@@ -0,0 +1,143 @@
+package bookimport
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBooks struct {
+	dbrepo.BookRepo
+	isbns []string
+}
+
+func (f fakeBooks) ExistingISBNs(ctx context.Context, isbns []string) ([]string, error) {
+	return f.isbns, nil
+}
+
+type fakeAuthors struct{ dbrepo.AuthorRepo }
+
+func (fakeAuthors) GetByName(ctx context.Context, name string) (*models.Author, error) {
+	if name == "鲁迅" {
+		return &models.Author{ID: 1, AuthorName: name}, nil
+	}
+	return new(models.Author), sql.ErrNoRows
+}
+
+type fakePublishers struct{ dbrepo.PublisherRepo }
+
+func (fakePublishers) GetByName(ctx context.Context, name string) (*models.Publisher, error) {
+	if name == "人民文学出版社" {
+		return &models.Publisher{ID: 1, PublisherName: name}, nil
+	}
+	return new(models.Publisher), sql.ErrNoRows
+}
+
+type fakeCategories struct{ dbrepo.CategoryRepo }
+
+func (fakeCategories) GetByName(ctx context.Context, name string) (*models.Category, error) {
+	return new(models.Category), sql.ErrNoRows
+}
+
+// fakeStore 没有数据库连接，ExecTx 在开始事务前返回错误，模拟数据库连接失败
+func fakeStore(existing ...string) dbrepo.Repository {
+	return dbrepo.Repository{
+		BookRepo:      fakeBooks{isbns: existing},
+		AuthorRepo:    fakeAuthors{},
+		PublisherRepo: fakePublishers{},
+		CategoryRepo:  fakeCategories{},
+	}
+}
+
+func newRow(line int, isbn, author, publisher string) *Row {
+	return &Row{
+		Line: line,
+		Book: models.Book{
+			ISBN:     isbn,
+			Title:    "呐喊",
+			Subtitle: "小说集",
+			CoverUrl: "/uploads/covers/a.jpg",
+			Pubdate:  types.GxTime{Time: time.Date(1923, 8, 1, 0, 0, 0, 0, time.UTC)},
+			Status:   1,
+			Type:     1,
+		},
+		Author:     author,
+		Publisher:  publisher,
+		Categories: []string{"小说"},
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []*Row
+		existing []string
+		dryRun   bool
+		want     Report
+		errors   map[int]map[string]string // 行号 => 错误
+		creates  map[int][]string
+	}{
+		{
+			name:    "dry-run",
+			rows:    []*Row{newRow(2, "9787020024759", "鲁迅", "人民文学出版社"), newRow(3, "9787020024766", "周作人", "新潮社")},
+			dryRun:  true,
+			want:    Report{DryRun: true, Total: 2, Valid: 2},
+			creates: map[int][]string{2: {"分类: 小说"}, 3: {"作者: 周作人", "出版社: 新潮社", "分类: 小说"}},
+		},
+		{
+			name: "validation",
+			rows: []*Row{
+				newRow(2, "9787020024759", "鲁迅", "人民文学出版社"),
+				newRow(3, "abc", "鲁迅", "人民文学出版社"),
+				newRow(4, "9787020024759", "鲁迅", "人民文学出版社"),
+				newRow(5, "9787020024773", "", "人民文学出版社"),
+				newRow(6, "9787020024780", "鲁迅", "人民文学出版社"),
+			},
+			existing: []string{"9787020024780"},
+			dryRun:   true,
+			want:     Report{DryRun: true, Total: 5, Valid: 1, Failed: 4},
+			errors: map[int]map[string]string{
+				3: {"isbn": "请输入合法的ISBN"},
+				4: {"isbn": "isbn与第2行重复"},
+				5: {"author": "请选择作者"},
+				6: {"isbn": "isbn已存在"},
+			},
+		},
+		{
+			name: "failed transaction",
+			rows: []*Row{newRow(2, "9787020024759", "鲁迅", "人民文学出版社"), newRow(3, "9787020024766", "鲁迅", "人民文学出版社")},
+			want: Report{Total: 2, Valid: 2, Failed: 2},
+			errors: map[int]map[string]string{
+				2: {"row": "`query Queryable` is not sqlx.DB"},
+				3: {"row": "`query Queryable` is not sqlx.DB"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := New(fakeStore(tt.existing...), 10).Import(context.Background(), tt.rows, tt.dryRun)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.DryRun, report.DryRun)
+			require.Equal(t, tt.want.Total, report.Total)
+			require.Equal(t, tt.want.Valid, report.Valid)
+			require.Equal(t, tt.want.Imported, report.Imported)
+			require.Equal(t, tt.want.Failed, report.Failed)
+
+			require.Len(t, report.Rows, len(tt.rows))
+			for _, result := range report.Rows {
+				require.Equal(t, tt.errors[result.Line], result.Errors, result.Line)
+				require.Zero(t, result.BookID)
+				if tt.creates != nil {
+					require.Equal(t, tt.creates[result.Line], result.Creates, result.Line)
+				}
+			}
+		})
+	}
+}
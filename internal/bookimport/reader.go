@@ -0,0 +1,231 @@
+package bookimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/xuri/excelize/v2"
+)
+
+// 支持的文件格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("仅支持csv、xlsx文件")
+	ErrEmptyFile         = errors.New("文件没有数据")
+	ErrMissingColumn     = errors.New("缺少必要的表头")
+)
+
+// Columns 导入文件支持的表头(不区分大小写)，可用于生成导入模板；
+// categories 多个分类用 | 分隔，price 单位分，pubdate 格式 2006-01-02
+var Columns = []string{
+	"isbn", "title", "subtitle", "author", "publisher", "categories", "cover_url",
+	"pubdate", "price", "status", "type", "stock", "source_url", "description",
+}
+
+// 必须存在的表头
+var requiredColumns = []string{"isbn", "title", "author", "publisher"}
+
+// 支持的出版日期格式
+var dateLayouts = []string{"2006-01-02", "2006/01/02", "2006-1-2", "2006/1/2", "2006-01", "2006"}
+
+// FormatOf 根据文件名获取文件格式，不支持返回 ErrUnsupportedFormat
+func FormatOf(filename string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ReadRows 读取csv或xlsx(第一个工作表)，第一行为表头，返回解析后的数据行；
+// 单行的解析错误记录在 Row.Errors 中，不会中断读取
+func ReadRows(r io.Reader, format string) ([]*Row, error) {
+	var (
+		records [][]string
+		lines   []int // 每条记录在文件中的行号
+		err     error
+	)
+
+	switch format {
+	case FormatCSV:
+		records, lines, err = readCSV(r)
+	case FormatXLSX:
+		records, err = readXLSX(r)
+		for i := range records {
+			lines = append(lines, i+1)
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < 2 {
+		return nil, ErrEmptyFile
+	}
+
+	// 表头 => 列下标
+	header := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		header[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := header[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+
+	rows := make([]*Row, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if isBlank(record) {
+			continue
+		}
+		line := lines[i+1]
+
+		cell := func(name string) string {
+			idx, ok := header[name]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		rows = append(rows, parseRow(line, cell))
+	}
+
+	return rows, nil
+}
+
+// readCSV 读取csv所有记录及其行号，csv.Reader 会跳过空行，因此行号需要单独记录
+func readCSV(r io.Reader) ([][]string, []int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var (
+		records [][]string
+		lines   []int
+	)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return records, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+}
+
+// readXLSX 读取xlsx第一个工作表的所有行
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrEmptyFile
+	}
+
+	return f.GetRows(sheets[0])
+}
+
+// parseRow 解析一行数据，line 为文件中的行号(从1开始，包含表头)
+func parseRow(line int, cell func(name string) string) *Row {
+	row := &Row{
+		Line:      line,
+		Author:    cell("author"),
+		Publisher: cell("publisher"),
+		Errors:    make(map[string]string),
+	}
+
+	for name := range strings.SplitSeq(cell("categories"), "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			row.Categories = append(row.Categories, name)
+		}
+	}
+
+	book := &row.Book
+	book.ISBN = strings.ReplaceAll(cell("isbn"), "-", "")
+	book.Title = cell("title")
+	book.Subtitle = cell("subtitle")
+	book.CoverUrl = cell("cover_url")
+	book.SourceUrl = cell("source_url")
+	book.Description = cell("description")
+
+	if text := cell("pubdate"); text != "" {
+		if t, ok := parseDate(text); ok {
+			book.Pubdate = types.GxTime{Time: t}
+		} else {
+			row.Errors["pubdate"] = "出版日期格式错误，如：2006-01-02"
+		}
+	}
+
+	// 数字列为空时使用默认值
+	parseUint := func(name string, def uint64) uint64 {
+		text := cell(name)
+		if text == "" {
+			return def
+		}
+		n, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			row.Errors[name] = fmt.Sprintf("%s 必须是非负整数", name)
+		}
+		return n
+	}
+
+	book.Price = uint(parseUint("price", 0))
+	book.Status = int(parseUint("status", 0))
+	book.Type = int(parseUint("type", 1))
+	book.Stock = uint(parseUint("stock", 0))
+
+	return row
+}
+
+// parseDate 解析出版日期，支持常见格式和excel的日期序列号
+func parseDate(text string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	if serial, err := strconv.ParseFloat(text, 64); err == nil && serial > 0 {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// isBlank 是否是空行
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
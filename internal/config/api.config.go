@@ -1 +1,13 @@
 package config
+
+import "time"
+
+// APIConfig 前台接口服务配置
+type APIConfig struct {
+	DownloadSignKey    string        `env:"DOWNLOAD_SIGN_KEY"`    // 下载地址签名密钥，为空时随机生成
+	DownloadURLExpires time.Duration `env:"DOWNLOAD_URL_EXPIRES"` // 下载地址有效期，默认5分钟
+	DownloadDir        string        `env:"DOWNLOAD_DIR"`         // 本地存储时的电子书文件目录，默认 ./ebooks，与后台服务的 EBOOK_DIR 相同，source_url 不是http地址时为其中文件的 key
+	WatermarkCacheDir  string        `env:"WATERMARK_CACHE_DIR"`  // 本地存储时添加水印后的电子书缓存目录，默认 ./watermarks
+	PreviewDir         string        `env:"PREVIEW_DIR"`          // 本地存储时的试读文件目录，默认 ./previews，需要与后台服务相同
+	StoreBookURL       string        `env:"STORE_BOOK_URL"`       // 书城的图书购买页地址，{id} 替换为图书id，如 https://shop.example.com/book/{id}；为空时OPDS不提供购买链接
+}
@@ -24,3 +24,18 @@ type RedisConfig struct {
 	Password string `env:"REDIS_PASSWORD"`
 	DB       int    `env:"REDIS_DB"`
 }
+
+// StorageConfig 上传文件的存储配置
+type StorageConfig struct {
+	Driver string `env:"STORAGE_DRIVER"` // local(默认) 本地目录，s3 兼容S3的对象存储，如 AWS S3、MinIO
+
+	S3Endpoint       string        `env:"S3_ENDPOINT"`        // 服务地址，如 https://s3.us-east-1.amazonaws.com、http://127.0.0.1:9000
+	S3Region         string        `env:"S3_REGION"`          // 默认 us-east-1
+	S3Bucket         string        `env:"S3_BUCKET"`          // 存储桶
+	S3AccessKey      string        `env:"S3_ACCESS_KEY"`      // 访问密钥
+	S3SecretKey      string        `env:"S3_SECRET_KEY"`      // 访问密钥
+	S3PathStyle      bool          `env:"S3_PATH_STYLE"`      // 使用 endpoint/bucket/key 形式的地址，MinIO 需要设置为 true
+	S3PublicURL      string        `env:"S3_PUBLIC_URL"`      // 公开访问地址前缀，如CDN地址，使用s3时必填；只需要公开 covers、banners、icons、avatars 目录，ebooks、watermark、preview 目录不公开
+	S3PresignExpires time.Duration `env:"S3_PRESIGN_EXPIRES"` // 上传、读取、删除使用的预签名地址有效期，默认1小时
+	S3Timeout        time.Duration `env:"S3_TIMEOUT"`         // 连接、TLS握手和等待响应头的超时，默认30秒；不限制传输内容的时间
+}
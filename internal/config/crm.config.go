@@ -1,6 +1,26 @@
 package config
 
+import "time"
+
 type CRMConfig struct {
 	ServerPort int    `env:"SERVER_PORT"`
 	LogLevel   string `env:"LOGGER_LEVEL"`
+	ExportDir  string `env:"EXPORT_DIR"` // 后台导出文件保存目录，默认 ./exports
+	UploadDir  string `env:"UPLOAD_DIR"` // 上传图片保存目录，默认 ./uploads
+	UploadURL  string `env:"UPLOAD_URL"` // 上传图片的访问地址前缀，默认 /uploads 由后台服务提供，可配置为CDN地址
+	EbookDir   string `env:"EBOOK_DIR"`  // 本地存储时上传电子书的保存目录，默认 ./ebooks
+
+	ResumableDir string `env:"RESUMABLE_DIR"` // 断点续传未完成的数据目录，默认 ./resumable，多个实例需要共享
+
+	// 上传封面、轮播图时生成的缩略图
+	ImageWidths  string `env:"IMAGE_WIDTHS"`  // 生成的宽度，逗号分隔，默认 160,320,640,1080
+	ImageQuality int    `env:"IMAGE_QUALITY"` // JPEG 质量，默认 80
+	ImageFormat  string `env:"IMAGE_FORMAT"`  // auto(默认，不透明的为JPEG、透明的为无损WebP) 或 webp(全部为无损WebP)
+
+	// 回收没有引用的上传文件
+	FileGCInterval time.Duration `env:"FILE_GC_INTERVAL"` // 执行间隔，默认24小时，小于0时不自动执行
+	FileGCGrace    time.Duration `env:"FILE_GC_GRACE"`    // 没有引用后的保留期，默认7天
+
+	// 生成试读时从电子书的存储读取文件，试读文件需要与前台接口服务相同
+	PreviewDir string `env:"PREVIEW_DIR"` // 本地存储时的试读文件目录，默认 ./previews
 }
@@ -2,17 +2,19 @@ package dbrepo
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 
 	"github.com/lightsaid/ebook/internal/models"
 )
 
 type AuthorRepo interface {
+	TrashRepo
 	baseRepo
-	Create(ctx context.Context, authorName string) (uint64, error)
-	Update(ctx context.Context, id uint64, authorName string) error
+	Create(ctx context.Context, author *models.Author) (uint64, error)
+	Update(ctx context.Context, author *models.Author) error
+	Patch(ctx context.Context, old, author *models.Author) error // 部分更新，仅更新有变化的列
 	Get(ctx context.Context, id uint64) (*models.Author, error)
+	GetByName(ctx context.Context, name string) (*models.Author, error) // 根据名称获取，同名取最早创建的
 	List(ctx context.Context, f Filters) (*PageQueryVo, error)
 	Delete(ctx context.Context, id uint64) error
 }
@@ -30,13 +32,23 @@ func NewAuthorRepo(db Queryable) *authorRepo {
 	return repo
 }
 
-func (r *authorRepo) Create(ctx context.Context, authorName string) (uint64, error) {
-	sql := `insert author(author_name) values(:author_name);`
+// authorColumns 作者查询字段
+const authorColumns = "id, author_name, biography, photo, nationality, website, aliases, created_at, updated_at"
+
+func (r *authorRepo) Create(ctx context.Context, author *models.Author) (uint64, error) {
+	sql := `
+	insert author set
+		author_name=:author_name,
+		biography=:biography,
+		photo=:photo,
+		nationality=:nationality,
+		website=:website,
+		aliases=:aliases;`
 
 	ctx, cancal := dbtk.withTimeout(ctx)
 	defer cancal()
 
-	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, envelop{"author_name": authorName})
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, author)
 	if err != nil {
 		return 0, err
 	}
@@ -45,16 +57,21 @@ func (r *authorRepo) Create(ctx context.Context, authorName string) (uint64, err
 	return dbtk.insertErrorHandler(ctx, result, err)
 }
 
-func (r *authorRepo) Update(ctx context.Context, id uint64, authorName string) error {
-	sql := `update author set author_name=:author_name where id=:id and deleted_at is null;`
+func (r *authorRepo) Update(ctx context.Context, author *models.Author) error {
+	sql := `
+	update author set
+		author_name=:author_name,
+		biography=:biography,
+		photo=:photo,
+		nationality=:nationality,
+		website=:website,
+		aliases=:aliases
+	where id=:id and deleted_at is null;`
 
 	ctx, cancal := dbtk.withTimeout(ctx)
 	defer cancal()
 
-	query, args, err := dbtk.debugSQL(
-		ctx, r.DB, sql,
-		envelop{"author_name": authorName, "id": id},
-	)
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, author)
 	if err != nil {
 		return err
 	}
@@ -63,10 +80,24 @@ func (r *authorRepo) Update(ctx context.Context, id uint64, authorName string) e
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
 
+// authorPatchColumns 允许部分更新的列
+var authorPatchColumns = []string{"author_name", "biography", "photo", "nationality", "website", "aliases"}
+
+// Patch 部分更新，仅更新 old 和 author 之间有变化的列，没有变化则不执行更新
+func (r *authorRepo) Patch(ctx context.Context, old, author *models.Author) error {
+	changes := changedColumns(old, author, authorPatchColumns)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	result, err := dbtk.patch(ctx, r.DB, "author", changes, "id=:id and deleted_at is null", map[string]any{"id": old.ID})
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
 func (r *authorRepo) Get(ctx context.Context, id uint64) (author *models.Author, err error) {
 	sql := `
 		select 
-			id, author_name, created_at, updated_at 
+			` + authorColumns + ` 
 		from 
 			author 
 		where 
@@ -80,55 +111,60 @@ func (r *authorRepo) Get(ctx context.Context, id uint64) (author *models.Author,
 	return author, err
 }
 
-// List 分页获取
-func (r *authorRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
-	sortFields := f.sortColumnWithDefault(r)
-
-	sql := fmt.Sprintf(
-		`select 
-			*, 
-			(select count(*) from author where deleted_at is null) as total
-		 from author where deleted_at is null
-		 order by %s
-		 limit ? offset ?`, sortFields,
-	)
+// GetByName 根据名称获取，不存在返回 sql.ErrNoRows
+func (r *authorRepo) GetByName(ctx context.Context, name string) (*models.Author, error) {
+	sql := `
+		select 
+			` + authorColumns + ` 
+		from 
+			author 
+		where 
+			author_name=? and deleted_at is null 
+		order by id limit 1;`
 
 	ctx, cancal := dbtk.withTimeout(ctx)
 	defer cancal()
 
-	query := r.DB.Rebind(sql)
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "name", name)
 
-	slog.DebugContext(
-		ctx,
-		spaceRex.ReplaceAllString(query, " "),
-		slog.String("sort", sortFields),
-		slog.Int("limit", f.limit()),
-		slog.Int("offset", f.offset()),
-	)
+	author := new(models.Author)
+	err := r.DB.GetContext(ctx, author, r.DB.Rebind(sql), name)
+	return author, err
+}
 
-	type SQLAutor struct {
-		models.Author
-		Total int `db:"total"`
-	}
+// authorListQuery 作者列表查询
+var authorListQuery = listQuery{
+	columns: authorColumns,
+	from:    "from author",
+	where:   []string{"deleted_at is null"},
+}
+
+// List 分页获取，支持 Filters.Conditions 查询条件
+func (r *authorRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
 
-	arr := make([]*SQLAutor, 0)
+	q, err := authorListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
 
-	err := r.DB.SelectContext(ctx, &arr, query, f.limit(), f.offset())
+	total, err := q.count(ctx, r.DB)
 	if err != nil {
 		return nil, err
 	}
 
-	list := make([]*models.Author, 0, len(arr))
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
 
-	for _, x := range arr {
-		list = append(list, &x.Author)
-	}
+	list := make([]*models.Author, 0, f.limit())
 
-	var metadata Metadata
-	if len(arr) > 0 {
-		metadata = dbtk.calculateMetadata(arr[0].Total, f.PageNum, f.PageSize)
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
 	}
 
+	list, metadata := pageResult(q, f, total, list)
+
 	vo := dbtk.makePageQueryVo(metadata, list)
 
 	return vo, err
@@ -154,3 +190,35 @@ func (r *authorRepo) defaultSortSafelist() []string {
 		"-id", "-author_name", "-created_at", "-updated_at",
 	}
 }
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *authorRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":          "id",
+		"author_name": "author_name",
+		"nationality": "nationality",
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+	}
+}
+
+// authorTrash 作者回收站
+var authorTrash = trash[models.Author]{
+	table:   "author",
+	columns: authorColumns + ", deleted_at",
+}
+
+// ListDeleted 分页获取已删除的数据
+func (r *authorRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return authorTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *authorRepo) Restore(ctx context.Context, id uint64) error {
+	return authorTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *authorRepo) Purge(ctx context.Context, id uint64) error {
+	return authorTrash.purge(ctx, r.DB, id)
+}
@@ -8,8 +8,10 @@ import (
 )
 
 type BannerRepo interface {
+	TrashRepo
 	Create(ctx context.Context, banner *models.Banner) (uint64, error)
 	Update(ctx context.Context, banner *models.Banner) error
+	Patch(ctx context.Context, old, banner *models.Banner) error // 部分更新，仅更新有变化的列
 	Get(ctx context.Context, id uint64) (*models.Banner, error)
 	List(ctx context.Context) ([]*models.Banner, error)
 	Delete(ctx context.Context, id uint64) error
@@ -65,6 +67,20 @@ func (r *bannerRepo) Update(ctx context.Context, banner *models.Banner) error {
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
 
+// bannerPatchColumns 允许部分更新的列
+var bannerPatchColumns = []string{"slogan", "link_type", "link_url", "image_url", "enable", "sort"}
+
+// Patch 部分更新，仅更新 old 和 banner 之间有变化的列，没有变化则不执行更新
+func (r *bannerRepo) Patch(ctx context.Context, old, banner *models.Banner) error {
+	changes := changedColumns(old, banner, bannerPatchColumns)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	result, err := dbtk.patch(ctx, r.DB, "banners", changes, "id=:id and deleted_at is null", map[string]any{"id": old.ID})
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
 func (r *bannerRepo) Get(ctx context.Context, id uint64) (banners *models.Banner, err error) {
 	sql := `select * from banners where id = ? and deleted_at is null;`
 
@@ -106,3 +122,24 @@ func (r *bannerRepo) Delete(ctx context.Context, id uint64) error {
 	result, err := r.DB.ExecContext(ctx, query, id)
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
+
+// bannerTrash banner回收站
+var bannerTrash = trash[models.Banner]{
+	table:   "banners",
+	columns: "id, slogan, link_type, link_url, image_url, enable, sort, created_at, updated_at, deleted_at",
+}
+
+// ListDeleted 分页获取已删除的数据
+func (r *bannerRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return bannerTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *bannerRepo) Restore(ctx context.Context, id uint64) error {
+	return bannerTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *bannerRepo) Purge(ctx context.Context, id uint64) error {
+	return bannerTrash.purge(ctx, r.DB, id)
+}
@@ -0,0 +1,96 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type BookPreviewRepo interface {
+	Get(ctx context.Context, bookID uint64) (*models.BookPreview, error)
+	Save(ctx context.Context, preview *models.BookPreview) error          // 新增或更新试读配置
+	Generated(ctx context.Context, bookID uint64, sourceUrl string) error // 记录生成试读的时间和文件地址
+	Delete(ctx context.Context, bookID uint64) error
+}
+
+var _ BookPreviewRepo = (*bookPreviewRepo)(nil)
+
+type bookPreviewRepo struct {
+	DB Queryable
+}
+
+func NewBookPreviewRepo(db Queryable) *bookPreviewRepo {
+	repo := &bookPreviewRepo{
+		DB: db,
+	}
+
+	return repo
+}
+
+func (r *bookPreviewRepo) Get(ctx context.Context, bookID uint64) (*models.BookPreview, error) {
+	sql := `
+		select
+			book_id, mode, value, source_url, generated_at, created_at, updated_at
+		from book_previews where book_id = ?;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "bookID", bookID)
+
+	preview := new(models.BookPreview)
+	err := r.DB.GetContext(ctx, preview, r.DB.Rebind(sql), bookID)
+	return preview, err
+}
+
+// Save 新增或更新试读配置，配置变化后需要重新生成试读
+func (r *bookPreviewRepo) Save(ctx context.Context, preview *models.BookPreview) error {
+	sql := `
+	insert into book_previews(book_id, mode, value) values(:book_id, :mode, :value)
+	on duplicate key update mode = values(mode), value = values(value);`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, preview)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+func (r *bookPreviewRepo) Generated(ctx context.Context, bookID uint64, sourceUrl string) error {
+	sql := `
+	update book_previews set source_url = :source_url, generated_at = current_timestamp
+	where book_id = :book_id;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	arg := map[string]any{"book_id": bookID, "source_url": sourceUrl}
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, arg)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+func (r *bookPreviewRepo) Delete(ctx context.Context, bookID uint64) error {
+	sql := `delete from book_previews where book_id = :book_id;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, map[string]any{"book_id": bookID})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
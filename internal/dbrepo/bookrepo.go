@@ -1,30 +1,43 @@
 package dbrepo
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lightsaid/ebook/internal/models"
 )
 
 type BookRepo interface {
+	TrashRepo
 	baseRepo
 	Create(ctx context.Context, book *models.Book) (uint64, error)
 	CreateTx(ctx context.Context, book *models.Book) (uint64, error)
 	Get(ctx context.Context, id uint64) (*models.Book, error)
-	Update(ctx context.Context, book *models.Book) error   // 仅更新books表
-	UpdateTx(ctx context.Context, book *models.Book) error // 更新图书和与之关联的分类、出版社、作者
+	Update(ctx context.Context, book *models.Book) error     // 仅更新books表，校验版本号，版本不一致返回 ErrVersionConflict
+	UpdateTx(ctx context.Context, book *models.Book) error   // 更新图书和与之关联的分类、出版社、作者，同样校验版本号
+	Patch(ctx context.Context, old, book *models.Book) error // 部分更新books表，仅更新有变化的列，同样校验版本号
 	List(context.Context, Filters) (*PageQueryVo, error)
-	ListByCategory(ctx context.Context, categoryID uint64, f Filters) (*PageQueryVo, error)
+	ListByCategory(ctx context.Context, categoryID uint64, descendants bool, f Filters) (*PageQueryVo, error) // descendants 为true时包括子孙分类的图书
 	ListWithCategory(ctx context.Context, filter Filters) (*PageQueryVo, error)
-	ListByAuthor(ctx context.Context, authorID uint64, filter Filters) (*PageQueryVo, error)
+	ListByAuthor(ctx context.Context, authorID uint64, filter Filters) (*PageQueryVo, error) // 作者、译者等任意角色的贡献者
 	ListByPublisher(ctx context.Context, publisherID uint64, filter Filters) (*PageQueryVo, error)
 	Delete(ctx context.Context, id uint64) error
+	DeleteVersion(ctx context.Context, id uint64, version int) error // 版本号一致才删除，否则返回 ErrVersionConflict
+
+	// ExistingISBNs 返回 isbns 中已被未删除图书使用的isbn
+	ExistingISBNs(ctx context.Context, isbns []string) ([]string, error)
+
+	ListContributors(ctx context.Context, bookID uint64) ([]*models.BookContributor, error)
+	SaveContributors(ctx context.Context, bookID uint64, list []*models.BookContributor) error // 替换图书的全部贡献者
 }
 
 var _ BookRepo = (*bookRepo)(nil)
@@ -55,6 +68,7 @@ func bookFieldToSQLArgs(book *models.Book) map[string]any {
 		"stock":        book.Stock,
 		"source_url":   book.SourceUrl,
 		"description":  book.Description,
+		"version":      book.Version,
 	}
 
 	if book.ID > 0 {
@@ -94,23 +108,30 @@ func (r *bookRepo) Create(ctx context.Context, book *models.Book) (uint64, error
 	return dbtk.insertErrorHandler(ctx, result, err)
 }
 
-// CreateTx
+// CreateTx 通过事务创建图书，同时保存贡献者和分类
 func (r *bookRepo) CreateTx(ctx context.Context, book *models.Book) (uint64, error) {
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	// 不存在分类
-	if book.Categories != nil && len(book.Categories) == 0 {
-		return r.Create(ctx, book)
-	}
+	contributors := bookContributors(book)
+
 	var bookID uint64
-	// 存在分类
 	err := dbtk.execTx(ctx, r.DB, func(r Repository) error {
 		var err error
 		bookID, err = r.BookRepo.Create(ctx, book)
 		if err != nil {
 			return err
 		}
+
+		if err = r.BookRepo.SaveContributors(ctx, bookID, contributors); err != nil {
+			return err
+		}
+
+		// 不存在分类
+		if len(book.Categories) == 0 {
+			return nil
+		}
+
 		list := make([]models.BookCategory, 0, len(book.Categories))
 		for _, x := range book.Categories {
 			list = append(list, models.BookCategory{BookID: bookID, CategoryID: x.ID})
@@ -186,6 +207,16 @@ func (r *bookRepo) Get(ctx context.Context, id uint64) (book *models.Book, err e
 	book = &queryBook.Book
 	book.Categories = categories
 
+	err = r.listContributorsByBooks(ctx, []*models.Book{book})
+	if err != nil {
+		return book, err
+	}
+
+	book.Series, err = bookSeries(ctx, r.DB, book.ID)
+	if err != nil {
+		return book, err
+	}
+
 	// by, _ := json.MarshalIndent(book, "", "\t")
 	// fmt.Println(string(by))
 
@@ -199,6 +230,7 @@ func (r *bookRepo) Get(ctx context.Context, id uint64) (book *models.Book, err e
 	return book, err
 }
 
+// Update 乐观锁更新图书，book.Version 必须是客户端读取时的版本号，更新成功后 book.Version 自增
 func (r *bookRepo) Update(ctx context.Context, book *models.Book) error {
 	sql := `
 	update books set 
@@ -214,8 +246,9 @@ func (r *bookRepo) Update(ctx context.Context, book *models.Book) error {
 		type=:type,
 		stock=:stock,
 		source_url=:source_url,
-		description=:description
-	where id=:id and deleted_at is null;
+		description=:description,
+		version=version+1
+	where id=:id and version=:version and deleted_at is null;
 	`
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
@@ -240,14 +273,93 @@ func (r *bookRepo) Update(ctx context.Context, book *models.Book) error {
 	}
 
 	result, err := r.DB.ExecContext(ctx, query, argv...)
-	return dbtk.updateErrorHandler(ctx, result, err)
+	if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+		return err
+	}
+
+	// NOTE: version=version+1 每次都会改变数据，因此影响行数为0说明版本号不一致或数据不存在
+	if eff, _ := result.RowsAffected(); eff == 0 {
+		return ErrVersionConflict
+	}
+
+	book.Version++
+
+	return nil
+}
+
+// bookPatchColumns 允许部分更新的列
+var bookPatchColumns = []string{
+	"isbn", "title", "subtitle", "author_id", "cover_url", "publisher_id", "pubdate",
+	"price", "status", "type", "stock", "source_url", "description",
+}
+
+// Patch 部分更新图书，仅更新 old 和 book 之间有变化的列；
+// book.Version 是客户端读取时的版本号，更新成功后 book.Version 自增；
+// 贡献者或第一作者有变化时，在事务中同时替换贡献者
+func (r *bookRepo) Patch(ctx context.Context, old, book *models.Book) error {
+	contributors, changed := patchedContributors(old, book)
+	if !changed {
+		return patchBook(ctx, r.DB, old, book, false)
+	}
+
+	// 事务回滚时还原版本号
+	version := book.Version
+
+	err := dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		if err := patchBook(ctx, tx.db, old, book, true); err != nil {
+			return err
+		}
+		return tx.BookRepo.SaveContributors(ctx, old.ID, contributors)
+	})
+	if err != nil {
+		book.Version = version
+	}
+	return err
 }
 
-// UpdateTx 通过事务更新图书，同时更新bookCategory表
+// patchBook 部分更新books表，force 为true时即使没有变化的列也自增版本号
+func patchBook(ctx context.Context, db Queryable, old, book *models.Book, force bool) error {
+	changes := changedColumns(old, book, bookPatchColumns)
+	if len(changes) == 0 && !force {
+		// 没有变化不更新，但版本号不一致依然视为冲突
+		if book.Version != old.Version {
+			return ErrVersionConflict
+		}
+		return nil
+	}
+
+	result, err := dbtk.patch(
+		ctx,
+		db,
+		"books",
+		changes,
+		"id=:id and version=:version and deleted_at is null",
+		map[string]any{"id": old.ID, "version": book.Version},
+		"version=version+1",
+	)
+	if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+		return err
+	}
+
+	if eff, _ := result.RowsAffected(); eff == 0 {
+		return ErrVersionConflict
+	}
+
+	book.Version++
+
+	return nil
+}
+
+// UpdateTx 通过事务更新图书，同时更新贡献者和bookCategory表
 func (r *bookRepo) UpdateTx(ctx context.Context, book *models.Book) error {
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
+	// 事务回滚时还原版本号
+	version := book.Version
+
+	contributors := bookContributors(book)
+
 	err := dbtk.execTx(context.Background(), r.DB, func(r Repository) error {
 		// 更新图书
 		err := r.BookRepo.Update(ctx, book)
@@ -256,6 +368,13 @@ func (r *bookRepo) UpdateTx(ctx context.Context, book *models.Book) error {
 			return err
 		}
 
+		// 替换贡献者
+		err = r.BookRepo.SaveContributors(ctx, book.ID, contributors)
+		if err != nil {
+			slog.ErrorContext(ctx, "[UpdateTx]->[r.BookRepo.SaveContributors] fail: ", slog.String("err", err.Error()))
+			return err
+		}
+
 		// 删除图书和分类关系（book_categories）
 		err = r.BookCategoryRepo.DeleteByBookID(ctx, book.ID)
 		if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -276,25 +395,51 @@ func (r *bookRepo) UpdateTx(ctx context.Context, book *models.Book) error {
 		// 添加新的对应关系
 		return r.BookCategoryRepo.BatchInsert(ctx, list)
 	})
+	if err != nil {
+		book.Version = version
+	}
 	return err
 }
 
-// List 分页查询图书，不包括分类信息
+// bookListQuery 图书列表查询，分类是一对多关系，仅按分类查询时才join
+var bookListQuery = listQuery{
+	/* NOTE: sqlx 查询嵌套结构体字段语法
+	author_name as "author.author_name",
+	publisher_name as "publisher.publisher_name"
+	*/
+	columns: `
+		b.*, 
+		a.id as "author.id",
+		a.author_name as "author.author_name", 
+		p.id as "publisher.id",
+		p.publisher_name as "publisher.publisher_name"`,
+	from: `
+	from books b
+	left join author a on a.id = b.author_id
+	left join publisher p on p.id = b.publisher_id`,
+	where: []string{"b.deleted_at is null"},
+	joins: map[string]string{
+		"category_id":      "left join book_categories bc on b.id = bc.book_id",
+		"contributor_id":   "left join book_contributors bcn on b.id = bcn.book_id",
+		"contributor_role": "left join book_contributors bcn on b.id = bcn.book_id",
+	},
+	groupBy: "b.id",
+}
+
+// List 分页查询图书，包括贡献者但不包括分类信息，支持 Filters.Conditions 查询条件和游标分页
 func (r *bookRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	// 查询总数
-	totalSQL := r.DB.Rebind(`
-	select count(*) as total from books b
-	left join author a on a.id=b.author_id
-	left join publisher p on p.id=b.publisher_id
-	where b.deleted_at is null`)
+	q, err := bookListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
 
 	var total int
 	var vo PageQueryVo
 
-	err := r.DB.GetContext(ctx, &total, totalSQL)
+	total, err = q.count(ctx, r.DB)
 	if err != nil {
 		// 暂无数据
 		if errors.Is(err, sql.ErrNoRows) {
@@ -303,97 +448,46 @@ func (r *bookRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
 		return nil, err
 	}
 
-	/* NOTE: sqlx 查询嵌套结构体字段语法
-	author_name as "author.author_name",
-	publisher_name as "publisher.publisher_name"
-	*/
-
-	if len(f.SortSafelist) == 0 {
-		f.SortSafelist = r.defaultSortSafelist()
-	}
-
-	query := fmt.Sprintf(`
-	select 
-		b.*, 
-		a.id as "author.id",
-		author_name as "author.author_name", 
-		p.id as "publisher.id",
-		publisher_name as "publisher.publisher_name" 
-	from books b
-	left join author a on a.id=b.author_id
-	left join publisher p on p.id=b.publisher_id
-	where b.deleted_at is null order by %s limit ? offset ?`, f.sortColumnWithDefault(r))
-
-	query = r.DB.Rebind(query)
+	// NOTE: 排序不能使用占位符，因为会解析为：ORDER BY 'id DESC,updated_at DESC'（把整个排序当成一个字符串常量）
 
 	list := make([]*models.Book, 0, f.limit())
 
-	slog.InfoContext(
-		ctx, spaceRex.ReplaceAllString(query, " "),
-		"limit", slog.IntValue(f.limit()),
-		"offset", slog.IntValue(f.offset()))
+	slog.InfoContext(ctx, q.pageSQL, "args", slog.AnyValue(q.pageArgs))
 
-	err = r.DB.SelectContext(ctx, &list, query, f.limit(), f.offset())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
 
+	list, vo.Metadata = pageResult(q, f, total, list)
 	vo.List = list
-	vo.Metadata = dbtk.calculateMetadata(total, f.PageNum, f.PageSize)
+
+	err = r.listContributorsByBooks(ctx, list)
 
 	return &vo, err
 }
 
-// ListByCategory 根据分类查询图书
-func (r *bookRepo) ListByCategory(ctx context.Context, categoryID uint64, f Filters) (*PageQueryVo, error) {
-	totalSQL := `
-		select count(*) as total from books b 
-		left join book_categories bc on b.id = bc.book_id
-		left join category c on c.id = bc.category_id
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where bc.category_id = ? and b.deleted_at is null;`
-
-	var total int
-	var vo PageQueryVo
+// ListByCategory 根据分类查询图书，descendants 为true时包括子孙分类的图书
+func (r *bookRepo) ListByCategory(ctx context.Context, categoryID uint64, descendants bool, f Filters) (*PageQueryVo, error) {
+	if !descendants {
+		f.Conditions = append(f.Conditions, Eq("category_id", categoryID))
+		return r.List(ctx, f)
+	}
 
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	err := r.DB.GetContext(ctx, &total, totalSQL, categoryID)
+	ids, err := categoryDescendants(ctx, r.DB, categoryID)
 	if err != nil {
-		// 暂无数据
-		if errors.Is(err, sql.ErrNoRows) {
-			return &vo, nil
-		}
 		return nil, err
 	}
 
-	if len(f.SortSafelist) == 0 {
-		f.SortSafelist = r.defaultSortSafelist()
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
 	}
-
-	query := fmt.Sprintf(`
-		select 
-			b.*, 
-			a.id as "author.id",
-			author_name as "author.author_name", 
-			p.id as "publisher.id",
-			publisher_name as "publisher.publisher_name"
-		from books b 
-		left join book_categories bc on b.id = bc.book_id
-		left join category c on c.id = bc.category_id
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where bc.category_id = ? and b.deleted_at is null 
-		order by %s`, f.sortColumnWithDefault(r))
-
-	query = r.DB.Rebind(query)
-
-	list := make([]*models.Book, 0, f.PageSize)
-
-	err = r.DB.SelectContext(ctx, &list, query, categoryID)
-	vo.List = list
-	vo.Metadata = dbtk.calculateMetadata(total, f.PageNum, f.PageSize)
-
-	return &vo, err
+	f.Conditions = append(f.Conditions, In("category_id", values...))
+	return r.List(ctx, f)
 }
 
 // listCategoryByBooks 根据图书查询分类
@@ -550,140 +644,320 @@ func (r *bookRepo) ListWithCategory(ctx context.Context, filter Filters) (*PageQ
 	// return list, nil
 }
 
+// ListByAuthor 根据作者查询图书和分类，作者是图书任意角色的贡献者即可
 func (r *bookRepo) ListByAuthor(ctx context.Context, authorID uint64, f Filters) (*PageQueryVo, error) {
-	// 如果没有使用默认的
-	if len(f.SortSafelist) == 0 {
-		f.SortSafelist = r.defaultSortSafelist()
-	}
+	f.Conditions = append(f.Conditions, Eq("contributor_id", authorID))
+	return r.ListWithCategory(ctx, f)
+}
+
+// ListByPublisher 根据出版社查询图书和分类
+func (r *bookRepo) ListByPublisher(ctx context.Context, publisherID uint64, f Filters) (*PageQueryVo, error) {
+	f.Conditions = append(f.Conditions, Eq("publisher_id", publisherID))
+	return r.ListWithCategory(ctx, f)
+}
 
+func (r *bookRepo) Delete(ctx context.Context, id uint64) error {
+	sql := `update books set deleted_at=now() where id=?`
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	totalSQL := `select count(*) as total from books b 
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where a.id = ? and b.deleted_at is null
-		`
+	query := r.DB.Rebind(sql)
+	slog.DebugContext(ctx, query, slog.Int64("id", int64(id)))
+
+	result, err := r.DB.ExecContext(ctx, query, id)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
 
-	var total int
-	var vo PageQueryVo
+// DeleteVersion 在同一条语句中校验版本号并软删除，避免先查询再删除时覆盖并发的更新；
+// 版本号不一致或图书不存在返回 ErrVersionConflict
+func (r *bookRepo) DeleteVersion(ctx context.Context, id uint64, version int) error {
+	sql := `update books set deleted_at=now() where id=? and version=? and deleted_at is null`
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
 
-	err := r.DB.GetContext(ctx, &total, totalSQL, authorID)
+	query := r.DB.Rebind(sql)
+	slog.DebugContext(ctx, query, slog.Int64("id", int64(id)), slog.Int("version", version))
+
+	result, err := r.DB.ExecContext(ctx, query, id, version)
+	if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+		return err
+	}
+
+	if eff, _ := result.RowsAffected(); eff == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// sortSafelist 导出默认的安全排序字段
+func (r *bookRepo) defaultSortSafelist() []string {
+	return []string{
+		"id", "isbn", "title", "subtitle", "author_id", "publisher_id", "pubdate",
+		"price", "status", "type", "stock", "created_at", "updated_at",
+
+		"-id", "-isbn", "-title", "-subtitle", "-author_id", "-publisher_id",
+		"-pubdate", "-price", "-status", "-type", "-stock", "-created_at", "-updated_at",
+	}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *bookRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":               "b.id",
+		"isbn":             "b.isbn",
+		"title":            "b.title",
+		"subtitle":         "b.subtitle",
+		"author_id":        "b.author_id",
+		"publisher_id":     "b.publisher_id",
+		"pubdate":          "b.pubdate",
+		"price":            "b.price",
+		"status":           "b.status",
+		"type":             "b.type",
+		"stock":            "b.stock",
+		"created_at":       "b.created_at",
+		"updated_at":       "b.updated_at",
+		"author_name":      "a.author_name",
+		"publisher_name":   "p.publisher_name",
+		"category_id":      "bc.category_id",
+		"contributor_id":   "bcn.author_id",
+		"contributor_role": "bcn.role",
+	}
+}
+
+// ExistingISBNs 返回 isbns 中已被未删除图书使用的isbn
+func (r *bookRepo) ExistingISBNs(ctx context.Context, isbns []string) ([]string, error) {
+	list := make([]string, 0)
+	if len(isbns) == 0 {
+		return list, nil
+	}
+
+	query, args, err := sqlx.In(`select isbn from books where isbn in (?) and deleted_at is null;`, isbns)
 	if err != nil {
-		// 暂无数据
-		if errors.Is(err, sql.ErrNoRows) {
-			return &vo, nil
-		}
 		return nil, err
 	}
 
-	query := fmt.Sprintf(`
-		select 
-			b.*, 
-			a.id as "author.id",
-			author_name as "author.author_name", 
-			p.id as "publisher.id",
-			publisher_name as "publisher.publisher_name"
-		from books b 
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where a.id = ? and b.deleted_at is null
-		order by %s limit ? offset ?
-		`, f.sortColumnWithDefault(r))
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
 
-	query = r.DB.Rebind(query)
+	slog.DebugContext(ctx, query, slog.Int("count", len(isbns)))
 
-	// NOTE: 排序不能使用占位符，因为会解析为：ORDER BY 'id DESC,updated_at DESC'（把整个排序当成一个字符串常量）
+	err = r.DB.SelectContext(ctx, &list, r.DB.Rebind(query), args...)
+	return list, err
+}
 
-	list := make([]*models.Book, 0, f.PageSize)
+// bookTrash 图书回收站
+var bookTrash = trash[models.Book]{
+	table: "books",
+	columns: "id, isbn, title, subtitle, author_id, cover_url, publisher_id, pubdate, price, " +
+		"status, type, stock, source_url, description, version, created_at, updated_at, deleted_at",
+	beforeRestore: bookBeforeRestore,
+}
 
-	err = r.DB.SelectContext(ctx, &list, query, authorID, f.limit(), f.offset())
+// ListDeleted 分页获取已删除的数据
+func (r *bookRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return bookTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *bookRepo) Restore(ctx context.Context, id uint64) error {
+	return bookTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *bookRepo) Purge(ctx context.Context, id uint64) error {
+	return bookTrash.purge(ctx, r.DB, id)
+}
+
+// bookBeforeRestore 图书的作者、出版社都未删除才能恢复
+func bookBeforeRestore(ctx context.Context, db Queryable, id uint64) error {
+	sql := `
+	select 
+		a.deleted_at is null and p.deleted_at is null
+	from 
+		books b 
+		join author a on b.author_id = a.id
+		join publisher p on b.publisher_id = p.id
+	where 
+		b.id = ? and b.deleted_at is not null;`
+
+	var ok bool
+	err := db.GetContext(ctx, &ok, db.Rebind(sql), id)
 	if err != nil {
-		return &vo, err
+		return err
 	}
+	if !ok {
+		return fmt.Errorf("%w: 请先恢复图书的作者和出版社", ErrRestoreDependency)
+	}
+	return nil
+}
 
-	list, err = r.listCategoryByBooks(ctx, list)
-	vo.List = list
-	vo.Metadata = dbtk.calculateMetadata(total, f.PageNum, f.PageSize)
+// ListContributors 查询图书的贡献者，按 sort 排序
+func (r *bookRepo) ListContributors(ctx context.Context, bookID uint64) ([]*models.BookContributor, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
 
-	return &vo, err
-}
+	group, err := r.contributorsByBooks(ctx, []uint64{bookID})
+	if err != nil {
+		return nil, err
+	}
 
-func (r *bookRepo) ListByPublisher(ctx context.Context, publisherID uint64, f Filters) (*PageQueryVo, error) {
-	// 如果没有使用默认的
-	if len(f.SortSafelist) == 0 {
-		f.SortSafelist = r.defaultSortSafelist()
+	list := group[bookID]
+	if list == nil {
+		list = make([]*models.BookContributor, 0)
 	}
+	return list, nil
+}
 
+// SaveContributors 替换图书的全部贡献者，需要和图书的新增、更新在同一个事务中执行
+func (r *bookRepo) SaveContributors(ctx context.Context, bookID uint64, list []*models.BookContributor) error {
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	totalSQL := `select count(*) as total from books b 
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where p.id = ? and b.deleted_at is null`
+	query := r.DB.Rebind(`delete from book_contributors where book_id=?`)
+	slog.DebugContext(ctx, query, "bookID", bookID)
 
-	var total int
-	var vo PageQueryVo
+	if _, err := r.DB.ExecContext(ctx, query, bookID); err != nil {
+		return err
+	}
 
-	err := r.DB.GetContext(ctx, &total, totalSQL, publisherID)
-	if err != nil {
-		// 暂无数据
-		if errors.Is(err, sql.ErrNoRows) {
-			return &vo, nil
-		}
-		return nil, err
+	if len(list) == 0 {
+		return nil
 	}
 
-	query := fmt.Sprintf(`
-		select 
-			b.*, 
+	sql := `insert into book_contributors(book_id, author_id, role, sort) values `
+	parts := make([]string, len(list))
+	args := make([]any, 0, len(list)*4)
+	for i, x := range list {
+		parts[i] = "(?, ?, ?, ?)"
+		args = append(args, bookID, x.AuthorID, x.Role, x.Sort)
+	}
+	sql += strings.Join(parts, ",")
+
+	query = r.DB.Rebind(sql)
+	slog.InfoContext(ctx, query, "args", slog.AnyValue(args))
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+// contributorsByBooks 批量查询图书的贡献者，图书id => 贡献者
+func (r *bookRepo) contributorsByBooks(ctx context.Context, bookIDs []uint64) (map[uint64][]*models.BookContributor, error) {
+	group := make(map[uint64][]*models.BookContributor, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return group, nil
+	}
+
+	query, arg, err := sqlx.In(
+		`select
+			bcn.book_id, bcn.author_id, bcn.role, bcn.sort,
 			a.id as "author.id",
-			author_name as "author.author_name", 
-			p.id as "publisher.id",
-			publisher_name as "publisher.publisher_name"
-		from books b 
-		left join author a on a.id = b.author_id
-		left join publisher p on p.id = b.publisher_id
-		where p.id = ? and b.deleted_at is null
-		order by %s limit ? offset ?
-		`, f.sortColumnWithDefault(r))
+			a.author_name as "author.author_name"
+		from book_contributors bcn
+		join author a on a.id = bcn.author_id
+		where bcn.book_id in (?)
+		order by bcn.book_id, bcn.sort;`,
+		bookIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	query = r.DB.Rebind(query)
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(query, " "), "args", slog.AnyValue(arg))
 
-	list := make([]*models.Book, 0, f.PageSize)
+	var list []*models.BookContributor
+	if err = r.DB.SelectContext(ctx, &list, query, arg...); err != nil {
+		return nil, err
+	}
 
-	err = r.DB.SelectContext(ctx, &list, query, publisherID, f.limit(), f.offset())
-	if err != nil {
-		return &vo, err
+	for _, x := range list {
+		group[x.BookID] = append(group[x.BookID], x)
 	}
+	return group, nil
+}
 
-	list, err = r.listCategoryByBooks(ctx, list)
-	vo.List = list
-	vo.Metadata = dbtk.calculateMetadata(total, f.PageNum, f.PageSize)
+// listContributorsByBooks 查询并设置图书的贡献者，
+// 没有贡献者记录的图书(如直接调用 Create 创建的)以 author_id 作为唯一作者
+func (r *bookRepo) listContributorsByBooks(ctx context.Context, list []*models.Book) error {
+	bookIDs := make([]uint64, 0, len(list))
+	for _, x := range list {
+		bookIDs = append(bookIDs, x.ID)
+	}
 
-	return &vo, err
+	group, err := r.contributorsByBooks(ctx, bookIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range list {
+		book.Contributors = group[book.ID]
+		if len(book.Contributors) == 0 {
+			book.Contributors = []*models.BookContributor{{
+				BookID:   book.ID,
+				AuthorID: book.AuthorID,
+				Role:     models.RoleAuthor,
+				Author:   book.Author,
+			}}
+		}
+	}
+	return nil
 }
 
-func (r *bookRepo) Delete(ctx context.Context, id uint64) error {
-	sql := `update books set deleted_at=now() where id=?`
-	ctx, cancel := dbtk.withTimeout(ctx)
-	defer cancel()
+// bookContributors 整理要保存的贡献者：没有作者角色时以 book.AuthorID 作为第一作者，
+// 按 sort 稳定排序后重新编号；同时以第一作者同步 book.AuthorID，兼容只有一个作者的旧接口
+func bookContributors(book *models.Book) []*models.BookContributor {
+	list := make([]*models.BookContributor, 0, len(book.Contributors)+1)
+	for _, x := range book.Contributors {
+		if x != nil {
+			list = append(list, &models.BookContributor{AuthorID: x.AuthorID, Role: x.Role, Sort: x.Sort})
+		}
+	}
 
-	query := r.DB.Rebind(sql)
-	slog.DebugContext(ctx, query, slog.Int64("id", int64(id)))
+	slices.SortStableFunc(list, func(a, b *models.BookContributor) int {
+		return cmp.Compare(a.Sort, b.Sort)
+	})
 
-	result, err := r.DB.ExecContext(ctx, query, id)
-	return dbtk.updateErrorHandler(ctx, result, err)
+	if models.FirstAuthor(list) == 0 && book.AuthorID > 0 {
+		list = slices.Insert(list, 0, &models.BookContributor{AuthorID: book.AuthorID, Role: models.RoleAuthor})
+	}
+
+	for i, x := range list {
+		x.BookID, x.Sort = book.ID, i
+	}
+
+	if id := models.FirstAuthor(list); id > 0 {
+		book.AuthorID = id
+	}
+	book.Contributors = list
+
+	return list
 }
 
-// sortSafelist 导出默认的安全排序字段
-func (r *bookRepo) defaultSortSafelist() []string {
-	return []string{
-		"id", "isbn", "title", "subtitle", "author_id", "publisher_id", "pubdate",
-		"price", "status", "type", "stock", "created_at", "updated_at",
+// patchedContributors 部分更新时整理贡献者，返回是否需要替换：
+// 贡献者有变化时以新的贡献者为准；只修改了 author_id 时替换原来的第一作者
+func patchedContributors(old, book *models.Book) ([]*models.BookContributor, bool) {
+	equal := slices.EqualFunc(old.Contributors, book.Contributors, func(a, b *models.BookContributor) bool {
+		return a != nil && b != nil && a.AuthorID == b.AuthorID && a.Role == b.Role && a.Sort == b.Sort
+	})
 
-		"-id", "-isbn", "-title", "-subtitle", "-author_id", "-publisher_id",
-		"-pubdate", "-price", "-status", "-type", "-stock", "-created_at", "-updated_at",
+	if !equal {
+		return bookContributors(book), true
+	}
+
+	if book.AuthorID == old.AuthorID {
+		return nil, false
 	}
+
+	// 新的第一作者排在最前，去掉原来的第一作者和重复的作者角色
+	first := &models.BookContributor{AuthorID: book.AuthorID, Role: models.RoleAuthor, Sort: math.MinInt}
+	list := []*models.BookContributor{first}
+	for _, x := range old.Contributors {
+		if x.Role == models.RoleAuthor && (x.AuthorID == old.AuthorID || x.AuthorID == book.AuthorID) {
+			continue
+		}
+		list = append(list, x)
+	}
+	book.Contributors = list
+
+	return bookContributors(book), true
 }
@@ -0,0 +1,122 @@
+package dbrepo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// listQuery 分页列表查询构建器，根据 Filters 生成查询条件一致的count语句和分页语句，
+// 避免每个列表方法各自拼接count、select两份sql
+type listQuery struct {
+	columns string            // select 查询字段
+	from    string            // from 表及固定的join
+	where   []string          // 固定查询条件，如：b.deleted_at is null
+	args    []any             // 固定查询条件参数
+	joins   map[string]string // 按需join，查询字段 => join语句，仅当该字段作为查询条件时才加入
+	groupBy string            // 按需join产生一对多时用于去重的字段，如：b.id
+}
+
+// builtQuery 构建好的sql和参数，sql已经 Rebind
+type builtQuery struct {
+	countSQL  string // 为空表示不需要查询总数
+	countArgs []any
+	pageSQL   string
+	pageArgs  []any
+	keyset    *keyset // 游标分页参数，为nil表示 limit offset 分页
+}
+
+// build 根据 Filters 构建count语句和分页语句，查询字段和排序字段都经过 baseRepo 的安全字段校验
+func (q listQuery) build(db Queryable, f Filters, br baseRepo) (*builtQuery, error) {
+	wheres, args, fields, err := f.whereColumn(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按需join，同一个join语句只加一次
+	var joins []string
+	for _, field := range fields {
+		if join, ok := q.joins[field]; ok && !slices.Contains(joins, join) {
+			joins = append(joins, join)
+		}
+	}
+
+	from := q.from
+	if len(joins) > 0 {
+		from += "\n\t" + strings.Join(joins, "\n\t")
+	}
+
+	where := strings.Join(append(slices.Clone(q.where), wheres...), " and ")
+	if where != "" {
+		where = "where " + where
+	}
+
+	condArgs := append(slices.Clone(q.args), args...)
+
+	// 按需join了一对多的表，count要去重，分页数据要分组
+	count, groupBy := "count(*)", ""
+	if len(joins) > 0 && q.groupBy != "" {
+		count = fmt.Sprintf("count(distinct %s)", q.groupBy)
+		groupBy = "group by " + q.groupBy
+	}
+
+	built := new(builtQuery)
+
+	// 游标分页带了游标时不再查询总数，总数只在首页返回
+	if !f.UseCursor || f.Cursor == "" {
+		countSQL := fmt.Sprintf("select %s as total %s %s", count, from, where)
+		built.countSQL = db.Rebind(spaceRex.ReplaceAllString(countSQL, " "))
+		built.countArgs = condArgs
+	}
+
+	if !f.UseCursor {
+		pageSQL := fmt.Sprintf(
+			"select %s %s %s %s order by %s limit ? offset ?",
+			q.columns, from, where, groupBy, f.sortColumnWithDefault(br),
+		)
+		built.pageSQL = db.Rebind(spaceRex.ReplaceAllString(pageSQL, " "))
+		built.pageArgs = append(slices.Clone(condArgs), f.limit(), f.offset())
+		return built, nil
+	}
+
+	// 游标分页：以游标条件代替offset，多查一条判断是否还有数据
+	ks, err := f.keyset(br)
+	if err != nil {
+		return nil, err
+	}
+
+	pageArgs := slices.Clone(condArgs)
+	if ksWhere, ksArgs := ks.where(); ksWhere != "" {
+		if where == "" {
+			where = "where " + ksWhere
+		} else {
+			where += " and " + ksWhere
+		}
+		pageArgs = append(pageArgs, ksArgs...)
+	}
+
+	pageSQL := fmt.Sprintf(
+		"select %s %s %s %s order by %s limit ?",
+		q.columns, from, where, groupBy, ks.orderBy(),
+	)
+	built.pageSQL = db.Rebind(spaceRex.ReplaceAllString(pageSQL, " "))
+	built.pageArgs = append(pageArgs, f.limit()+1)
+	built.keyset = ks
+
+	return built, nil
+}
+
+// count 查询总数，countSQL 为空时不查询，返回0
+func (q *builtQuery) count(ctx context.Context, db Queryable) (int, error) {
+	var total int
+	if q.countSQL == "" {
+		return total, nil
+	}
+
+	slog.DebugContext(ctx, q.countSQL, slog.Any("args", q.countArgs))
+
+	err := db.GetContext(ctx, &total, q.countSQL, q.countArgs...)
+	return total, err
+}
@@ -2,17 +2,27 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/lightsaid/ebook/internal/models"
 )
 
 type CategoryRepo interface {
-	Create(ctx context.Context, category models.Category) (uint64, error)
-	Update(ctx context.Context, category models.Category) error
+	TrashRepo
+	Create(ctx context.Context, category models.Category) (uint64, error) // 添加到父分类下，排在同级最后
+	Update(ctx context.Context, category models.Category) error           // 更新名称和图标，层级和排序通过 Move 调整
+	Patch(ctx context.Context, old, category *models.Category) error      // 部分更新，仅更新有变化的列
 	Get(ctx context.Context, id uint64) (*models.Category, error)
+	GetByName(ctx context.Context, name string) (*models.Category, error) // 根据名称获取，同名取最早创建的
 	List(ctx context.Context) ([]*models.Category, error)
-	Delete(ctx context.Context, id uint64) error
+	Tree(ctx context.Context) ([]*models.Category, error)          // 按层级组装的分类树
+	Descendants(ctx context.Context, id uint64) ([]uint64, error)  // 分类自身及所有子孙分类的id
+	Move(ctx context.Context, id, parentID uint64, sort int) error // 移动到 parentID 下的 sort 位置，同级的排序保持连续
+	Delete(ctx context.Context, id uint64) error                   // 有子分类时返回 ErrCategoryNotEmpty
 }
 
 var _ CategoryRepo = (*categoryRepo)(nil)
@@ -28,14 +38,28 @@ func NewCategoryRepo(db Queryable) *categoryRepo {
 	return repo
 }
 
-func (r *categoryRepo) Create(ctx context.Context, category models.Category) (uint64, error) {
-	// NOTE: 这是mysql特有的语法？好像是，记得，并且sqlx.Named不支持
-	// sql := `insert category set category_name=?, icon=?, sort=?;`
-	sql := `insert into category(category_name, icon, sort) values (:category_name, :icon, :sort);`
+// categoryColumns 分类查询字段
+const categoryColumns = "id, parent_id, path, category_name, icon, sort, created_at, updated_at"
+
+// maxCategoryDepth 分类最多的层级
+const maxCategoryDepth = 5
 
+func (r *categoryRepo) Create(ctx context.Context, category models.Category) (uint64, error) {
 	ctx, cancal := dbtk.withTimeout(ctx)
 	defer cancal()
 
+	path, err := categoryChildPath(ctx, r.DB, category.ParentID)
+	if err != nil {
+		return 0, err
+	}
+	category.Path = path
+
+	// 排在同级分类的最后
+	sql := `
+	insert into category(parent_id, path, category_name, icon, sort)
+	select :parent_id, :path, :category_name, :icon, coalesce(max(sort) + 1, 0)
+	from category where parent_id = :parent_id and deleted_at is null;`
+
 	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, category)
 	if err != nil {
 		return 0, err
@@ -46,7 +70,7 @@ func (r *categoryRepo) Create(ctx context.Context, category models.Category) (ui
 }
 
 func (r *categoryRepo) Update(ctx context.Context, category models.Category) error {
-	sql := `update category set category_name=:category_name, icon=:icon, sort=:sort where id=:id and deleted_at is null;`
+	sql := `update category set category_name=:category_name, icon=:icon where id=:id and deleted_at is null;`
 
 	ctx, cancal := dbtk.withTimeout(ctx)
 	defer cancal()
@@ -64,10 +88,24 @@ func (r *categoryRepo) Update(ctx context.Context, category models.Category) err
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
 
+// categoryPatchColumns 允许部分更新的列
+var categoryPatchColumns = []string{"category_name", "icon"}
+
+// Patch 部分更新，仅更新 old 和 category 之间有变化的列，没有变化则不执行更新
+func (r *categoryRepo) Patch(ctx context.Context, old, category *models.Category) error {
+	changes := changedColumns(old, category, categoryPatchColumns)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	result, err := dbtk.patch(ctx, r.DB, "category", changes, "id=:id and deleted_at is null", map[string]any{"id": old.ID})
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
 func (r *categoryRepo) Get(ctx context.Context, id uint64) (*models.Category, error) {
 	sql := `
 		select
-			id, category_name, icon, sort, created_at, updated_at 
+			` + categoryColumns + ` 
 		from 
 			category 
 		where 
@@ -87,8 +125,30 @@ func (r *categoryRepo) Get(ctx context.Context, id uint64) (*models.Category, er
 	return category, err
 }
 
+// GetByName 根据名称获取，不存在返回 sql.ErrNoRows
+func (r *categoryRepo) GetByName(ctx context.Context, name string) (*models.Category, error) {
+	sql := `
+		select 
+			` + categoryColumns + ` 
+		from 
+			category 
+		where 
+			category_name=? and deleted_at is null 
+		order by id limit 1;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "name", name)
+
+	category := new(models.Category)
+	err := r.DB.GetContext(ctx, category, r.DB.Rebind(sql), name)
+	return category, err
+}
+
+// List 获取全部分类，按父分类、同级排序
 func (r *categoryRepo) List(ctx context.Context) (list []*models.Category, err error) {
-	sql := `select * from category where deleted_at is null;`
+	sql := `select ` + categoryColumns + ` from category where deleted_at is null order by parent_id, sort, id;`
 
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
@@ -101,14 +161,276 @@ func (r *categoryRepo) List(ctx context.Context) (list []*models.Category, err e
 	return list, err
 }
 
+// Tree 获取分类树，顶级分类及其子分类都按 sort 排序
+func (r *categoryRepo) Tree(ctx context.Context) ([]*models.Category, error) {
+	list, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models.CategoryTree(list), nil
+}
+
+// Descendants 获取分类自身及所有子孙分类的id，分类不存在返回 sql.ErrNoRows
+func (r *categoryRepo) Descendants(ctx context.Context, id uint64) ([]uint64, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	return categoryDescendants(ctx, r.DB, id)
+}
+
+// Move 移动分类到 parentID 下的 sort 位置，parentID 不变时即调整同级排序；
+// 原来和新的同级分类的 sort 都保持从0开始连续，子孙分类的 path 一并更新
+func (r *categoryRepo) Move(ctx context.Context, id, parentID uint64, sort int) error {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	return dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		return moveCategory(ctx, tx.db, id, parentID, sort)
+	})
+}
+
+// Delete 软删除分类，有未删除的子分类时不能删除；删除后同级分类的 sort 保持连续
 func (r *categoryRepo) Delete(ctx context.Context, id uint64) error {
-	sql := `update category set deleted_at = now() where id = ?;`
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
 
-	sql = r.DB.Rebind(sql)
+	return dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		node, err := lockCategory(ctx, tx.db, id)
+		if err != nil {
+			return err
+		}
+
+		hasChildren, err := dbtk.existsAlive(ctx, tx.db, "category", "parent_id = ?", id)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return ErrCategoryNotEmpty
+		}
+
+		query := tx.db.Rebind(`update category set deleted_at = now() where id = ?;`)
+		slog.DebugContext(ctx, query, "id", id)
+
+		result, err := tx.db.ExecContext(ctx, query, id)
+		if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+			return err
+		}
+
+		return closeCategoryGap(ctx, tx.db, node.ParentID, node.Sort)
+	})
+}
+
+// categoryChildPath 返回 parentID 下子分类的 path，父分类不存在返回 ErrCategoryParent，
+// 超过最大层级返回 ErrCategoryDepth
+func categoryChildPath(ctx context.Context, db Queryable, parentID uint64) (string, error) {
+	if parentID == 0 {
+		return "/", nil
+	}
+
+	query := db.Rebind(`select id, path from category where id = ? and deleted_at is null;`)
+	slog.DebugContext(ctx, query, "parentID", parentID)
+
+	parent := new(models.Category)
+	err := db.GetContext(ctx, parent, query, parentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCategoryParent
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if parent.Depth() >= maxCategoryDepth {
+		return "", ErrCategoryDepth
+	}
+	return parent.DescendantPath(), nil
+}
 
-	slog.DebugContext(ctx, sql, "id", id)
+// categoryDescendants 查询分类自身及所有未删除的子孙分类的id
+func categoryDescendants(ctx context.Context, db Queryable, id uint64) ([]uint64, error) {
+	query := db.Rebind(`select id, path from category where id = ? and deleted_at is null;`)
+	slog.DebugContext(ctx, query, "id", id)
 
-	result, err := r.DB.Exec(sql, id)
+	node := new(models.Category)
+	if err := db.GetContext(ctx, node, query, id); err != nil {
+		return nil, err
+	}
+
+	query = db.Rebind(`select id from category where path like ? and deleted_at is null order by path, sort;`)
+	slog.DebugContext(ctx, query, "path", node.DescendantPath())
+
+	ids := []uint64{id}
+	var children []uint64
+	if err := db.SelectContext(ctx, &children, query, node.DescendantPath()+"%"); err != nil {
+		return nil, err
+	}
+	return append(ids, children...), nil
+}
+
+// lockCategory 在事务中查询并锁定未删除的分类
+func lockCategory(ctx context.Context, db Queryable, id uint64) (*models.Category, error) {
+	query := db.Rebind(`select ` + categoryColumns + ` from category where id = ? and deleted_at is null for update;`)
+	slog.DebugContext(ctx, query, "id", id)
+
+	node := new(models.Category)
+	err := db.GetContext(ctx, node, query, id)
+	return node, err
+}
 
+// closeCategoryGap 分类从 parentID 下移出或删除后，排在它后面的同级分类前移一位
+func closeCategoryGap(ctx context.Context, db Queryable, parentID uint64, sort int) error {
+	query := db.Rebind(`update category set sort = sort - 1 where parent_id = ? and sort > ? and deleted_at is null;`)
+	slog.DebugContext(ctx, query, "parentID", parentID, "sort", sort)
+
+	result, err := db.ExecContext(ctx, query, parentID, sort)
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
+
+// moveCategory 需要在事务中执行，见 categoryRepo.Move
+func moveCategory(ctx context.Context, db Queryable, id, parentID uint64, pos int) error {
+	node, err := lockCategory(ctx, db, id)
+	if err != nil {
+		return err
+	}
+
+	if parentID == id {
+		return ErrCategoryCycle
+	}
+
+	path, err := categoryChildPath(ctx, db, parentID)
+	if err != nil {
+		return err
+	}
+
+	// 新的父分类是自己的子孙分类
+	oldPrefix := node.DescendantPath()
+	if strings.HasPrefix(path, oldPrefix) {
+		return ErrCategoryCycle
+	}
+
+	// 移动后子孙分类的层级不能超过限制
+	var deepest int
+	query := db.Rebind(`select coalesce(max(length(path) - length(replace(path, '/', ''))), 0) from category where path like ?;`)
+	if err = db.GetContext(ctx, &deepest, query, oldPrefix+"%"); err != nil {
+		return err
+	}
+	if levels := max(deepest, node.Depth()) - node.Depth(); strings.Count(path, "/")+levels > maxCategoryDepth {
+		return ErrCategoryDepth
+	}
+
+	// 从原来的位置移出
+	if err = closeCategoryGap(ctx, db, node.ParentID, node.Sort); err != nil {
+		return err
+	}
+
+	// 新位置超出范围时排在最后
+	var count int
+	query = db.Rebind(`select count(*) from category where parent_id = ? and id != ? and deleted_at is null;`)
+	if err = db.GetContext(ctx, &count, query, parentID, id); err != nil {
+		return err
+	}
+	if pos < 0 || pos > count {
+		pos = count
+	}
+
+	query = db.Rebind(`update category set sort = sort + 1 where parent_id = ? and sort >= ? and id != ? and deleted_at is null;`)
+	slog.DebugContext(ctx, query, "parentID", parentID, "sort", pos)
+	if _, err = db.ExecContext(ctx, query, parentID, pos, id); err != nil {
+		return err
+	}
+
+	query = db.Rebind(`update category set parent_id = ?, path = ?, sort = ? where id = ?;`)
+	slog.DebugContext(ctx, query, "id", id, "parentID", parentID, "path", path, "sort", pos)
+	if _, err = db.ExecContext(ctx, query, parentID, path, pos, id); err != nil {
+		return err
+	}
+
+	if path == node.Path {
+		return nil
+	}
+
+	// 子孙分类替换 path 前缀，包括已删除的，恢复后仍在原来的位置
+	newPrefix := fmt.Sprintf("%s%d/", path, id)
+	query = db.Rebind(`update category set path = concat(?, substring(path, ?)) where path like ?;`)
+	slog.DebugContext(ctx, query, "from", oldPrefix, "to", newPrefix)
+
+	_, err = db.ExecContext(ctx, query, newPrefix, len(oldPrefix)+1, oldPrefix+"%")
+	return err
+}
+
+// categoryTrash 分类回收站
+var categoryTrash = trash[models.Category]{
+	table:         "category",
+	columns:       categoryColumns + ", deleted_at",
+	beforeRestore: categoryBeforeRestore,
+	beforePurge:   categoryBeforePurge,
+}
+
+// ListDeleted 分页获取已删除的数据
+func (r *categoryRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return categoryTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *categoryRepo) Restore(ctx context.Context, id uint64) error {
+	return categoryTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *categoryRepo) Purge(ctx context.Context, id uint64) error {
+	return categoryTrash.purge(ctx, r.DB, id)
+}
+
+// categoryBeforeRestore 父分类未删除才能恢复，恢复后排在同级分类的最后
+func categoryBeforeRestore(ctx context.Context, db Queryable, id uint64) error {
+	query := db.Rebind(`select ` + categoryColumns + ` from category where id = ? and deleted_at is not null;`)
+
+	node := new(models.Category)
+	err := db.GetContext(ctx, node, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if node.ParentID > 0 {
+		alive, err := dbtk.existsAlive(ctx, db, "category", "id = ?", node.ParentID)
+		if err != nil {
+			return err
+		}
+		if !alive {
+			return fmt.Errorf("%w: 请先恢复父分类", ErrRestoreDependency)
+		}
+	}
+
+	var count int
+	query = db.Rebind(`select count(*) from category where parent_id = ? and deleted_at is null;`)
+	if err = db.GetContext(ctx, &count, query, node.ParentID); err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(ctx, db.Rebind(`update category set sort = ? where id = ?;`), count, id)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+// categoryBeforePurge 分类仍被未删除的图书使用或者还有子分类(包括已删除的)时不能彻底删除，
+// book_categories 的外键是级联删除，因此需要在这里检查
+func categoryBeforePurge(ctx context.Context, db Queryable, id uint64) error {
+	var hasChildren bool
+	query := db.Rebind(`select exists(select 1 from category where parent_id = ?);`)
+	if err := db.GetContext(ctx, &hasChildren, query, id); err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("%w: 分类下还有子分类", ErrReferenced)
+	}
+
+	used, err := dbtk.existsAlive(ctx, db, "books b join book_categories bc on b.id = bc.book_id", "bc.category_id = ?", id)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("%w: 分类仍被图书使用", ErrReferenced)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package dbrepo
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// 与sqlx默认一致的字段映射，用于按db tag取出结构体字段的值，如游标字段、更新前后有变化的列
+var fieldMapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// cursor 游标分页(keyset)的游标，编码为不透明的base64字符串返回给客户端
+type cursor struct {
+	Field string `json:"f"`           // 排序字段，必须是 SortSafelist 中主表的列
+	Desc  bool   `json:"d,omitempty"` // 是否倒序
+	Value any    `json:"v"`           // 上一页边界数据的排序字段值
+	Time  bool   `json:"t,omitempty"` // Value 是否为时间，时间以RFC3339Nano格式保存
+	ID    uint64 `json:"i"`           // 上一页边界数据的id，排序字段值相同时用id区分
+	Prev  bool   `json:"p,omitempty"` // 是否向前翻页
+}
+
+// encode 编码游标
+func (c cursor) encode() string {
+	buf, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodeCursor 解码游标，游标无效返回 ErrInvalidFilter
+func decodeCursor(text string) (*cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidFilter)
+	}
+
+	// 使用 json.Number 避免大整数丢失精度
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	c := new(cursor)
+	if err := dec.Decode(c); err != nil || c.Field == "" {
+		return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidFilter)
+	}
+
+	if c.Time {
+		text, _ := c.Value.(string)
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidFilter)
+		}
+		c.Value = t
+	}
+
+	return c, nil
+}
+
+// keyset 游标分页的查询参数，由游标解码得到，首页则由排序字段得到
+type keyset struct {
+	cursor   *cursor // 客户端传入的游标，首页为nil
+	field    string  // 排序字段
+	column   string  // 排序字段对应的SQL列名
+	idColumn string  // id 对应的SQL列名
+	desc     bool    // 是否倒序
+	prev     bool    // 是否向前翻页
+}
+
+// keyset 根据游标或排序字段获取游标分页的查询参数，排序只取第一个排序字段，再以id保证顺序唯一，
+// 排序字段的值不能为NULL，否则该行会被跳过
+func (f Filters) keyset(br baseRepo) (*keyset, error) {
+	if len(f.WhereSafelist) == 0 {
+		f.WhereSafelist = br.defaultWhereSafelist()
+	}
+	if len(f.SortSafelist) == 0 {
+		f.SortSafelist = br.defaultSortSafelist()
+	}
+
+	idColumn, ok := f.WhereSafelist["id"]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持游标分页", ErrInvalidFilter)
+	}
+
+	ks := &keyset{field: "id", column: idColumn, idColumn: idColumn}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		column, ok := f.keysetColumn(c.Field, idColumn)
+		if !ok {
+			return nil, fmt.Errorf("%w: 无效的游标", ErrInvalidFilter)
+		}
+		ks.cursor, ks.field, ks.column, ks.desc, ks.prev = c, c.Field, column, c.Desc, c.Prev
+		return ks, nil
+	}
+
+	for _, field := range f.SortFields {
+		if !slices.Contains(f.SortSafelist, field) {
+			continue
+		}
+		name := strings.TrimPrefix(field, "-")
+		column, ok := f.keysetColumn(name, idColumn)
+		if !ok {
+			return nil, fmt.Errorf("%w: 字段 %s 不支持游标分页", ErrInvalidFilter, name)
+		}
+		ks.field, ks.column, ks.desc = name, column, f.sortDirection(field) == "DESC"
+		break
+	}
+
+	return ks, nil
+}
+
+// columnRex 匹配普通的列名，如：price、b.price
+var columnRex = regexp.MustCompile(`^(\w+\.)?\w+$`)
+
+// keysetColumn 获取游标分页排序字段对应的列，字段必须在 SortSafelist 中，且是与id同一个表(主表)的列；
+// join表的列和表达式不能作为游标字段，join表的列在结果中没有对应的顶层字段，无法生成游标
+func (f Filters) keysetColumn(field, idColumn string) (string, bool) {
+	if field == "id" {
+		return idColumn, true
+	}
+	if !slices.Contains(f.SortSafelist, field) {
+		return "", false
+	}
+
+	column, ok := f.WhereSafelist[field]
+	if !ok || !columnRex.MatchString(column) {
+		return "", false
+	}
+
+	return column, columnTable(column) == columnTable(idColumn)
+}
+
+// columnTable 获取列名的表名或别名，没有则返回空字符串，如：b.price => b
+func columnTable(column string) string {
+	table, _, found := strings.Cut(column, ".")
+	if !found {
+		return ""
+	}
+	return table
+}
+
+// where 游标条件，如升序向后翻页：(b.price > ? or (b.price = ? and b.id > ?))
+func (ks *keyset) where() (string, []any) {
+	if ks.cursor == nil {
+		return "", nil
+	}
+
+	op := ">"
+	if ks.desc != ks.prev {
+		op = "<"
+	}
+
+	if ks.field == "id" {
+		return fmt.Sprintf("%s %s ?", ks.idColumn, op), []any{ks.cursor.ID}
+	}
+
+	where := fmt.Sprintf("(%s %s ? or (%s = ? and %s %s ?))", ks.column, op, ks.column, ks.idColumn, op)
+	return where, []any{ks.cursor.Value, ks.cursor.Value, ks.cursor.ID}
+}
+
+// orderBy 游标排序，向前翻页时反向排序，查询结果需要再反转
+func (ks *keyset) orderBy() string {
+	direction := "ASC"
+	if ks.desc != ks.prev {
+		direction = "DESC"
+	}
+
+	if ks.field == "id" {
+		return fmt.Sprintf("%s %s", ks.idColumn, direction)
+	}
+	return fmt.Sprintf("%s %s, %s %s", ks.column, direction, ks.idColumn, direction)
+}
+
+// makeCursor 根据一行数据生成游标，row 是带db tag的结构体(指针)
+func (ks *keyset) makeCursor(row any, prev bool) string {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	names := fieldMapper.TypeMap(v.Type()).Names
+
+	c := cursor{Field: ks.field, Desc: ks.desc, Prev: prev}
+
+	if fi, ok := names["id"]; ok {
+		c.ID = reflectx.FieldByIndexesReadOnly(v, fi.Index).Uint()
+	}
+
+	fi, ok := names[ks.field]
+	if !ok {
+		return ""
+	}
+	value := reflectx.FieldByIndexesReadOnly(v, fi.Index).Interface()
+
+	// 自定义类型如 types.GxTime 取出写入数据库时的值
+	if valuer, ok := value.(driver.Valuer); ok {
+		value, _ = valuer.Value()
+	}
+	if t, ok := value.(time.Time); ok {
+		value, c.Time = t.Format(time.RFC3339Nano), true
+	}
+	c.Value = value
+
+	return c.encode()
+}
+
+// pageResult 根据分页方式处理查询结果，返回最终的列表和分页数据；
+// 游标分页会多查一条用于判断是否还有数据
+func pageResult[T any](q *builtQuery, f Filters, total int, list []T) ([]T, Metadata) {
+	if q.keyset == nil {
+		return list, dbtk.calculateMetadata(total, f.PageNum, f.PageSize)
+	}
+
+	limit := f.limit()
+	hasMore := len(list) > limit
+	if hasMore {
+		list = list[:limit]
+	}
+
+	ks := q.keyset
+	if ks.prev {
+		slices.Reverse(list)
+	}
+
+	metadata := Metadata{PageSize: limit, TotalCount: total}
+	if len(list) == 0 {
+		return list, metadata
+	}
+
+	// 向后翻页：还有数据才有下一页，带了游标才有上一页；向前翻页反之
+	hasNext, hasPrev := hasMore, ks.cursor != nil
+	if ks.prev {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		metadata.NextCursor = ks.makeCursor(list[len(list)-1], false)
+	}
+	if hasPrev {
+		metadata.PrevCursor = ks.makeCursor(list[0], true)
+	}
+
+	return list, metadata
+}
@@ -95,6 +95,9 @@ func Close() {
 type baseRepo interface {
 	// defaultSortSafelist 导出默认的安全排序字段
 	defaultSortSafelist() []string
+
+	// defaultWhereSafelist 导出默认的安全查询字段，入参字段 => SQL列名
+	defaultWhereSafelist() map[string]string
 }
 
 // 内部使用的工具箱 toolkit
@@ -0,0 +1,95 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type DownloadLogRepo interface {
+	baseRepo
+	Create(ctx context.Context, log *models.DownloadLog) (uint64, error)
+	List(ctx context.Context, f Filters) (*PageQueryVo, error)
+}
+
+var _ DownloadLogRepo = (*downloadLogRepo)(nil)
+
+type downloadLogRepo struct {
+	DB Queryable
+}
+
+func NewDownloadLogRepo(db Queryable) *downloadLogRepo {
+	repo := &downloadLogRepo{
+		DB: db,
+	}
+
+	return repo
+}
+
+func (r *downloadLogRepo) Create(ctx context.Context, log *models.DownloadLog) (uint64, error) {
+	sql := `
+	insert into download_logs(user_id, book_id, ip, user_agent, range_header, status, bytes)
+	values(:user_id, :book_id, :ip, :user_agent, :range_header, :status, :bytes);`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, log)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.insertErrorHandler(ctx, result, err)
+}
+
+// downloadLogListQuery 下载记录列表查询
+var downloadLogListQuery = listQuery{
+	columns: "id, user_id, book_id, ip, user_agent, range_header, status, bytes, created_at",
+	from:    "from download_logs",
+}
+
+// List 分页获取下载记录，支持 Filters.Conditions 查询条件
+func (r *downloadLogRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	q, err := downloadLogListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*models.DownloadLog, 0, f.limit())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	return dbtk.makePageQueryVo(metadata, list), nil
+}
+
+// defaultSortSafelist 导出默认的安全排序字段
+func (r *downloadLogRepo) defaultSortSafelist() []string {
+	return []string{"id", "created_at", "-id", "-created_at"}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *downloadLogRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":         "id",
+		"user_id":    "user_id",
+		"book_id":    "book_id",
+		"status":     "status",
+		"created_at": "created_at",
+	}
+}
@@ -17,6 +17,23 @@ var (
 	ErrInsertFailed = errors.New("插入数据失败")
 	ErrNoEffectDB   = errors.New("qb not is *sql.DB")
 	ErrNoEmail      = errors.New("邮箱地址不能为空")
+
+	ErrInvalidFilter = errors.New("无效的查询条件")
+
+	// ErrVersionConflict 乐观锁更新失败，数据的版本号已经改变(或数据不存在)
+	ErrVersionConflict = errors.New("数据版本冲突")
+
+	// ErrReferenced 数据仍被引用，不能彻底删除
+	ErrReferenced = errors.New("数据仍被引用，无法彻底删除")
+	// ErrRestoreDependency 关联的数据已被删除，不能恢复
+	ErrRestoreDependency = errors.New("关联数据已被删除，无法恢复")
+
+	ErrCategoryParent   = errors.New("父分类不存在")
+	ErrCategoryCycle    = errors.New("不能移动到自身或子分类下")
+	ErrCategoryDepth    = errors.New("分类层级不能超过5级")
+	ErrCategoryNotEmpty = errors.New("分类下还有子分类，无法删除")
+
+	ErrReviewSelfVote = errors.New("不能给自己的评价投票")
 )
 
 // ConvertToApiError 将db错误转换为 *gotk.ApiError
@@ -27,6 +44,27 @@ func ConvertToApiError(err error) *gotk.ApiError {
 	if errors.Is(err, ErrNotFound) {
 		return errs.ErrNotFound.WithError(err)
 	}
+	if errors.Is(err, ErrInvalidFilter) {
+		return errs.ErrBadRequest.WithError(err).WithMessage(err.Error())
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		return errs.ErrVersionConflict.WithError(err)
+	}
+	if errors.Is(err, ErrReferenced) {
+		return errs.ErrRecordReferenced.WithError(err).WithMessage(err.Error())
+	}
+	if errors.Is(err, ErrCategoryParent) || errors.Is(err, ErrCategoryCycle) || errors.Is(err, ErrCategoryDepth) {
+		return errs.ErrUnprocessableEntity.WithError(err).WithMessage(err.Error())
+	}
+	if errors.Is(err, ErrCategoryNotEmpty) {
+		return errs.ErrRecordReferenced.WithError(err).WithMessage(err.Error())
+	}
+	if errors.Is(err, ErrReviewSelfVote) {
+		return errs.ErrUnprocessableEntity.WithError(err).WithMessage(err.Error())
+	}
+	if errors.Is(err, ErrRestoreDependency) {
+		return errs.ErrUnprocessableEntity.WithError(err).WithMessage(err.Error())
+	}
 	if errors.Is(err, ErrInsertFailed) || errors.Is(err, ErrNoEffectDB) {
 		return errs.ErrServerError.WithError(err)
 	}
@@ -40,6 +78,9 @@ func ConvertToApiError(err error) *gotk.ApiError {
 		if dbErr.Number == 1062 { // 数据冲突，已存在
 			return errs.ErrRecordExists.WithError(err)
 		}
+		if dbErr.Number == 1451 { // 仍被外键引用，不能删除
+			return errs.ErrRecordReferenced.WithError(err)
+		}
 	}
 
 	return errs.ErrServerError.WithError(err)
@@ -0,0 +1,185 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type FavoriteRepo interface {
+	baseRepo
+	Add(ctx context.Context, userID, bookID uint64) (uint64, error)                          // 收藏图书，已收藏则保留首次收藏时的价格，只更新是否可购买
+	Remove(ctx context.Context, userID, bookID uint64) error                                 // 取消收藏，没有收藏返回 ErrNotFound
+	List(ctx context.Context, userID uint64, f Filters) (*PageQueryVo, error)                // 用户收藏的未删除图书，标记降价和重新可购买
+	FavoritedBookIDs(ctx context.Context, userID uint64, bookIDs []uint64) ([]uint64, error) // 返回 bookIDs 中用户已收藏的图书id
+}
+
+var _ FavoriteRepo = (*favoriteRepo)(nil)
+
+type favoriteRepo struct {
+	DB Queryable
+}
+
+func NewFavoriteRepo(db Queryable) *favoriteRepo {
+	var repo = &favoriteRepo{
+		DB: db,
+	}
+	return repo
+}
+
+// bookAvailableSQL 图书是否可购买：上架，且是电子书或有库存
+const bookAvailableSQL = "(b.status = 1 and (b.type = 1 or b.stock > 0))"
+
+// Add 收藏图书，记录收藏时的价格和是否可购买；图书不存在返回 ErrNotFound
+func (r *favoriteRepo) Add(ctx context.Context, userID, bookID uint64) (uint64, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	exists, err := dbtk.existsAlive(ctx, r.DB, "books", "id=?", bookID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	// 使用 last_insert_id(id) 使已收藏时也返回收藏id；
+	// 重复收藏不更新价格，避免降价后再次收藏覆盖比较降价的基准价格
+	sql := `
+	insert into favorites(user_id, book_id, price, available)
+	select ?, b.id, b.price, ` + bookAvailableSQL + ` from books b where b.id = ?
+	on duplicate key update
+		id=last_insert_id(favorites.id),
+		available=values(available);`
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "userID", userID, "bookID", bookID)
+
+	result, err := r.DB.ExecContext(ctx, sql, userID, bookID)
+	return dbtk.insertErrorHandler(ctx, result, err)
+}
+
+// Remove 取消收藏
+func (r *favoriteRepo) Remove(ctx context.Context, userID, bookID uint64) error {
+	sql := `delete from favorites where user_id = ? and book_id = ?;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, sql, "userID", userID, "bookID", bookID)
+
+	result, err := r.DB.ExecContext(ctx, sql, userID, bookID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// favoriteListQuery 收藏列表查询，已删除的图书不返回
+var favoriteListQuery = listQuery{
+	columns: `
+		f.id, f.user_id, f.book_id, f.price, f.available, f.created_at,
+		b.id as "book.id",
+		b.isbn as "book.isbn",
+		b.title as "book.title",
+		b.subtitle as "book.subtitle",
+		b.author_id as "book.author_id",
+		b.cover_url as "book.cover_url",
+		b.publisher_id as "book.publisher_id",
+		b.price as "book.price",
+		b.status as "book.status",
+		b.type as "book.type",
+		b.stock as "book.stock",
+		b.rating_avg as "book.rating_avg",
+		b.rating_count as "book.rating_count",
+		` + favoriteOnSaleSQL + ` as on_sale,
+		` + favoriteBackInStockSQL + ` as back_in_stock`,
+	from: `
+	from favorites f
+	join books b on b.id = f.book_id and b.deleted_at is null`,
+}
+
+const (
+	favoriteOnSaleSQL      = "(" + bookAvailableSQL + " and b.price < f.price)"
+	favoriteBackInStockSQL = "(f.available = 0 and " + bookAvailableSQL + ")"
+)
+
+// List 分页获取用户的收藏，支持按 on_sale、back_in_stock 查询，如：where=on_sale:eq:1
+func (r *favoriteRepo) List(ctx context.Context, userID uint64, f Filters) (*PageQueryVo, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	f.Conditions = append(f.Conditions, Eq("user_id", userID))
+
+	q, err := favoriteListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*models.Favorite, 0, f.limit())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	return dbtk.makePageQueryVo(metadata, list), nil
+}
+
+// FavoritedBookIDs 返回 bookIDs 中用户已收藏的图书id，用于图书列表标记是否已收藏
+func (r *favoriteRepo) FavoritedBookIDs(ctx context.Context, userID uint64, bookIDs []uint64) ([]uint64, error) {
+	list := make([]uint64, 0)
+	if len(bookIDs) == 0 {
+		return list, nil
+	}
+
+	query, args, err := sqlx.In(`select book_id from favorites where user_id = ? and book_id in (?);`, userID, bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, query, slog.Uint64("userID", userID), slog.Int("count", len(bookIDs)))
+
+	err = r.DB.SelectContext(ctx, &list, r.DB.Rebind(query), args...)
+	return list, err
+}
+
+// defaultSortSafelist 导出默认的安全排序字段
+func (r *favoriteRepo) defaultSortSafelist() []string {
+	return []string{
+		"id", "created_at",
+		"-id", "-created_at",
+	}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *favoriteRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":            "f.id",
+		"user_id":       "f.user_id",
+		"book_id":       "f.book_id",
+		"created_at":    "f.created_at",
+		"on_sale":       favoriteOnSaleSQL,
+		"back_in_stock": favoriteBackInStockSQL,
+	}
+}
@@ -0,0 +1,133 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type FileRepo interface {
+	Register(ctx context.Context, file *models.File) error // 登记上传的文件，已存在时刷新保留期，不更新地址
+	Recount(ctx context.Context) error                     // 按文件名重新统计所有文件的引用数
+	// Unreferenced 没有引用且在 before 之前就没有引用的文件，缩略图排在原图之前
+	Unreferenced(ctx context.Context, before time.Time, limit int) ([]*models.File, error)
+	Delete(ctx context.Context, id uint64) error
+}
+
+var _ FileRepo = (*fileRepo)(nil)
+
+type fileRepo struct {
+	DB Queryable
+}
+
+func NewFileRepo(db Queryable) *fileRepo {
+	repo := &fileRepo{
+		DB: db,
+	}
+
+	return repo
+}
+
+// Register 登记上传的文件，相同内容重复上传时文件已存在，没有引用的重新计算保留期，避免刚上传就被回收；
+// 已登记的地址不更新，引用按文件名统计，与地址无关
+func (r *fileRepo) Register(ctx context.Context, file *models.File) error {
+	sql := `
+	insert into files(kind, file_key, url, parent_url) values(:kind, :file_key, :url, :parent_url)
+	on duplicate key update
+		unreferenced_at = if(ref_count = 0, current_timestamp, unreferenced_at);`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, file)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Recount 统计图书封面、电子书文件、轮播图、分类图标、用户头像、作者照片、出版社logo、系列封面的引用数，
+// 按地址的文件名(去掉查询参数)与 file_key 的文件名匹配，file_key 是内容的哈希，更换访问地址前缀(如CDN)后仍能匹配；
+// 软删除的数据可以恢复，仍然算作引用；缩略图的引用数与原图相同
+func (r *fileRepo) Recount(ctx context.Context) error {
+	sql := `
+	update files f
+	left join (
+		select substring_index(substring_index(url, '?', 1), '/', -1) as name, count(*) as n from (
+			select cover_url as url from books
+			union all select source_url from books
+			union all select image_url from banners
+			union all select icon from category
+			union all select avatar from users
+			union all select photo from author
+			union all select logo from publisher
+			union all select cover_url from series
+		) refs
+		group by name
+	) r on r.name = substring_index(f.file_key, '/', -1)
+	set
+		f.ref_count = coalesce(r.n, 0),
+		f.unreferenced_at = if(coalesce(r.n, 0) = 0, coalesce(f.unreferenced_at, current_timestamp), null)
+	where f.parent_url = '';`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "))
+
+	if _, err := r.DB.ExecContext(ctx, sql); err != nil {
+		return err
+	}
+
+	sql = `
+	update files v
+	left join files p on p.kind = v.kind and p.parent_url = ''
+		and substring_index(p.file_key, '/', -1) = substring_index(substring_index(v.parent_url, '?', 1), '/', -1)
+	set
+		v.ref_count = coalesce(p.ref_count, 0),
+		v.unreferenced_at = if(coalesce(p.ref_count, 0) = 0, coalesce(v.unreferenced_at, current_timestamp), null)
+	where v.parent_url <> '';`
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "))
+
+	_, err := r.DB.ExecContext(ctx, sql)
+	return err
+}
+
+func (r *fileRepo) Unreferenced(ctx context.Context, before time.Time, limit int) ([]*models.File, error) {
+	sql := `
+		select
+			id, kind, file_key, url, parent_url, ref_count, unreferenced_at, created_at, updated_at
+		from files
+		where ref_count = 0 and unreferenced_at < ?
+		order by parent_url = '', id
+		limit ?;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "before", before, "limit", limit)
+
+	var list []*models.File
+	err := r.DB.SelectContext(ctx, &list, r.DB.Rebind(sql), before, limit)
+	return list, err
+}
+
+func (r *fileRepo) Delete(ctx context.Context, id uint64) error {
+	sql := `delete from files where id = :id and ref_count = 0;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, map[string]any{"id": id})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
@@ -15,13 +15,96 @@ var (
 
 	// 限制每页最大条数
 	maxPageSize = 100
+
+	// like 查询转义通配符，避免用户输入的 %、_ 被当作通配符
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 )
 
 type Filters struct {
-	PageNum      int
-	PageSize     int
-	SortFields   []string // SQL ORDER BY 排序字段, 必须存在于 SortSafelist
-	SortSafelist []string // 定义安全的排序字段，带"-"的是DESC，反之ASC
+	PageNum       int
+	PageSize      int
+	SortFields    []string          // SQL ORDER BY 排序字段, 必须存在于 SortSafelist
+	SortSafelist  []string          // 定义安全的排序字段，带"-"的是DESC，反之ASC
+	Conditions    []Condition       // SQL WHERE 查询条件, 字段必须存在于 WhereSafelist
+	WhereSafelist map[string]string // 定义安全的查询字段，入参字段 => SQL列名，如：author_id => b.author_id
+	UseCursor     bool              // 使用游标(keyset)分页代替 limit offset 分页，此时忽略 PageNum
+	Cursor        string            // 游标分页的游标，取自上次返回的 nextCursor/prevCursor，为空表示首页
+}
+
+// Operator 查询条件操作符
+type Operator string
+
+const (
+	OpEq    Operator = "eq"    // 等于：field = ?
+	OpIn    Operator = "in"    // 包含：field in (?, ?)
+	OpRange Operator = "range" // 范围：field >= ? and field <= ?，任一端为nil则不限制该端
+	OpLike  Operator = "like"  // 模糊：field like %?%
+)
+
+// Condition 一个查询条件，Field 为入参字段，必须存在于 WhereSafelist
+type Condition struct {
+	Field  string
+	Op     Operator
+	Values []any
+}
+
+// Eq 构建一个等于查询条件
+func Eq(field string, value any) Condition {
+	return Condition{Field: field, Op: OpEq, Values: []any{value}}
+}
+
+// In 构建一个包含查询条件
+func In(field string, values ...any) Condition {
+	return Condition{Field: field, Op: OpIn, Values: values}
+}
+
+// Range 构建一个范围查询条件，min、max 为nil表示不限制
+func Range(field string, min, max any) Condition {
+	return Condition{Field: field, Op: OpRange, Values: []any{min, max}}
+}
+
+// Like 构建一个模糊查询条件
+func Like(field string, keyword string) Condition {
+	return Condition{Field: field, Op: OpLike, Values: []any{keyword}}
+}
+
+// ParseCondition 解析url查询条件，格式为 field:op:value，如：
+// status:eq:1、type:in:1,3、price:range:100,500、price:range:,500、title:like:golang
+//
+// 格式不对的条件不会在这里报错，而是在构建sql时被校验拒绝
+func ParseCondition(raw string) Condition {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 {
+		return Condition{Field: raw}
+	}
+
+	c := Condition{Field: strings.TrimSpace(parts[0]), Op: Operator(strings.TrimSpace(parts[1]))}
+	value := parts[2]
+
+	switch c.Op {
+	case OpIn:
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, v)
+			}
+		}
+	case OpRange:
+		bounds := strings.SplitN(value, ",", 2)
+		if len(bounds) != 2 {
+			return c
+		}
+		for _, v := range bounds {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, v)
+			} else {
+				c.Values = append(c.Values, nil)
+			}
+		}
+	default:
+		c.Values = []any{value}
+	}
+
+	return c
 }
 
 // 使用时，先要设置 SortSafelist 安全字段值
@@ -65,6 +148,71 @@ func (f Filters) sortDirection(sortField string) string {
 	return "ASC"
 }
 
+// whereColumn 获取安全的查询条件，返回条件语句、参数和用到的入参字段：
+// ['b.status = ?', 'b.price >= ? and b.price <= ?'], [1, 100, 500], ['status', 'price']
+//
+// 字段不在 WhereSafelist 或者条件值不合法时，返回 ErrInvalidFilter
+func (f Filters) whereColumn(br baseRepo) ([]string, []any, []string, error) {
+	if len(f.WhereSafelist) == 0 {
+		f.WhereSafelist = br.defaultWhereSafelist()
+	}
+
+	wheres := make([]string, 0, len(f.Conditions))
+	args := make([]any, 0, len(f.Conditions))
+	fields := make([]string, 0, len(f.Conditions))
+
+	for _, c := range f.Conditions {
+		column, ok := f.WhereSafelist[c.Field]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("%w: 不支持查询字段 %s", ErrInvalidFilter, c.Field)
+		}
+
+		switch c.Op {
+		case OpEq:
+			if len(c.Values) != 1 {
+				return nil, nil, nil, fmt.Errorf("%w: %s eq 需要一个值", ErrInvalidFilter, c.Field)
+			}
+			wheres = append(wheres, column+" = ?")
+			args = append(args, c.Values[0])
+		case OpIn:
+			if len(c.Values) == 0 {
+				return nil, nil, nil, fmt.Errorf("%w: %s in 至少需要一个值", ErrInvalidFilter, c.Field)
+			}
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(c.Values)), ", ")
+			wheres = append(wheres, fmt.Sprintf("%s in (%s)", column, marks))
+			args = append(args, c.Values...)
+		case OpRange:
+			if len(c.Values) != 2 || (c.Values[0] == nil && c.Values[1] == nil) {
+				return nil, nil, nil, fmt.Errorf("%w: %s range 需要提供最小值或最大值", ErrInvalidFilter, c.Field)
+			}
+			if c.Values[0] != nil {
+				wheres = append(wheres, column+" >= ?")
+				args = append(args, c.Values[0])
+			}
+			if c.Values[1] != nil {
+				wheres = append(wheres, column+" <= ?")
+				args = append(args, c.Values[1])
+			}
+		case OpLike:
+			var keyword string
+			if len(c.Values) == 1 {
+				keyword, _ = c.Values[0].(string)
+			}
+			if keyword == "" {
+				return nil, nil, nil, fmt.Errorf("%w: %s like 需要一个关键字", ErrInvalidFilter, c.Field)
+			}
+			wheres = append(wheres, column+" like ?")
+			args = append(args, "%"+likeEscaper.Replace(keyword)+"%")
+		default:
+			return nil, nil, nil, fmt.Errorf("%w: 不支持的操作符 %s:%s", ErrInvalidFilter, c.Field, c.Op)
+		}
+
+		fields = append(fields, c.Field)
+	}
+
+	return wheres, args, fields, nil
+}
+
 // check 检查PageSize、PageNum 是否满足条件，不满足就设置为默认值
 func (f *Filters) check() {
 	if f.PageSize <= 0 {
@@ -93,10 +241,12 @@ func (f Filters) offset() int {
 }
 
 type Metadata struct {
-	PageNum    int `json:"pageNum,omitzero"`
-	PageSize   int `json:"pageSize,omitzero"`
-	LastPage   int `json:"lastPage,omitzero"`
-	TotalCount int `json:"totalCount,omitzero"`
+	PageNum    int    `json:"pageNum,omitzero"`
+	PageSize   int    `json:"pageSize,omitzero"`
+	LastPage   int    `json:"lastPage,omitzero"`
+	TotalCount int    `json:"totalCount,omitzero"`
+	NextCursor string `json:"nextCursor,omitzero"` // 游标分页下一页游标，没有下一页则为空
+	PrevCursor string `json:"prevCursor,omitzero"` // 游标分页上一页游标，没有上一页则为空
 }
 
 // PageQueryVo 分页数据通用结构体，意在分页数据返回统一结构体
@@ -0,0 +1,91 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type ImageVariantRepo interface {
+	Save(ctx context.Context, url string, srcset models.Srcset) error              // 新增或替换图片的各尺寸地址
+	Srcsets(ctx context.Context, urls ...string) (map[string]models.Srcset, error) // 批量查询，没有生成的图片不在结果中
+	Delete(ctx context.Context, url string) error
+}
+
+var _ ImageVariantRepo = (*imageVariantRepo)(nil)
+
+type imageVariantRepo struct {
+	DB Queryable
+}
+
+func NewImageVariantRepo(db Queryable) *imageVariantRepo {
+	repo := &imageVariantRepo{
+		DB: db,
+	}
+
+	return repo
+}
+
+func (r *imageVariantRepo) Save(ctx context.Context, url string, srcset models.Srcset) error {
+	sql := `
+	insert into image_variants(url, srcset) values(:url, :srcset)
+	on duplicate key update srcset = values(srcset);`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	arg := map[string]any{"url": url, "srcset": srcset}
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, arg)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *imageVariantRepo) Srcsets(ctx context.Context, urls ...string) (map[string]models.Srcset, error) {
+	result := make(map[string]models.Srcset, len(urls))
+	if len(urls) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(`select url, srcset from image_variants where url in (?);`, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, query, "urls", urls)
+
+	var rows []struct {
+		URL    string        `db:"url"`
+		Srcset models.Srcset `db:"srcset"`
+	}
+	if err := r.DB.SelectContext(ctx, &rows, r.DB.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.URL] = row.Srcset
+	}
+	return result, nil
+}
+
+func (r *imageVariantRepo) Delete(ctx context.Context, url string) error {
+	sql := `delete from image_variants where url = :url;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, map[string]any{"url": url})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
@@ -1,6 +1,22 @@
 package dbrepo
 
-type OrderRepo interface{}
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type OrderRepo interface {
+	baseRepo
+	List(ctx context.Context, f Filters) (*PageQueryVo, error)
+
+	// PaidBookIDs 返回 bookIDs 中用户已支付订单包含的图书id
+	PaidBookIDs(ctx context.Context, userID uint64, bookIDs []uint64) ([]uint64, error)
+	// PaidOrderNo 返回用户购买该图书的最早一笔已支付订单的订单号
+	PaidOrderNo(ctx context.Context, userID, bookID uint64) (uint64, error)
+}
 
 var _ OrderRepo = (*orderRepo)(nil)
 
@@ -15,3 +31,104 @@ func NewOrderRepo(db Queryable) *orderRepo {
 
 	return repo
 }
+
+// orderListQuery 订单列表查询
+var orderListQuery = listQuery{
+	columns: "id, order_no, user_id, order_status, order_amount, paid_at, created_at, updated_at",
+	from:    "from orders",
+	where:   []string{"deleted_at is null"},
+}
+
+// List 分页获取订单，支持 Filters.Conditions 查询条件
+func (r *orderRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	q, err := orderListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*models.Order, 0, f.limit())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	return dbtk.makePageQueryVo(metadata, list), nil
+}
+
+// PaidBookIDs 返回 bookIDs 中用户已支付订单包含的图书id，已支付即 paid_at 不为空
+func (r *orderRepo) PaidBookIDs(ctx context.Context, userID uint64, bookIDs []uint64) ([]uint64, error) {
+	list := make([]uint64, 0)
+	if len(bookIDs) == 0 {
+		return list, nil
+	}
+
+	query, args, err := sqlx.In(`
+		select distinct oi.book_id from order_items oi
+		join orders o on o.id = oi.order_id
+		where o.user_id = ? and o.paid_at is not null and o.deleted_at is null
+			and oi.deleted_at is null and oi.book_id in (?);`, userID, bookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, query, slog.Uint64("userID", userID), slog.Int("count", len(bookIDs)))
+
+	err = r.DB.SelectContext(ctx, &list, r.DB.Rebind(query), args...)
+	return list, err
+}
+
+// PaidOrderNo 返回用户购买该图书的最早一笔已支付订单的订单号，同一用户多次下载使用同一个订单号，
+// 没有则返回 sql.ErrNoRows
+func (r *orderRepo) PaidOrderNo(ctx context.Context, userID, bookID uint64) (uint64, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		select o.order_no from orders o
+		join order_items oi on oi.order_id = o.id
+		where o.user_id = ? and oi.book_id = ? and o.paid_at is not null
+			and o.deleted_at is null and oi.deleted_at is null
+		order by o.paid_at, o.id limit 1;`
+
+	slog.DebugContext(ctx, query, slog.Uint64("userID", userID), slog.Uint64("bookID", bookID))
+
+	var orderNo uint64
+	err := r.DB.GetContext(ctx, &orderNo, query, userID, bookID)
+	return orderNo, err
+}
+
+// defaultSortSafelist 导出默认的安全排序字段
+func (r *orderRepo) defaultSortSafelist() []string {
+	return []string{
+		"id", "order_no", "order_amount", "paid_at", "created_at",
+		"-id", "-order_no", "-order_amount", "-paid_at", "-created_at",
+	}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *orderRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":           "id",
+		"order_no":     "order_no",
+		"user_id":      "user_id",
+		"order_status": "order_status",
+		"order_amount": "order_amount",
+		"paid_at":      "paid_at",
+		"created_at":   "created_at",
+	}
+}
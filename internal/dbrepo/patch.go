@@ -0,0 +1,100 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// changedColumns 对比更新前后的数据，返回 columns 中值有变化的列，列名 => 新值；
+// old、new 是同类型带db tag的结构体(指针)
+func changedColumns(old, new any, columns []string) map[string]any {
+	ov := reflect.Indirect(reflect.ValueOf(old))
+	nv := reflect.Indirect(reflect.ValueOf(new))
+	names := fieldMapper.TypeMap(ov.Type()).Names
+
+	changes := make(map[string]any)
+	for _, column := range columns {
+		fi, ok := names[column]
+		if !ok {
+			continue
+		}
+		before := columnValue(reflectx.FieldByIndexesReadOnly(ov, fi.Index))
+		after := columnValue(reflectx.FieldByIndexesReadOnly(nv, fi.Index))
+		if !columnEqual(before, after) {
+			changes[column] = after
+		}
+	}
+
+	return changes
+}
+
+// columnValue 取出写入数据库时的值，自定义类型如 types.GxTime 取 driver.Valuer 的值
+func columnValue(v reflect.Value) any {
+	value := v.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		if dv, err := valuer.Value(); err == nil {
+			return dv
+		}
+	}
+	return value
+}
+
+// columnEqual 比较两个列的值，时间只比较时刻，忽略时区
+func columnEqual(a, b any) bool {
+	ta, ok1 := a.(time.Time)
+	tb, ok2 := b.(time.Time)
+	if ok1 && ok2 {
+		return ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// patch 部分更新，仅更新 changes 中的列；where 为sqlx命名参数格式的更新条件，whereArg 为条件参数，
+// 键不能与 changes 的列名重复；extra 为额外的赋值语句，如：version=version+1
+func (*toolkit) patch(
+	ctx context.Context,
+	db Queryable,
+	table string,
+	changes map[string]any,
+	where string,
+	whereArg map[string]any,
+	extra ...string,
+) (sql.Result, error) {
+	// 排序保证生成的sql稳定
+	columns := slices.Sorted(maps.Keys(changes))
+
+	sets := make([]string, 0, len(columns)+len(extra))
+	for _, column := range columns {
+		sets = append(sets, fmt.Sprintf("%s=:%s", column, column))
+	}
+	sets = append(sets, extra...)
+
+	arg := maps.Clone(changes)
+	for k, v := range whereArg {
+		if _, ok := arg[k]; ok {
+			return nil, fmt.Errorf("dbtk.patch: 条件参数 %s 与更新列重复", k)
+		}
+		arg[k] = v
+	}
+
+	sql := fmt.Sprintf("update %s set %s where %s;", table, strings.Join(sets, ", "), where)
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := dbtk.debugSQL(ctx, db, sql, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ExecContext(ctx, query, args...)
+}
@@ -8,9 +8,12 @@ import (
 )
 
 type PublisherRepo interface {
-	Create(ctx context.Context, name string) (uint64, error)
-	Update(ctx context.Context, name string) error
+	TrashRepo
+	Create(ctx context.Context, publisher *models.Publisher) (uint64, error)
+	Update(ctx context.Context, publisher *models.Publisher) error
+	Patch(ctx context.Context, old, publisher *models.Publisher) error // 部分更新，仅更新有变化的列
 	Get(ctx context.Context, id uint64) (*models.Publisher, error)
+	GetByName(ctx context.Context, name string) (*models.Publisher, error) // 根据名称获取，同名取最早创建的
 	List(ctx context.Context) ([]*models.Publisher, error)
 	Delete(ctx context.Context, id uint64) error
 }
@@ -28,40 +31,71 @@ func NewPublisherRepo(db Queryable) *publisherRepo {
 	return repo
 }
 
-func (r *publisherRepo) Create(ctx context.Context, name string) (uint64, error) {
-	sql := `insert publisher set publisher_name = ?;`
+// publisherColumns 出版社查询字段
+const publisherColumns = "id, publisher_name, logo, address, contact, website, created_at, updated_at"
+
+func (r *publisherRepo) Create(ctx context.Context, publisher *models.Publisher) (uint64, error) {
+	sql := `
+	insert publisher set
+		publisher_name=:publisher_name,
+		logo=:logo,
+		address=:address,
+		contact=:contact,
+		website=:website;`
 
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	query := r.DB.Rebind(sql)
-
-	slog.DebugContext(ctx, query, slog.String("name", name))
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, publisher)
+	if err != nil {
+		return 0, err
+	}
 
-	result, err := r.DB.ExecContext(ctx, query, name)
+	result, err := r.DB.ExecContext(ctx, query, args...)
 
 	return dbtk.insertErrorHandler(ctx, result, err)
 }
 
-func (r *publisherRepo) Update(ctx context.Context, name string) error {
-	sql := `update publisher set publisher_name = ? where deleted_at is null;`
+func (r *publisherRepo) Update(ctx context.Context, publisher *models.Publisher) error {
+	sql := `
+	update publisher set
+		publisher_name=:publisher_name,
+		logo=:logo,
+		address=:address,
+		contact=:contact,
+		website=:website
+	where id=:id and deleted_at is null;`
 
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
-	query := r.DB.Rebind(sql)
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, publisher)
+	if err != nil {
+		return err
+	}
 
-	slog.DebugContext(ctx, query, slog.String("name", name))
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
 
-	result, err := r.DB.ExecContext(ctx, query, name)
+// publisherPatchColumns 允许部分更新的列
+var publisherPatchColumns = []string{"publisher_name", "logo", "address", "contact", "website"}
+
+// Patch 部分更新，仅更新 old 和 publisher 之间有变化的列，没有变化则不执行更新
+func (r *publisherRepo) Patch(ctx context.Context, old, publisher *models.Publisher) error {
+	changes := changedColumns(old, publisher, publisherPatchColumns)
+	if len(changes) == 0 {
+		return nil
+	}
 
+	result, err := dbtk.patch(ctx, r.DB, "publisher", changes, "id=:id and deleted_at is null", map[string]any{"id": old.ID})
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
 
 func (r *publisherRepo) Get(ctx context.Context, id uint64) (publisher *models.Publisher, err error) {
 	sql := `
 		select 
-			id, publisher_name, created_at, updated_at 
+			` + publisherColumns + ` 
 		from 
 			publisher 
 		where 
@@ -80,8 +114,29 @@ func (r *publisherRepo) Get(ctx context.Context, id uint64) (publisher *models.P
 	return publisher, err
 }
 
+// GetByName 根据名称获取，不存在返回 sql.ErrNoRows
+func (r *publisherRepo) GetByName(ctx context.Context, name string) (*models.Publisher, error) {
+	sql := `
+		select 
+			` + publisherColumns + ` 
+		from 
+			publisher 
+		where 
+			publisher_name=? and deleted_at is null 
+		order by id limit 1;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "name", name)
+
+	publisher := new(models.Publisher)
+	err := r.DB.GetContext(ctx, publisher, r.DB.Rebind(sql), name)
+	return publisher, err
+}
+
 func (r *publisherRepo) List(ctx context.Context) (list []*models.Publisher, err error) {
-	sql := `select * from publisher where deleted_at is null;`
+	sql := `select ` + publisherColumns + ` from publisher where deleted_at is null;`
 
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
@@ -107,3 +162,24 @@ func (r *publisherRepo) Delete(ctx context.Context, id uint64) error {
 	result, err := r.DB.ExecContext(ctx, query, id)
 	return dbtk.updateErrorHandler(ctx, result, err)
 }
+
+// publisherTrash 出版社回收站
+var publisherTrash = trash[models.Publisher]{
+	table:   "publisher",
+	columns: publisherColumns + ", deleted_at",
+}
+
+// ListDeleted 分页获取已删除的数据
+func (r *publisherRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return publisherTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *publisherRepo) Restore(ctx context.Context, id uint64) error {
+	return publisherTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *publisherRepo) Purge(ctx context.Context, id uint64) error {
+	return publisherTrash.purge(ctx, r.DB, id)
+}
@@ -0,0 +1,250 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type ReadingRepo interface {
+	Push(ctx context.Context, userID uint64, s *models.ReadingSync) (*models.ReadingPushResult, error) // 按客户端修改时间最后写入为准合并
+	Changes(ctx context.Context, userID, since uint64, limit int) (*models.ReadingChanges, error)      // 同步序号 since 之后的变更
+	State(ctx context.Context, userID, bookID uint64) (*models.ReadingState, error)                    // 一本书的阅读进度和未删除的书签、高亮
+}
+
+var _ ReadingRepo = (*readingRepo)(nil)
+
+type readingRepo struct {
+	DB Queryable
+}
+
+func NewReadingRepo(db Queryable) *readingRepo {
+	var repo = &readingRepo{
+		DB: db,
+	}
+	return repo
+}
+
+// Push 写入阅读进度和书签、高亮，客户端修改时间晚于服务器数据才覆盖，否则忽略；
+// 每条数据分配新的同步序号，包括被忽略的，序号可以不连续
+func (r *readingRepo) Push(ctx context.Context, userID uint64, s *models.ReadingSync) (*models.ReadingPushResult, error) {
+	result := new(models.ReadingPushResult)
+	n := len(s.Progress) + len(s.Annotations)
+	if n == 0 {
+		return result, nil
+	}
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	err := dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		seq, err := nextReadingSeq(ctx, tx.db, userID, n)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range s.Progress {
+			p.UserID, p.Seq = userID, seq
+			seq++
+
+			applied, err := saveReadingProgress(ctx, tx.db, p)
+			if err != nil {
+				return err
+			}
+			if applied {
+				result.Applied++
+			} else {
+				result.Ignored++
+			}
+		}
+
+		for _, a := range s.Annotations {
+			a.UserID, a.Seq = userID, seq
+			seq++
+
+			applied, err := saveReadingAnnotation(ctx, tx.db, a)
+			if err != nil {
+				return err
+			}
+			if applied {
+				result.Applied++
+			} else {
+				result.Ignored++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// nextReadingSeq 为用户分配 n 个连续的同步序号，返回第一个；
+// 在事务中锁住用户的序号行，同一用户的写入按序号顺序提交
+func nextReadingSeq(ctx context.Context, db Queryable, userID uint64, n int) (uint64, error) {
+	query := `insert ignore into reading_sync_seq(user_id, seq) values(?, 0);`
+	slog.DebugContext(ctx, query, "userID", userID)
+	if _, err := db.ExecContext(ctx, query, userID); err != nil {
+		return 0, err
+	}
+
+	query = `update reading_sync_seq set seq = last_insert_id(seq + ?) where user_id = ?;`
+	slog.DebugContext(ctx, query, "userID", userID, "n", n)
+	result, err := db.ExecContext(ctx, query, n, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	last, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(last) - uint64(n) + 1, nil
+}
+
+// saveReadingProgress 写入阅读进度，客户端修改时间晚于已有数据才更新；
+// client_updated_at 必须最后赋值，前面的 if 比较的是更新前的值
+func saveReadingProgress(ctx context.Context, db Queryable, p *models.ReadingProgress) (bool, error) {
+	query := `
+	insert into reading_progress(user_id, book_id, position, percentage, client_updated_at, seq)
+	values(:user_id, :book_id, :position, :percentage, :client_updated_at, :seq)
+	on duplicate key update
+		position = if(values(client_updated_at) > client_updated_at, values(position), position),
+		percentage = if(values(client_updated_at) > client_updated_at, values(percentage), percentage),
+		seq = if(values(client_updated_at) > client_updated_at, values(seq), seq),
+		client_updated_at = greatest(client_updated_at, values(client_updated_at));`
+
+	query, args, err := dbtk.debugSQL(ctx, db, query, p)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	// 新增为1，更新为2，没有变化为0
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// saveReadingAnnotation 写入书签、高亮，规则同 saveReadingProgress
+func saveReadingAnnotation(ctx context.Context, db Queryable, a *models.ReadingAnnotation) (bool, error) {
+	query := `
+	insert into reading_annotations(user_id, book_id, client_id, kind, position, content, note, color, deleted, client_updated_at, seq)
+	values(:user_id, :book_id, :client_id, :kind, :position, :content, :note, :color, :deleted, :client_updated_at, :seq)
+	on duplicate key update
+		book_id = if(values(client_updated_at) > client_updated_at, values(book_id), book_id),
+		kind = if(values(client_updated_at) > client_updated_at, values(kind), kind),
+		position = if(values(client_updated_at) > client_updated_at, values(position), position),
+		content = if(values(client_updated_at) > client_updated_at, values(content), content),
+		note = if(values(client_updated_at) > client_updated_at, values(note), note),
+		color = if(values(client_updated_at) > client_updated_at, values(color), color),
+		deleted = if(values(client_updated_at) > client_updated_at, values(deleted), deleted),
+		seq = if(values(client_updated_at) > client_updated_at, values(seq), seq),
+		client_updated_at = greatest(client_updated_at, values(client_updated_at));`
+
+	query, args, err := dbtk.debugSQL(ctx, db, query, a)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+const (
+	readingProgressColumns   = "book_id, position, percentage, client_updated_at, seq"
+	readingAnnotationColumns = "book_id, client_id, kind, position, content, note, color, deleted, client_updated_at, seq"
+)
+
+// Changes 按序号获取 since 之后最多 limit 条变更，包括已删除的书签、高亮；
+// 两个表共用同一个序号，各取 limit+1 条合并后截取
+func (r *readingRepo) Changes(ctx context.Context, userID, since uint64, limit int) (*models.ReadingChanges, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	query := `select ` + readingProgressColumns + ` from reading_progress
+		where user_id = ? and seq > ? order by seq limit ?;`
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(query, " "), "userID", userID, "since", since)
+
+	progress := make([]*models.ReadingProgress, 0)
+	if err := r.DB.SelectContext(ctx, &progress, query, userID, since, limit+1); err != nil {
+		return nil, err
+	}
+
+	query = `select ` + readingAnnotationColumns + ` from reading_annotations
+		where user_id = ? and seq > ? order by seq limit ?;`
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(query, " "), "userID", userID, "since", since)
+
+	annotations := make([]*models.ReadingAnnotation, 0)
+	if err := r.DB.SelectContext(ctx, &annotations, query, userID, since, limit+1); err != nil {
+		return nil, err
+	}
+
+	changes := &models.ReadingChanges{
+		Progress:    make([]*models.ReadingProgress, 0),
+		Annotations: make([]*models.ReadingAnnotation, 0),
+	}
+
+	// 按序号合并，最多取 limit 条
+	next := since
+	i, j := 0, 0
+	for i+j < limit && (i < len(progress) || j < len(annotations)) {
+		if j >= len(annotations) || (i < len(progress) && progress[i].Seq < annotations[j].Seq) {
+			changes.Progress = append(changes.Progress, progress[i])
+			next = progress[i].Seq
+			i++
+		} else {
+			changes.Annotations = append(changes.Annotations, annotations[j])
+			next = annotations[j].Seq
+			j++
+		}
+	}
+
+	changes.HasMore = i < len(progress) || j < len(annotations)
+	changes.Next = strconv.FormatUint(next, 10)
+	return changes, nil
+}
+
+// State 获取一本书的阅读进度和未删除的书签、高亮，没有阅读记录时 Progress 为nil
+func (r *readingRepo) State(ctx context.Context, userID, bookID uint64) (*models.ReadingState, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	state := &models.ReadingState{}
+
+	query := `select ` + readingProgressColumns + ` from reading_progress where user_id = ? and book_id = ?;`
+	slog.DebugContext(ctx, query, "userID", userID, "bookID", bookID)
+
+	progress := new(models.ReadingProgress)
+	err := r.DB.GetContext(ctx, progress, query, userID, bookID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		state.Progress = progress
+	}
+
+	query = `select ` + readingAnnotationColumns + ` from reading_annotations
+		where user_id = ? and book_id = ? and deleted = 0 order by seq;`
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(query, " "), "userID", userID, "bookID", bookID)
+
+	state.Annotations = make([]*models.ReadingAnnotation, 0)
+	err = r.DB.SelectContext(ctx, &state.Annotations, query, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
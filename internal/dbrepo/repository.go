@@ -1,5 +1,7 @@
 package dbrepo
 
+import "context"
+
 type Repository struct {
 	BookRepo         BookRepo
 	AuthorRepo       AuthorRepo
@@ -10,6 +12,16 @@ type Repository struct {
 	UserRepo         UserRepo
 	OrderRepo        OrderRepo
 	ShoppingCartRepo ShoppingCartRepo
+	DownloadLogRepo  DownloadLogRepo
+	BookPreviewRepo  BookPreviewRepo
+	ImageVariantRepo ImageVariantRepo
+	FileRepo         FileRepo
+	SeriesRepo       SeriesRepo
+	ReviewRepo       ReviewRepo
+	FavoriteRepo     FavoriteRepo
+	ReadingRepo      ReadingRepo
+
+	db Queryable
 }
 
 // NewRepository创建一个Repository仓库，使用Queryable接口，同时兼容sql.DB和sql.Tx方法
@@ -24,5 +36,20 @@ func NewRepository(db Queryable) Repository {
 		UserRepo:         NewUserRepo(db),
 		OrderRepo:        NewOrderRepo(db),
 		ShoppingCartRepo: NewShoppingCartRepo(db),
+		DownloadLogRepo:  NewDownloadLogRepo(db),
+		BookPreviewRepo:  NewBookPreviewRepo(db),
+		ImageVariantRepo: NewImageVariantRepo(db),
+		FileRepo:         NewFileRepo(db),
+		SeriesRepo:       NewSeriesRepo(db),
+		ReviewRepo:       NewReviewRepo(db),
+		FavoriteRepo:     NewFavoriteRepo(db),
+		ReadingRepo:      NewReadingRepo(db),
+		db:               db,
 	}
 }
+
+// ExecTx 在事务中执行fn，fn 的参数是基于同一个事务的 Repository，fn 返回错误则回滚；
+// 只能在基于 sqlx.DB 创建的 Repository 上调用
+func (r Repository) ExecTx(ctx context.Context, fn func(Repository) error) error {
+	return dbtk.execTx(ctx, r.db, fn)
+}
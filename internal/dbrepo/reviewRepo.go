@@ -0,0 +1,236 @@
+package dbrepo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type ReviewRepo interface {
+	baseRepo
+	Save(ctx context.Context, review *models.Review) (uint64, error) // 新增或修改用户对图书的评价，修改后重新进入待审核
+	Get(ctx context.Context, id uint64) (*models.Review, error)
+	List(ctx context.Context, f Filters) (*PageQueryVo, error)                      // 全部评价，审核列表使用
+	ListByBook(ctx context.Context, bookID uint64, f Filters) (*PageQueryVo, error) // 图书已通过的评价
+	Moderate(ctx context.Context, id uint64, m *models.ReviewModeration) error      // 审核评价并重新计算图书评分
+	Vote(ctx context.Context, id, userID uint64) error                              // 投票有用，重复投票忽略，只能给他人已通过的评价投票
+}
+
+var _ ReviewRepo = (*reviewRepo)(nil)
+
+type reviewRepo struct {
+	DB Queryable
+}
+
+func NewReviewRepo(db Queryable) *reviewRepo {
+	var repo = &reviewRepo{
+		DB: db,
+	}
+	return repo
+}
+
+// Save 新增或修改评价，同一用户对同一本书只有一条评价；
+// 修改后状态重置为待审核，原来已通过的评价不再计入评分
+func (r *reviewRepo) Save(ctx context.Context, review *models.Review) (uint64, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	var id uint64
+	err := dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		// 使用 last_insert_id(id) 使修改时也返回评价id
+		sql := `
+		insert into reviews(user_id, book_id, rating, content, status)
+		values(:user_id, :book_id, :rating, :content, :status)
+		on duplicate key update
+			id=last_insert_id(id),
+			rating=values(rating),
+			content=values(content),
+			status=values(status),
+			reason='',
+			moderated_at=null;`
+
+		review.Status = models.ReviewPending
+		query, args, err := dbtk.debugSQL(ctx, tx.db, sql, review)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.db.ExecContext(ctx, query, args...)
+		id, err = dbtk.insertErrorHandler(ctx, result, err)
+		if err != nil {
+			return err
+		}
+
+		return refreshBookRating(ctx, tx.db, review.BookID)
+	})
+	return id, err
+}
+
+// reviewColumns 评价查询字段，包括评价用户的昵称、头像
+const reviewColumns = `
+	rv.id, rv.user_id, rv.book_id, rv.rating, rv.content, rv.status, rv.reason,
+	rv.helpful_count, rv.moderated_at, rv.created_at, rv.updated_at,
+	u.nickname as "user.nickname",
+	u.avatar as "user.avatar"`
+
+func (r *reviewRepo) Get(ctx context.Context, id uint64) (*models.Review, error) {
+	sql := `
+		select ` + reviewColumns + `
+		from reviews rv
+		left join users u on u.id = rv.user_id
+		where rv.id = ?;`
+
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "id", id)
+
+	review := new(models.Review)
+	err := r.DB.GetContext(ctx, review, sql, id)
+	return review, err
+}
+
+// reviewListQuery 评价列表查询
+var reviewListQuery = listQuery{
+	columns: reviewColumns,
+	from: `
+	from reviews rv
+	left join users u on u.id = rv.user_id`,
+}
+
+// List 分页获取评价，支持 Filters.Conditions 查询条件，如按 status 获取待审核的评价
+func (r *reviewRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	q, err := reviewListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*models.Review, 0, f.limit())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	return dbtk.makePageQueryVo(metadata, list), nil
+}
+
+// ListByBook 分页获取图书已通过的评价
+func (r *reviewRepo) ListByBook(ctx context.Context, bookID uint64, f Filters) (*PageQueryVo, error) {
+	f.Conditions = append(f.Conditions, Eq("book_id", bookID), Eq("status", models.ReviewApproved))
+	return r.List(ctx, f)
+}
+
+// Moderate 审核评价，状态变化后重新计算图书评分；评价不存在返回 sql.ErrNoRows
+func (r *reviewRepo) Moderate(ctx context.Context, id uint64, m *models.ReviewModeration) error {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	return dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		var bookID uint64
+		sql := `select book_id from reviews where id = ? for update;`
+		slog.DebugContext(ctx, sql, "id", id)
+		if err := tx.db.GetContext(ctx, &bookID, sql, id); err != nil {
+			return err
+		}
+
+		reason := m.Reason
+		if m.Status == models.ReviewApproved {
+			reason = ""
+		}
+
+		sql = `update reviews set status = ?, reason = ?, moderated_at = now() where id = ?;`
+		slog.DebugContext(ctx, sql, "id", id, "status", m.Status)
+		if _, err := tx.db.ExecContext(ctx, sql, m.Status, reason, id); err != nil {
+			return err
+		}
+
+		return refreshBookRating(ctx, tx.db, bookID)
+	})
+}
+
+// Vote 投票有用，评价不存在或未通过返回 sql.ErrNoRows，给自己的评价投票返回 ErrReviewSelfVote
+func (r *reviewRepo) Vote(ctx context.Context, id, userID uint64) error {
+	ctx, cancel := dbtk.withTimeout(ctx)
+	defer cancel()
+
+	return dbtk.execTx(ctx, r.DB, func(tx Repository) error {
+		var ownerID uint64
+		sql := `select user_id from reviews where id = ? and status = ?;`
+		slog.DebugContext(ctx, sql, "id", id)
+		err := tx.db.GetContext(ctx, &ownerID, sql, id, models.ReviewApproved)
+		if err != nil {
+			return err
+		}
+		if ownerID == userID {
+			return ErrReviewSelfVote
+		}
+
+		sql = `insert ignore into review_votes(review_id, user_id) values(?, ?);`
+		slog.DebugContext(ctx, sql, "id", id, "userID", userID)
+		result, err := tx.db.ExecContext(ctx, sql, id, userID)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+
+		sql = `update reviews set helpful_count = helpful_count + 1 where id = ?;`
+		_, err = tx.db.ExecContext(ctx, sql, id)
+		return err
+	})
+}
+
+// refreshBookRating 根据已通过的评价重新计算图书的平均分和评价数
+func refreshBookRating(ctx context.Context, db Queryable, bookID uint64) error {
+	sql := `
+	update books b
+	join (
+		select count(*) as n, coalesce(avg(rating), 0) as avg
+		from reviews where book_id = ? and status = ?
+	) r
+	set b.rating_avg = r.avg, b.rating_count = r.n
+	where b.id = ?;`
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "bookID", bookID)
+
+	_, err := db.ExecContext(ctx, sql, bookID, models.ReviewApproved, bookID)
+	return err
+}
+
+// defaultSortSafelist 导出默认的安全排序字段，helpful_count 按有用数、created_at 按时间
+func (r *reviewRepo) defaultSortSafelist() []string {
+	return []string{
+		"id", "rating", "helpful_count", "created_at", "updated_at",
+		"-id", "-rating", "-helpful_count", "-created_at", "-updated_at",
+	}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *reviewRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":            "rv.id",
+		"user_id":       "rv.user_id",
+		"book_id":       "rv.book_id",
+		"rating":        "rv.rating",
+		"status":        "rv.status",
+		"helpful_count": "rv.helpful_count",
+		"created_at":    "rv.created_at",
+		"updated_at":    "rv.updated_at",
+	}
+}
@@ -0,0 +1,328 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+type SeriesRepo interface {
+	TrashRepo
+	baseRepo
+	Create(ctx context.Context, series *models.Series) (uint64, error)
+	Update(ctx context.Context, series *models.Series) error
+	Patch(ctx context.Context, old, series *models.Series) error // 部分更新，仅更新有变化的列
+	Get(ctx context.Context, id uint64) (*models.Series, error)
+	List(ctx context.Context, f Filters) (*PageQueryVo, error)
+	Delete(ctx context.Context, id uint64) error
+
+	ListVolumes(ctx context.Context, seriesID uint64) ([]*models.SeriesVolume, error) // 按卷号排序的未删除图书
+	SaveBook(ctx context.Context, sb *models.SeriesBook) error                        // 把图书加入系列，已在其他系列中则移动过来，卷号重复返回冲突
+	RemoveBook(ctx context.Context, seriesID, bookID uint64) error                    // 图书不在该系列中返回 ErrNotFound
+}
+
+var _ SeriesRepo = (*seriesRepo)(nil)
+
+type seriesRepo struct {
+	DB Queryable
+}
+
+func NewSeriesRepo(db Queryable) *seriesRepo {
+	var repo = &seriesRepo{
+		DB: db,
+	}
+	return repo
+}
+
+// seriesColumns 系列查询字段
+const seriesColumns = "id, series_name, description, cover_url, created_at, updated_at"
+
+func (r *seriesRepo) Create(ctx context.Context, series *models.Series) (uint64, error) {
+	sql := `
+	insert series set
+		series_name=:series_name,
+		description=:description,
+		cover_url=:cover_url;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, series)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.insertErrorHandler(ctx, result, err)
+}
+
+func (r *seriesRepo) Update(ctx context.Context, series *models.Series) error {
+	sql := `
+	update series set
+		series_name=:series_name,
+		description=:description,
+		cover_url=:cover_url
+	where id=:id and deleted_at is null;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, series)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+// seriesPatchColumns 允许部分更新的列
+var seriesPatchColumns = []string{"series_name", "description", "cover_url"}
+
+// Patch 部分更新，仅更新 old 和 series 之间有变化的列，没有变化则不执行更新
+func (r *seriesRepo) Patch(ctx context.Context, old, series *models.Series) error {
+	changes := changedColumns(old, series, seriesPatchColumns)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	result, err := dbtk.patch(ctx, r.DB, "series", changes, "id=:id and deleted_at is null", map[string]any{"id": old.ID})
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+func (r *seriesRepo) Get(ctx context.Context, id uint64) (series *models.Series, err error) {
+	sql := `
+		select
+			` + seriesColumns + `
+		from
+			series
+		where
+			id=? and deleted_at is null;`
+	series = new(models.Series)
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	err = r.DB.GetContext(ctx, series, sql, id)
+	return series, err
+}
+
+// seriesListQuery 系列列表查询
+var seriesListQuery = listQuery{
+	columns: seriesColumns,
+	from:    "from series",
+	where:   []string{"deleted_at is null"},
+}
+
+// List 分页获取，支持 Filters.Conditions 查询条件
+func (r *seriesRepo) List(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	q, err := seriesListQuery.build(r.DB, f, r)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*models.Series, 0, f.limit())
+
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	vo := dbtk.makePageQueryVo(metadata, list)
+
+	return vo, err
+}
+
+// Delete 软删除系列，图书与系列的关联保留，恢复后仍然有效
+func (r *seriesRepo) Delete(ctx context.Context, id uint64) error {
+	sql := `update series set deleted_at = now() where id = ? and deleted_at is null;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	slog.DebugContext(ctx, sql, slog.Int64("id", int64(id)))
+
+	result, err := r.DB.ExecContext(ctx, sql, id)
+	return dbtk.updateErrorHandler(ctx, result, err)
+}
+
+// ListVolumes 按卷号获取系列中未删除的图书，包括作者、出版社
+func (r *seriesRepo) ListVolumes(ctx context.Context, seriesID uint64) ([]*models.SeriesVolume, error) {
+	sql := `
+	select
+		sb.volume,
+		b.id, b.isbn, b.title, b.subtitle, b.author_id, b.cover_url, b.publisher_id, b.pubdate,
+		b.price, b.status, b.type, b.stock, b.rating_avg, b.rating_count, b.source_url, b.description,
+		b.version, b.created_at, b.updated_at, b.deleted_at,
+		a.id as "author.id",
+		a.author_name as "author.author_name",
+		p.id as "publisher.id",
+		p.publisher_name as "publisher.publisher_name"
+	from series_books sb
+	join books b on b.id = sb.book_id and b.deleted_at is null
+	left join author a on a.id = b.author_id
+	left join publisher p on p.id = b.publisher_id
+	where sb.series_id = ?
+	order by sb.volume;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(sql, " "), "seriesID", seriesID)
+
+	list := make([]*models.SeriesVolume, 0)
+	err := r.DB.SelectContext(ctx, &list, sql, seriesID)
+	return list, err
+}
+
+// SaveBook 把图书加入系列或修改卷号，一本书只属于一个系列，已在其他系列中则移动过来；
+// 系列或图书不存在返回 ErrNotFound
+func (r *seriesRepo) SaveBook(ctx context.Context, sb *models.SeriesBook) error {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	exists, err := dbtk.existsAlive(ctx, r.DB, "series", "id=?", sb.SeriesID)
+	if err == nil && exists {
+		exists, err = dbtk.existsAlive(ctx, r.DB, "books", "id=?", sb.BookID)
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	sql := `
+	insert into series_books(book_id, series_id, volume)
+	values(:book_id, :series_id, :volume)
+	on duplicate key update series_id=values(series_id), volume=values(volume);`
+
+	query, args, err := dbtk.debugSQL(ctx, r.DB, sql, sb)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// RemoveBook 把图书移出系列，图书不在该系列中返回 ErrNotFound
+func (r *seriesRepo) RemoveBook(ctx context.Context, seriesID, bookID uint64) error {
+	sql := `delete from series_books where series_id = ? and book_id = ?;`
+
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	slog.DebugContext(ctx, sql, "seriesID", seriesID, "bookID", bookID)
+
+	result, err := r.DB.ExecContext(ctx, sql, seriesID, bookID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// bookSeries 查询图书所属的未删除系列，以及卷号大于当前卷的第一本未删除且已上架的图书作为下一卷；
+// 不属于任何系列返回 nil
+func bookSeries(ctx context.Context, db Queryable, bookID uint64) (*models.BookSeries, error) {
+	query := `
+	select sb.series_id, s.series_name, sb.volume
+	from series_books sb
+	join series s on s.id = sb.series_id and s.deleted_at is null
+	where sb.book_id = ?;`
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(query, " "), "bookID", bookID)
+
+	series := new(models.BookSeries)
+	err := db.GetContext(ctx, series, query, bookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nextSQL := `
+	select sb.volume, b.id, b.title, b.cover_url
+	from series_books sb
+	join books b on b.id = sb.book_id and b.deleted_at is null and b.status = 1
+	where sb.series_id = ? and sb.volume > ?
+	order by sb.volume
+	limit 1;`
+
+	slog.DebugContext(ctx, spaceRex.ReplaceAllString(nextSQL, " "), "seriesID", series.SeriesID, "volume", series.Volume)
+
+	next := new(models.SeriesVolume)
+	err = db.GetContext(ctx, next, nextSQL, series.SeriesID, series.Volume)
+	if errors.Is(err, sql.ErrNoRows) {
+		return series, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	series.Next = next
+	return series, nil
+}
+
+// defaultSortSafelist 导出默认的安全排序字段
+func (r *seriesRepo) defaultSortSafelist() []string {
+	return []string{
+		"id", "series_name", "created_at", "updated_at",
+		"-id", "-series_name", "-created_at", "-updated_at",
+	}
+}
+
+// defaultWhereSafelist 导出默认的安全查询字段
+func (r *seriesRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":          "id",
+		"series_name": "series_name",
+		"created_at":  "created_at",
+		"updated_at":  "updated_at",
+	}
+}
+
+// seriesTrash 系列回收站，彻底删除时级联删除图书与系列的关联
+var seriesTrash = trash[models.Series]{
+	table:   "series",
+	columns: seriesColumns + ", deleted_at",
+}
+
+// ListDeleted 分页获取已删除的数据
+func (r *seriesRepo) ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) {
+	return seriesTrash.list(ctx, r.DB, f)
+}
+
+// Restore 恢复已删除的数据
+func (r *seriesRepo) Restore(ctx context.Context, id uint64) error {
+	return seriesTrash.restore(ctx, r.DB, id)
+}
+
+// Purge 彻底删除已软删除的数据
+func (r *seriesRepo) Purge(ctx context.Context, id uint64) error {
+	return seriesTrash.purge(ctx, r.DB, id)
+}
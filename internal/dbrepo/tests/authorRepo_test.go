@@ -16,7 +16,7 @@ func createAuthor(t *testing.T) *models.Author {
 	defer cancel()
 
 	var name = random.RandomString(6)
-	newID, err := tRepo.AuthorRepo.Create(ctx, name)
+	newID, err := tRepo.AuthorRepo.Create(ctx, &models.Author{AuthorName: name})
 	require.NoError(t, err)
 	require.True(t, newID > 0)
 
@@ -40,12 +40,20 @@ func TestUpdateAuthor(t *testing.T) {
 	defer cancel()
 	newName := random.RandomString(7)
 	time.Sleep(time.Second * 3)
-	err := tRepo.AuthorRepo.Update(ctx, a.ID, newName)
+	a.AuthorName = newName
+	a.Biography = random.RandomString(64)
+	a.Nationality = random.RandomString(6)
+	a.Website = "https://example.com/" + random.RandomString(6)
+	a.Aliases = models.Aliases{random.RandomString(4), random.RandomString(5)}
+	err := tRepo.AuthorRepo.Update(ctx, a)
 	require.NoError(t, err)
 	a2, err := tRepo.AuthorRepo.Get(ctx, a.ID)
 	require.NoError(t, err)
 	require.Equal(t, a.ID, a2.ID)
 	require.Equal(t, newName, a2.AuthorName)
+	require.Equal(t, a.Biography, a2.Biography)
+	require.Equal(t, a.Website, a2.Website)
+	require.Equal(t, a.Aliases, a2.Aliases)
 	require.WithinDuration(t, a2.UpdatedAt.Time, time.Now(), time.Second)
 }
 
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -99,6 +100,51 @@ func TestUpdateBook(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestUpdateBookVersionConflict(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	b1 := createBook(t)
+	version := b1.Version
+
+	b1.Title = random.RandomString(8)
+	err := tRepo.BookRepo.Update(ctx, b1)
+	require.NoError(t, err)
+	require.Equal(t, version+1, b1.Version)
+
+	// 使用旧版本号更新
+	b1.Version = version
+	b1.Title = random.RandomString(8)
+	err = tRepo.BookRepo.Update(ctx, b1)
+	require.ErrorIs(t, err, dbrepo.ErrVersionConflict)
+	require.Equal(t, version, b1.Version)
+}
+
+func TestPatchBook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	old := createBook(t)
+
+	b1 := *old
+	b1.Title = random.RandomString(8)
+	b1.Price = old.Price + 100
+	err := tRepo.BookRepo.Patch(ctx, old, &b1)
+	require.NoError(t, err)
+	require.Equal(t, old.Version+1, b1.Version)
+
+	b2, err := tRepo.BookRepo.Get(ctx, old.ID)
+	require.NoError(t, err)
+	require.Equal(t, b1.Title, b2.Title)
+	require.Equal(t, b1.Price, b2.Price)
+	require.Equal(t, old.Subtitle, b2.Subtitle)
+	require.Equal(t, b1.Version, b2.Version)
+
+	// old 的版本号已过期
+	b3 := *old
+	b3.Title = random.RandomString(8)
+	err = tRepo.BookRepo.Patch(ctx, old, &b3)
+	require.ErrorIs(t, err, dbrepo.ErrVersionConflict)
+}
+
 func TestDeleted(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
 	defer cancel()
@@ -112,6 +158,27 @@ func TestDeleted(t *testing.T) {
 	require.ErrorIs(t, err, sql.ErrNoRows)
 }
 
+func TestDeleteVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	b := createBook(t)
+
+	// 版本号已过期
+	err := tRepo.BookRepo.DeleteVersion(ctx, b.ID, b.Version+1)
+	require.ErrorIs(t, err, dbrepo.ErrVersionConflict)
+	_, err = tRepo.BookRepo.Get(ctx, b.ID)
+	require.NoError(t, err)
+
+	err = tRepo.BookRepo.DeleteVersion(ctx, b.ID, b.Version)
+	require.NoError(t, err)
+	_, err = tRepo.BookRepo.Get(ctx, b.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// 已删除
+	err = tRepo.BookRepo.DeleteVersion(ctx, b.ID, b.Version)
+	require.ErrorIs(t, err, dbrepo.ErrVersionConflict)
+}
+
 func TestListBook(t *testing.T) {
 	var size = 10
 	for range size {
@@ -206,7 +273,7 @@ func TestListByCategory(t *testing.T) {
 		PageNum:  1,
 		PageSize: 10,
 	}
-	list, err := tRepo.BookRepo.ListByCategory(ctx, c1.ID, f)
+	list, err := tRepo.BookRepo.ListByCategory(ctx, c1.ID, false, f)
 	require.NoError(t, err)
 	require.NotEmpty(t, list)
 
@@ -308,6 +375,117 @@ func TestListByPublisher(t *testing.T) {
 	fmt.Println(string(by))
 }
 
+func TestListBookWithConditions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	a := createAuthor(t)
+	p := createPublisher(t)
+
+	for i := range 6 {
+		b := makeEmptyIDBookBy(a.ID, p.ID)
+		b.Price = uint(100 * (i + 1))
+		_, err := tRepo.BookRepo.Create(ctx, b)
+		require.NoError(t, err)
+	}
+
+	f := dbrepo.Filters{
+		PageNum:  1,
+		PageSize: 2,
+		Conditions: []dbrepo.Condition{
+			dbrepo.Eq("author_id", a.ID),
+			dbrepo.Range("price", 200, 500),
+		},
+		SortFields: []string{"-price"},
+	}
+	vo, err := tRepo.BookRepo.List(ctx, f)
+	require.NoError(t, err)
+	list := vo.List.([]*models.Book)
+	require.Len(t, list, 2)
+	require.Equal(t, 4, vo.Metadata.TotalCount)
+	require.Equal(t, uint(500), list[0].Price)
+
+	f.Conditions = []dbrepo.Condition{dbrepo.Eq("password", "x")}
+	_, err = tRepo.BookRepo.List(ctx, f)
+	require.ErrorIs(t, err, dbrepo.ErrInvalidFilter)
+}
+
+func TestListBookByCursor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	a := createAuthor(t)
+	p := createPublisher(t)
+
+	for range 5 {
+		_, err := tRepo.BookRepo.Create(ctx, makeEmptyIDBookBy(a.ID, p.ID))
+		require.NoError(t, err)
+	}
+
+	f := dbrepo.Filters{
+		PageSize:   2,
+		UseCursor:  true,
+		Conditions: []dbrepo.Condition{dbrepo.Eq("author_id", a.ID)},
+		SortFields: []string{"-id"},
+	}
+
+	// 向后翻页直到没有下一页
+	var ids []uint64
+	for {
+		vo, err := tRepo.BookRepo.List(ctx, f)
+		require.NoError(t, err)
+		for _, b := range vo.List.([]*models.Book) {
+			ids = append(ids, b.ID)
+		}
+		if vo.Metadata.NextCursor == "" {
+			break
+		}
+		f.Cursor = vo.Metadata.NextCursor
+	}
+	require.Len(t, ids, 5)
+	require.IsDecreasing(t, ids)
+
+	// 从第二页向前翻页回到第一页
+	f.Cursor = ""
+	first, err := tRepo.BookRepo.List(ctx, f)
+	require.NoError(t, err)
+	require.Empty(t, first.Metadata.PrevCursor)
+
+	f.Cursor = first.Metadata.NextCursor
+	second, err := tRepo.BookRepo.List(ctx, f)
+	require.NoError(t, err)
+	require.NotEmpty(t, second.Metadata.PrevCursor)
+
+	// 总数只在首页查询
+	require.Equal(t, 5, first.Metadata.TotalCount)
+	require.Zero(t, second.Metadata.TotalCount)
+
+	f.Cursor = second.Metadata.PrevCursor
+	back, err := tRepo.BookRepo.List(ctx, f)
+	require.NoError(t, err)
+	require.Equal(t, first.List, back.List)
+	require.Empty(t, back.Metadata.PrevCursor)
+}
+
+func TestListBookByCursorInvalidField(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+
+	// join表的字段不能作为游标字段
+	for _, field := range []string{"category_id", "author_name", "password"} {
+		text := fmt.Sprintf(`{"f":%q,"v":1,"i":1}`, field)
+		f := dbrepo.Filters{
+			PageSize:  2,
+			UseCursor: true,
+			Cursor:    base64.RawURLEncoding.EncodeToString([]byte(text)),
+		}
+		_, err := tRepo.BookRepo.List(ctx, f)
+		require.ErrorIs(t, err, dbrepo.ErrInvalidFilter, field)
+	}
+
+	f := dbrepo.Filters{PageSize: 2, UseCursor: true, SortFields: []string{"-price"}}
+	_, err := tRepo.BookRepo.List(ctx, f)
+	require.NoError(t, err)
+}
+
 func TestListShoppingCart(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
 	defer cancel()
@@ -315,3 +493,130 @@ func TestListShoppingCart(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(data)
 }
+
+func TestBookTrash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	b1 := createBook(t)
+
+	err := tRepo.BookRepo.Delete(ctx, b1.ID)
+	require.NoError(t, err)
+
+	vo, err := tRepo.BookRepo.ListDeleted(ctx, dbrepo.Filters{PageNum: 1, PageSize: 10})
+	require.NoError(t, err)
+	list := vo.List.([]*models.Book)
+	require.NotEmpty(t, list)
+	require.NotNil(t, list[0].DeletedAt)
+
+	// 已删除的isbn可以重新创建
+	b2 := makeEmptyIDBookBy(b1.AuthorID, b1.PublisherID)
+	b2.ISBN = b1.ISBN
+	id2, err := tRepo.BookRepo.Create(ctx, b2)
+	require.NoError(t, err)
+
+	// isbn已被占用，不能恢复
+	err = tRepo.BookRepo.Restore(ctx, b1.ID)
+	require.Error(t, err)
+
+	// 未删除的数据不能彻底删除
+	err = tRepo.BookRepo.Purge(ctx, id2)
+	require.ErrorIs(t, err, dbrepo.ErrNotFound)
+
+	err = tRepo.BookRepo.Delete(ctx, id2)
+	require.NoError(t, err)
+	err = tRepo.BookRepo.Purge(ctx, id2)
+	require.NoError(t, err)
+
+	err = tRepo.BookRepo.Restore(ctx, b1.ID)
+	require.NoError(t, err)
+
+	_, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+}
+
+func TestBookPreview(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+	b := createBook(t)
+
+	_, err := tRepo.BookPreviewRepo.Get(ctx, b.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = tRepo.BookPreviewRepo.Save(ctx, &models.BookPreview{BookID: b.ID, Mode: models.PreviewChapters, Value: 3})
+	require.NoError(t, err)
+
+	// 再次保存为更新
+	err = tRepo.BookPreviewRepo.Save(ctx, &models.BookPreview{BookID: b.ID, Mode: models.PreviewPercent, Value: 10})
+	require.NoError(t, err)
+
+	p, err := tRepo.BookPreviewRepo.Get(ctx, b.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PreviewPercent, p.Mode)
+	require.Equal(t, uint(10), p.Value)
+	require.Nil(t, p.GeneratedAt)
+
+	err = tRepo.BookPreviewRepo.Generated(ctx, b.ID, b.SourceUrl)
+	require.NoError(t, err)
+	p, err = tRepo.BookPreviewRepo.Get(ctx, b.ID)
+	require.NoError(t, err)
+	require.NotNil(t, p.GeneratedAt)
+	require.Equal(t, b.SourceUrl, p.SourceUrl)
+
+	err = tRepo.BookPreviewRepo.Delete(ctx, b.ID)
+	require.NoError(t, err)
+	_, err = tRepo.BookPreviewRepo.Get(ctx, b.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestBookContributors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+
+	a1 := createAuthor(t)
+	a2 := createAuthor(t)
+	translator := createAuthor(t)
+	p := createPublisher(t)
+
+	b1 := makeEmptyIDBookBy(0, p.ID)
+	b1.Contributors = []*models.BookContributor{
+		{AuthorID: translator.ID, Role: models.RoleTranslator, Sort: 2},
+		{AuthorID: a1.ID, Role: models.RoleAuthor, Sort: 0},
+		{AuthorID: a2.ID, Role: models.RoleAuthor, Sort: 1},
+	}
+	newID, err := tRepo.BookRepo.CreateTx(ctx, b1)
+	require.NoError(t, err)
+
+	// 第一作者同步到 author_id
+	b2, err := tRepo.BookRepo.Get(ctx, newID)
+	require.NoError(t, err)
+	require.Equal(t, a1.ID, b2.AuthorID)
+	require.Equal(t, a1.AuthorName, b2.Author.AuthorName)
+	require.Len(t, b2.Contributors, 3)
+	require.Equal(t, a1.ID, b2.Contributors[0].AuthorID)
+	require.Equal(t, a2.ID, b2.Contributors[1].AuthorID)
+	require.Equal(t, translator.ID, b2.Contributors[2].AuthorID)
+	require.Equal(t, translator.AuthorName, b2.Contributors[2].Author.AuthorName)
+
+	// 任意角色都能按作者查询到
+	for _, id := range []uint64{a1.ID, a2.ID, translator.ID} {
+		vo, err := tRepo.BookRepo.ListByAuthor(ctx, id, dbrepo.Filters{PageNum: 1, PageSize: 10})
+		require.NoError(t, err)
+		list := vo.List.([]*models.Book)
+		require.Len(t, list, 1)
+		require.Equal(t, newID, list[0].ID)
+	}
+
+	// 只修改 author_id 替换第一作者，其他贡献者保留
+	a3 := createAuthor(t)
+	b3 := *b2
+	b3.AuthorID = a3.ID
+	err = tRepo.BookRepo.Patch(ctx, b2, &b3)
+	require.NoError(t, err)
+
+	list, err := tRepo.BookRepo.ListContributors(ctx, newID)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, a3.ID, list[0].AuthorID)
+	require.Equal(t, a2.ID, list[1].AuthorID)
+	require.Equal(t, translator.ID, list[2].AuthorID)
+}
@@ -2,19 +2,25 @@ package tests
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/lightsaid/ebook/internal/dbrepo"
 	"github.com/lightsaid/ebook/internal/models"
 	"github.com/lightsaid/ebook/pkg/random"
 	"github.com/stretchr/testify/require"
 )
 
 func createCategory(t *testing.T) *models.Category {
+	return createChildCategory(t, 0)
+}
+
+func createChildCategory(t *testing.T, parentID uint64) *models.Category {
 	var c = models.Category{
+		ParentID:     parentID,
 		CategoryName: random.RandomString(4),
 		Icon:         random.RandomString(10),
-		Sort:         random.RandomInt(1, 100),
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -29,7 +35,7 @@ func createCategory(t *testing.T) *models.Category {
 	require.True(t, id == c2.ID)
 	require.Equal(t, c.CategoryName, c2.CategoryName)
 	require.Equal(t, c.Icon, c2.Icon)
-	require.Equal(t, c.Sort, c2.Sort)
+	require.Equal(t, parentID, c2.ParentID)
 	require.WithinDuration(t, time.Now(), c2.CreatedAt.Time, time.Second)
 	require.WithinDuration(t, time.Now(), c2.UpdatedAt.Time, time.Second)
 
@@ -47,7 +53,7 @@ func TestUpdateCategory(t *testing.T) {
 	c2 := models.Category{
 		CategoryName: random.RandomString(4),
 		Icon:         random.RandomString(10),
-		Sort:         random.RandomInt(1, 100),
+		Sort:         c1.Sort + 100,
 	}
 
 	c2.ID = c1.ID
@@ -60,7 +66,73 @@ func TestUpdateCategory(t *testing.T) {
 
 	require.Equal(t, c3.CategoryName, c2.CategoryName)
 	require.Equal(t, c3.Icon, c2.Icon)
-	require.Equal(t, c3.Sort, c2.Sort)
+	// 排序只能通过 Move 调整
+	require.Equal(t, c1.Sort, c3.Sort)
+}
+
+func TestMoveCategory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	root := createCategory(t)
+	a := createChildCategory(t, root.ID)
+	b := createChildCategory(t, root.ID)
+	c := createChildCategory(t, root.ID)
+	a1 := createChildCategory(t, a.ID)
+	require.Equal(t, []int{0, 1, 2}, []int{a.Sort, b.Sort, c.Sort})
+	require.Equal(t, a.DescendantPath(), a1.Path)
+
+	// 同级调整排序：c 移到最前
+	err := tRepo.CategoryRepo.Move(ctx, c.ID, root.ID, 0)
+	require.NoError(t, err)
+	requireChildren(t, root.ID, c.ID, a.ID, b.ID)
+
+	// 不能移动到自身或子孙分类下
+	err = tRepo.CategoryRepo.Move(ctx, a.ID, a.ID, 0)
+	require.ErrorIs(t, err, dbrepo.ErrCategoryCycle)
+	err = tRepo.CategoryRepo.Move(ctx, a.ID, a1.ID, 0)
+	require.ErrorIs(t, err, dbrepo.ErrCategoryCycle)
+
+	// a 移到 b 下，子分类 a1 的 path 跟着更新
+	err = tRepo.CategoryRepo.Move(ctx, a.ID, b.ID, -1)
+	require.NoError(t, err)
+	requireChildren(t, root.ID, c.ID, b.ID)
+	requireChildren(t, b.ID, a.ID)
+
+	got, err := tRepo.CategoryRepo.Get(ctx, a1.ID)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%s%d/%d/", root.DescendantPath(), b.ID, a.ID), got.Path)
+
+	ids, err := tRepo.CategoryRepo.Descendants(ctx, b.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{b.ID, a.ID, a1.ID}, ids)
+
+	// 有子分类不能删除
+	err = tRepo.CategoryRepo.Delete(ctx, a.ID)
+	require.ErrorIs(t, err, dbrepo.ErrCategoryNotEmpty)
+
+	err = tRepo.CategoryRepo.Delete(ctx, a1.ID)
+	require.NoError(t, err)
+	err = tRepo.CategoryRepo.Delete(ctx, a.ID)
+	require.NoError(t, err)
+}
+
+// requireChildren 检查 parentID 下的子分类及顺序
+func requireChildren(t *testing.T, parentID uint64, ids ...uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	list, err := tRepo.CategoryRepo.List(ctx)
+	require.NoError(t, err)
+
+	var got []uint64
+	for _, x := range list {
+		if x.ParentID == parentID {
+			require.Equal(t, len(got), x.Sort)
+			got = append(got, x.ID)
+		}
+	}
+	require.Equal(t, ids, got)
 }
 
 // TODO:
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFavorite(t *testing.T) {
+	u1 := createUser(t)
+	b1, b2, b3 := createBook(t), createBook(t), createBook(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	id, err := tRepo.FavoriteRepo.Add(ctx, u1.ID, b1.ID)
+	require.NoError(t, err)
+	require.True(t, id > 0)
+
+	// 重复收藏返回同一个id
+	again, err := tRepo.FavoriteRepo.Add(ctx, u1.ID, b1.ID)
+	require.NoError(t, err)
+	require.Equal(t, id, again)
+
+	_, err = tRepo.FavoriteRepo.Add(ctx, u1.ID, b2.ID)
+	require.NoError(t, err)
+
+	ids, err := tRepo.FavoriteRepo.FavoritedBookIDs(ctx, u1.ID, []uint64{b1.ID, b2.ID, b3.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{b1.ID, b2.ID}, ids)
+
+	require.NoError(t, tRepo.FavoriteRepo.Remove(ctx, u1.ID, b2.ID))
+	require.ErrorIs(t, tRepo.FavoriteRepo.Remove(ctx, u1.ID, b2.ID), dbrepo.ErrNotFound)
+
+	vo, err := tRepo.FavoriteRepo.List(ctx, u1.ID, dbrepo.Filters{})
+	require.NoError(t, err)
+	list, ok := vo.List.([]*models.Favorite)
+	require.True(t, ok)
+	require.Len(t, list, 1)
+	require.Equal(t, b1.ID, list[0].Book.ID)
+	require.False(t, list[0].OnSale)
+}
+
+func TestFavoriteOnSale(t *testing.T) {
+	u1 := createUser(t)
+	b1, b2 := createBook(t), createBook(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	// b1 上架的电子书，b2 下架
+	b1.Status, b1.Type = 1, 1
+	require.NoError(t, tRepo.BookRepo.Update(ctx, b1))
+	b2.Status, b2.Type = 0, 1
+	require.NoError(t, tRepo.BookRepo.Update(ctx, b2))
+
+	_, err := tRepo.FavoriteRepo.Add(ctx, u1.ID, b1.ID)
+	require.NoError(t, err)
+	_, err = tRepo.FavoriteRepo.Add(ctx, u1.ID, b2.ID)
+	require.NoError(t, err)
+
+	// b1 降价，b2 重新上架
+	b1.Price--
+	require.NoError(t, tRepo.BookRepo.Update(ctx, b1))
+	b2.Status = 1
+	require.NoError(t, tRepo.BookRepo.Update(ctx, b2))
+
+	vo, err := tRepo.FavoriteRepo.List(ctx, u1.ID, dbrepo.Filters{SortFields: []string{"id"}})
+	require.NoError(t, err)
+	list := vo.List.([]*models.Favorite)
+	require.Len(t, list, 2)
+	require.True(t, list[0].OnSale)
+	require.False(t, list[0].BackInStock)
+	require.False(t, list[1].OnSale)
+	require.True(t, list[1].BackInStock)
+
+	// 降价后重复收藏不覆盖收藏时的价格
+	_, err = tRepo.FavoriteRepo.Add(ctx, u1.ID, b1.ID)
+	require.NoError(t, err)
+
+	vo, err = tRepo.FavoriteRepo.List(ctx, u1.ID, dbrepo.Filters{Conditions: []dbrepo.Condition{dbrepo.Eq("on_sale", 1)}})
+	require.NoError(t, err)
+	list = vo.List.([]*models.Favorite)
+	require.Len(t, list, 1)
+	require.Equal(t, b1.ID, list[0].BookID)
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/filegc"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/random"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUploader 只记录删除的 key
+type fakeUploader struct {
+	fileupload.FileUploader
+	mu      sync.Mutex
+	deleted map[string]bool
+}
+
+func (u *fakeUploader) Delete(key string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.deleted[key] = true
+	return nil
+}
+
+func (u *fakeUploader) isDeleted(key string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.deleted[key]
+}
+
+func TestFileGC(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	uploader := &fakeUploader{deleted: map[string]bool{}}
+	uploaders := map[string]fileupload.FileUploader{
+		"cover": uploader, "banner": uploader, "icon": uploader, "avatar": uploader, "ebook": uploader,
+	}
+	collector := filegc.New(tRepo, uploaders, time.Nanosecond)
+
+	// 相同内容上传两次，第二次的地址不同(如更换了CDN)，图书引用第一次的地址
+	used := "covers/" + random.RandomString(32) + ".jpg"
+	first := &models.File{Kind: "cover", FileKey: used, URL: "https://old.example.com/" + used}
+	require.NoError(t, tRepo.FileRepo.Register(ctx, first))
+	second := &models.File{Kind: "cover", FileKey: used, URL: "https://cdn.example.com/" + used + "?v=2"}
+	require.NoError(t, tRepo.FileRepo.Register(ctx, second))
+
+	variant := &models.File{Kind: "cover", FileKey: used + ".320w.jpg", URL: first.URL + ".320w.jpg", ParentURL: first.URL}
+	require.NoError(t, tRepo.FileRepo.Register(ctx, variant))
+
+	book := createBook(t)
+	book.CoverUrl = first.URL
+	require.NoError(t, tRepo.BookRepo.Update(ctx, book))
+
+	// 没有引用的电子书
+	unused := random.RandomString(32) + ".epub"
+	require.NoError(t, tRepo.FileRepo.Register(ctx, &models.File{Kind: "ebook", FileKey: unused, URL: unused}))
+
+	// unreferenced_at 精确到秒
+	time.Sleep(1100 * time.Millisecond)
+
+	report, err := collector.Run(ctx, false)
+	require.NoError(t, err)
+	require.False(t, uploader.isDeleted(used))
+	require.False(t, uploader.isDeleted(variant.FileKey))
+	require.True(t, uploader.isDeleted(unused))
+
+	var url string
+	err = tDb.GetContext(ctx, &url, "select url from files where kind = 'cover' and file_key = ?", used)
+	require.NoError(t, err)
+	require.Equal(t, first.URL, url)
+
+	var refCount uint
+	err = tDb.GetContext(ctx, &refCount, "select ref_count from files where kind = 'cover' and file_key = ?", variant.FileKey)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, refCount)
+
+	for _, f := range report.Files {
+		require.NotEqual(t, used, f.FileKey)
+	}
+}
@@ -14,7 +14,7 @@ func createPublisher(t *testing.T) *models.Publisher {
 	name := random.RandomString(12)
 	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
 	defer cancel()
-	id, err := tRepo.PublisherRepo.Create(ctx, name)
+	id, err := tRepo.PublisherRepo.Create(ctx, &models.Publisher{PublisherName: name})
 	require.NoError(t, err)
 	require.True(t, id > 0)
 
@@ -34,3 +34,27 @@ func TestCreatePublisher(t *testing.T) {
 }
 
 // TODO: TEST crud
+
+func TestUpdatePublisher(t *testing.T) {
+	p1 := createPublisher(t)
+	p2 := createPublisher(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	p1.PublisherName = random.RandomString(12)
+	p1.Address = random.RandomString(32)
+	p1.Contact = random.RandomString(11)
+	err := tRepo.PublisherRepo.Update(ctx, p1)
+	require.NoError(t, err)
+
+	got, err := tRepo.PublisherRepo.Get(ctx, p1.ID)
+	require.NoError(t, err)
+	require.Equal(t, p1.PublisherName, got.PublisherName)
+	require.Equal(t, p1.Address, got.Address)
+	require.Equal(t, p1.Contact, got.Contact)
+
+	// 只更新指定的出版社
+	other, err := tRepo.PublisherRepo.Get(ctx, p2.ID)
+	require.NoError(t, err)
+	require.Equal(t, p2.PublisherName, other.PublisherName)
+}
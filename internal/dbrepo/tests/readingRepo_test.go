@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/random"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadingSync(t *testing.T) {
+	u1 := createUser(t)
+	b1 := createBook(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UnixMilli()
+	clientID := random.RandomString(16)
+
+	result, err := tRepo.ReadingRepo.Push(ctx, u1.ID, &models.ReadingSync{
+		Progress: []*models.ReadingProgress{
+			{BookID: b1.ID, Position: "epubcfi(/6/4!/4/2/1:0)", Percentage: 10, UpdatedAt: now},
+		},
+		Annotations: []*models.ReadingAnnotation{
+			{BookID: b1.ID, ClientID: clientID, Kind: models.AnnotationHighlight, Position: "epubcfi(/6/4!/4/2,/1:0,/1:8)", Content: "highlight", UpdatedAt: now},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Applied)
+
+	// 较旧的进度被忽略，较新的删除书签生效
+	result, err = tRepo.ReadingRepo.Push(ctx, u1.ID, &models.ReadingSync{
+		Progress: []*models.ReadingProgress{
+			{BookID: b1.ID, Position: "epubcfi(/6/2!/4/2/1:0)", Percentage: 5, UpdatedAt: now - 1000},
+		},
+		Annotations: []*models.ReadingAnnotation{
+			{BookID: b1.ID, ClientID: clientID, Kind: models.AnnotationHighlight, Position: "epubcfi(/6/4!/4/2,/1:0,/1:8)", Deleted: true, UpdatedAt: now + 1000},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Applied)
+	require.Equal(t, 1, result.Ignored)
+
+	state, err := tRepo.ReadingRepo.State(ctx, u1.ID, b1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, state.Progress)
+	require.Equal(t, "epubcfi(/6/4!/4/2/1:0)", state.Progress.Position)
+	require.InDelta(t, 10, state.Progress.Percentage, 0.001)
+	require.Empty(t, state.Annotations)
+
+	// 全量拉取：进度和已删除的高亮
+	changes, err := tRepo.ReadingRepo.Changes(ctx, u1.ID, 0, 100)
+	require.NoError(t, err)
+	require.False(t, changes.HasMore)
+	require.Len(t, changes.Progress, 1)
+	require.Len(t, changes.Annotations, 1)
+	require.True(t, changes.Annotations[0].Deleted)
+	require.Greater(t, changes.Annotations[0].Seq, changes.Progress[0].Seq)
+
+	// 分页拉取
+	page, err := tRepo.ReadingRepo.Changes(ctx, u1.ID, 0, 1)
+	require.NoError(t, err)
+	require.True(t, page.HasMore)
+	require.Len(t, page.Progress, 1)
+
+	since, err := strconv.ParseUint(page.Next, 10, 64)
+	require.NoError(t, err)
+	page, err = tRepo.ReadingRepo.Changes(ctx, u1.ID, since, 1)
+	require.NoError(t, err)
+	require.False(t, page.HasMore)
+	require.Len(t, page.Annotations, 1)
+
+	// 没有新的变更
+	since, err = strconv.ParseUint(changes.Next, 10, 64)
+	require.NoError(t, err)
+	empty, err := tRepo.ReadingRepo.Changes(ctx, u1.ID, since, 100)
+	require.NoError(t, err)
+	require.Empty(t, empty.Progress)
+	require.Empty(t, empty.Annotations)
+	require.Equal(t, changes.Next, empty.Next)
+}
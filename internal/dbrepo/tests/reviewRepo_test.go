@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/random"
+	"github.com/stretchr/testify/require"
+)
+
+func createReview(t *testing.T, userID, bookID uint64, rating uint8) uint64 {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	id, err := tRepo.ReviewRepo.Save(ctx, &models.Review{
+		UserID:  userID,
+		BookID:  bookID,
+		Rating:  rating,
+		Content: random.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.True(t, id > 0)
+	return id
+}
+
+func TestModerateReview(t *testing.T) {
+	b1 := createBook(t)
+	u1, u2 := createUser(t), createUser(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	r1 := createReview(t, u1.ID, b1.ID, 5)
+	r2 := createReview(t, u2.ID, b1.ID, 2)
+
+	// 待审核的评价不计入评分
+	book, err := tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Zero(t, book.RatingCount)
+
+	approve := &models.ReviewModeration{Status: models.ReviewApproved}
+	require.NoError(t, tRepo.ReviewRepo.Moderate(ctx, r1, approve))
+	require.NoError(t, tRepo.ReviewRepo.Moderate(ctx, r2, approve))
+
+	book, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), book.RatingCount)
+	require.InDelta(t, 3.5, book.RatingAvg, 0.001)
+
+	// 隐藏后重新计算
+	hide := &models.ReviewModeration{Status: models.ReviewHidden, Reason: "广告"}
+	require.NoError(t, tRepo.ReviewRepo.Moderate(ctx, r2, hide))
+
+	got, err := tRepo.ReviewRepo.Get(ctx, r2)
+	require.NoError(t, err)
+	require.Equal(t, models.ReviewHidden, got.Status)
+	require.Equal(t, "广告", got.Reason)
+	require.NotNil(t, got.ModeratedAt)
+
+	book, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), book.RatingCount)
+	require.InDelta(t, 5, book.RatingAvg, 0.001)
+
+	// 修改评价返回同一个id，重新进入待审核
+	id := createReview(t, u1.ID, b1.ID, 4)
+	require.Equal(t, r1, id)
+	got, err = tRepo.ReviewRepo.Get(ctx, r1)
+	require.NoError(t, err)
+	require.Equal(t, models.ReviewPending, got.Status)
+
+	book, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Zero(t, book.RatingCount)
+}
+
+func TestReviewHelpful(t *testing.T) {
+	b1 := createBook(t)
+	u1, u2, u3 := createUser(t), createUser(t), createUser(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	r1 := createReview(t, u1.ID, b1.ID, 4)
+	r2 := createReview(t, u2.ID, b1.ID, 3)
+
+	// 未通过的评价不能投票
+	require.Error(t, tRepo.ReviewRepo.Vote(ctx, r2, u3.ID))
+
+	approve := &models.ReviewModeration{Status: models.ReviewApproved}
+	require.NoError(t, tRepo.ReviewRepo.Moderate(ctx, r1, approve))
+	require.NoError(t, tRepo.ReviewRepo.Moderate(ctx, r2, approve))
+
+	require.ErrorIs(t, tRepo.ReviewRepo.Vote(ctx, r2, u2.ID), dbrepo.ErrReviewSelfVote)
+	require.NoError(t, tRepo.ReviewRepo.Vote(ctx, r2, u1.ID))
+	require.NoError(t, tRepo.ReviewRepo.Vote(ctx, r2, u3.ID))
+	require.NoError(t, tRepo.ReviewRepo.Vote(ctx, r2, u3.ID)) // 重复投票忽略
+
+	vo, err := tRepo.ReviewRepo.ListByBook(ctx, b1.ID, dbrepo.Filters{SortFields: []string{"-helpful_count", "-id"}})
+	require.NoError(t, err)
+	list, ok := vo.List.([]*models.Review)
+	require.True(t, ok)
+	require.Len(t, list, 2)
+	require.Equal(t, r2, list[0].ID)
+	require.Equal(t, uint(2), list[0].HelpfulCount)
+	require.Equal(t, u2.Nickname, list[0].User.Nickname)
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/lightsaid/ebook/pkg/random"
+	"github.com/stretchr/testify/require"
+)
+
+func createSeries(t *testing.T) *models.Series {
+	name := random.RandomString(12)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	id, err := tRepo.SeriesRepo.Create(ctx, &models.Series{SeriesName: name, Description: random.RandomString(32)})
+	require.NoError(t, err)
+	require.True(t, id > 0)
+
+	s1, err := tRepo.SeriesRepo.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, id, s1.ID)
+	require.Equal(t, name, s1.SeriesName)
+	require.WithinDuration(t, time.Now(), s1.CreatedAt.Time, time.Second)
+
+	return s1
+}
+
+// createOnSaleBook 创建已上架的图书，系列的下一卷只返回已上架的图书
+func createOnSaleBook(t *testing.T) *models.Book {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	b1 := makeEmptyIDBook(t)
+	b1.Status = 1
+	id, err := tRepo.BookRepo.Create(ctx, b1)
+	require.NoError(t, err)
+
+	b2, err := tRepo.BookRepo.Get(ctx, id)
+	require.NoError(t, err)
+	return b2
+}
+
+func TestCreateSeries(t *testing.T) {
+	_ = createSeries(t)
+}
+
+func TestSeriesVolumes(t *testing.T) {
+	s1 := createSeries(t)
+	b1, b2, b3 := createOnSaleBook(t), createOnSaleBook(t), createOnSaleBook(t)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	// 加入顺序与卷号无关
+	for volume, b := range map[uint]*models.Book{3: b3, 1: b1, 2: b2} {
+		err := tRepo.SeriesRepo.SaveBook(ctx, &models.SeriesBook{SeriesID: s1.ID, BookID: b.ID, Volume: volume})
+		require.NoError(t, err)
+	}
+
+	// 卷号重复
+	b4 := createBook(t)
+	err := tRepo.SeriesRepo.SaveBook(ctx, &models.SeriesBook{SeriesID: s1.ID, BookID: b4.ID, Volume: 2})
+	require.Error(t, err)
+
+	list, err := tRepo.SeriesRepo.ListVolumes(ctx, s1.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	for i, b := range []*models.Book{b1, b2, b3} {
+		require.Equal(t, uint(i+1), list[i].Volume)
+		require.Equal(t, b.ID, list[i].ID)
+		require.Equal(t, b.Title, list[i].Title)
+		require.NotNil(t, list[i].Author)
+		require.Equal(t, b.AuthorID, list[i].Author.ID)
+		require.NotNil(t, list[i].Publisher)
+		require.Equal(t, b.PublisherID, list[i].Publisher.ID)
+	}
+
+	// 下一卷跳过已删除的图书
+	got, err := tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Series)
+	require.Equal(t, s1.ID, got.Series.SeriesID)
+	require.Equal(t, uint(1), got.Series.Volume)
+	require.NotNil(t, got.Series.Next)
+	require.Equal(t, b2.ID, got.Series.Next.ID)
+
+	require.NoError(t, tRepo.BookRepo.Delete(ctx, b2.ID))
+	got, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Equal(t, b3.ID, got.Series.Next.ID)
+
+	// 下一卷跳过已下架的图书
+	offSale := *b3
+	offSale.Status = 0
+	require.NoError(t, tRepo.BookRepo.Patch(ctx, b3, &offSale))
+	got, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Series)
+	require.Nil(t, got.Series.Next)
+
+	// 最后一卷没有下一卷
+	got, err = tRepo.BookRepo.Get(ctx, b3.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Series)
+	require.Nil(t, got.Series.Next)
+
+	// 移出系列
+	require.NoError(t, tRepo.SeriesRepo.RemoveBook(ctx, s1.ID, b3.ID))
+	require.ErrorIs(t, tRepo.SeriesRepo.RemoveBook(ctx, s1.ID, b3.ID), dbrepo.ErrNotFound)
+	got, err = tRepo.BookRepo.Get(ctx, b3.ID)
+	require.NoError(t, err)
+	require.Nil(t, got.Series)
+
+	// 系列删除后图书详情不再返回系列
+	require.NoError(t, tRepo.SeriesRepo.Delete(ctx, s1.ID))
+	got, err = tRepo.BookRepo.Get(ctx, b1.ID)
+	require.NoError(t, err)
+	require.Nil(t, got.Series)
+}
@@ -0,0 +1,143 @@
+package dbrepo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// TrashRepo 回收站，管理已软删除的数据：查看、恢复、彻底删除
+type TrashRepo interface {
+	ListDeleted(ctx context.Context, f Filters) (*PageQueryVo, error) // 分页获取已删除的数据，默认按删除时间倒序
+	Restore(ctx context.Context, id uint64) error                     // 恢复已删除的数据，不存在或未删除返回 ErrNotFound
+	Purge(ctx context.Context, id uint64) error                       // 彻底删除，只能删除已软删除的数据，仍被引用返回 ErrReferenced
+}
+
+// trash 回收站的通用实现，T 为表对应的模型
+type trash[T any] struct {
+	table   string // 表名
+	columns string // 查询字段，需包含 deleted_at
+
+	// beforeRestore 恢复前的检查，比如图书的作者、出版社必须未删除
+	beforeRestore func(ctx context.Context, db Queryable, id uint64) error
+	// beforePurge 彻底删除前的检查，外键约束(RESTRICT)以外的引用在这里检查
+	beforePurge func(ctx context.Context, db Queryable, id uint64) error
+}
+
+var _ baseRepo = trash[any]{}
+
+// list 分页获取已删除的数据
+func (t trash[T]) list(ctx context.Context, db Queryable, f Filters) (*PageQueryVo, error) {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	if len(f.SortFields) == 0 {
+		f.SortFields = []string{"-deleted_at"}
+	}
+
+	lq := listQuery{
+		columns: t.columns,
+		from:    "from " + t.table,
+		where:   []string{"deleted_at is not null"},
+	}
+
+	q, err := lq.build(db, f, t)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := q.count(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
+	list := make([]*T, 0, f.limit())
+	err = db.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, metadata := pageResult(q, f, total, list)
+
+	return dbtk.makePageQueryVo(metadata, list), nil
+}
+
+// restore 恢复已删除的数据，唯一索引冲突(如已有相同isbn的图书)返回 mysql 1062 错误
+func (t trash[T]) restore(ctx context.Context, db Queryable, id uint64) error {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	if t.beforeRestore != nil {
+		if err := t.beforeRestore(ctx, db, id); err != nil {
+			return err
+		}
+	}
+
+	sql := fmt.Sprintf("update %s set deleted_at = null where id = ? and deleted_at is not null;", t.table)
+
+	slog.DebugContext(ctx, sql, slog.Uint64("id", id))
+
+	result, err := db.ExecContext(ctx, db.Rebind(sql), id)
+	if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+		return err
+	}
+
+	if eff, _ := result.RowsAffected(); eff == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// purge 彻底删除已软删除的数据，仍被外键引用时 mysql 返回 1451 错误
+func (t trash[T]) purge(ctx context.Context, db Queryable, id uint64) error {
+	ctx, cancal := dbtk.withTimeout(ctx)
+	defer cancal()
+
+	if t.beforePurge != nil {
+		if err := t.beforePurge(ctx, db, id); err != nil {
+			return err
+		}
+	}
+
+	sql := fmt.Sprintf("delete from %s where id = ? and deleted_at is not null;", t.table)
+
+	slog.DebugContext(ctx, sql, slog.Uint64("id", id))
+
+	result, err := db.ExecContext(ctx, db.Rebind(sql), id)
+	if err = dbtk.updateErrorHandler(ctx, result, err); err != nil {
+		return err
+	}
+
+	if eff, _ := result.RowsAffected(); eff == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// defaultSortSafelist 回收站可按id和删除时间排序
+func (t trash[T]) defaultSortSafelist() []string {
+	return []string{"id", "deleted_at", "-id", "-deleted_at"}
+}
+
+// defaultWhereSafelist 回收站可按id和删除时间查询
+func (t trash[T]) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":         "id",
+		"deleted_at": "deleted_at",
+	}
+}
+
+// existsAlive 判断表中是否存在未删除的数据，where 为查询条件
+func (*toolkit) existsAlive(ctx context.Context, db Queryable, table, where string, args ...any) (bool, error) {
+	sql := fmt.Sprintf("select exists(select 1 from %s where %s and deleted_at is null);", table, where)
+
+	slog.DebugContext(ctx, sql, slog.Any("args", args))
+
+	var exists bool
+	err := db.GetContext(ctx, &exists, db.Rebind(sql), args...)
+	return exists, err
+}
@@ -2,7 +2,6 @@ package dbrepo
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 
 	"github.com/lightsaid/ebook/internal/models"
@@ -124,11 +123,9 @@ func (r *userRepo) GetByUqField(ctx context.Context, uq UserUq) (*models.User, e
 	return user, err
 }
 
-func (r *userRepo) List(ctx context.Context, filter Filters) (*PageQueryVo, error) {
-	// 检查分页设置
-	filter.check()
-	query := fmt.Sprintf(`
-	select 
+// userListQuery 用户列表查询，不查询密码字段
+var userListQuery = listQuery{
+	columns: `
 		id,
 		email,
 		nickname,
@@ -137,34 +134,36 @@ func (r *userRepo) List(ctx context.Context, filter Filters) (*PageQueryVo, erro
 		login_at,
 		login_ip,
 		created_at,
-		updated_at
-	from users where deleted_at is null
-	order by %s limit ? offset ?
-	`, filter.sortColumnWithDefault(r))
-
-	query = r.DB.Rebind(query)
-
-	slog.DebugContext(ctx, query)
+		updated_at`,
+	from:  "from users",
+	where: []string{"deleted_at is null"},
+}
 
+func (r *userRepo) List(ctx context.Context, filter Filters) (*PageQueryVo, error) {
 	ctx, cancel := dbtk.withTimeout(ctx)
 	defer cancel()
 
+	q, err := userListQuery.build(r.DB, filter, r)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, q.pageSQL, slog.Any("args", q.pageArgs))
+
 	list := make([]*models.User, 0)
 
-	err := r.DB.SelectContext(ctx, &list, query, filter.limit(), filter.offset())
+	err = r.DB.SelectContext(ctx, &list, q.pageSQL, q.pageArgs...)
 	if err != nil {
 		return nil, err
 	}
 
-	totalQuery := `select count(*) as total from users where deleted_at is null`
-
 	var total = 0
-	err = r.DB.GetContext(ctx, &total, totalQuery)
+	total, err = q.count(ctx, r.DB)
 	if err != nil {
 		return nil, err
 	}
 
-	metadata := dbtk.calculateMetadata(total, filter.PageNum, filter.PageSize)
+	list, metadata := pageResult(q, filter, total, list)
 
 	vo := dbtk.makePageQueryVo(metadata, list)
 
@@ -177,3 +176,15 @@ func (r *userRepo) defaultSortSafelist() []string {
 		"-id", "-email", "-nickname", "-role", "-login_at", "-created_at", "-updated_at",
 	}
 }
+
+func (r *userRepo) defaultWhereSafelist() map[string]string {
+	return map[string]string{
+		"id":         "id",
+		"email":      "email",
+		"nickname":   "nickname",
+		"role":       "role",
+		"login_at":   "login_at",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	}
+}
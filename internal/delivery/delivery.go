@@ -0,0 +1,193 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+var (
+	ErrNoEbook     = errors.New("该图书没有电子版")
+	ErrNotEntitled = errors.New("请先购买该图书")
+	ErrBadSource   = errors.New("电子书文件地址无效")
+)
+
+// 每次写入后延长的写超时，服务默认的 WriteTimeout 不足以传输大文件
+const writeTimeout = 30 * time.Second
+
+// HasEbook 图书是否有电子版：类型为电子书或电子书+实体、有文件地址；不校验是否上架
+func HasEbook(book *models.Book) bool {
+	return (book.Type == 1 || book.Type == 3) && book.SourceUrl != ""
+}
+
+// Entitled 校验用户是否可以下载：上架的免费图书都可以下载，其他需要有已支付的订单，
+// 已购买的图书下架后买家仍可下载
+func Entitled(ctx context.Context, orders dbrepo.OrderRepo, userID uint64, book *models.Book) error {
+	if !HasEbook(book) {
+		return ErrNoEbook
+	}
+	if book.Price == 0 && book.Status == 1 {
+		return nil
+	}
+
+	// 没有购买的下架图书视为没有电子版
+	notEntitled := ErrNotEntitled
+	if book.Status != 1 {
+		notEntitled = ErrNoEbook
+	}
+	if userID == 0 {
+		return notEntitled
+	}
+
+	paid, err := orders.PaidBookIDs(ctx, userID, []uint64{book.ID})
+	if err != nil {
+		return err
+	}
+	if len(paid) == 0 {
+		return notEntitled
+	}
+	return nil
+}
+
+// Result 一次下载的响应结果，用于记录下载日志
+type Result struct {
+	Status int
+	Bytes  int64
+}
+
+// Serve 输出电子书文件，支持Range分段下载：
+// source 为 http(s) 地址时转发请求(包括Range)并透传响应，否则作为 files 中文件的 key
+func Serve(w http.ResponseWriter, r *http.Request, source string, files fileupload.FileUploader) (Result, error) {
+	cw := &countingWriter{ResponseWriter: w, rc: http.NewResponseController(w)}
+	cw.extend()
+
+	var err error
+	if isRemote(source) {
+		err = serveRemote(cw, r, source)
+	} else {
+		err = serveFile(cw, r, source, files)
+	}
+
+	if cw.status == 0 && err == nil {
+		cw.status = http.StatusOK
+	}
+	return Result{Status: cw.status, Bytes: cw.bytes}, err
+}
+
+// isRemote source 是否为 http(s) 地址
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// serveFile 使用 http.ServeContent 输出 files 中的文件，自动处理 Range、If-Range 等请求头
+func serveFile(w http.ResponseWriter, r *http.Request, key string, files fileupload.FileUploader) error {
+	f, err := files.Open(key)
+	if errors.Is(err, fileupload.ErrFileNotFound) {
+		return ErrBadSource
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	setAttachment(w, key)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// 透传的源站响应头
+var proxyHeaders = []string{
+	"Accept-Ranges", "Content-Length", "Content-Range", "Content-Type", "ETag", "Last-Modified",
+}
+
+// serveRemote 请求源站并透传响应，转发 Range、If-Range 请求头
+func serveRemote(w http.ResponseWriter, r *http.Request, source string) error {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, source, nil)
+	if err != nil {
+		return ErrBadSource
+	}
+	for _, h := range []string{"Range", "If-Range"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		return fmt.Errorf("源站响应 %s", resp.Status)
+	}
+
+	for _, h := range proxyHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	setAttachment(w, source)
+	w.WriteHeader(resp.StatusCode)
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// setAttachment 设置下载的文件名
+func setAttachment(w http.ResponseWriter, source string) {
+	name := path.Base(strings.SplitN(source, "?", 2)[0])
+	if name == "" || name == "." || name == "/" {
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+}
+
+// countingWriter 记录响应状态码和发送的字节数，每次写入延长写超时
+type countingWriter struct {
+	http.ResponseWriter
+	rc     *http.ResponseController
+	status int
+	bytes  int64
+}
+
+func (cw *countingWriter) extend() {
+	cw.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.extend()
+	n, err := cw.ResponseWriter.Write(p)
+	cw.bytes += int64(n)
+	return n, err
+}
+
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,159 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	s, err := NewSigner("secret", time.Minute)
+	require.NoError(t, err)
+	now := time.Now()
+
+	q, exp := s.Sign(1, 2, now)
+	require.Equal(t, now.Add(time.Minute).Truncate(time.Second), exp)
+
+	userID, err := s.Verify(1, q, now)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, userID)
+
+	// 其他图书
+	_, err = s.Verify(3, q, now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 篡改用户id、过期时间、签名
+	for key, value := range map[string]string{
+		"uid": "3",
+		"exp": strconv.FormatInt(exp.Add(time.Hour).Unix(), 10),
+		"sig": q.Get("sig")[1:],
+	} {
+		tampered := url.Values{}
+		for k := range q {
+			tampered.Set(k, q.Get(k))
+		}
+		tampered.Set(key, value)
+		_, err = s.Verify(1, tampered, now)
+		require.ErrorIs(t, err, ErrInvalidSignature, key)
+	}
+	_, err = s.Verify(1, url.Values{}, now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 过期
+	_, err = s.Verify(1, q, exp.Add(time.Second))
+	require.ErrorIs(t, err, ErrURLExpired)
+
+	// 其他密钥签发的地址
+	other, err := NewSigner("", 0)
+	require.NoError(t, err)
+	_, err = other.Verify(1, q, now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+// fakeOrders userID => 已支付的图书id
+type fakeOrders struct {
+	dbrepo.OrderRepo
+	paid map[uint64][]uint64
+}
+
+func (o fakeOrders) PaidBookIDs(ctx context.Context, userID uint64, bookIDs []uint64) ([]uint64, error) {
+	var ids []uint64
+	for _, id := range o.paid[userID] {
+		for _, want := range bookIDs {
+			if id == want {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func TestEntitled(t *testing.T) {
+	orders := fakeOrders{paid: map[uint64][]uint64{1: {10}}}
+	book := func(id uint64, price uint, status, typ int) *models.Book {
+		return &models.Book{ID: id, Price: price, Status: status, Type: typ, SourceUrl: "a.epub"}
+	}
+
+	tests := []struct {
+		name    string
+		userID  uint64
+		book    *models.Book
+		wantErr error
+	}{
+		{"上架的免费图书", 0, book(11, 0, 1, 1), nil},
+		{"下架的免费图书", 2, book(11, 0, 0, 1), ErrNoEbook},
+		{"实体书", 1, book(10, 100, 1, 2), ErrNoEbook},
+		{"没有文件", 1, &models.Book{ID: 10, Price: 100, Status: 1, Type: 1}, ErrNoEbook},
+		{"已购买", 1, book(10, 100, 1, 3), nil},
+		{"已购买后下架", 1, book(10, 100, 0, 1), nil},
+		{"未购买", 2, book(10, 100, 1, 1), ErrNotEntitled},
+		{"未登录", 0, book(10, 100, 1, 1), ErrNotEntitled},
+		{"未购买的下架图书", 2, book(10, 100, 0, 1), ErrNoEbook},
+	}
+	for _, tt := range tests {
+		err := Entitled(context.Background(), orders, tt.userID, tt.book)
+		if tt.wantErr == nil {
+			require.NoError(t, err, tt.name)
+		} else {
+			require.ErrorIs(t, err, tt.wantErr, tt.name)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("0123456789abcdef")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "book.epub"), content, 0o644))
+	files := fileupload.NewLocalUplader(dir, "", nil, 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/download/1", nil)
+	req.Header.Set("Range", "bytes=4-7")
+	w := httptest.NewRecorder()
+	result, err := Serve(w, req, "book.epub", files)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "4567", w.Body.String())
+	require.Equal(t, Result{Status: http.StatusPartialContent, Bytes: 4}, result)
+	require.Equal(t, `attachment; filename="book.epub"`, w.Header().Get("Content-Disposition"))
+
+	w = httptest.NewRecorder()
+	result, err = Serve(w, httptest.NewRequest(http.MethodGet, "/download/1", nil), "book.epub", files)
+	require.NoError(t, err)
+	require.Equal(t, content, w.Body.Bytes())
+	require.EqualValues(t, len(content), result.Bytes)
+
+	_, err = Serve(httptest.NewRecorder(), req, "missing.epub", files)
+	require.ErrorIs(t, err, ErrBadSource)
+	_, err = Serve(httptest.NewRecorder(), req, "../book.epub", files)
+	require.ErrorIs(t, err, ErrBadSource)
+}
+
+func TestServeRemote(t *testing.T) {
+	content := []byte("0123456789abcdef")
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer origin.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/download/1", nil)
+	req.Header.Set("Range", "bytes=10-")
+	w := httptest.NewRecorder()
+	result, err := Serve(w, req, origin.URL+"/files/book.pdf?token=x", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusPartialContent, result.Status)
+	require.Equal(t, "abcdef", w.Body.String())
+	require.Equal(t, "bytes 10-15/16", w.Header().Get("Content-Range"))
+	require.Equal(t, `attachment; filename="book.pdf"`, w.Header().Get("Content-Disposition"))
+}
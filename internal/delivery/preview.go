@@ -0,0 +1,32 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/ebook"
+	"github.com/lightsaid/ebook/internal/fileupload"
+)
+
+// PreviewKey 试读文件的缓存文件名：图书id/源文件地址的哈希/试读范围，替换源文件或修改试读范围后重新生成
+func PreviewKey(bookID uint64, source string, opt ebook.PreviewOptions) string {
+	return fmt.Sprintf("preview/%d/%s/%s-%d.epub", bookID, sourceHash(source), opt.Mode, opt.Value)
+}
+
+// EnsurePreview 确保试读文件已生成，缓存中没有或 force 为 true 时从源文件生成；
+// 只支持 EPUB，返回缓存文件名和是否重新生成
+func EnsurePreview(ctx context.Context, source string, files fileupload.FileUploader, cache Cache, bookID uint64, opt ebook.PreviewOptions, force bool) (string, bool, error) {
+	name := strings.SplitN(source, "?", 2)[0]
+	if !strings.EqualFold(path.Ext(name), ".epub") {
+		return "", false, ebook.ErrPreviewUnsupported
+	}
+
+	key := PreviewKey(bookID, source, opt)
+	generated, err := ensureCached(ctx, source, files, cache, key, force, func(dst io.Writer, src io.ReaderAt, size int64) error {
+		return ebook.PreviewEPUB(dst, src, size, opt)
+	})
+	return key, generated, err
+}
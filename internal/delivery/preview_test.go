@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lightsaid/ebook/internal/ebook"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsurePreview(t *testing.T) {
+	dir := t.TempDir()
+	files := fileupload.NewLocalUplader(dir, "", nil, 0)
+	cache := NewStoreCache(fileupload.NewLocalUplader(t.TempDir(), "", nil, 0))
+	opt := ebook.PreviewOptions{Mode: models.PreviewChapters, Value: 1}
+
+	_, _, err := EnsurePreview(context.Background(), "book.pdf", files, cache, 1, opt, false)
+	require.ErrorIs(t, err, ebook.ErrPreviewUnsupported)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	w.Write([]byte("application/epub+zip"))
+	w, _ = zw.Create("META-INF/container.xml")
+	w.Write([]byte(`<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`))
+	w, _ = zw.Create("content.opf")
+	w.Write([]byte(`<package><metadata></metadata><manifest>` +
+		`<item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/>` +
+		`<item id="c2" href="c2.xhtml" media-type="application/xhtml+xml"/>` +
+		`</manifest><spine><itemref idref="c1"/><itemref idref="c2"/></spine></package>`))
+	w, _ = zw.Create("c1.xhtml")
+	w.Write([]byte(`<html><body><a href="c2.xhtml">next</a></body></html>`))
+	w, _ = zw.Create("c2.xhtml")
+	w.Write([]byte(`<html><body>hello</body></html>`))
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "book.epub"), buf.Bytes(), 0o644))
+
+	key, generated, err := EnsurePreview(context.Background(), "book.epub", files, cache, 1, opt, false)
+	require.NoError(t, err)
+	require.True(t, generated)
+	require.Equal(t, PreviewKey(1, "book.epub", opt), key)
+
+	f, _, err := cache.Open(key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	require.Equal(t, []string{"mimetype", "META-INF/container.xml", "content.opf", "c1.xhtml", "preview-end.xhtml"}, names)
+
+	// 已缓存时不重新生成，force 时重新生成
+	_, generated, err = EnsurePreview(context.Background(), "book.epub", files, cache, 1, opt, false)
+	require.NoError(t, err)
+	require.False(t, generated)
+	_, generated, err = EnsurePreview(context.Background(), "book.epub", files, cache, 1, opt, true)
+	require.NoError(t, err)
+	require.True(t, generated)
+}
@@ -0,0 +1,216 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/watermark"
+)
+
+var ErrNotCached = errors.New("文件未缓存")
+
+// Cache 水印文件的存储，首次下载时生成，之后直接读取
+type Cache interface {
+	// Open 打开缓存文件，不存在返回 ErrNotCached
+	Open(key string) (io.ReadSeekCloser, time.Time, error)
+	// Save 保存缓存文件，写入完成前不能被 Open 读到
+	Save(key string, r io.Reader) error
+}
+
+// StoreCache 使用 FileUploader 保存的缓存，本地目录或对象存储，多个实例可以共用
+type StoreCache struct {
+	store fileupload.FileUploader
+}
+
+var _ Cache = (*StoreCache)(nil)
+
+// NewStoreCache 创建缓存，文件通过 store 的 Put 保存、Open 读取
+func NewStoreCache(store fileupload.FileUploader) *StoreCache {
+	return &StoreCache{store: store}
+}
+
+// Open 实现 Cache 接口
+func (c *StoreCache) Open(key string) (io.ReadSeekCloser, time.Time, error) {
+	f, err := c.store.Open(key)
+	if errors.Is(err, fileupload.ErrFileNotFound) {
+		return nil, time.Time{}, ErrNotCached
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	return f, info.ModTime(), nil
+}
+
+// Save 实现 Cache 接口
+func (c *StoreCache) Save(key string, r io.Reader) error {
+	return c.store.Put(key, r)
+}
+
+// cacheKey 水印文件的缓存文件名：图书id/源文件地址的哈希/水印标识，替换源文件后重新生成
+func cacheKey(bookID uint64, source, format string, m watermark.Mark) string {
+	return fmt.Sprintf("watermark/%d/%s/%s.%s", bookID, sourceHash(source), m.ID(), format)
+}
+
+// sourceHash 源文件地址的哈希，用于缓存文件名
+func sourceHash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:4])
+}
+
+// ServeWatermarked 输出添加了买家水印的电子书，支持Range分段下载；
+// 缓存中没有时读取源文件生成并保存，不支持添加水印的格式按原文件输出
+func ServeWatermarked(w http.ResponseWriter, r *http.Request, source string, files fileupload.FileUploader, cache Cache, bookID uint64, m watermark.Mark) (Result, error) {
+	format, err := watermark.FormatOf(source)
+	if err != nil {
+		return Serve(w, r, source, files)
+	}
+
+	key := cacheKey(bookID, source, format, m)
+	_, err = ensureCached(r.Context(), source, files, cache, key, false, func(dst io.Writer, src io.ReaderAt, size int64) error {
+		return watermark.Apply(dst, src, size, format, m)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	setAttachment(w, source)
+	return ServeCached(w, r, cache, key)
+}
+
+// ServeCached 输出缓存中的文件，支持Range分段下载，Content-Type 根据 key 的扩展名确定
+func ServeCached(w http.ResponseWriter, r *http.Request, cache Cache, key string) (Result, error) {
+	f, modTime, err := cache.Open(key)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	cw := &countingWriter{ResponseWriter: w, rc: http.NewResponseController(w)}
+	cw.extend()
+	http.ServeContent(cw, r, path.Base(key), modTime, f)
+	return Result{Status: cw.status, Bytes: cw.bytes}, nil
+}
+
+// ensureCached 缓存中没有 key 或 force 为 true 时读取源文件，经 transform 处理后保存到缓存，返回是否重新生成
+func ensureCached(
+	ctx context.Context,
+	source string,
+	files fileupload.FileUploader,
+	cache Cache,
+	key string,
+	force bool,
+	transform func(dst io.Writer, src io.ReaderAt, size int64) error,
+) (bool, error) {
+	if !force {
+		f, _, err := cache.Open(key)
+		if err == nil {
+			return false, f.Close()
+		}
+		if !errors.Is(err, ErrNotCached) {
+			return false, err
+		}
+	}
+
+	src, err := openSource(ctx, source, files)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	out, err := os.CreateTemp("", "ebook-cache-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if err := transform(out, src, info.Size()); err != nil {
+		return false, err
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return true, cache.Save(key, out)
+}
+
+// tempFile 关闭时删除的临时文件
+type tempFile struct {
+	*os.File
+}
+
+func (t tempFile) Close() error {
+	defer os.Remove(t.Name())
+	return t.File.Close()
+}
+
+// sourceFile 源文件，*os.File 或 tempFile
+type sourceFile interface {
+	io.ReaderAt
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// openSource 打开源文件，本地文件直接使用，远程文件和对象存储中的文件先下载到临时文件
+func openSource(ctx context.Context, source string, files fileupload.FileUploader) (sourceFile, error) {
+	if !isRemote(source) {
+		f, err := files.Open(source)
+		if errors.Is(err, fileupload.ErrFileNotFound) {
+			return nil, ErrBadSource
+		}
+		if err != nil {
+			return nil, err
+		}
+		if local, ok := f.(*os.File); ok {
+			return local, nil
+		}
+		defer f.Close()
+		return downloadTemp(f)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, ErrBadSource
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("源站响应 %s", resp.Status)
+	}
+	return downloadTemp(resp.Body)
+}
+
+// downloadTemp 把 r 的内容写入临时文件，关闭时删除
+func downloadTemp(r io.Reader) (sourceFile, error) {
+	f, err := os.CreateTemp("", "ebook-source-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		tempFile{f}.Close()
+		return nil, err
+	}
+	return tempFile{f}, nil
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/watermark"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreCache(t *testing.T) {
+	cache := NewStoreCache(fileupload.NewLocalUplader(t.TempDir(), "", nil, 0))
+
+	_, _, err := cache.Open("watermark/1/a.epub")
+	require.ErrorIs(t, err, ErrNotCached)
+
+	require.NoError(t, cache.Save("watermark/1/a.epub", bytes.NewReader([]byte("v1"))))
+	require.NoError(t, cache.Save("watermark/1/a.epub", bytes.NewReader([]byte("v2"))))
+
+	f, modTime, err := cache.Open("watermark/1/a.epub")
+	require.NoError(t, err)
+	defer f.Close()
+	require.False(t, modTime.IsZero())
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(data))
+}
+
+func TestServeWatermarked(t *testing.T) {
+	dir := t.TempDir()
+	files := fileupload.NewLocalUplader(dir, "", nil, 0)
+	cacheDir := t.TempDir()
+	cache := NewStoreCache(fileupload.NewLocalUplader(cacheDir, "", nil, 0))
+	mark := watermark.NewMark(1, "reader@example.com")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	w.Write([]byte("application/epub+zip"))
+	w, _ = zw.Create("META-INF/container.xml")
+	w.Write([]byte(`<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`))
+	w, _ = zw.Create("content.opf")
+	w.Write([]byte(`<package><metadata></metadata><manifest><item href="c1.xhtml" media-type="application/xhtml+xml"/></manifest></package>`))
+	w, _ = zw.Create("c1.xhtml")
+	w.Write([]byte(`<html><body>hello</body></html>`))
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "book.epub"), buf.Bytes(), 0o644))
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		_, err := ServeWatermarked(rec, httptest.NewRequest(http.MethodGet, "/", nil), "book.epub", files, cache, 1, mark)
+		require.NoError(t, err)
+		return rec
+	}
+
+	rec := serve()
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `attachment; filename="book.epub"`, rec.Header().Get("Content-Disposition"))
+	require.Contains(t, rec.Body.String(), "mimetypeapplication/epub+zip")
+
+	key := cacheKey(1, "book.epub", watermark.FormatEPUB, mark)
+	_, err := os.Stat(filepath.Join(cacheDir, filepath.FromSlash(key)))
+	require.NoError(t, err)
+
+	// 之后直接读取缓存，不再读取源文件
+	require.NoError(t, os.Remove(filepath.Join(dir, "book.epub")))
+	require.Equal(t, rec.Body.Bytes(), serve().Body.Bytes())
+}
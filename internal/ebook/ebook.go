@@ -0,0 +1,195 @@
+// Package ebook 解析电子书文件的元数据，用于上传电子书时预填图书信息：
+// EPUB 读取 OPF 元数据和 NCX/导航文档的页码表，PDF 读取文档信息字典和 XMP 元数据
+package ebook
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrUnsupported = errors.New("仅支持解析EPUB、PDF文件")
+
+// 支持的文件格式
+const (
+	FormatEPUB = "epub"
+	FormatPDF  = "pdf"
+)
+
+// Metadata 电子书元数据，没有的字段为零值
+type Metadata struct {
+	Format      string     `json:"format"`
+	Title       string     `json:"title"`
+	Subtitle    string     `json:"subtitle"`
+	Authors     []string   `json:"authors"`
+	Publisher   string     `json:"publisher"`
+	ISBN        string     `json:"isbn"`
+	Pubdate     *time.Time `json:"pubdate"`
+	Language    string     `json:"language"`
+	Description string     `json:"description"`
+	PageCount   int        `json:"pageCount"` // EPUB 没有页码表时为0
+	Cover       *Cover     `json:"-"`
+}
+
+// Cover 封面图片
+type Cover struct {
+	Data      []byte
+	MediaType string
+}
+
+// Ext 封面图片的扩展名，如 .jpg
+func (c *Cover) Ext() string {
+	switch c.MediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
+
+// FormatOf 根据文件头判断格式：PDF 以 %PDF- 开头；EPUB 是 zip 文件，
+// 第一个文件通常是未压缩的 mimetype，不规范的文件根据扩展名判断
+func FormatOf(r io.ReaderAt, filename string) (string, error) {
+	head := make([]byte, 64)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		if bytes.Contains(head, []byte("application/epub+zip")) || strings.EqualFold(path.Ext(filename), ".epub") {
+			return FormatEPUB, nil
+		}
+	}
+	return "", ErrUnsupported
+}
+
+// Parse 解析电子书元数据，r 为整个文件
+func Parse(r io.ReaderAt, size int64, filename string) (*Metadata, error) {
+	format, err := FormatOf(r, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatEPUB:
+		return ParseEPUB(r, size)
+	case FormatPDF:
+		return ParsePDF(r, size)
+	}
+	return nil, ErrUnsupported
+}
+
+var (
+	isbnPattern = regexp.MustCompile(`(?i)isbn(?:-1[03])?[:：\s]*([0-9][0-9\- ]{8,16}[0-9x])`)
+	tagPattern  = regexp.MustCompile(`<[^>]*>`)
+	spaces      = regexp.MustCompile(`\s+`)
+)
+
+// normalizeISBN 去掉连字符和空格，校验位正确返回 ISBN，否则返回空字符串
+func normalizeISBN(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, prefix := range []string{"URN:ISBN:", "ISBN:", "ISBN"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s))
+
+	switch len(s) {
+	case 10:
+		sum := 0
+		for i, c := range s {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case c == 'X' && i == 9:
+				d = 10
+			default:
+				return ""
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 == 0 {
+			return s
+		}
+	case 13:
+		sum := 0
+		for i, c := range s {
+			if c < '0' || c > '9' {
+				return ""
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		if sum%10 == 0 {
+			return s
+		}
+	}
+	return ""
+}
+
+// findISBN 从文本中查找 "ISBN xxx"
+func findISBN(text string) string {
+	for _, m := range isbnPattern.FindAllStringSubmatch(text, -1) {
+		if isbn := normalizeISBN(m[1]); isbn != "" {
+			return isbn
+		}
+	}
+	return ""
+}
+
+// plainText 去掉 HTML 标签、反转义并合并空白
+func plainText(s string) string {
+	s = tagPattern.ReplaceAllString(html.UnescapeString(s), " ")
+	return strings.TrimSpace(spaces.ReplaceAllString(html.UnescapeString(s), " "))
+}
+
+// splitTitle 拆分 "标题：副标题" 或 "Title: Subtitle"
+func splitTitle(title string) (string, string) {
+	for _, sep := range []string{"：", ": ", " - ", "——"} {
+		if main, sub, ok := strings.Cut(title, sep); ok && strings.TrimSpace(main) != "" && strings.TrimSpace(sub) != "" {
+			return strings.TrimSpace(main), strings.TrimSpace(sub)
+		}
+	}
+	return strings.TrimSpace(title), ""
+}
+
+// splitNames 拆分多个作者
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ';' || r == '；' || r == '、' || r == '&' || r == '，'
+	}) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseDate 解析日期，支持 2006-01-02、2006-01、2006 和 RFC3339
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	if len(s) > 10 {
+		return parseDate(s[:10])
+	}
+	return nil
+}
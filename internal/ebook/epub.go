@@ -0,0 +1,372 @@
+package ebook
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 解压的单个文件最大 32MB，避免恶意文件耗尽内存
+const maxEntrySize = 32 << 20
+
+var errBadEPUB = errors.New("无效的EPUB文件")
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []opfTitle   `xml:"title"`
+		Creators    []opfCreator `xml:"creator"`
+		Identifiers []opfID      `xml:"identifier"`
+		Publisher   string       `xml:"publisher"`
+		Dates       []opfDate    `xml:"date"`
+		Language    string       `xml:"language"`
+		Description string       `xml:"description"`
+		Metas       []opfMeta    `xml:"meta"`
+	} `xml:"metadata"`
+	Items []opfItem `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type opfTitle struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:",chardata"`
+}
+
+type opfCreator struct {
+	ID    string `xml:"id,attr"`
+	Role  string `xml:"role,attr"` // EPUB2 的 opf:role
+	Value string `xml:",chardata"`
+}
+
+type opfID struct {
+	Scheme string `xml:"scheme,attr"` // EPUB2 的 opf:scheme
+	Value  string `xml:",chardata"`
+}
+
+type opfDate struct {
+	Event string `xml:"event,attr"` // EPUB2 的 opf:event
+	Value string `xml:",chardata"`
+}
+
+// opfMeta EPUB2 为 name/content，EPUB3 为 property/refines 和文本
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// ParseEPUB 解析 EPUB 元数据和封面
+func ParseEPUB(r io.ReaderAt, size int64) (*Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	opfPath, err := opfPath(zr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readEntry(zr, opfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	dir := path.Dir(opfPath)
+	m := &Metadata{Format: FormatEPUB}
+	md := pkg.Metadata
+
+	// EPUB3 通过 <meta refines="#id" property="..."> 补充标题类型、作者角色
+	refines := func(id, property string) string {
+		for _, meta := range md.Metas {
+			if id != "" && meta.Refines == "#"+id && meta.Property == property {
+				return strings.TrimSpace(meta.Value)
+			}
+		}
+		return ""
+	}
+
+	for _, t := range md.Titles {
+		title := strings.TrimSpace(t.Value)
+		switch {
+		case title == "":
+		case refines(t.ID, "title-type") == "subtitle":
+			m.Subtitle = title
+		case m.Title == "":
+			m.Title = title
+		}
+	}
+	if m.Subtitle == "" {
+		m.Title, m.Subtitle = splitTitle(m.Title)
+	}
+
+	// 作者：角色为 aut 的创建者，都没有标注角色时取全部
+	var others []string
+	for _, c := range md.Creators {
+		name := strings.TrimSpace(c.Value)
+		if name == "" {
+			continue
+		}
+		role := c.Role
+		if role == "" {
+			role = refines(c.ID, "role")
+		}
+		if role == "" || role == "aut" {
+			m.Authors = append(m.Authors, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+	if len(m.Authors) == 0 {
+		m.Authors = others
+	}
+
+	for _, id := range md.Identifiers {
+		if isbn := normalizeISBN(id.Value); isbn != "" {
+			m.ISBN = isbn
+			break
+		}
+	}
+
+	for _, d := range md.Dates {
+		if t := parseDate(d.Value); t != nil && (m.Pubdate == nil || d.Event == "publication") {
+			m.Pubdate = t
+		}
+	}
+
+	m.Publisher = strings.TrimSpace(md.Publisher)
+	m.Language = strings.TrimSpace(md.Language)
+	m.Description = plainText(md.Description)
+	if m.ISBN == "" {
+		m.ISBN = findISBN(m.Description)
+	}
+
+	// NCX 或 EPUB3 导航文档补充标题、作者和页数，解析失败不影响其他元数据
+	if ncx := pkg.item(func(item opfItem) bool {
+		return item.ID == pkg.Spine.Toc || item.MediaType == "application/x-dtbncx+xml"
+	}); ncx != nil {
+		parseNCX(zr, entryPath(dir, ncx.Href), m)
+	}
+	if m.PageCount == 0 {
+		if nav := pkg.item(func(item opfItem) bool { return hasProperty(item.Properties, "nav") }); nav != nil {
+			m.PageCount = navPageCount(zr, entryPath(dir, nav.Href))
+		}
+	}
+
+	if cover := pkg.cover(); cover != nil {
+		if data, err := readEntry(zr, entryPath(dir, cover.Href)); err == nil {
+			m.Cover = &Cover{Data: data, MediaType: cover.MediaType}
+		}
+	}
+
+	return m, nil
+}
+
+func (pkg *opfPackage) item(match func(opfItem) bool) *opfItem {
+	for i := range pkg.Items {
+		if match(pkg.Items[i]) {
+			return &pkg.Items[i]
+		}
+	}
+	return nil
+}
+
+// cover 封面图片：EPUB3 为 properties="cover-image"，EPUB2 为 <meta name="cover" content="id">，
+// 都没有时取 id 或文件名包含 cover 的图片
+func (pkg *opfPackage) cover() *opfItem {
+	isImage := func(item opfItem) bool { return strings.HasPrefix(item.MediaType, "image/") }
+
+	if item := pkg.item(func(item opfItem) bool {
+		return isImage(item) && hasProperty(item.Properties, "cover-image")
+	}); item != nil {
+		return item
+	}
+	for _, meta := range pkg.Metadata.Metas {
+		if meta.Name == "cover" && meta.Content != "" {
+			if item := pkg.item(func(item opfItem) bool { return isImage(item) && item.ID == meta.Content }); item != nil {
+				return item
+			}
+		}
+	}
+	return pkg.item(func(item opfItem) bool {
+		return isImage(item) && (strings.Contains(strings.ToLower(item.ID), "cover") ||
+			strings.Contains(strings.ToLower(path.Base(item.Href)), "cover"))
+	})
+}
+
+func hasProperty(properties, name string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNCX 读取 NCX 的 docTitle、docAuthor 和 pageList
+func parseNCX(zr *zip.Reader, name string, m *Metadata) {
+	data, err := readEntry(zr, name)
+	if err != nil {
+		return
+	}
+
+	var ncx struct {
+		Title   string   `xml:"docTitle>text"`
+		Authors []string `xml:"docAuthor>text"`
+		Pages   []struct {
+			Value string `xml:"value,attr"`
+		} `xml:"pageList>pageTarget"`
+	}
+	if err := xml.Unmarshal(data, &ncx); err != nil {
+		return
+	}
+
+	if m.Title == "" {
+		m.Title, m.Subtitle = splitTitle(ncx.Title)
+	}
+	if len(m.Authors) == 0 {
+		for _, a := range ncx.Authors {
+			if a = strings.TrimSpace(a); a != "" {
+				m.Authors = append(m.Authors, a)
+			}
+		}
+	}
+	m.PageCount = len(ncx.Pages)
+}
+
+// navPageCount EPUB3 导航文档中 <nav epub:type="page-list"> 的链接数
+func navPageCount(zr *zip.Reader, name string) int {
+	data, err := readEntry(zr, name)
+	if err != nil {
+		return 0
+	}
+
+	d := xml.NewDecoder(strings.NewReader(string(data)))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	count, depth := 0, 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return count
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth > 0 {
+				if t.Name.Local == "nav" {
+					depth++
+				} else if t.Name.Local == "a" {
+					count++
+				}
+				continue
+			}
+			if t.Name.Local != "nav" {
+				continue
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "type" && hasProperty(attr.Value, "page-list") {
+					depth = 1
+				}
+			}
+		case xml.EndElement:
+			if depth > 0 && t.Name.Local == "nav" {
+				depth--
+				if depth == 0 {
+					return count
+				}
+			}
+		}
+	}
+}
+
+// opfPath 从 META-INF/container.xml 读取 OPF 文件路径
+func opfPath(zr *zip.Reader) (string, error) {
+	data, err := readEntry(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	for _, rf := range container.Rootfiles {
+		if rf.FullPath != "" && (rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml") {
+			return rf.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("%w: 没有找到OPF文件", errBadEPUB)
+}
+
+// entryPath 清单中相对 OPF 的地址转为 zip 中的路径
+func entryPath(dir, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if s, err := url.PathUnescape(href); err == nil {
+		href = s
+	}
+	return strings.TrimPrefix(path.Clean(path.Join(dir, href)), "./")
+}
+
+func readEntry(zr *zip.Reader, name string) ([]byte, error) {
+	var f *zip.File
+	for _, file := range zr.File {
+		if file.Name == name {
+			f = file
+			break
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%w: 缺少 %s", errBadEPUB, name)
+	}
+	if f.UncompressedSize64 > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s 太大", errBadEPUB, f.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s 太大", errBadEPUB, f.Name)
+	}
+	return data, nil
+}
@@ -0,0 +1,175 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const containerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// buildEPUB 生成测试用的EPUB，mimetype 在最前面，files 为 zip 中的路径和内容，按顺序写入
+func buildEPUB(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	w.Write([]byte("application/epub+zip"))
+
+	files = append([]string{"META-INF/container.xml", containerXML}, files...)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		require.NoError(t, err)
+		w.Write([]byte(files[i+1]))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func parseEPUB(t *testing.T, data []byte) *Metadata {
+	t.Helper()
+	m, err := Parse(bytes.NewReader(data), int64(len(data)), "book.epub")
+	require.NoError(t, err)
+	return m
+}
+
+func TestParseEPUB2(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:opf="http://www.idpf.org/2007/opf" version="2.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<dc:title>三体：地球往事</dc:title>
+	<dc:creator opf:role="trl">译者</dc:creator>
+	<dc:creator opf:role="aut">刘慈欣</dc:creator>
+	<dc:identifier opf:scheme="uuid">urn:uuid:1234</dc:identifier>
+	<dc:identifier opf:scheme="ISBN">978-7-5366-9293-0</dc:identifier>
+	<dc:publisher> 重庆出版社 </dc:publisher>
+	<dc:date opf:event="modification">2020-01-01</dc:date>
+	<dc:date opf:event="publication">2008-01</dc:date>
+	<dc:language>zh</dc:language>
+	<dc:description>&lt;p&gt;文化大革命&amp;amp;  红岸基地&lt;/p&gt;</dc:description>
+	<meta name="cover" content="img1"/>
+</metadata>
+<manifest>
+	<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+	<item id="img1" href="images/front.jpg" media-type="image/jpeg"/>
+	<item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+<spine toc="ncx"><itemref idref="c1"/></spine>
+</package>`
+	ncx := `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<docTitle><text>NCX 标题</text></docTitle>
+<docAuthor><text>NCX 作者</text></docAuthor>
+<pageList><pageTarget value="1"/><pageTarget value="2"/><pageTarget value="3"/></pageList>
+</ncx>`
+
+	m := parseEPUB(t, buildEPUB(t,
+		"OEBPS/content.opf", opf,
+		"OEBPS/toc.ncx", ncx,
+		"OEBPS/images/front.jpg", "jpeg data",
+		"OEBPS/c1.xhtml", "<html/>",
+	))
+
+	require.Equal(t, FormatEPUB, m.Format)
+	require.Equal(t, "三体", m.Title)
+	require.Equal(t, "地球往事", m.Subtitle)
+	require.Equal(t, []string{"刘慈欣"}, m.Authors)
+	require.Equal(t, "9787536692930", m.ISBN)
+	require.Equal(t, "重庆出版社", m.Publisher)
+	require.Equal(t, time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC), *m.Pubdate)
+	require.Equal(t, "zh", m.Language)
+	require.Equal(t, "文化大革命& 红岸基地", m.Description)
+	require.Equal(t, 3, m.PageCount)
+	require.NotNil(t, m.Cover)
+	require.Equal(t, "jpeg data", string(m.Cover.Data))
+	require.Equal(t, ".jpg", m.Cover.Ext())
+}
+
+func TestParseEPUB3(t *testing.T) {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<dc:title id="t1">The Book</dc:title>
+	<dc:title id="t2">A Subtitle</dc:title>
+	<meta refines="#t2" property="title-type">subtitle</meta>
+	<dc:creator id="c1">Editor</dc:creator>
+	<meta refines="#c1" property="role" scheme="marc:relators">edt</meta>
+	<dc:creator id="c2">Author One</dc:creator>
+	<meta refines="#c2" property="role" scheme="marc:relators">aut</meta>
+	<dc:creator id="c3">Author Two</dc:creator>
+	<dc:identifier>urn:uuid:1234</dc:identifier>
+	<dc:date>2021-06-15T00:00:00Z</dc:date>
+	<dc:description>See ISBN 0-306-40615-2 for details</dc:description>
+</metadata>
+<manifest>
+	<item id="nav" href="nav%20doc.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+	<item id="cover" href="cover.png" media-type="image/png" properties="cover-image"/>
+	<item id="thumb" href="cover-thumb.jpg" media-type="image/jpeg"/>
+</manifest>
+<spine/>
+</package>`
+	nav := `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<nav epub:type="toc"><ol><li><a href="c1.xhtml">1</a></li></ol></nav>
+<nav epub:type="page-list"><ol><li><a href="c1.xhtml#p1">1</a></li><li><a href="c1.xhtml#p2">2</a><br></li></ol></nav>
+</body></html>`
+
+	m := parseEPUB(t, buildEPUB(t,
+		"OEBPS/content.opf", opf,
+		"OEBPS/nav doc.xhtml", nav,
+		"OEBPS/cover.png", "png data",
+		"OEBPS/cover-thumb.jpg", "jpeg data",
+	))
+
+	require.Equal(t, "The Book", m.Title)
+	require.Equal(t, "A Subtitle", m.Subtitle)
+	require.Equal(t, []string{"Author One", "Author Two"}, m.Authors)
+	require.Equal(t, "0306406152", m.ISBN)
+	require.Equal(t, 2021, m.Pubdate.Year())
+	require.Equal(t, 2, m.PageCount)
+	require.Equal(t, "png data", string(m.Cover.Data))
+}
+
+func TestParseEPUBInvalid(t *testing.T) {
+	_, err := ParseEPUB(bytes.NewReader([]byte("PK\x03\x04")), 4)
+	require.ErrorIs(t, err, errBadEPUB)
+
+	// 缺少 OPF
+	data := buildEPUB(t)
+	_, err = ParseEPUB(bytes.NewReader(data), int64(len(data)))
+	require.ErrorIs(t, err, errBadEPUB)
+
+	_, err = Parse(bytes.NewReader([]byte("hello")), 5, "a.epub")
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	for in, want := range map[string]string{
+		"978-7-5366-9293-0":   "9787536692930",
+		"urn:isbn:0306406152": "0306406152",
+		"ISBN 0-8044-2957-X":  "080442957X",
+		"978-7-5366-9293-1":   "",
+		"12345":               "",
+	} {
+		require.Equal(t, want, normalizeISBN(in), in)
+	}
+	require.Equal(t, "9787536692930", findISBN("书号 ISBN：978-7-5366-9293-0，定价23元"))
+}
+
+func TestSplitTitle(t *testing.T) {
+	for in, want := range map[string][2]string{
+		"三体：地球往事":          {"三体", "地球往事"},
+		"Go: The Language": {"Go", "The Language"},
+		"没有副标题":            {"没有副标题", ""},
+		"：只有副标题":           {"：只有副标题", ""},
+	} {
+		title, sub := splitTitle(in)
+		require.Equal(t, want, [2]string{title, sub}, in)
+	}
+}
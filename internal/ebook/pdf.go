@@ -0,0 +1,211 @@
+package ebook
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/pdf"
+)
+
+// PDF 文件最大 512MB，需要整个读入内存解析
+const maxPDFSize = 512 << 20
+
+// ParsePDF 解析 PDF 的文档信息字典、XMP 元数据和页数，
+// 封面取第一页中最大的 JPEG 图片，加密的文件只能读取页数
+func ParsePDF(r io.ReaderAt, size int64) (*Metadata, error) {
+	if size > maxPDFSize {
+		return nil, fmt.Errorf("%w: 文件太大", pdf.ErrInvalid)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := pdf.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := f.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{Format: FormatPDF, PageCount: len(pages)}
+	if f.Encrypted() {
+		return m, nil
+	}
+
+	infoMetadata(f, m)
+	xmpMetadata(f, m)
+
+	if m.Title != "" && m.Subtitle == "" {
+		m.Title, m.Subtitle = splitTitle(m.Title)
+	}
+	if m.ISBN == "" {
+		m.ISBN = findISBN(m.Description)
+	}
+	if len(pages) > 0 {
+		m.Cover = firstJPEG(f, pages[0])
+	}
+	return m, nil
+}
+
+// infoMetadata 读取 trailer 中的 /Info 文档信息字典
+func infoMetadata(f *pdf.File, m *Metadata) {
+	v, _ := f.Resolve(f.Trailer["Info"])
+	info, ok := v.(pdf.Dict)
+	if !ok {
+		return
+	}
+
+	text := func(key pdf.Name) string {
+		v, _ := f.Resolve(info[key])
+		s, _ := v.(pdf.String)
+		return strings.TrimSpace(s.Text())
+	}
+
+	m.Title = text("Title")
+	m.Authors = splitNames(text("Author"))
+	m.Description = plainText(text("Subject"))
+	m.ISBN = findISBN(text("Keywords") + " " + text("Subject"))
+	m.Pubdate = pdfDate(text("CreationDate"))
+}
+
+// pdfDate 解析 D:YYYYMMDDHHmmSS 格式的日期，只取年月日
+func pdfDate(s string) *time.Time {
+	s = strings.TrimPrefix(s, "D:")
+	for _, layout := range []string{"20060102", "200601", "2006"} {
+		if len(s) >= len(layout) {
+			if t, err := time.Parse(layout, s[:len(layout)]); err == nil {
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// xmpMetadata 读取目录中 /Metadata 的 XMP，补充文档信息字典中没有的字段
+func xmpMetadata(f *pdf.File, m *Metadata) {
+	v, _ := f.Resolve(f.Trailer["Root"])
+	catalog, _ := v.(pdf.Dict)
+	v, _ = f.Resolve(catalog["Metadata"])
+	stm, ok := v.(*pdf.Stream)
+	if !ok {
+		return
+	}
+	data, err := f.Decode(stm)
+	if err != nil {
+		return
+	}
+
+	dc := dublinCore(data)
+	first := func(key string) string {
+		if len(dc[key]) > 0 {
+			return dc[key][0]
+		}
+		return ""
+	}
+
+	if m.Title == "" {
+		m.Title = first("title")
+	}
+	if len(m.Authors) == 0 {
+		m.Authors = dc["creator"]
+	}
+	if m.Publisher == "" {
+		m.Publisher = first("publisher")
+	}
+	if m.Description == "" {
+		m.Description = plainText(first("description"))
+	}
+	if m.Language == "" {
+		m.Language = first("language")
+	}
+	if m.ISBN == "" {
+		for _, id := range dc["identifier"] {
+			if isbn := normalizeISBN(id); isbn != "" {
+				m.ISBN = isbn
+				break
+			}
+		}
+	}
+	if date := parseDate(first("date")); date != nil {
+		m.Pubdate = date
+	}
+}
+
+// dublinCore 读取 XMP 中 dc 命名空间的字段，rdf:Alt/Seq/Bag 中的每个 rdf:li 作为一个值
+func dublinCore(data []byte) map[string][]string {
+	const dcNS = "http://purl.org/dc/elements/1.1/"
+
+	values := make(map[string][]string)
+	d := xml.NewDecoder(strings.NewReader(string(data)))
+	d.Strict = false
+
+	var field string
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return values
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == dcNS {
+				field = t.Name.Local
+			}
+			text.Reset()
+		case xml.CharData:
+			if field != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if field == "" {
+				continue
+			}
+			if s := strings.TrimSpace(text.String()); s != "" && (t.Name.Local == "li" || t.Name.Space == dcNS) {
+				values[field] = append(values[field], s)
+			}
+			text.Reset()
+			if t.Name.Space == dcNS {
+				field = ""
+			}
+		}
+	}
+}
+
+// firstJPEG 页面资源中最大的 JPEG 图片，电子书的第一页通常就是封面
+func firstJPEG(f *pdf.File, page pdf.Page) *Cover {
+	v, _ := f.Resolve(page.Resources["XObject"])
+	xobjects, _ := v.(pdf.Dict)
+
+	var cover *Cover
+	var area float64
+	for _, ref := range xobjects {
+		v, _ := f.Resolve(ref)
+		stm, ok := v.(*pdf.Stream)
+		if !ok || stm.Dict["Subtype"] != pdf.Name("Image") {
+			continue
+		}
+
+		filter, _ := f.Resolve(stm.Dict["Filter"])
+		if arr, ok := filter.(pdf.Array); ok && len(arr) == 1 {
+			filter = arr[0]
+		}
+		if filter != pdf.Name("DCTDecode") {
+			continue
+		}
+
+		w, _ := f.Resolve(stm.Dict["Width"])
+		h, _ := f.Resolve(stm.Dict["Height"])
+		if a := pdf.Number(w) * pdf.Number(h); a > area {
+			area = a
+			cover = &Cover{Data: stm.Data, MediaType: "image/jpeg"}
+		}
+	}
+	return cover
+}
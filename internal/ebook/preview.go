@@ -0,0 +1,248 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+var ErrPreviewUnsupported = errors.New("仅EPUB电子书支持试读")
+
+// previewEnd 试读结束页，替换所有指向未包含章节的链接
+const (
+	previewEndID   = "ebook-preview-end"
+	previewEndName = "preview-end.xhtml"
+	previewEndPage = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>试读结束</title></head>
+<body><p style="margin-top:3em;text-align:center;">试读结束，购买后阅读完整内容。</p></body>
+</html>
+`
+)
+
+// PreviewOptions 试读范围，Mode 为 models.PreviewChapters 时 Value 为章数，models.PreviewPercent 时为百分比
+type PreviewOptions struct {
+	Mode  string
+	Value int
+}
+
+// PreviewEPUB 生成试读版 EPUB：保留阅读顺序(spine)中的前N个章节或按内容大小计算的前N%，
+// 删除其余章节，在末尾添加试读结束页，目录和正文中指向已删除章节的链接改为指向结束页
+func PreviewEPUB(dst io.Writer, src io.ReaderAt, size int64, opt PreviewOptions) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	opfName, err := opfPath(zr)
+	if err != nil {
+		return err
+	}
+	opf, err := readEntry(zr, opfName)
+	if err != nil {
+		return err
+	}
+
+	var pkg opfPackage
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	dir := path.Dir(opfName)
+	removedIDs, removed := previewRemoved(zr, &pkg, dir, opt)
+	endName := entryPath(dir, previewEndName)
+
+	// OPF 删除章节的 manifest 和 spine 条目，添加结束页
+	opf = removeElements(opf, "item", "id", removedIDs)
+	opf = removeElements(opf, "itemref", "idref", removedIDs)
+	opf = insertBeforeTag(opf, "manifest",
+		fmt.Sprintf(`<item id="%s" href="%s" media-type="application/xhtml+xml"/>`, previewEndID, previewEndName))
+	opf = insertBeforeTag(opf, "spine", fmt.Sprintf(`<itemref idref="%s"/>`, previewEndID))
+	opf = rewriteLinks(opf, opfName, removed, endName)
+
+	// 需要改写链接的文件：XHTML 页面、NCX
+	rewrite := make(map[string]bool)
+	for _, item := range pkg.Items {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "application/x-dtbncx+xml" {
+			rewrite[entryPath(dir, item.Href)] = true
+		}
+	}
+
+	zw := zip.NewWriter(dst)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		var data []byte
+		switch {
+		case f.Name == "mimetype" || f.Name == endName || removed[f.Name]:
+			continue
+		case f.Name == opfName:
+			data = opf
+		case rewrite[f.Name]:
+			page, err := readEntry(zr, f.Name)
+			if err != nil {
+				return err
+			}
+			data = rewriteLinks(page, f.Name, removed, endName)
+		default:
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeEntry(zw, f.Name, data); err != nil {
+			return err
+		}
+	}
+
+	if err := writeEntry(zw, endName, []byte(previewEndPage)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// previewRemoved 计算试读不包含的章节，返回 manifest id 和 zip 路径；
+// 只计算线性阅读(linear 不为 no)的章节，至少保留一个
+func previewRemoved(zr *zip.Reader, pkg *opfPackage, dir string, opt PreviewOptions) (map[string]bool, map[string]bool) {
+	type chapter struct {
+		id, name string
+		size     uint64
+	}
+
+	var chapters []chapter
+	var total uint64
+	for _, ref := range pkg.Spine.Itemrefs {
+		if ref.Linear == "no" {
+			continue
+		}
+		item := pkg.item(func(item opfItem) bool { return item.ID == ref.IDRef })
+		if item == nil {
+			continue
+		}
+		c := chapter{id: item.ID, name: entryPath(dir, item.Href)}
+		for _, f := range zr.File {
+			if f.Name == c.name {
+				c.size = f.UncompressedSize64
+			}
+		}
+		chapters = append(chapters, c)
+		total += c.size
+	}
+
+	keep := opt.Value
+	if opt.Mode == models.PreviewPercent {
+		var sum uint64
+		for keep = 0; keep < len(chapters) && sum*100 < total*uint64(opt.Value); keep++ {
+			sum += chapters[keep].size
+		}
+	}
+	keep = max(keep, 1)
+
+	ids, names := make(map[string]bool), make(map[string]bool)
+	for i := keep; i < len(chapters); i++ {
+		ids[chapters[i].id] = true
+		names[chapters[i].name] = true
+	}
+	return ids, names
+}
+
+var elementAttr = regexp.MustCompile(`\s([\w:-]+)\s*=\s*("[^"]*"|'[^']*')`)
+
+// removeElements 删除属性 attr 的值在 values 中的元素，元素为自闭合或紧跟结束标签的形式
+func removeElements(data []byte, tag, attr string, values map[string]bool) []byte {
+	if len(values) == 0 {
+		return data
+	}
+	pattern := regexp.MustCompile(`<(?:[\w-]+:)?` + tag + `\b[^>]*?(?:/>|>\s*</(?:[\w-]+:)?` + tag + `\s*>)`)
+	return pattern.ReplaceAllFunc(data, func(elem []byte) []byte {
+		for _, m := range elementAttr.FindAllSubmatch(elem, -1) {
+			name := string(m[1])
+			if i := strings.IndexByte(name, ':'); i >= 0 {
+				name = name[i+1:]
+			}
+			if name == attr && values[string(m[2][1:len(m[2])-1])] {
+				return nil
+			}
+		}
+		return elem
+	})
+}
+
+var linkAttr = regexp.MustCompile(`\s(href|src)\s*=\s*("[^"]*"|'[^']*')`)
+
+// rewriteLinks 把文件 name 中指向已删除文件的链接改为指向 endName
+func rewriteLinks(data []byte, name string, removed map[string]bool, endName string) []byte {
+	if len(removed) == 0 {
+		return data
+	}
+	dir := path.Dir(name)
+	return linkAttr.ReplaceAllFunc(data, func(m []byte) []byte {
+		sub := linkAttr.FindSubmatch(m)
+		quote, value := sub[2][0], string(sub[2][1:len(sub[2])-1])
+		if value == "" || strings.HasPrefix(value, "#") || strings.Contains(value, ":") {
+			return m
+		}
+		if !removed[entryPath(dir, value)] {
+			return m
+		}
+		link := (&url.URL{Path: relPath(dir, endName)}).EscapedPath()
+		return fmt.Appendf(nil, " %s=%c%s%c", sub[1], quote, link, quote)
+	})
+}
+
+// relPath zip 中 dir 目录到 name 的相对路径
+func relPath(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	from := strings.Split(dir, "/")
+	to := strings.Split(name, "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	return strings.Repeat("../", len(from)-i) + strings.Join(to[i:], "/")
+}
+
+// insertBeforeTag 在结束标签 </tag> 或 </prefix:tag> 前插入元素 elem，elem 使用相同的命名空间前缀
+func insertBeforeTag(data []byte, tag, elem string) []byte {
+	pattern := regexp.MustCompile(`</([\w-]+:)?` + tag + `\s*>`)
+	loc := pattern.FindSubmatchIndex(data)
+	if loc == nil {
+		return data
+	}
+	if loc[2] >= 0 {
+		elem = "<" + string(data[loc[2]:loc[3]]) + elem[1:]
+	}
+
+	out := make([]byte, 0, len(data)+len(elem))
+	out = append(out, data[:loc[0]]...)
+	out = append(out, elem...)
+	return append(out, data[loc[0]:]...)
+}
+
+func writeEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, bytes.NewReader(data))
+	return err
+}
@@ -0,0 +1,143 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lightsaid/ebook/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// previewSource 4个章节的EPUB，附录不在线性阅读顺序中
+func previewSource(t *testing.T) []byte {
+	opf := `<?xml version="1.0" encoding="UTF-8"?>
+<opf:package xmlns:opf="http://www.idpf.org/2007/opf" version="3.0">
+<opf:metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Preview</dc:title></opf:metadata>
+<opf:manifest>
+	<opf:item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+	<opf:item id="c1" href="text/c1.xhtml" media-type="application/xhtml+xml"/>
+	<opf:item id="c2" href="text/c2.xhtml" media-type="application/xhtml+xml"/>
+	<opf:item id="c3" href="text/c3.xhtml" media-type="application/xhtml+xml"></opf:item>
+	<opf:item id="c4" href="text/c4.xhtml" media-type="application/xhtml+xml"/>
+	<opf:item id="appendix" href="text/appendix.xhtml" media-type="application/xhtml+xml"/>
+</opf:manifest>
+<opf:spine>
+	<opf:itemref idref="c1"/>
+	<opf:itemref idref="appendix" linear="no"/>
+	<opf:itemref idref="c2"/>
+	<opf:itemref idref="c3"/>
+	<opf:itemref idref="c4"/>
+</opf:spine>
+</opf:package>`
+	nav := `<html><body><nav><ol>
+<li><a href="text/c1.xhtml">1</a></li><li><a href="text/c2.xhtml">2</a></li>
+<li><a href="text/c3.xhtml#s1">3</a></li><li><a href='text/c4.xhtml'>4</a></li>
+</ol></nav></body></html>`
+	chapter := func(body string, size int) string {
+		return "<html><body>" + body + strings.Repeat("x", size) + "</body></html>"
+	}
+	return buildEPUB(t,
+		"OEBPS/content.opf", opf,
+		"OEBPS/nav.xhtml", nav,
+		"OEBPS/text/c1.xhtml", chapter(`<a href="c3.xhtml">下一节</a><a href="#top">顶部</a><a href="https://example.com/c3.xhtml">外链</a>`, 1000),
+		"OEBPS/text/c2.xhtml", chapter("", 1000),
+		"OEBPS/text/c3.xhtml", chapter("", 8000),
+		"OEBPS/text/c4.xhtml", chapter("", 10000),
+		"OEBPS/text/appendix.xhtml", chapter("", 10),
+	)
+}
+
+func preview(t *testing.T, src []byte, opt PreviewOptions) (*zip.Reader, map[string]string) {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, PreviewEPUB(&out, bytes.NewReader(src), int64(len(src)), opt))
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(data)
+	}
+
+	// 试读文件仍是有效的EPUB：mimetype 是第一个文件且不压缩
+	require.Equal(t, "mimetype", zr.File[0].Name)
+	require.Equal(t, zip.Store, zr.File[0].Method)
+	require.Equal(t, "application/epub+zip", files["mimetype"])
+	m, err := ParseEPUB(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	require.Equal(t, "Preview", m.Title)
+	return zr, files
+}
+
+func TestPreviewChapters(t *testing.T) {
+	_, files := preview(t, previewSource(t), PreviewOptions{Mode: models.PreviewChapters, Value: 2})
+
+	require.Contains(t, files, "OEBPS/text/c1.xhtml")
+	require.Contains(t, files, "OEBPS/text/c2.xhtml")
+	require.Contains(t, files, "OEBPS/text/appendix.xhtml")
+	require.NotContains(t, files, "OEBPS/text/c3.xhtml")
+	require.NotContains(t, files, "OEBPS/text/c4.xhtml")
+	require.Equal(t, previewEndPage, files["OEBPS/preview-end.xhtml"])
+
+	opf := files["OEBPS/content.opf"]
+	require.NotContains(t, opf, `id="c3"`)
+	require.NotContains(t, opf, `id="c4"`)
+	require.NotContains(t, opf, `idref="c3"`)
+	require.Contains(t, opf, `<opf:item id="ebook-preview-end" href="preview-end.xhtml" media-type="application/xhtml+xml"/></opf:manifest>`)
+	require.Contains(t, opf, `<opf:itemref idref="ebook-preview-end"/></opf:spine>`)
+
+	// 指向已删除章节的链接改为指向结束页
+	nav := files["OEBPS/nav.xhtml"]
+	require.Contains(t, nav, `href="text/c2.xhtml"`)
+	require.Contains(t, nav, `href="preview-end.xhtml">3`)
+	require.Contains(t, nav, `href='preview-end.xhtml'>4`)
+
+	c1 := files["OEBPS/text/c1.xhtml"]
+	require.Contains(t, c1, `href="../preview-end.xhtml"`)
+	require.Contains(t, c1, `href="#top"`)
+	require.Contains(t, c1, `href="https://example.com/c3.xhtml"`)
+}
+
+func TestPreviewPercent(t *testing.T) {
+	// 章节大小约为 1000、1000、8000、10000
+	tests := []struct {
+		value int
+		keep  []string
+	}{
+		{1, []string{"c1"}},
+		{10, []string{"c1", "c2"}},
+		{50, []string{"c1", "c2", "c3"}},
+		{100, []string{"c1", "c2", "c3", "c4"}},
+	}
+	for _, tt := range tests {
+		_, files := preview(t, previewSource(t), PreviewOptions{Mode: models.PreviewPercent, Value: tt.value})
+		var kept []string
+		for _, c := range []string{"c1", "c2", "c3", "c4"} {
+			if _, ok := files["OEBPS/text/"+c+".xhtml"]; ok {
+				kept = append(kept, c)
+			}
+		}
+		require.Equal(t, tt.keep, kept, tt.value)
+	}
+}
+
+func TestPreviewKeepsOneChapter(t *testing.T) {
+	_, files := preview(t, previewSource(t), PreviewOptions{Mode: models.PreviewChapters, Value: 0})
+	require.Contains(t, files, "OEBPS/text/c1.xhtml")
+	require.NotContains(t, files, "OEBPS/text/c2.xhtml")
+}
+
+func TestRelPath(t *testing.T) {
+	require.Equal(t, "end.xhtml", relPath(".", "end.xhtml"))
+	require.Equal(t, "../end.xhtml", relPath("OEBPS/text", "OEBPS/end.xhtml"))
+	require.Equal(t, "end.xhtml", relPath("OEBPS", "OEBPS/end.xhtml"))
+	require.Equal(t, "../../b/end.xhtml", relPath("a/c/d", "a/b/end.xhtml"))
+}
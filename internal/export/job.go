@@ -0,0 +1,169 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+)
+
+// 导出任务状态
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// 任务完成后保留的时长，过期后删除任务和文件
+const jobTTL = 24 * time.Hour
+
+var ErrJobNotFound = errors.New("导出任务不存在或已过期")
+
+// Job 后台导出任务
+type Job struct {
+	ID         string     `json:"id"`
+	Entity     string     `json:"entity"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"` // 开始时统计的总数
+	Rows       int        `json:"rows"`  // 已写出的行数
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	path string
+}
+
+// Filename 下载时的文件名
+func (j Job) Filename() string {
+	return fmt.Sprintf("%s-%s.%s", j.Entity, j.CreatedAt.Format("20060102150405"), j.Format)
+}
+
+// Path 导出文件路径
+func (j Job) Path() string {
+	return j.path
+}
+
+// Jobs 内存中的导出任务，进程重启后任务丢失
+type Jobs struct {
+	dir  string
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+// NewJobs 创建任务管理，导出文件保存在 dir 目录
+func NewJobs(dir string) *Jobs {
+	return &Jobs{dir: dir, jobs: make(map[string]*Job)}
+}
+
+// Start 在后台执行导出，返回任务的快照
+func (js *Jobs) Start(entity, format string, exporter Exporter, f dbrepo.Filters, total int) (Job, error) {
+	if !Supported(format) {
+		return Job{}, ErrUnsupportedFormat
+	}
+
+	if err := os.MkdirAll(js.dir, 0o755); err != nil {
+		return Job{}, err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return Job{}, err
+	}
+	id := hex.EncodeToString(buf)
+
+	job := &Job{
+		ID:        id,
+		Entity:    entity,
+		Format:    format,
+		Status:    JobPending,
+		Total:     total,
+		CreatedAt: time.Now(),
+		path:      filepath.Join(js.dir, id+"."+format),
+	}
+
+	js.mu.Lock()
+	js.cleanup()
+	js.jobs[id] = job
+	snapshot := *job
+	js.mu.Unlock()
+
+	go js.run(job, exporter, f)
+
+	return snapshot, nil
+}
+
+// Get 获取任务的快照
+func (js *Jobs) Get(id string) (Job, error) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// run 执行导出，失败时删除未完成的文件
+func (js *Jobs) run(job *Job, exporter Exporter, f dbrepo.Filters) {
+	js.update(job, func(j *Job) { j.Status = JobRunning })
+
+	err := js.write(job, exporter, f)
+	if err != nil {
+		slog.Error("export job", "id", job.ID, "entity", job.Entity, "error", err)
+		os.Remove(job.path)
+	}
+
+	js.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Status = JobDone
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = err.Error()
+		}
+	})
+}
+
+func (js *Jobs) write(job *Job, exporter Exporter, f dbrepo.Filters) error {
+	file, err := os.Create(job.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = exporter.Export(context.Background(), file, job.Format, f, func(n int) {
+		js.update(job, func(j *Job) { j.Rows = n })
+	})
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func (js *Jobs) update(job *Job, fn func(j *Job)) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	fn(job)
+}
+
+// cleanup 删除过期的任务和文件，调用方需持有锁
+func (js *Jobs) cleanup() {
+	for id, job := range js.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > jobTTL {
+			os.Remove(job.path)
+			delete(js.jobs, id)
+		}
+	}
+}
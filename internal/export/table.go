@@ -0,0 +1,186 @@
+// Package export 导出图书、用户、订单等数据为csv、xlsx、jsonl，
+// 复用 dbrepo 的列表查询和 Filters，以游标分页逐页写出，不会一次性加载全部数据
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+// Exporter 可导出的数据表
+type Exporter interface {
+	// Count 符合条件的总数，用于判断是否需要后台导出
+	Count(ctx context.Context, f dbrepo.Filters) (int, error)
+	// Export 按 format 写出所有符合条件的数据，每写完一页调用一次 progress(已写行数)
+	Export(ctx context.Context, w io.Writer, format string, f dbrepo.Filters, progress func(n int)) error
+}
+
+// Column 导出的列
+type Column[T any] struct {
+	Header string
+	Value  func(T) any
+}
+
+// Table 基于列表查询的数据表，T 为列表元素类型
+type Table[T any] struct {
+	Columns []Column[T]
+	List    func(ctx context.Context, f dbrepo.Filters) (*dbrepo.PageQueryVo, error)
+}
+
+var _ Exporter = Table[*models.Book]{}
+
+// Count 符合条件的总数
+func (t Table[T]) Count(ctx context.Context, f dbrepo.Filters) (int, error) {
+	f.PageNum, f.PageSize = 1, 1
+	f.UseCursor, f.Cursor = false, ""
+
+	vo, err := t.List(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+	return vo.Metadata.TotalCount, nil
+}
+
+// Export 以游标分页逐页查询并写出
+func (t Table[T]) Export(ctx context.Context, w io.Writer, format string, f dbrepo.Filters, progress func(n int)) error {
+	ew, err := NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	headers := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		headers[i] = c.Header
+	}
+	if err := ew.Header(headers); err != nil {
+		return err
+	}
+
+	// 使用每页最大条数，首页从头开始
+	f.PageNum, f.PageSize = 1, pageSize
+	f.UseCursor, f.Cursor = true, ""
+
+	var n int
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vo, err := t.List(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		list, ok := vo.List.([]T)
+		if !ok {
+			return fmt.Errorf("export: 列表类型 %T 与导出类型不一致", vo.List)
+		}
+
+		for _, item := range list {
+			values := make([]any, len(t.Columns))
+			for i, c := range t.Columns {
+				values[i] = c.Value(item)
+			}
+			if err := ew.Write(item, values); err != nil {
+				return err
+			}
+		}
+
+		n += len(list)
+		if progress != nil {
+			progress(n)
+		}
+
+		if vo.Metadata.NextCursor == "" {
+			break
+		}
+		f.Cursor = vo.Metadata.NextCursor
+	}
+
+	return ew.Close()
+}
+
+// 每次查询的条数，与 dbrepo 允许的最大分页一致
+const pageSize = 100
+
+// Tables 可导出的数据表，key 为导出的实体名称
+func Tables(store dbrepo.Repository) map[string]Exporter {
+	return map[string]Exporter{
+		"books":  bookTable(store.BookRepo),
+		"users":  userTable(store.UserRepo),
+		"orders": orderTable(store.OrderRepo),
+	}
+}
+
+func bookTable(repo dbrepo.BookRepo) Table[*models.Book] {
+	return Table[*models.Book]{
+		List: repo.ListWithCategory,
+		Columns: []Column[*models.Book]{
+			{"id", func(b *models.Book) any { return b.ID }},
+			{"isbn", func(b *models.Book) any { return b.ISBN }},
+			{"title", func(b *models.Book) any { return b.Title }},
+			{"subtitle", func(b *models.Book) any { return b.Subtitle }},
+			{"author", func(b *models.Book) any {
+				if b.Author == nil {
+					return ""
+				}
+				return b.Author.AuthorName
+			}},
+			{"publisher", func(b *models.Book) any {
+				if b.Publisher == nil {
+					return ""
+				}
+				return b.Publisher.PublisherName
+			}},
+			{"categories", func(b *models.Book) any {
+				names := make([]string, 0, len(b.Categories))
+				for _, c := range b.Categories {
+					names = append(names, c.CategoryName)
+				}
+				return strings.Join(names, "|")
+			}},
+			{"cover_url", func(b *models.Book) any { return b.CoverUrl }},
+			{"pubdate", func(b *models.Book) any { return b.Pubdate.Format("2006-01-02") }},
+			{"price", func(b *models.Book) any { return b.Price }},
+			{"status", func(b *models.Book) any { return b.Status }},
+			{"type", func(b *models.Book) any { return b.Type }},
+			{"stock", func(b *models.Book) any { return b.Stock }},
+			{"created_at", func(b *models.Book) any { return b.CreatedAt }},
+			{"updated_at", func(b *models.Book) any { return b.UpdatedAt }},
+		},
+	}
+}
+
+func userTable(repo dbrepo.UserRepo) Table[*models.User] {
+	return Table[*models.User]{
+		List: repo.List,
+		Columns: []Column[*models.User]{
+			{"id", func(u *models.User) any { return u.ID }},
+			{"email", func(u *models.User) any { return u.Email }},
+			{"nickname", func(u *models.User) any { return u.Nickname }},
+			{"role", func(u *models.User) any { return u.Role }},
+			{"login_at", func(u *models.User) any { return u.LoginAt }},
+			{"created_at", func(u *models.User) any { return u.CreatedAt }},
+		},
+	}
+}
+
+func orderTable(repo dbrepo.OrderRepo) Table[*models.Order] {
+	return Table[*models.Order]{
+		List: repo.List,
+		Columns: []Column[*models.Order]{
+			{"id", func(o *models.Order) any { return o.ID }},
+			{"order_no", func(o *models.Order) any { return o.OrderNo }},
+			{"user_id", func(o *models.Order) any { return o.UserID }},
+			{"order_status", func(o *models.Order) any { return o.OrderStatus }},
+			{"order_amount", func(o *models.Order) any { return o.OrderAmount }},
+			{"paid_at", func(o *models.Order) any { return o.PaidAt }},
+			{"created_at", func(o *models.Order) any { return o.CreatedAt }},
+		},
+	}
+}
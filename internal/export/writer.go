@@ -0,0 +1,195 @@
+package export
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 支持的导出格式
+const (
+	FormatCSV   = "csv"
+	FormatXLSX  = "xlsx"
+	FormatJSONL = "jsonl"
+)
+
+var ErrUnsupportedFormat = errors.New("仅支持csv、xlsx、jsonl格式")
+
+// 导出时间的格式
+const timeFormat = "2006-01-02 15:04:05"
+
+// Supported 是否支持该导出格式
+func Supported(format string) bool {
+	switch format {
+	case FormatCSV, FormatXLSX, FormatJSONL:
+		return true
+	}
+	return false
+}
+
+// ContentType 导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// Writer 按行写入导出文件
+type Writer interface {
+	// Header 写入表头，jsonl 没有表头
+	Header(columns []string) error
+	// Write 写入一行，csv、xlsx 写入 values，jsonl 写入 item 的json
+	Write(item any, values []any) error
+	// Close 写入剩余的数据，xlsx 在这时才输出到 io.Writer
+	Close() error
+}
+
+// NewWriter 根据格式创建 Writer
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		// 写入BOM，避免 Excel 打开中文乱码
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &xlsxWriter{out: w, file: f, sw: sw, row: 1}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Header(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Write(item any, values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = fmt.Sprint(cellValue(v))
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// 每行都刷新，数据不在内存中堆积
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// xlsxWriter 使用 excelize 的流式写入，超过内存阈值的行会写到临时文件
+type xlsxWriter struct {
+	out  io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func (x *xlsxWriter) Header(columns []string) error {
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+	return x.Write(nil, values)
+}
+
+func (x *xlsxWriter) Write(item any, values []any) error {
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = cellValue(v)
+	}
+
+	x.row++
+	return x.sw.SetRow(cell, row)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.sw.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.out)
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Header(columns []string) error {
+	return nil
+}
+
+func (j *jsonlWriter) Write(item any, values []any) error {
+	return j.enc.Encode(item)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// cellValue 转换为单元格的值：nil指针为空字符串，时间格式化，自定义类型取 driver.Valuer 的值
+func cellValue(v any) any {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return ""
+	}
+
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return ""
+		}
+		v = dv
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(timeFormat)
+	case *time.Time:
+		if x == nil || x.IsZero() {
+			return ""
+		}
+		return x.Format(timeFormat)
+	}
+
+	return v
+}
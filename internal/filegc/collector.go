@@ -0,0 +1,121 @@
+// Package filegc 回收没有引用的上传文件：统计 files 表中每个文件被图书、轮播图等引用的次数，
+// 删除没有引用超过保留期的文件，支持只列出待删除文件的 dry-run 模式
+package filegc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/dbrepo"
+	"github.com/lightsaid/ebook/internal/fileupload"
+	"github.com/lightsaid/ebook/internal/models"
+)
+
+// 默认保留期和每次最多删除的文件数
+const (
+	DefaultGrace = 7 * 24 * time.Hour
+	MaxPerRun    = 1000
+)
+
+var ErrUnknownKind = errors.New("未知的上传类型")
+
+// FileError 删除失败的文件
+type FileError struct {
+	ID    uint64 `json:"id"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// Report 回收报告
+type Report struct {
+	DryRun  bool           `json:"dryRun"`
+	Before  time.Time      `json:"before"`  // 在该时间之前就没有引用的文件会被删除
+	Files   []*models.File `json:"files"`   // 待删除(dry-run)或已删除的文件
+	Deleted int            `json:"deleted"` // 删除的文件数，dry-run 为0
+	Failed  []*FileError   `json:"failed,omitempty"`
+}
+
+// Collector 文件回收
+type Collector struct {
+	store     dbrepo.Repository
+	uploaders map[string]fileupload.FileUploader // 上传类型 => FileUploader，与 files.kind 对应
+	grace     time.Duration
+}
+
+// New 创建文件回收，grace 为没有引用后的保留期，不大于0时使用 DefaultGrace
+func New(store dbrepo.Repository, uploaders map[string]fileupload.FileUploader, grace time.Duration) *Collector {
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	return &Collector{store: store, uploaders: uploaders, grace: grace}
+}
+
+// Run 重新统计引用数，删除没有引用超过保留期的文件，一次最多处理 MaxPerRun 个；
+// dryRun 为 true 时只返回待删除的文件
+func (c *Collector) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	if err := c.store.FileRepo.Recount(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun, Before: time.Now().Add(-c.grace)}
+	files, err := c.store.FileRepo.Unreferenced(ctx, report.Before, MaxPerRun)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		report.Files = files
+		return report, nil
+	}
+
+	report.Files = make([]*models.File, 0, len(files))
+	for _, f := range files {
+		if err := c.remove(ctx, f); err != nil {
+			report.Failed = append(report.Failed, &FileError{ID: f.ID, URL: f.URL, Error: err.Error()})
+			continue
+		}
+		report.Files = append(report.Files, f)
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// remove 删除文件和记录，原图同时删除 srcset 记录；
+// 先删除文件，失败时保留记录下次重试
+func (c *Collector) remove(ctx context.Context, f *models.File) error {
+	uploader, ok := c.uploaders[f.Kind]
+	if !ok {
+		return ErrUnknownKind
+	}
+	if err := uploader.Delete(f.FileKey); err != nil {
+		return err
+	}
+	if f.ParentURL == "" {
+		if err := c.store.ImageVariantRepo.Delete(ctx, f.URL); err != nil {
+			return err
+		}
+	}
+	return c.store.FileRepo.Delete(ctx, f.ID)
+}
+
+// Schedule 每隔 interval 执行一次回收，ctx 取消时退出
+func (c *Collector) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := c.Run(ctx, false)
+		if err != nil {
+			slog.ErrorContext(ctx, "file gc", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "file gc", "deleted", report.Deleted, "failed", len(report.Failed))
+	}
+}
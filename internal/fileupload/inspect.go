@@ -0,0 +1,485 @@
+package fileupload
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// 图片限制，解码前先读取尺寸，避免解压炸弹占满内存
+const (
+	maxImageSide   = 10000
+	maxImagePixels = 40_000_000
+)
+
+// 压缩包(EPUB)限制
+const (
+	maxZipEntries  = 10000
+	maxZipUnpacked = 1 << 30 // 解压后总大小 1GB
+	maxZipRatio    = 200     // 大于1MB的文件，压缩比不能超过该值
+)
+
+// contentTypes 扩展名对应 http.DetectContentType 识别的类型，不在表中的扩展名不校验内容
+var contentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".epub": "application/zip",
+	".pdf":  "application/pdf",
+}
+
+// prepare 校验文件类型，复制 r 的内容到临时文件，复制时限制大小，header.Size 可以伪造不能作为依据；
+// 再根据实际内容校验类型：图片解码校验尺寸并去掉EXIF等元数据，EPUB 校验压缩包防止解压炸弹。
+// 返回以内容的 SHA-256 命名的文件名(相同的内容得到相同的文件名，用于去重)，
+// 和已定位到开头的临时文件，临时文件由调用方关闭和删除
+func (l limits) prepare(filename string, r io.Reader) (string, *os.File, int64, error) {
+	ext, err := l.check(filename, -1)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	f, err := os.CreateTemp("", "ebook-upload-*")
+	if err != nil {
+		return "", nil, 0, err
+	}
+	fail := func(err error) (string, *os.File, int64, error) {
+		f.Close()
+		os.Remove(f.Name())
+		return "", nil, 0, err
+	}
+
+	size, err := io.Copy(f, io.LimitReader(r, l.maxBytes+1))
+	if err != nil {
+		return fail(err)
+	}
+	if size > l.maxBytes {
+		return fail(ErrFileTooLarge)
+	}
+
+	head := make([]byte, 512)
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	if isSVG(head) {
+		return fail(ErrSVGNotAllowed)
+	}
+	if want, ok := contentTypes[strings.ToLower(ext)]; ok && http.DetectContentType(head) != want {
+		return fail(fmt.Errorf("%w: %s", ErrContentMismatch, ext))
+	}
+
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		clean, cleanSize, err := sanitizeImage(f, size)
+		if err != nil {
+			return fail(err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+		f, size = clean, cleanSize
+	case ".epub":
+		if err := checkZip(f, size); err != nil {
+			return fail(err)
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fail(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return hex.EncodeToString(h.Sum(nil)) + strings.ToLower(ext), f, size, nil
+}
+
+// isSVG 文件开头是否为SVG，SVG 可以包含脚本，即使扩展名是图片也拒绝
+func isSVG(head []byte) bool {
+	head = bytes.ToLower(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))))
+	if !bytes.HasPrefix(head, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
+// sanitizeImage 解码校验图片，去掉元数据后写入新的临时文件；
+// 除了需要按EXIF方向旋转的JPEG外，都是直接删除元数据块，不重新编码
+func sanitizeImage(f *os.File, size int64) (*os.File, int64, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, size))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrBadImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxImageSide || cfg.Height > maxImageSide ||
+		cfg.Width*cfg.Height > maxImagePixels {
+		return nil, 0, fmt.Errorf("%w: %dx%d", ErrImageDimension, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrBadImage, err)
+	}
+
+	var clean []byte
+	switch format {
+	case "jpeg":
+		var orientation int
+		clean, orientation, err = stripJPEG(data)
+		if err == nil && orientation > 1 && orientation <= 8 {
+			var buf bytes.Buffer
+			err = jpeg.Encode(&buf, orient(img, orientation), &jpeg.Options{Quality: 90})
+			clean = buf.Bytes()
+		}
+	case "png":
+		clean, err = stripPNG(data)
+	case "webp":
+		clean, err = stripWebP(data)
+	case "gif":
+		clean, err = stripGIF(data)
+	default:
+		clean = data
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrBadImage, err)
+	}
+
+	out, err := os.CreateTemp("", "ebook-upload-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := out.Write(clean); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, 0, err
+	}
+	return out, int64(len(clean)), nil
+}
+
+// stripJPEG 删除 APP1(EXIF、XMP)、APP13(IPTC) 和注释段，返回EXIF中的方向
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, fmt.Errorf("not jpeg")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 0
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, 0, fmt.Errorf("bad jpeg segment at %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // 填充字节
+			i++
+			continue
+		}
+		// SOS 之后是图像数据，原样保留
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return nil, 0, fmt.Errorf("bad jpeg segment length at %d", i)
+		}
+		seg := data[i : i+2+n]
+		switch marker {
+		case 0xE1:
+			if bytes.HasPrefix(seg[4:], []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(seg[10:])
+			}
+		case 0xED, 0xFE:
+		default:
+			out.Write(seg)
+		}
+		i += 2 + n
+	}
+}
+
+// exifOrientation 读取 TIFF 结构中 IFD0 的 Orientation(0x0112)
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(bo.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(bo.Uint16(tiff[ifd:]))
+	for k := 0; k < count; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 0
+		}
+		if bo.Uint16(tiff[e:]) == 0x0112 {
+			return int(bo.Uint16(tiff[e+8:]))
+		}
+	}
+	return 0
+}
+
+// orient 按EXIF方向变换图片，使之不依赖元数据也能正确显示；
+// 先转换为 RGBA，再按像素直接复制 Pix，避免逐像素调用 At、Set
+func orient(src image.Image, o int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+w*4]
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch o {
+			case 2: // 水平翻转
+				dx = w - 1 - x
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dy = h - 1 - y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			}
+			i := dy*dst.Stride + dx*4
+			copy(dst.Pix[i:i+4], row[x*4:x*4+4])
+		}
+	}
+	return dst
+}
+
+// stripPNG 删除 eXIf 和文本、时间块
+func stripPNG(data []byte) ([]byte, error) {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(sig)) {
+		return nil, fmt.Errorf("not png")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(sig)
+	for i := len(sig); i < len(data); {
+		if i+12 > len(data) {
+			return nil, fmt.Errorf("bad png chunk at %d", i)
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + n
+		if end > len(data) {
+			return nil, fmt.Errorf("bad png chunk length at %d", i)
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// stripWebP 删除 EXIF、XMP 块，并清除 VP8X 中对应的标志位
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not webp")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("bad webp chunk at %d", i)
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n
+		if end > len(data) {
+			return nil, fmt.Errorf("bad webp chunk length at %d", i)
+		}
+		// 奇数长度的块有一个填充字节，最后一个块可能省略
+		if n%2 == 1 && end < len(data) {
+			end++
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[i:end])
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	clean := out.Bytes()
+	binary.LittleEndian.PutUint32(clean[4:], uint32(len(clean)-8))
+	return clean, nil
+}
+
+// stripGIF 删除注释扩展和除循环播放(NETSCAPE2.0、ANIMEXTS1.0)外的应用扩展，如XMP；
+// 图形控制扩展、纯文本扩展和图像数据原样保留
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, fmt.Errorf("not gif")
+	}
+
+	// 文件头、逻辑屏幕描述符和全局颜色表
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, fmt.Errorf("bad gif color table")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:i])
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x3B: // 结束
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x2C: // 图像描述符、局部颜色表、LZW最小码长和图像数据
+			if i+11 > len(data) {
+				return nil, fmt.Errorf("bad gif image at %d", i)
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			end, err := gifSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			i = end
+		case 0x21: // 扩展
+			if i+2 > len(data) {
+				return nil, fmt.Errorf("bad gif extension at %d", i)
+			}
+			label := data[i+1]
+			end, err := gifSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			keep := label != 0xFE
+			if label == 0xFF {
+				// 第一个子块是11字节的应用标识
+				app := data[i+3 : min(i+14, end)]
+				keep = bytes.Equal(app, []byte("NETSCAPE2.0")) || bytes.Equal(app, []byte("ANIMEXTS1.0"))
+			}
+			if keep {
+				out.Write(data[start:end])
+			}
+			i = end
+		default:
+			return nil, fmt.Errorf("bad gif block at %d", i)
+		}
+	}
+	// 缺少结束符的文件也能解码，补上
+	out.WriteByte(0x3B)
+	return out.Bytes(), nil
+}
+
+// gifSubBlocks 跳过从 i 开始的子块，返回结束块之后的位置
+func gifSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, fmt.Errorf("bad gif sub-block at %d", i)
+		}
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i, nil
+		}
+		i += n
+	}
+}
+
+// checkZip 校验压缩包：文件数量、声明的解压大小和压缩比；
+// 声明的大小可以伪造，再实际解压一遍统计总大小，archive/zip 读取超过声明大小时会返回错误
+func checkZip(f *os.File, size int64) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+	if len(zr.File) > maxZipEntries {
+		return fmt.Errorf("%w: 文件数量 %d", ErrBadArchive, len(zr.File))
+	}
+
+	var declared uint64
+	for _, zf := range zr.File {
+		declared += zf.UncompressedSize64
+		if declared > maxZipUnpacked {
+			return fmt.Errorf("%w: 解压后太大", ErrBadArchive)
+		}
+		if zf.UncompressedSize64 > 1<<20 && zf.UncompressedSize64 > zf.CompressedSize64*maxZipRatio {
+			return fmt.Errorf("%w: %s 压缩比异常", ErrBadArchive, zf.Name)
+		}
+	}
+
+	var unpacked int64
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+		n, err := io.Copy(io.Discard, io.LimitReader(rc, maxZipUnpacked-unpacked+1))
+		rc.Close()
+		unpacked += n
+		if unpacked > maxZipUnpacked {
+			return fmt.Errorf("%w: 解压后太大", ErrBadArchive)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s %v", ErrBadArchive, zf.Name, err)
+		}
+	}
+	return nil
+}
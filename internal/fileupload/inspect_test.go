@@ -0,0 +1,239 @@
+package fileupload
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = newLimits([]string{".jpg", ".png", ".gif", ".webp", ".epub", ".pdf", ".svg"}, 4<<20)
+
+// prepareBytes 调用 prepare 并读取处理后的内容
+func prepareBytes(t *testing.T, l limits, filename string, data []byte) ([]byte, error) {
+	t.Helper()
+	_, f, _, err := l.prepare(filename, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	out, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return out, nil
+}
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 40), uint8(y * 40), 100, 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// withJPEGSegments 在 SOI 之后插入 EXIF(方向为 orientation) 和注释段
+func withJPEGSegments(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = append(tiff, 0, 3, 0, 0, 0, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+
+	var out []byte
+	out = append(out, data[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(exif)+2))
+	out = append(out, exif...)
+	comment := []byte("secret comment")
+	out = append(out, 0xFF, 0xFE)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(comment)+2))
+	out = append(out, comment...)
+	return append(out, data[2:]...)
+}
+
+// withPNGText 在 IHDR 之后插入 tEXt 块
+func withPNGText(data []byte, text string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	body := append([]byte("tEXt"), text...)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+
+	ihdrEnd := 8 + 12 + 13
+	return append(append(bytes.Clone(data[:ihdrEnd]), chunk...), data[ihdrEnd:]...)
+}
+
+// gifExtension 应用扩展或注释扩展
+func gifExtension(label byte, blocks ...string) []byte {
+	out := []byte{0x21, label}
+	for _, b := range blocks {
+		out = append(out, byte(len(b)))
+		out = append(out, b...)
+	}
+	return append(out, 0)
+}
+
+func buildZip(t *testing.T, files map[string][]byte, method uint16) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestPrepareRejects(t *testing.T) {
+	jpg := encodeJPEG(t, testImage(4, 3))
+	manyEntries := make(map[string][]byte, maxZipEntries+1)
+	for i := 0; i <= maxZipEntries; i++ {
+		manyEntries[fmt.Sprintf("f%d", i)] = nil
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		limits   limits
+		wantErr  error
+	}{
+		{"svg扩展名", "a.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), testLimits, ErrSVGNotAllowed},
+		{"svg内容伪装成png", "a.png", []byte(`<?xml version="1.0"?><svg><script>alert(1)</script></svg>`), testLimits, ErrSVGNotAllowed},
+		{"jpeg伪装成png", "a.png", jpg, testLimits, ErrContentMismatch},
+		{"文本伪装成pdf", "a.pdf", []byte("hello world"), testLimits, ErrContentMismatch},
+		{"html伪装成epub", "a.epub", []byte("<html><body>hi</body></html>"), testLimits, ErrContentMismatch},
+		{"不允许的扩展名", "a.exe", []byte("MZ"), testLimits, ErrrNotAllowExt},
+		{"超过大小", "a.jpg", jpg, newLimits([]string{".jpg"}, int64(len(jpg)-1)), ErrFileTooLarge},
+		{"损坏的图片", "a.jpg", jpg[:20], testLimits, ErrBadImage},
+		{"压缩比异常", "a.epub", buildZip(t, map[string][]byte{"big": make([]byte, 4<<20)}, zip.Deflate), newLimits([]string{".epub"}, 8<<20), ErrBadArchive},
+		{"文件数量过多", "a.epub", buildZip(t, manyEntries, zip.Store), newLimits([]string{".epub"}, 8<<20), ErrBadArchive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := prepareBytes(t, tt.limits, tt.filename, tt.data)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPrepareEPUB(t *testing.T) {
+	data := buildZip(t, map[string][]byte{
+		"mimetype":             []byte("application/epub+zip"),
+		"OEBPS/chapter1.xhtml": bytes.Repeat([]byte("<p>text</p>"), 1000),
+	}, zip.Deflate)
+	out, err := prepareBytes(t, testLimits, "book.epub", data)
+	require.NoError(t, err)
+	require.Equal(t, data, out)
+}
+
+func TestPrepareStripsJPEG(t *testing.T) {
+	// 方向为1只删除元数据，不重新编码
+	plain := encodeJPEG(t, testImage(4, 3))
+	out, err := prepareBytes(t, testLimits, "a.jpg", withJPEGSegments(plain, 1))
+	require.NoError(t, err)
+	require.Equal(t, plain, out)
+
+	// 方向为6(顺时针旋转90度)时旋转后重新编码，宽高互换
+	out, err = prepareBytes(t, testLimits, "a.jpg", withJPEGSegments(plain, 6))
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "Exif")
+	require.NotContains(t, string(out), "secret comment")
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Equal(t, 3, cfg.Width)
+	require.Equal(t, 4, cfg.Height)
+}
+
+func TestPrepareStripsPNG(t *testing.T) {
+	plain := encodePNG(t, testImage(4, 3))
+	out, err := prepareBytes(t, testLimits, "a.png", withPNGText(plain, "Author\x00someone"))
+	require.NoError(t, err)
+	require.Equal(t, plain, out)
+}
+
+func TestPrepareStripsGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 3), palette)
+	frame.SetColorIndex(1, 1, 1)
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, &gif.GIF{
+		Image: []*image.Paletted{frame, frame},
+		Delay: []int{10, 10},
+	}))
+	plain := buf.Bytes()
+	require.Contains(t, string(plain), "NETSCAPE2.0")
+
+	// 在结束符之前插入注释扩展和XMP应用扩展
+	dirty := bytes.Clone(plain[:len(plain)-1])
+	dirty = append(dirty, gifExtension(0xFE, "secret comment")...)
+	dirty = append(dirty, gifExtension(0xFF, "XMP DataXMP", "<x:xmpmeta>secret</x:xmpmeta>")...)
+	dirty = append(dirty, 0x3B)
+
+	out, err := prepareBytes(t, testLimits, "a.gif", dirty)
+	require.NoError(t, err)
+	require.Equal(t, plain, out)
+
+	g, err := gif.DecodeAll(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.Len(t, g.Image, 2)
+}
+
+func TestOrient(t *testing.T) {
+	const w, h = 3, 2
+	src := testImage(w, h)
+	at := func(img image.Image, x, y int) color.Color { return img.At(x, y) }
+
+	// 原图 (0,0) 和 (w-1,0) 在各方向变换后的位置
+	tests := []struct {
+		o        int
+		dw, dh   int
+		p00, pW0 image.Point
+	}{
+		{2, w, h, image.Pt(w-1, 0), image.Pt(0, 0)},
+		{3, w, h, image.Pt(w-1, h-1), image.Pt(0, h-1)},
+		{4, w, h, image.Pt(0, h-1), image.Pt(w-1, h-1)},
+		{5, h, w, image.Pt(0, 0), image.Pt(0, w-1)},
+		{6, h, w, image.Pt(h-1, 0), image.Pt(h-1, w-1)},
+		{7, h, w, image.Pt(h-1, w-1), image.Pt(h-1, 0)},
+		{8, h, w, image.Pt(0, w-1), image.Pt(0, 0)},
+	}
+	for _, tt := range tests {
+		dst := orient(src, tt.o)
+		require.Equal(t, image.Rect(0, 0, tt.dw, tt.dh), dst.Bounds(), tt.o)
+		require.Equal(t, at(src, 0, 0), at(dst, tt.p00.X, tt.p00.Y), tt.o)
+		require.Equal(t, at(src, w-1, 0), at(dst, tt.pW0.X, tt.pW0.Y), tt.o)
+	}
+
+	// 起点不为0的图片
+	sub := testImage(5, 4).SubImage(image.Rect(1, 1, 4, 3))
+	dst := orient(sub, 3)
+	require.Equal(t, sub.At(1, 1), dst.At(2, 1))
+}
@@ -1,60 +1,42 @@
 package fileupload
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
-
-	"github.com/lightsaid/ebook/pkg/random"
 )
 
 // LocalUploader 本地文件上传实现结构体
 type LocalUploader struct {
-	uploadDir string   // 保存文件地址
-	allowExts []string // 允许文件类型
-	maxBytes  int64    // 文件大小限制
+	limits
+	uploadDir string // 保存文件地址
+	baseURL   string // 访问地址前缀，为空时不公开访问
 }
 
 // 类型检查
 var _ FileUploader = (*LocalUploader)(nil)
 
-// NewLocalUplader 创建一个本地上传图片实例, dir 文件存储地址, allowExts 允许文件格式，max 文件最大限制
-func NewLocalUplader(dir string, allowExts []string, max int64) FileUploader {
+// NewLocalUplader 创建一个本地上传图片实例, dir 文件存储地址, baseURL 文件的访问地址前缀(如 https://cdn.example.com/covers)，
+// 为空时不公开访问(如电子书)，只能通过 Open 读取, allowExts 允许文件格式，max 文件最大限制
+func NewLocalUplader(dir, baseURL string, allowExts []string, max int64) FileUploader {
 	if !strings.HasSuffix(dir, "/") {
 		dir += "/"
 	}
-	if max <= 0 {
-		max = 2 << 20 // 1MB
-	}
 	return &LocalUploader{
+		limits:    newLimits(allowExts, max),
 		uploadDir: dir,
-		allowExts: allowExts,
-		maxBytes:  max,
-	}
-}
-
-// IsAllowExt 允许文件类型 fileExt => .xxx
-func (l *LocalUploader) IsAllowExt(fileExt string) bool {
-	for _, ext := range l.allowExts {
-		if ext == fileExt {
-			return true
-		}
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
 	}
-	return false
 }
 
 // SaveFile 本地上传，实现接口
 func (l *LocalUploader) SaveFile(file multipart.File, header *multipart.FileHeader) (string, error) {
-	ext := path.Ext(header.Filename)
-	if allow := l.IsAllowExt(ext); !allow {
-		return "", fmt.Errorf("%w:%s; allow ext: %v", ErrrNotAllowExt, ext, l.allowExts)
-	}
-
-	if header.Size > l.maxBytes {
-		return "", ErrFileTooLarge
+	if _, err := l.check(header.Filename, header.Size); err != nil {
+		return "", err
 	}
 	src, err := header.Open()
 	if err != nil {
@@ -62,21 +44,105 @@ func (l *LocalUploader) SaveFile(file multipart.File, header *multipart.FileHead
 	}
 	defer src.Close()
 
+	return l.Save(header.Filename, src)
+}
+
+// Save 保存 r 的内容，实现接口，key 为保存的文件名，内容相同的文件已存在时不重复保存
+func (l *LocalUploader) Save(filename string, r io.Reader) (string, error) {
+	key, tmp, _, err := l.prepare(filename, r)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
 	// 构建本地存储路径
-	dstPath := l.uploadDir + random.RandomString(10) + ext
+	dstPath := l.uploadDir + key
+	if _, err := os.Stat(dstPath); err == nil {
+		return key, nil
+	}
 
-	// 创建目标文件
-	newFile, err := os.Create(dstPath)
+	// 先写入同目录的临时文件再重命名，同时上传相同的内容也不会读到不完整的文件
+	newFile, err := os.CreateTemp(l.uploadDir, ".upload-*")
 	if err != nil {
 		return "", err
 	}
+	defer os.Remove(newFile.Name())
 	defer newFile.Close()
 
-	// 复制文件内容
-	_, err = io.Copy(newFile, src)
-	if err != nil {
+	if _, err := io.Copy(newFile, tmp); err != nil {
 		return "", err
 	}
+	if err := newFile.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(newFile.Name(), dstPath); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Put 实现接口，key 可以包含子目录，先写入临时文件再重命名
+func (l *LocalUploader) Put(key string, r io.Reader) error {
+	if !validKey(key) {
+		return fmt.Errorf("无效的文件key: %q", key)
+	}
+	dstPath := l.uploadDir + key
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dstPath)
+}
+
+// Open 实现接口
+func (l *LocalUploader) Open(key string) (File, error) {
+	if !validKey(key) {
+		return nil, ErrFileNotFound
+	}
+	f, err := os.Open(l.uploadDir + key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 
-	return dstPath, nil
+// Delete 实现接口
+func (l *LocalUploader) Delete(key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	err := os.Remove(l.uploadDir + key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// URL 实现接口，没有设置 baseURL 时返回 ErrNotPublic，不暴露本地路径
+func (l *LocalUploader) URL(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrFileNotFound
+	}
+	if l.baseURL == "" {
+		return "", ErrNotPublic
+	}
+	return l.baseURL + "/" + key, nil
 }
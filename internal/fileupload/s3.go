@@ -0,0 +1,432 @@
+package fileupload
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/config"
+)
+
+// S3Uploader 兼容S3的对象存储实现，上传、下载、删除都使用预签名的地址完成，
+// 不依赖SDK，可以对接 AWS S3、MinIO 以及测试用的本地模拟服务
+type S3Uploader struct {
+	limits
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	publicURL string
+	expires   time.Duration
+	prefix    string // key 的前缀目录，如 covers
+	client    *http.Client
+}
+
+// 类型检查
+var _ FileUploader = (*S3Uploader)(nil)
+
+// NewS3Uploader 创建对象存储上传实例，prefix 为 key 的前缀目录，allowExts 允许文件格式，max 文件最大限制
+func NewS3Uploader(conf config.StorageConfig, prefix string, allowExts []string, max int64) (FileUploader, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(conf.S3Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("S3_ENDPOINT 无效: %q", conf.S3Endpoint)
+	}
+	if conf.S3Bucket == "" || conf.S3AccessKey == "" || conf.S3SecretKey == "" {
+		return nil, errors.New("S3_BUCKET、S3_ACCESS_KEY、S3_SECRET_KEY 不能为空")
+	}
+
+	s := &S3Uploader{
+		limits:    newLimits(allowExts, max),
+		endpoint:  endpoint,
+		region:    conf.S3Region,
+		bucket:    conf.S3Bucket,
+		accessKey: conf.S3AccessKey,
+		secretKey: conf.S3SecretKey,
+		pathStyle: conf.S3PathStyle,
+		publicURL: strings.TrimSuffix(conf.S3PublicURL, "/"),
+		expires:   conf.S3PresignExpires,
+		prefix:    strings.Trim(prefix, "/"),
+		client:    newS3Client(conf.S3Timeout),
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	// 预签名地址最长有效期7天
+	if s.expires <= 0 || s.expires > 7*24*time.Hour {
+		s.expires = time.Hour
+	}
+	return s, nil
+}
+
+// newS3Client 对象存储使用的 http 客户端，连接、TLS握手和等待响应头都有超时，避免服务无响应时一直阻塞；
+// 上传、下载大文件时传输内容的时间不确定，不设置整个请求的超时
+func newS3Client(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
+
+// SaveFile 实现接口
+func (s *S3Uploader) SaveFile(file multipart.File, header *multipart.FileHeader) (string, error) {
+	if _, err := s.check(header.Filename, header.Size); err != nil {
+		return "", err
+	}
+	src, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	return s.Save(header.Filename, src)
+}
+
+// Save 实现接口，PUT 请求需要 Content-Length，prepare 先写入临时文件确定大小；
+// 内容相同的对象已存在时不重复上传
+func (s *S3Uploader) Save(filename string, r io.Reader) (string, error) {
+	name, tmp, n, err := s.prepare(filename, r)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	if exists, err := s.exists(key); err != nil || exists {
+		return key, err
+	}
+	if err := s.put(key, tmp, n); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Put 实现接口，先写入临时文件确定大小再上传
+func (s *S3Uploader) Put(key string, r io.Reader) error {
+	if !validKey(key) {
+		return fmt.Errorf("无效的文件key: %q", key)
+	}
+	tmp, err := os.CreateTemp("", "ebook-put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.put(key, tmp, n)
+}
+
+// put 上传 n 字节的 r 到 key
+func (s *S3Uploader) put(key string, r io.Reader, n int64) error {
+	req, err := http.NewRequest(http.MethodPut, s.Presign(http.MethodPut, key, s.expires), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = n
+	if ctype := mime.TypeByExtension(path.Ext(key)); ctype != "" {
+		req.Header.Set("Content-Type", ctype)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// exists 对象是否存在
+func (s *S3Uploader) exists(key string) (bool, error) {
+	_, err := s.stat(key)
+	if errors.Is(err, ErrFileNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// stat 通过 HEAD 请求获取对象的大小和修改时间
+func (s *S3Uploader) stat(key string) (*objectInfo, error) {
+	req, err := http.NewRequest(http.MethodHead, s.Presign(http.MethodHead, key, s.expires), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &objectInfo{name: path.Base(key), size: resp.ContentLength}
+	info.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// Open 实现接口，返回的文件按需从当前位置发送 Range 请求读取
+func (s *S3Uploader) Open(key string) (File, error) {
+	if !validKey(key) {
+		return nil, ErrFileNotFound
+	}
+	info, err := s.stat(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{s: s, key: key, info: info}, nil
+}
+
+// Delete 实现接口
+func (s *S3Uploader) Delete(key string) error {
+	if !validKey(key) {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, s.Presign(http.MethodDelete, key, s.expires), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if errors.Is(err, ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// URL 实现接口，使用公开访问地址拼接，没有设置时文件不公开访问；
+// 预签名的下载地址会过期，不能作为访问地址保存
+func (s *S3Uploader) URL(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrFileNotFound
+	}
+	if s.publicURL == "" {
+		return "", ErrNotPublic
+	}
+	return s.publicURL + "/" + key, nil
+}
+
+// do 发送请求，非2xx响应返回错误
+func (s *S3Uploader) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	// 错误响应是XML，截取一部分用于排查
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("对象存储 %s %s: %s %s", req.Method, s.objectPath(req.URL.Path), resp.Status, body)
+}
+
+// objectPath 去掉路径风格地址中的存储桶，用于错误信息
+func (s *S3Uploader) objectPath(p string) string {
+	if s.pathStyle {
+		return strings.TrimPrefix(p, "/"+s.bucket)
+	}
+	return p
+}
+
+// Presign 生成预签名地址(AWS Signature Version 4，签名参数放在查询字符串中)，
+// 有效期内持有地址即可执行 method 操作，不需要密钥，可交给浏览器直接上传或下载
+func (s *S3Uploader) Presign(method, key string, expires time.Duration) string {
+	return s.presign(method, key, expires, time.Now().UTC())
+}
+
+func (s *S3Uploader) presign(method, key string, expires time.Duration, now time.Time) string {
+	host := s.endpoint.Host
+	uri := s.endpoint.Path + "/" + s3Escape(key, false)
+	if s.pathStyle {
+		uri = s.endpoint.Path + "/" + s3Escape(s.bucket, true) + "/" + s3Escape(key, false)
+	} else {
+		host = s.bucket + "." + host
+	}
+
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(expires / time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = s3Escape(k, true) + "=" + s3Escape(query[k], true)
+	}
+	canonicalQuery := strings.Join(pairs, "&")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		uri,
+		canonicalQuery,
+		"host:" + host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return s.endpoint.Scheme + "://" + host + uri + "?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按签名规则编码，只保留 A-Z a-z 0-9 - _ . ~，encodeSlash 为 false 时保留路径中的 /
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Object 对象存储中的文件，第一次读取或 Seek 到新位置后读取时，从当前位置发送 Range 请求；
+// http.ServeContent 分段下载时只会请求需要的部分
+type s3Object struct {
+	s      *S3Uploader
+	key    string
+	info   *objectInfo
+	offset int64
+	body   io.ReadCloser // 从 offset 开始的响应体，Seek 后关闭
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.info.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		req, err := http.NewRequest(http.MethodGet, o.s.Presign(http.MethodGet, o.key, o.s.expires), nil)
+		if err != nil {
+			return 0, err
+		}
+		if o.offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+		}
+		resp, err := o.s.do(req)
+		if err != nil {
+			return 0, err
+		}
+		if o.offset > 0 && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return 0, fmt.Errorf("对象存储不支持 Range 请求: %s", resp.Status)
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.info.size
+	default:
+		return 0, errors.New("s3Object.Seek: 无效的 whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("s3Object.Seek: 偏移量不能小于0")
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+func (o *s3Object) Stat() (fs.FileInfo, error) {
+	return o.info, nil
+}
+
+// objectInfo 对象的信息，实现 fs.FileInfo
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i *objectInfo) Name() string       { return i.name }
+func (i *objectInfo) Size() int64        { return i.size }
+func (i *objectInfo) Mode() fs.FileMode  { return 0o444 }
+func (i *objectInfo) ModTime() time.Time { return i.modTime }
+func (i *objectInfo) IsDir() bool        { return false }
+func (i *objectInfo) Sys() any           { return nil }
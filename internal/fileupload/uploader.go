@@ -2,22 +2,127 @@ package fileupload
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/config"
 )
 
 var (
 	ErrrNotAllowExt = errors.New("不支持文件类型")
 	ErrFileTooLarge = errors.New("文件太大")
+	ErrFileNotFound = errors.New("文件不存在")
+	ErrNotPublic    = errors.New("文件不公开访问")
+
+	ErrSVGNotAllowed   = errors.New("不支持SVG图片")
+	ErrContentMismatch = errors.New("文件内容与类型不符")
+	ErrBadImage        = errors.New("图片无法解析")
+	ErrImageDimension  = errors.New("图片尺寸过大")
+	ErrBadArchive      = errors.New("压缩包损坏或异常")
 )
 
-// FileUploader 保存文件接口
+// File Open 返回的文件，支持 Seek，可以直接用于 http.ServeContent
+type File interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// FileUploader 保存文件接口，文件以 key 标识，调用方通过 key 读取、删除文件和获取访问地址，不直接处理文件路径
 type FileUploader interface {
+	// SaveFile 保存表单上传的文件，返回文件的 key
 	SaveFile(multipart.File, *multipart.FileHeader) (string, error)
+	// Save 保存 r 的内容，filename 用于校验文件类型和确定扩展名，用于保存非表单上传的文件，返回文件的 key
+	Save(filename string, r io.Reader) (string, error)
+	// Put 把 r 的内容保存为 key，已存在时覆盖，写入完成前不能被 Open 读到；
+	// 不校验文件类型和大小，用于保存服务生成的文件，如水印文件、试读文件
+	Put(key string, r io.Reader) error
+	// Open 读取文件，文件不存在返回 ErrFileNotFound
+	Open(key string) (File, error)
+	// Delete 删除文件，文件不存在不返回错误
+	Delete(key string) error
+	// URL 文件的公开访问地址，不公开访问的文件返回 ErrNotPublic
+	URL(key string) (string, error)
+}
+
+// New 按 conf.Driver 创建 FileUploader：local 保存在 dir，访问地址为 baseURL/key；
+// s3 保存在存储桶的 prefix 目录下，访问地址为 S3_PUBLIC_URL/key。
+// baseURL 为空时文件不公开访问，两种存储的 URL 都返回 ErrNotPublic
+func New(conf config.StorageConfig, dir, prefix, baseURL string, allowExts []string, max int64) (FileUploader, error) {
+	switch conf.Driver {
+	case "", "local":
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return NewLocalUplader(dir, baseURL, allowExts, max), nil
+	case "s3":
+		if baseURL == "" {
+			conf.S3PublicURL = ""
+		} else if conf.S3PublicURL == "" {
+			// 预签名的下载地址会过期，不能保存到数据库
+			return nil, errors.New("STORAGE_DRIVER=s3 时需要配置 S3_PUBLIC_URL")
+		}
+		return NewS3Uploader(conf, prefix, allowExts, max)
+	}
+	return nil, fmt.Errorf("不支持的 STORAGE_DRIVER: %q", conf.Driver)
 }
 
+// IsUploaderError 是否为上传文件不符合要求的错误
 func IsUploaderError(err error) bool {
-	if errors.Is(err, ErrrNotAllowExt) || errors.Is(err, ErrFileTooLarge) {
-		return true
+	for _, target := range []error{
+		ErrrNotAllowExt, ErrFileTooLarge, ErrSVGNotAllowed, ErrContentMismatch,
+		ErrBadImage, ErrImageDimension, ErrBadArchive,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
 	}
 	return false
 }
+
+// limits 文件类型和大小限制，LocalUploader、S3Uploader 共用
+type limits struct {
+	allowExts []string // 允许文件类型
+	maxBytes  int64    // 文件大小限制
+}
+
+func newLimits(allowExts []string, max int64) limits {
+	if max <= 0 {
+		max = 2 << 20 // 1MB
+	}
+	return limits{allowExts: allowExts, maxBytes: max}
+}
+
+// IsAllowExt 允许文件类型 fileExt => .xxx
+func (l limits) IsAllowExt(fileExt string) bool {
+	for _, ext := range l.allowExts {
+		if ext == fileExt {
+			return true
+		}
+	}
+	return false
+}
+
+// check 校验文件类型和大小，size 未知时传 -1，返回扩展名
+func (l limits) check(filename string, size int64) (string, error) {
+	ext := path.Ext(filename)
+	if strings.EqualFold(ext, ".svg") {
+		return "", ErrSVGNotAllowed
+	}
+	if allow := l.IsAllowExt(ext); !allow {
+		return "", fmt.Errorf("%w:%s; allow ext: %v", ErrrNotAllowExt, ext, l.allowExts)
+	}
+	if size > l.maxBytes {
+		return "", ErrFileTooLarge
+	}
+	return ext, nil
+}
+
+// validKey key 由 Save 生成，只包含文件名和可选的前缀目录，不能跳出保存目录
+func validKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key && !strings.HasPrefix(key, "../") && key != ".."
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/lightsaid/ebook/internal/types"
@@ -8,13 +12,62 @@ import (
 )
 
 type Author struct {
-	ID         uint64       `db:"id" json:"id"`
-	AuthorName string       `db:"author_name" json:"authorName"`
-	CreatedAt  types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
-	UpdatedAt  types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
-	DeletedAt  *time.Time   `db:"deleted_at" json:"-"`
+	ID          uint64       `db:"id" json:"id"`
+	AuthorName  string       `db:"author_name" json:"authorName"`
+	Biography   string       `db:"biography" json:"biography"`     // 简介
+	Photo       string       `db:"photo" json:"photo"`             // 照片地址
+	Nationality string       `db:"nationality" json:"nationality"` // 国籍
+	Website     string       `db:"website" json:"website"`         // 个人网站
+	Aliases     Aliases      `db:"aliases" json:"aliases"`         // 别名、笔名
+	CreatedAt   types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt   types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+	DeletedAt   *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
 }
 
 func (p Author) Verifiy(v *gotk.Validator) {
 	v.Check(p.AuthorName != "", "authorName", "作者名称称必填")
+	v.Check(len([]rune(p.Nationality)) <= 64, "nationality", "国籍最多64个字符")
+	v.Check(IsWebsite(p.Website), "website", "请输入合法的网址")
+	v.Check(len(p.Aliases) <= 20, "aliases", "别名最多20个")
+	for _, alias := range p.Aliases {
+		v.Check(alias != "", "aliases", "别名不能为空")
+	}
+}
+
+// Aliases 别名列表，保存为JSON数组
+type Aliases []string
+
+// Value 实现 driver.Valuer，保存为JSON
+func (a Aliases) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner
+func (a *Aliases) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("Aliases: 不支持的类型 %T", src)
+	}
+	return json.Unmarshal(b, a)
+}
+
+// IsWebsite 检查是否是http(s)网址，空值视为合法
+func IsWebsite(s string) bool {
+	if s == "" {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
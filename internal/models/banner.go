@@ -9,16 +9,17 @@ import (
 )
 
 type Banner struct {
-	ID        uint64       `db:"id" json:"id"`
-	Slogan    string       `db:"slogan" json:"slogan"`
-	LinkType  int          `db:"link_type" json:"linkType"`
-	LinkUrl   string       `db:"link_url" json:"linkUrl"`
-	ImageUrl  string       `db:"image_url" json:"imageUrl"`
-	Enable    int          `db:"enable" json:"enable"`
-	Sort      int          `db:"sort" json:"sort"`
-	CreatedAt types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
-	UpdatedAt types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
-	DeletedAt *time.Time   `db:"deleted_at" json:"-"`
+	ID          uint64       `db:"id" json:"id"`
+	Slogan      string       `db:"slogan" json:"slogan"`
+	LinkType    int          `db:"link_type" json:"linkType"`
+	LinkUrl     string       `db:"link_url" json:"linkUrl"`
+	ImageUrl    string       `db:"image_url" json:"imageUrl"`
+	ImageSrcset Srcset       `db:"-" json:"imageSrcset,omitempty"` // 图片的各尺寸地址，上传时生成
+	Enable      int          `db:"enable" json:"enable"`
+	Sort        int          `db:"sort" json:"sort"`
+	CreatedAt   types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt   types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+	DeletedAt   *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
 }
 
 // Verifiy 实现validator.Verifiyer校验接口
@@ -21,6 +21,7 @@ type Book struct {
 	Subtitle    string       `db:"subtitle" json:"subtitle"`
 	AuthorID    uint64       `db:"author_id" json:"authorId"`
 	CoverUrl    string       `db:"cover_url" json:"coverUrl"`
+	CoverSrcset Srcset       `db:"-" json:"coverSrcset,omitempty"` // 封面的各尺寸地址，上传时生成
 	PublisherID uint64       `db:"publisher_id" json:"publisherId"`
 	Pubdate     types.GxTime `db:"pubdate" json:"pubdate"`
 	// 价格,单位分
@@ -30,16 +31,20 @@ type Book struct {
 	//1-电子书,2-实体,3-电子书+实体
 	Type        int          `db:"type" json:"type"`
 	Stock       uint         `db:"stock" json:"stock"`
-	SourceUrl   string       `db:"source_url" json:"sourceUrl"`
+	RatingAvg   float64      `db:"rating_avg" json:"ratingAvg"`           // 已通过评价的平均分，审核时更新
+	RatingCount uint         `db:"rating_count" json:"ratingCount"`       // 已通过评价的数量
+	SourceUrl   string       `db:"source_url" json:"sourceUrl,omitempty"` // 电子书文件地址，公开接口不返回
 	Description string       `db:"description" json:"description"`
-	Version     string       `db:"version" json:"version"`
+	Version     int          `db:"version" json:"version"` // 版本号，更新时校验并自增，实现乐观锁
 	CreatedAt   types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
 	UpdatedAt   types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
-	DeletedAt   *time.Time   `db:"deleted_at" json:"-"`
+	DeletedAt   *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
 
-	Author     *Author     `json:"author"`
-	Publisher  *Publisher  `json:"publisher"`
-	Categories []*Category `json:"categories"`
+	Author       *Author            `json:"author"` // 第一作者，兼容只有一个作者的旧接口
+	Publisher    *Publisher         `json:"publisher"`
+	Categories   []*Category        `json:"categories"`
+	Contributors []*BookContributor `json:"contributors"`            // 作者、译者、插画等，为空时以 AuthorID 作为唯一作者
+	Series       *BookSeries        `db:"-" json:"series,omitempty"` // 所属系列，仅详情返回
 }
 
 type SQLBoook struct {
@@ -66,7 +71,14 @@ func (b Book) Verifiy(v *gotk.Validator) {
 	v.Check(b.ISBN != "", "isbn", "isbn不能为空")
 	v.Check(IsISBN(b.ISBN), "isbn", "请输入合法的ISBN")
 	v.Check(b.CoverUrl != "", "coverUrl", "请上传封面图")
-	v.Check(b.AuthorID > 0, "authorId", "请选择作者")
+	v.Check(b.AuthorID > 0 || FirstAuthor(b.Contributors) > 0, "authorId", "请选择作者")
+	for _, c := range b.Contributors {
+		if c == nil {
+			v.AddError("contributors", "贡献者不能为空")
+			continue
+		}
+		c.Verifiy(v)
+	}
 	v.Check(b.PublisherID > 0, "publisherID", "请选择出版社")
 	v.Check(!b.Pubdate.IsZero(), "pubdate", "出版日期必填")
 	v.Check(gotk.OneOf(b.Status, 0, 1), "status", "状态: 0-下架,1-上架")
@@ -0,0 +1,45 @@
+package models
+
+import "github.com/lightsaid/gotk"
+
+// 图书贡献者角色
+const (
+	RoleAuthor      = "author"      // 作者
+	RoleTranslator  = "translator"  // 译者
+	RoleIllustrator = "illustrator" // 插画
+	RoleEditor      = "editor"      // 编者
+)
+
+// BookContributor 图书贡献者，一本书可以有多个作者、译者、插画等，按 Sort 排序
+type BookContributor struct {
+	BookID   uint64 `db:"book_id" json:"bookId"`
+	AuthorID uint64 `db:"author_id" json:"authorId"`
+	Role     string `db:"role" json:"role"`
+	Sort     int    `db:"sort" json:"sort"`
+
+	Author *Author `json:"author,omitempty"`
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (c BookContributor) Verifiy(v *gotk.Validator) {
+	v.Check(c.AuthorID > 0, "contributors.authorId", "请选择作者")
+	v.Check(
+		gotk.OneOf(c.Role, RoleAuthor, RoleTranslator, RoleIllustrator, RoleEditor),
+		"contributors.role",
+		"角色: author-作者,translator-译者,illustrator-插画,editor-编者",
+	)
+}
+
+// FirstAuthor 返回第一个作者角色的贡献者id，没有则返回0
+func FirstAuthor(list []*BookContributor) uint64 {
+	var first *BookContributor
+	for _, c := range list {
+		if c != nil && c.Role == RoleAuthor && (first == nil || c.Sort < first.Sort) {
+			first = c
+		}
+	}
+	if first == nil {
+		return 0
+	}
+	return first.AuthorID
+}
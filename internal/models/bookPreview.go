@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/lightsaid/gotk"
+)
+
+// 试读方式
+const (
+	PreviewChapters = "chapters" // 前N章
+	PreviewPercent  = "percent"  // 前N%
+)
+
+// BookPreview 图书试读配置，只有配置了的电子书提供试读
+type BookPreview struct {
+	BookID      uint64        `db:"book_id" json:"bookId"`
+	Mode        string        `db:"mode" json:"mode"`
+	Value       uint          `db:"value" json:"value"`
+	SourceUrl   string        `db:"source_url" json:"sourceUrl"`                          // 最近一次生成试读时的电子书文件地址
+	GeneratedAt *types.GxTime `db:"generated_at" json:"generatedAt" swaggertype:"string"` // 最近一次生成试读的时间
+	CreatedAt   types.GxTime  `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt   types.GxTime  `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (p BookPreview) Verifiy(v *gotk.Validator) {
+	v.Check(gotk.OneOf(p.Mode, PreviewChapters, PreviewPercent), "mode", "试读方式: chapters-前N章,percent-前N%")
+	if p.Mode == PreviewPercent {
+		v.Check(p.Value >= 1 && p.Value <= 50, "value", "试读百分比范围 1~50")
+	} else {
+		v.Check(p.Value >= 1 && p.Value <= 20, "value", "试读章数范围 1~20")
+	}
+}
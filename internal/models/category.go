@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -10,12 +11,16 @@ import (
 
 type Category struct {
 	ID           uint64       `db:"id" json:"id"`
+	ParentID     uint64       `db:"parent_id" json:"parentId"` // 父分类id，0为顶级分类
+	Path         string       `db:"path" json:"path"`          // 祖先分类的物化路径，如 /1/5/，由系统维护
 	CategoryName string       `db:"category_name" json:"categoryName"`
 	Icon         string       `db:"icon" json:"icon"`
-	Sort         int          `db:"sort" json:"sort"`
+	Sort         int          `db:"sort" json:"sort"` // 同级分类的排序，从0开始连续，通过移动接口调整
 	CreatedAt    types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
 	UpdatedAt    types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
-	DeletedAt    *time.Time   `db:"deleted_at" json:"-"`
+	DeletedAt    *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
+
+	Children []*Category `db:"-" json:"children,omitempty"`
 }
 
 // Verifiy 实现validator.Verifiyer校验接口
@@ -24,6 +29,41 @@ func (c Category) Verifiy(v *gotk.Validator) {
 	v.Check(len(c.CategoryName) > 0, "categoryName", "分类名称不能为空")
 }
 
+// DescendantPath 子孙分类的 path 前缀
+func (c Category) DescendantPath() string {
+	return fmt.Sprintf("%s%d/", c.Path, c.ID)
+}
+
+// Depth 分类的层级，顶级分类为1
+func (c Category) Depth() int {
+	return strings.Count(c.Path, "/")
+}
+
+// CategoryMove 移动分类或调整同级排序
+type CategoryMove struct {
+	ParentID uint64 `json:"parentId"` // 新的父分类id，0为顶级分类
+	Sort     int    `json:"sort"`     // 在同级分类中的位置，从0开始，小于0或超出时排在最后
+}
+
+// CategoryTree 把分类列表组装为树，list 需按 sort 排序；父分类不在列表中的作为顶级分类
+func CategoryTree(list []*Category) []*Category {
+	nodes := make(map[uint64]*Category, len(list))
+	for _, c := range list {
+		c.Children = nil
+		nodes[c.ID] = c
+	}
+
+	roots := make([]*Category, 0)
+	for _, c := range list {
+		if parent, ok := nodes[c.ParentID]; ok && c.ParentID != c.ID {
+			parent.Children = append(parent.Children, c)
+			continue
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
+
 // SQLBookCategory 方便查询映射, 不存库
 type SQLBookCategory struct {
 	Category     Category     `db:"category"`
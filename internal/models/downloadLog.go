@@ -0,0 +1,16 @@
+package models
+
+import "github.com/lightsaid/ebook/internal/types"
+
+// DownloadLog 电子书下载记录，每次请求(包括Range分段请求)记录一条
+type DownloadLog struct {
+	ID          uint64       `db:"id" json:"id"`
+	UserID      uint64       `db:"user_id" json:"userId"`
+	BookID      uint64       `db:"book_id" json:"bookId"`
+	IP          string       `db:"ip" json:"ip"`
+	UserAgent   string       `db:"user_agent" json:"userAgent"`
+	RangeHeader string       `db:"range_header" json:"rangeHeader"`
+	Status      int          `db:"status" json:"status"`
+	Bytes       int64        `db:"bytes" json:"bytes"`
+	CreatedAt   types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+}
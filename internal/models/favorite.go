@@ -6,5 +6,12 @@ type Favorite struct {
 	ID        uint64       `db:"id" json:"id"`
 	UserID    uint64       `db:"user_id" json:"userId"`
 	BookID    uint64       `db:"book_id" json:"bookId"`
-	UpdatedAt types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+	Price     uint         `db:"price" json:"price"`         // 收藏时的价格，单位分
+	Available bool         `db:"available" json:"available"` // 收藏时是否可购买
+	CreatedAt types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+
+	// 列表查询时返回
+	Book        *Book `db:"book" json:"book,omitempty"`
+	OnSale      bool  `db:"on_sale" json:"onSale"`            // 降价：可购买且价格低于收藏时
+	BackInStock bool  `db:"back_in_stock" json:"backInStock"` // 收藏时不可购买，现在可购买
 }
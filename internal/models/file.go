@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lightsaid/ebook/internal/types"
+)
+
+// File 上传的文件，回收任务统计引用数，没有引用超过保留期的文件会被删除
+type File struct {
+	ID             uint64       `db:"id" json:"id"`
+	Kind           string       `db:"kind" json:"kind"`
+	FileKey        string       `db:"file_key" json:"fileKey"`
+	URL            string       `db:"url" json:"url"`
+	ParentURL      string       `db:"parent_url" json:"parentUrl"`
+	RefCount       uint         `db:"ref_count" json:"refCount"`
+	UnreferencedAt *time.Time   `db:"unreferenced_at" json:"unreferencedAt" swaggertype:"string"`
+	CreatedAt      types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt      types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Srcset 图片各宽度的地址，key 为 "320w" 形式，可直接拼接为 img 的 srcset 属性
+type Srcset map[string]string
+
+// Value 实现 driver.Valuer，保存为JSON
+func (s Srcset) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner
+func (s *Srcset) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("Srcset: 不支持的类型 %T", src)
+	}
+	return json.Unmarshal(b, s)
+}
@@ -10,11 +10,18 @@ import (
 type Publisher struct {
 	ID            uint64       `db:"id" json:"id"`
 	PublisherName string       `db:"publisher_name" json:"publisherName"`
+	Logo          string       `db:"logo" json:"logo"`       // logo地址
+	Address       string       `db:"address" json:"address"` // 地址
+	Contact       string       `db:"contact" json:"contact"` // 联系方式，电话或邮箱
+	Website       string       `db:"website" json:"website"` // 官网
 	CreatedAt     types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
 	UpdatedAt     types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
-	DeletedAt     *time.Time   `db:"deleted_at" json:"-"`
+	DeletedAt     *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
 }
 
 func (p Publisher) Verifiy(v *gotk.Validator) {
 	v.Check(p.PublisherName != "", "publisherName", "出版社名称必填")
+	v.Check(len([]rune(p.Address)) <= 255, "address", "地址最多255个字符")
+	v.Check(len([]rune(p.Contact)) <= 100, "contact", "联系方式最多100个字符")
+	v.Check(IsWebsite(p.Website), "website", "请输入合法的网址")
 }
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lightsaid/gotk"
+)
+
+// 书签、高亮类型
+const (
+	AnnotationBookmark  = "bookmark"  // 书签
+	AnnotationHighlight = "highlight" // 高亮，可以有笔记
+)
+
+// MaxSyncItems 一次同步最多推送的进度和书签、高亮总数
+const MaxSyncItems = 500
+
+// maxClockSkew 允许客户端时间超前服务器的时长，避免时间错误的设备一直覆盖其他设备的修改
+const maxClockSkew = 5 * time.Minute
+
+// ReadingProgress 阅读进度，每个用户每本书一条，按客户端修改时间最后写入为准
+type ReadingProgress struct {
+	UserID     uint64  `db:"user_id" json:"-"`
+	BookID     uint64  `db:"book_id" json:"bookId"`
+	Position   string  `db:"position" json:"position"`           // EPUB CFI 或 PDF 页码
+	Percentage float64 `db:"percentage" json:"percentage"`       // 0-100
+	UpdatedAt  int64   `db:"client_updated_at" json:"updatedAt"` // 客户端修改时间，毫秒时间戳
+	Seq        uint64  `db:"seq" json:"seq"`                     // 同步序号，由服务器分配
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (p ReadingProgress) Verifiy(v *gotk.Validator) {
+	v.Check(p.BookID > 0, "progress.bookId", "请选择图书")
+	v.Check(strings.TrimSpace(p.Position) != "", "progress.position", "阅读位置不能为空")
+	v.Check(len(p.Position) <= 1024, "progress.position", "阅读位置最多1024个字符")
+	v.Check(p.Percentage >= 0 && p.Percentage <= 100, "progress.percentage", "阅读百分比为0-100")
+	checkClientTime(v, "progress.updatedAt", p.UpdatedAt)
+}
+
+// ReadingAnnotation 书签或高亮，ClientID 由客户端生成，删除时 Deleted 为true，记录保留用于同步
+type ReadingAnnotation struct {
+	ID        uint64 `db:"id" json:"-"`
+	UserID    uint64 `db:"user_id" json:"-"`
+	BookID    uint64 `db:"book_id" json:"bookId"`
+	ClientID  string `db:"client_id" json:"clientId"`
+	Kind      string `db:"kind" json:"kind"`
+	Position  string `db:"position" json:"position"` // EPUB CFI(高亮为范围) 或 PDF 页码
+	Content   string `db:"content" json:"content"`   // 高亮的文字
+	Note      string `db:"note" json:"note"`
+	Color     string `db:"color" json:"color"`
+	Deleted   bool   `db:"deleted" json:"deleted"`
+	UpdatedAt int64  `db:"client_updated_at" json:"updatedAt"` // 客户端修改时间，毫秒时间戳
+	Seq       uint64 `db:"seq" json:"seq"`                     // 同步序号，由服务器分配
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (a ReadingAnnotation) Verifiy(v *gotk.Validator) {
+	v.Check(a.BookID > 0, "annotations.bookId", "请选择图书")
+	v.Check(a.ClientID != "" && len(a.ClientID) <= 64, "annotations.clientId", "clientId 为1-64个字符")
+	v.Check(gotk.OneOf(a.Kind, AnnotationBookmark, AnnotationHighlight), "annotations.kind", "类型: bookmark-书签,highlight-高亮")
+	v.Check(strings.TrimSpace(a.Position) != "", "annotations.position", "位置不能为空")
+	v.Check(len(a.Position) <= 1024, "annotations.position", "位置最多1024个字符")
+	v.Check(len([]rune(a.Content)) <= 5000, "annotations.content", "高亮文字最多5000个字符")
+	v.Check(len([]rune(a.Note)) <= 5000, "annotations.note", "笔记最多5000个字符")
+	v.Check(len(a.Color) <= 16, "annotations.color", "颜色最多16个字符")
+	checkClientTime(v, "annotations.updatedAt", a.UpdatedAt)
+}
+
+// checkClientTime 校验客户端修改时间，不能超前服务器 maxClockSkew 以上
+func checkClientTime(v *gotk.Validator, field string, ms int64) {
+	v.Check(ms > 0, field, "请提供客户端修改时间(毫秒时间戳)")
+	v.Check(ms <= time.Now().Add(maxClockSkew).UnixMilli(), field, "客户端时间超前，请校准设备时间")
+}
+
+// ReadingSync 推送的阅读进度和书签、高亮
+type ReadingSync struct {
+	Progress    []*ReadingProgress   `json:"progress"`
+	Annotations []*ReadingAnnotation `json:"annotations"`
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (s ReadingSync) Verifiy(v *gotk.Validator) {
+	v.Check(len(s.Progress)+len(s.Annotations) <= MaxSyncItems, "sync", fmt.Sprintf("一次最多同步%d条数据", MaxSyncItems))
+	for _, p := range s.Progress {
+		if p == nil {
+			v.AddError("progress", "阅读进度不能为空")
+			continue
+		}
+		p.Verifiy(v)
+	}
+	for _, a := range s.Annotations {
+		if a == nil {
+			v.AddError("annotations", "书签、高亮不能为空")
+			continue
+		}
+		a.Verifiy(v)
+	}
+}
+
+// BookIDs 返回推送涉及的图书id，去重
+func (s ReadingSync) BookIDs() []uint64 {
+	seen := make(map[uint64]bool)
+	ids := make([]uint64, 0)
+	for _, p := range s.Progress {
+		if !seen[p.BookID] {
+			seen[p.BookID] = true
+			ids = append(ids, p.BookID)
+		}
+	}
+	for _, a := range s.Annotations {
+		if !seen[a.BookID] {
+			seen[a.BookID] = true
+			ids = append(ids, a.BookID)
+		}
+	}
+	return ids
+}
+
+// ReadingPushResult 推送结果，客户端修改时间不晚于服务器数据的被忽略
+type ReadingPushResult struct {
+	Applied int `json:"applied"`
+	Ignored int `json:"ignored"`
+}
+
+// ReadingChanges 同步序号 since 之后的变更，按序号排序；
+// 客户端保存 Next 作为下次的 since，HasMore 为true时继续拉取
+type ReadingChanges struct {
+	Progress    []*ReadingProgress   `json:"progress"`
+	Annotations []*ReadingAnnotation `json:"annotations"`
+	Next        string               `json:"next"`
+	HasMore     bool                 `json:"hasMore"`
+}
+
+// ReadingState 用户一本书的阅读状态
+type ReadingState struct {
+	Progress    *ReadingProgress     `json:"progress"` // 没有阅读记录为null
+	Annotations []*ReadingAnnotation `json:"annotations"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/lightsaid/gotk"
+)
+
+// 评价审核状态，只有已通过的评价公开并计入图书评分
+const (
+	ReviewPending  = 0 // 待审核
+	ReviewApproved = 1 // 已通过
+	ReviewRejected = 2 // 已驳回
+	ReviewHidden   = 3 // 已隐藏，通过后因举报等原因下线
+)
+
+// Review 图书评价，每个用户对每本书只有一条，修改后重新进入待审核
+type Review struct {
+	ID           uint64        `db:"id" json:"id"`
+	UserID       uint64        `db:"user_id" json:"userId"`
+	BookID       uint64        `db:"book_id" json:"bookId"`
+	Rating       uint8         `db:"rating" json:"rating"` // 1-5星
+	Content      string        `db:"content" json:"content"`
+	Status       int           `db:"status" json:"status"`
+	Reason       string        `db:"reason" json:"reason,omitempty"` // 驳回或隐藏的原因
+	HelpfulCount uint          `db:"helpful_count" json:"helpfulCount"`
+	ModeratedAt  *types.GxTime `db:"moderated_at" json:"moderatedAt,omitempty" swaggertype:"string"`
+	CreatedAt    types.GxTime  `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt    types.GxTime  `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+
+	User *ReviewUser `db:"user" json:"user,omitempty"`
+}
+
+// ReviewUser 评价展示的用户信息
+type ReviewUser struct {
+	Nickname string `db:"nickname" json:"nickname"`
+	Avatar   string `db:"avatar" json:"avatar"`
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (r Review) Verifiy(v *gotk.Validator) {
+	v.Check(r.Rating >= 1 && r.Rating <= 5, "rating", "评分为1-5星")
+	v.Check(strings.TrimSpace(r.Content) != "", "content", "评价内容不能为空")
+	v.Check(len([]rune(r.Content)) <= 2000, "content", "评价内容最多2000个字符")
+}
+
+// ReviewModeration 审核评价，驳回和隐藏必须填写原因
+type ReviewModeration struct {
+	Status int    `json:"status"` // 1-通过，2-驳回，3-隐藏
+	Reason string `json:"reason"`
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (m ReviewModeration) Verifiy(v *gotk.Validator) {
+	v.Check(gotk.OneOf(m.Status, ReviewApproved, ReviewRejected, ReviewHidden), "status", "状态: 1-通过,2-驳回,3-隐藏")
+	v.Check(m.Status == ReviewApproved || strings.TrimSpace(m.Reason) != "", "reason", "驳回或隐藏必须填写原因")
+	v.Check(len([]rune(m.Reason)) <= 255, "reason", "原因最多255个字符")
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lightsaid/ebook/internal/types"
+	"github.com/lightsaid/gotk"
+)
+
+// Series 图书系列，如多卷本的丛书
+type Series struct {
+	ID          uint64       `db:"id" json:"id"`
+	SeriesName  string       `db:"series_name" json:"seriesName"`
+	Description string       `db:"description" json:"description"`
+	CoverUrl    string       `db:"cover_url" json:"coverUrl"`
+	CreatedAt   types.GxTime `db:"created_at" json:"createdAt" swaggertype:"string"`
+	UpdatedAt   types.GxTime `db:"updated_at" json:"updatedAt" swaggertype:"string"`
+	DeletedAt   *time.Time   `db:"deleted_at" json:"deletedAt,omitempty" swaggertype:"string"`
+
+	Volumes []*SeriesVolume `db:"-" json:"volumes,omitempty"` // 按卷号排序的图书，仅详情返回
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (s Series) Verifiy(v *gotk.Validator) {
+	v.Check(strings.TrimSpace(s.SeriesName) != "", "seriesName", "系列名称不能为空")
+	v.Check(len([]rune(s.SeriesName)) <= 120, "seriesName", "系列名称最多120个字符")
+}
+
+// SeriesBook 系列中的一卷
+type SeriesBook struct {
+	SeriesID uint64 `db:"series_id" json:"seriesId"`
+	BookID   uint64 `db:"book_id" json:"bookId"`
+	Volume   uint   `db:"volume" json:"volume"` // 卷号，从1开始
+}
+
+// Verifiy 实现validator.Verifiyer校验接口
+func (s SeriesBook) Verifiy(v *gotk.Validator) {
+	v.Check(s.BookID > 0, "bookId", "请选择图书")
+	v.Check(s.Volume > 0, "volume", "卷号从1开始")
+}
+
+// SeriesVolume 系列的一卷图书
+type SeriesVolume struct {
+	Volume uint `db:"volume" json:"volume"`
+	*Book  `json:"book"`
+}
+
+// BookSeries 图书所属的系列和卷号，以及下一卷
+type BookSeries struct {
+	SeriesID   uint64        `db:"series_id" json:"seriesId"`
+	SeriesName string        `db:"series_name" json:"seriesName"`
+	Volume     uint          `db:"volume" json:"volume"`
+	Next       *SeriesVolume `db:"-" json:"next,omitempty"` // 下一卷，没有则为空
+}
@@ -0,0 +1,194 @@
+package opds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OPDS 1.2 基于 Atom，命名空间前缀直接写在标签名中
+
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string      `xml:"xmlns:opensearch,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	TotalResults int         `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int         `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int         `xml:"opensearch:startIndex,omitempty"`
+	Links        []atomLink  `xml:"link"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel   string     `xml:"rel,attr,omitempty"`
+	Href  string     `xml:"href,attr"`
+	Type  string     `xml:"type,attr,omitempty"`
+	Title string     `xml:"title,attr,omitempty"`
+	Price *atomPrice `xml:"opds:price,omitempty"`
+}
+
+type atomPrice struct {
+	Currency string `xml:"currencycode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomAuthor   `xml:"author,omitempty"`
+	Publisher  string         `xml:"dc:publisher,omitempty"`
+	Issued     string         `xml:"dc:issued,omitempty"`
+	Language   string         `xml:"dc:language,omitempty"`
+	Categories []atomCategory `xml:"category,omitempty"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content,omitempty"`
+	Links      []atomLink     `xml:"link"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.Format(time.RFC3339)
+}
+
+func toAtomLinks(links []Link, defaultType string) []atomLink {
+	list := make([]atomLink, 0, len(links))
+	for _, l := range links {
+		al := atomLink{Rel: l.Rel, Href: l.Href, Type: l.Type, Title: l.Title}
+		if al.Type == "" {
+			al.Type = defaultType
+		}
+		if l.Price != nil {
+			al.Price = &atomPrice{Currency: l.Price.Currency, Value: fmt.Sprintf("%.2f", l.Price.Value)}
+		}
+		list = append(list, al)
+	}
+	return list
+}
+
+func (f *Feed) writeAtom(w io.Writer) error {
+	feed := atomFeed{
+		Xmlns:        "http://www.w3.org/2005/Atom",
+		XmlnsDC:      "http://purl.org/dc/terms/",
+		XmlnsOPDS:    "http://opds-spec.org/2010/catalog",
+		XmlnsSearch:  "http://a9.com/-/spec/opensearch/1.1/",
+		ID:           f.ID,
+		Title:        f.Title,
+		Updated:      atomTime(f.Updated),
+		TotalResults: f.TotalResults,
+		ItemsPerPage: f.ItemsPerPage,
+		Links:        toAtomLinks(f.Links, f.ContentType(V1)),
+	}
+	if f.TotalResults > 0 && f.CurrentPage > 0 {
+		feed.StartIndex = (f.CurrentPage-1)*f.ItemsPerPage + 1
+	}
+
+	for _, n := range f.Navigation {
+		kind := n.Kind
+		if kind == "" {
+			kind = TypeNavigation
+		}
+		entry := atomEntry{
+			ID:      n.ID,
+			Title:   n.Title,
+			Updated: atomTime(n.Updated),
+			Links:   []atomLink{{Rel: RelSubsection, Href: n.Href, Type: kind}},
+		}
+		if n.Content != "" {
+			entry.Content = &atomContent{Type: "text", Value: n.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	for _, p := range f.Publications {
+		title := p.Title
+		if p.Subtitle != "" {
+			title += ": " + p.Subtitle
+		}
+		entry := atomEntry{
+			ID:        p.ID,
+			Title:     title,
+			Updated:   atomTime(p.Updated),
+			Publisher: p.Publisher,
+			Language:  p.Language,
+			Summary:   p.Description,
+			Links:     toAtomLinks(p.Links, ""),
+		}
+		if !p.Issued.IsZero() {
+			entry.Issued = p.Issued.Format(time.DateOnly)
+		}
+		for _, a := range p.Authors {
+			entry.Authors = append(entry.Authors, atomAuthor{Name: a})
+		}
+		for _, c := range p.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c, Label: c})
+		}
+		if p.Image != "" {
+			entry.Links = append(entry.Links,
+				atomLink{Rel: RelImage, Href: p.Image, Type: imageType(p.Image)},
+				atomLink{Rel: RelThumbnail, Href: p.Image, Type: imageType(p.Image)},
+			)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+// OpenSearch 写出 OpenSearch 描述文档，template 为搜索地址模板，如 /opds/1.2/search?q={searchTerms}
+func OpenSearch(w io.Writer, shortName, description, template, feedType string) error {
+	doc := struct {
+		XMLName     xml.Name `xml:"OpenSearchDescription"`
+		Xmlns       string   `xml:"xmlns,attr"`
+		ShortName   string   `xml:"ShortName"`
+		Description string   `xml:"Description"`
+		InputEnc    string   `xml:"InputEncoding"`
+		OutputEnc   string   `xml:"OutputEncoding"`
+		URL         struct {
+			Type     string `xml:"type,attr"`
+			Template string `xml:"template,attr"`
+		} `xml:"Url"`
+	}{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   shortName,
+		Description: description,
+		InputEnc:    "UTF-8",
+		OutputEnc:   "UTF-8",
+	}
+	doc.URL.Type = feedType
+	doc.URL.Template = template
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
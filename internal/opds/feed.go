@@ -0,0 +1,126 @@
+// Package opds 生成 OPDS 目录，供 KOReader、Thorium 等阅读器浏览和下载电子书；
+// 同一个 Feed 可以输出为 OPDS 1.2(Atom XML) 或 OPDS 2.0(JSON)
+package opds
+
+import (
+	"io"
+	"time"
+)
+
+// Version OPDS 版本
+type Version string
+
+const (
+	V1 Version = "1.2"
+	V2 Version = "2.0"
+)
+
+// 链接的 rel
+const (
+	RelSelf        = "self"
+	RelStart       = "start"
+	RelUp          = "up"
+	RelNext        = "next"
+	RelPrev        = "previous"
+	RelFirst       = "first"
+	RelLast        = "last"
+	RelSearch      = "search"
+	RelSubsection  = "subsection"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelBuy         = "http://opds-spec.org/acquisition/buy"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+	RelNew         = "http://opds-spec.org/sort/new"
+)
+
+// 媒体类型
+const (
+	TypeNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	TypeAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	TypeEntry       = "application/atom+xml;type=entry;profile=opds-catalog"
+	TypeOPDS2       = "application/opds+json"
+	TypeOPDS2Pub    = "application/opds-publication+json"
+	TypeOpenSearch  = "application/opensearchdescription+xml"
+	TypeHTML        = "text/html"
+	TypeJSON        = "application/json"
+)
+
+// Link 链接，Type 为空时按 Feed 的版本填充
+type Link struct {
+	Rel   string
+	Href  string
+	Type  string
+	Title string
+	Price *Price // 购买链接的价格
+}
+
+// Price 价格
+type Price struct {
+	Value    float64
+	Currency string
+}
+
+// Navigation 导航条目，指向另一个 Feed
+type Navigation struct {
+	ID      string
+	Title   string
+	Href    string
+	Content string // 简短说明，如图书数量
+	Updated time.Time
+	Kind    string // 目标 Feed 类型：TypeNavigation、TypeAcquisition
+}
+
+// Publication 出版物条目
+type Publication struct {
+	ID          string // 唯一标识，如 urn:isbn:9787111111111
+	Title       string
+	Subtitle    string
+	Description string
+	Authors     []string
+	Publisher   string
+	Categories  []string
+	Language    string
+	Issued      time.Time
+	Updated     time.Time
+	Image       string // 封面图
+	Links       []Link // 获取、购买等链接
+}
+
+// Feed 目录，Navigation 和 Publications 只应其一有值
+type Feed struct {
+	ID           string
+	Title        string
+	Updated      time.Time
+	Links        []Link
+	Navigation   []Navigation
+	Publications []Publication
+
+	// 分页信息，TotalResults 为0时不输出
+	TotalResults int
+	ItemsPerPage int
+	CurrentPage  int
+}
+
+// IsAcquisition 是否是获取(图书列表)目录
+func (f *Feed) IsAcquisition() bool {
+	return len(f.Publications) > 0 || len(f.Navigation) == 0
+}
+
+// ContentType 对应版本的 Content-Type
+func (f *Feed) ContentType(v Version) string {
+	if v == V2 {
+		return TypeOPDS2
+	}
+	if f.IsAcquisition() {
+		return TypeAcquisition
+	}
+	return TypeNavigation
+}
+
+// Write 按版本写出目录
+func (f *Feed) Write(w io.Writer, v Version) error {
+	if v == V2 {
+		return f.writeJSON(w)
+	}
+	return f.writeAtom(w)
+}
@@ -0,0 +1,197 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// OPDS 2.0 基于 Readium Web Publication Manifest 的 JSON 格式
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified,omitempty"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type jsonLink struct {
+	Rel        string          `json:"rel,omitempty"`
+	Href       string          `json:"href"`
+	Type       string          `json:"type,omitempty"`
+	Title      string          `json:"title,omitempty"`
+	Templated  bool            `json:"templated,omitempty"`
+	Properties *jsonProperties `json:"properties,omitempty"`
+}
+
+type jsonProperties struct {
+	Price *jsonPrice `json:"price,omitempty"`
+}
+
+type jsonPrice struct {
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPubMetadata `json:"metadata"`
+	Links    []jsonLink      `json:"links"`
+	Images   []jsonLink      `json:"images,omitempty"`
+}
+
+type jsonPubMetadata struct {
+	Type        string     `json:"@type"`
+	Identifier  string     `json:"identifier"`
+	Title       string     `json:"title"`
+	Subtitle    string     `json:"subtitle,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Author      []jsonName `json:"author,omitempty"`
+	Publisher   string     `json:"publisher,omitempty"`
+	Subject     []jsonName `json:"subject,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	Published   string     `json:"published,omitempty"`
+	Modified    string     `json:"modified,omitempty"`
+}
+
+type jsonName struct {
+	Name string `json:"name"`
+}
+
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// toJSONType 把 Atom 的媒体类型转为 OPDS 2.0 的媒体类型
+func toJSONType(t string) string {
+	switch t {
+	case TypeNavigation, TypeAcquisition:
+		return TypeOPDS2
+	case TypeEntry:
+		return TypeOPDS2Pub
+	}
+	return t
+}
+
+func toJSONLinks(links []Link, defaultType string) []jsonLink {
+	list := make([]jsonLink, 0, len(links))
+	for _, l := range links {
+		jl := jsonLink{Rel: l.Rel, Href: l.Href, Type: toJSONType(l.Type), Title: l.Title}
+		if jl.Type == "" {
+			jl.Type = defaultType
+		}
+		// 搜索链接使用模板地址
+		if l.Rel == RelSearch {
+			jl.Type = TypeOPDS2
+			jl.Templated = true
+		}
+		if l.Price != nil {
+			jl.Properties = &jsonProperties{Price: &jsonPrice{Value: l.Price.Value, Currency: l.Price.Currency}}
+		}
+		list = append(list, jl)
+	}
+	return list
+}
+
+func (f *Feed) writeJSON(w io.Writer) error {
+	feed := jsonFeed{
+		Metadata: jsonFeedMetadata{
+			Title:         f.Title,
+			Modified:      jsonTime(f.Updated),
+			NumberOfItems: f.TotalResults,
+			ItemsPerPage:  f.ItemsPerPage,
+			CurrentPage:   f.CurrentPage,
+		},
+		Links: toJSONLinks(f.Links, TypeOPDS2),
+	}
+
+	for _, n := range f.Navigation {
+		feed.Navigation = append(feed.Navigation, jsonLink{
+			Rel:   RelSubsection,
+			Href:  n.Href,
+			Type:  TypeOPDS2,
+			Title: n.Title,
+		})
+	}
+
+	for _, p := range f.Publications {
+		pub := jsonPublication{
+			Metadata: jsonPubMetadata{
+				Type:        "http://schema.org/Book",
+				Identifier:  p.ID,
+				Title:       p.Title,
+				Subtitle:    p.Subtitle,
+				Description: p.Description,
+				Publisher:   p.Publisher,
+				Language:    p.Language,
+				Published:   jsonTime(p.Issued),
+				Modified:    jsonTime(p.Updated),
+			},
+			Links: toJSONLinks(p.Links, ""),
+		}
+		for _, a := range p.Authors {
+			pub.Metadata.Author = append(pub.Metadata.Author, jsonName{Name: a})
+		}
+		for _, c := range p.Categories {
+			pub.Metadata.Subject = append(pub.Metadata.Subject, jsonName{Name: c})
+		}
+		if p.Image != "" {
+			pub.Images = []jsonLink{{Href: p.Image, Type: imageType(p.Image)}}
+		}
+		feed.Publications = append(feed.Publications, pub)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(feed)
+}
+
+// ext 去掉查询参数后的小写扩展名
+func ext(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	return strings.ToLower(path.Ext(url))
+}
+
+// FileType 根据扩展名推断电子书文件类型，用于获取链接的 type
+func FileType(url string) string {
+	switch ext(url) {
+	case ".epub":
+		return "application/epub+zip"
+	case ".pdf":
+		return "application/pdf"
+	case ".mobi":
+		return "application/x-mobipocket-ebook"
+	case ".azw3":
+		return "application/vnd.amazon.ebook"
+	case ".txt":
+		return "text/plain"
+	}
+	return "application/octet-stream"
+}
+
+// imageType 根据扩展名推断图片类型
+func imageType(url string) string {
+	switch ext(url) {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,123 @@
+// Package pdf 一个够用的 PDF 对象读写工具：读取交叉引用表(传统 xref 表和 1.5+ 的 xref 流)、
+// 对象流中的对象和页面树，序列化对象用于增量更新；流只支持 FlateDecode 解码
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalid           = errors.New("无效的PDF文件")
+	ErrUnsupportedFilter = errors.New("不支持的PDF流过滤器")
+)
+
+// 流解压后最大 64MB，避免压缩炸弹
+const maxStreamSize = 64 << 20
+
+// PDF 对象类型，数字为 int64 或 float64，布尔为 bool，null 为 nil
+type (
+	Name   string
+	String []byte
+	Array  []any
+	Dict   map[Name]any
+	Ref    struct{ Num, Gen int }
+	Stream struct {
+		Dict Dict
+		Data []byte // 原始(未解码)的数据
+	}
+)
+
+// Text 文本字符串转为 UTF-8：以 FE FF 开头为 UTF-16BE，否则按 PDFDocEncoding(近似 Latin-1)处理
+func (s String) Text() string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF {
+		return string(s[3:])
+	}
+
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// Number 数字对象转为 float64，不是数字返回 0
+func Number(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// WriteObject 序列化对象，字典的键排序保证输出稳定，字符串统一输出为十六进制
+func WriteObject(buf *bytes.Buffer, v any) {
+	switch o := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(o))
+	case int64:
+		buf.WriteString(strconv.FormatInt(o, 10))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(o, 'f', -1, 64))
+	case Name:
+		buf.WriteByte('/')
+		for _, c := range []byte(o) {
+			if c < '!' || c > '~' || c == '#' || isDelim(c) {
+				fmt.Fprintf(buf, "#%02X", c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	case String:
+		fmt.Fprintf(buf, "<%x>", []byte(o))
+	case Ref:
+		fmt.Fprintf(buf, "%d %d R", o.Num, o.Gen)
+	case Array:
+		buf.WriteByte('[')
+		for i, e := range o {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			WriteObject(buf, e)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		keys := make([]Name, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			WriteObject(buf, k)
+			buf.WriteByte(' ')
+			WriteObject(buf, o[k])
+		}
+		buf.WriteString(">>")
+	case *Stream:
+		dict := Dict{}
+		for k, v := range o.Dict {
+			dict[k] = v
+		}
+		dict["Length"] = int64(len(o.Data))
+		WriteObject(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(o.Data)
+		buf.WriteString("\nendstream")
+	}
+}
@@ -0,0 +1,97 @@
+package pdf
+
+import "fmt"
+
+// Page 页面对象及继承的属性
+type Page struct {
+	Ref       Ref
+	Dict      Dict
+	Resources Dict       // 已解析的资源字典，包括从父节点继承的
+	Box       [4]float64 // 优先 CropBox，没有则 MediaBox，默认 Letter 大小
+}
+
+// Pages 遍历页面树返回所有页面，处理继承的 Resources、MediaBox、CropBox
+func (f *File) Pages() ([]Page, error) {
+	catalog, err := f.Resolve(f.Trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	root, ok := catalog.(Dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: /Root 无效", ErrInvalid)
+	}
+
+	var pages []Page
+	visited := make(map[int]bool)
+
+	var walk func(v any, inherited Dict) error
+	walk = func(v any, inherited Dict) error {
+		ref, ok := v.(Ref)
+		if !ok || visited[ref.Num] {
+			return nil
+		}
+		visited[ref.Num] = true
+
+		obj, err := f.Object(ref.Num)
+		if err != nil {
+			return err
+		}
+		node, ok := obj.(Dict)
+		if !ok {
+			return nil
+		}
+
+		attrs := Dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range []Name{"Resources", "MediaBox", "CropBox"} {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		if kids, ok := node["Kids"]; ok || node["Type"] == Name("Pages") {
+			kids, err := f.Resolve(kids)
+			if err != nil {
+				return err
+			}
+			arr, _ := kids.(Array)
+			for _, kid := range arr {
+				if err := walk(kid, attrs); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		page := Page{Ref: ref, Dict: node, Box: [4]float64{0, 0, 612, 792}}
+		res, err := f.Resolve(attrs["Resources"])
+		if err != nil {
+			return err
+		}
+		page.Resources, _ = res.(Dict)
+
+		box := attrs["CropBox"]
+		if box == nil {
+			box = attrs["MediaBox"]
+		}
+		if box, err = f.Resolve(box); err != nil {
+			return err
+		}
+		if arr, ok := box.(Array); ok && len(arr) == 4 {
+			for i, v := range arr {
+				v, _ = f.Resolve(v)
+				page.Box[i] = Number(v)
+			}
+		}
+
+		pages = append(pages, page)
+		return nil
+	}
+
+	if err := walk(root["Pages"], nil); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
@@ -0,0 +1,710 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// xrefEntry 对象在文件中的位置，inStream 为 true 时对象在对象流 stream 的第 index 个
+type xrefEntry struct {
+	offset   int64
+	gen      int
+	inStream bool
+	stream   int
+	index    int
+}
+
+// File 解析后的 PDF 文件，对象按需读取并缓存
+type File struct {
+	Trailer   Dict
+	StartXref int64 // 最后一个 xref 的位置，增量更新时作为 /Prev
+	XrefTable bool  // 最后一个 xref 是传统表，增量更新时沿用相同格式
+
+	data  []byte
+	xref  map[int]xrefEntry
+	cache map[int]any
+}
+
+// Parse 读取交叉引用表和 trailer，加密的文件也可以解析，但字符串和流是加密后的内容
+func Parse(data []byte) (*File, error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: 缺少文件头", ErrInvalid)
+	}
+
+	f := &File{data: data, xref: make(map[int]xrefEntry), cache: make(map[int]any)}
+
+	tail := data[max(0, len(data)-2048):]
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("%w: 没有找到 startxref", ErrInvalid)
+	}
+	lx := &lexer{data: tail, pos: i + len("startxref")}
+	n, ok := lx.next().(int64)
+	if !ok {
+		return nil, fmt.Errorf("%w: startxref 无效", ErrInvalid)
+	}
+	f.StartXref = n
+
+	// 沿 /Prev 读取所有 xref，先读到的(更新的)优先
+	seen := make(map[int64]bool)
+	for offset, first := n, true; offset >= 0 && !seen[offset]; first = false {
+		seen[offset] = true
+		trailer, isTable, err := f.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			f.Trailer = trailer
+			f.XrefTable = isTable
+		}
+
+		// 混合文件：传统表的 trailer 中 /XRefStm 指向补充的 xref 流
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := f.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	if _, ok := f.Trailer["Root"].(Ref); !ok {
+		return nil, fmt.Errorf("%w: 缺少 /Root", ErrInvalid)
+	}
+	return f, nil
+}
+
+// Encrypted 文件是否加密
+func (f *File) Encrypted() bool {
+	_, ok := f.Trailer["Encrypt"]
+	return ok
+}
+
+// Data 原文件内容
+func (f *File) Data() []byte {
+	return f.data
+}
+
+// Size 下一个可用的对象编号
+func (f *File) Size() int {
+	next, _ := f.Trailer["Size"].(int64)
+	for num := range f.xref {
+		next = max(next, int64(num)+1)
+	}
+	return int(next)
+}
+
+// readXref 读取 offset 处的传统 xref 表或 xref 流，返回 trailer
+func (f *File) readXref(offset int64) (Dict, bool, error) {
+	if offset <= 0 || offset >= int64(len(f.data)) {
+		return nil, false, fmt.Errorf("%w: xref 位置无效", ErrInvalid)
+	}
+
+	lx := &lexer{data: f.data, pos: int(offset)}
+	lx.skipSpace()
+	if bytes.HasPrefix(f.data[lx.pos:], []byte("xref")) {
+		lx.pos += len("xref")
+		trailer, err := f.readXrefTable(lx)
+		return trailer, true, err
+	}
+
+	obj, err := lx.indirect()
+	if err != nil {
+		return nil, false, err
+	}
+	stm, ok := obj.(*Stream)
+	if !ok || stm.Dict["Type"] != Name("XRef") {
+		return nil, false, fmt.Errorf("%w: xref 流无效", ErrInvalid)
+	}
+	return stm.Dict, false, f.readXrefStream(stm)
+}
+
+func (f *File) readXrefTable(lx *lexer) (Dict, error) {
+	for {
+		tok := lx.next()
+		if kw, ok := tok.(keyword); ok && kw == "trailer" {
+			obj, err := lx.object()
+			dict, ok := obj.(Dict)
+			if err != nil || !ok {
+				return nil, fmt.Errorf("%w: trailer 无效", ErrInvalid)
+			}
+			return dict, nil
+		}
+
+		start, ok1 := tok.(int64)
+		count, ok2 := lx.next().(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: xref 表无效", ErrInvalid)
+		}
+		for i := range int(count) {
+			off, ok1 := lx.next().(int64)
+			gen, ok2 := lx.next().(int64)
+			kw, ok3 := lx.next().(keyword)
+			if !ok1 || !ok2 || !ok3 {
+				return nil, fmt.Errorf("%w: xref 表无效", ErrInvalid)
+			}
+			num := int(start) + i
+			if _, exists := f.xref[num]; exists || kw != "n" {
+				continue
+			}
+			f.xref[num] = xrefEntry{offset: off, gen: int(gen)}
+		}
+	}
+}
+
+func (f *File) readXrefStream(stm *Stream) error {
+	data, err := f.Decode(stm)
+	if err != nil {
+		return err
+	}
+
+	w, ok := stm.Dict["W"].(Array)
+	if !ok || len(w) != 3 {
+		return fmt.Errorf("%w: xref 流 /W 无效", ErrInvalid)
+	}
+	widths := make([]int, 3)
+	for i, v := range w {
+		n, _ := v.(int64)
+		widths[i] = int(n)
+	}
+	rowSize := widths[0] + widths[1] + widths[2]
+	if rowSize == 0 {
+		return fmt.Errorf("%w: xref 流 /W 无效", ErrInvalid)
+	}
+
+	size, _ := stm.Dict["Size"].(int64)
+	index := Array{int64(0), size}
+	if idx, ok := stm.Dict["Index"].(Array); ok {
+		index = idx
+	}
+
+	field := func(row []byte, i int) int64 {
+		start := 0
+		for j := range i {
+			start += widths[j]
+		}
+		var v int64
+		for _, b := range row[start : start+widths[i]] {
+			v = v<<8 | int64(b)
+		}
+		return v
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		for j := range int(count) {
+			if pos+rowSize > len(data) {
+				return nil
+			}
+			row := data[pos : pos+rowSize]
+			pos += rowSize
+
+			typ := int64(1)
+			if widths[0] > 0 {
+				typ = field(row, 0)
+			}
+			num := int(start) + j
+			if _, exists := f.xref[num]; exists {
+				continue
+			}
+			switch typ {
+			case 1:
+				f.xref[num] = xrefEntry{offset: field(row, 1), gen: int(field(row, 2))}
+			case 2:
+				f.xref[num] = xrefEntry{inStream: true, stream: int(field(row, 1)), index: int(field(row, 2))}
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve 解析间接引用，其他值原样返回
+func (f *File) Resolve(v any) (any, error) {
+	ref, ok := v.(Ref)
+	if !ok {
+		return v, nil
+	}
+	return f.Object(ref.Num)
+}
+
+// Object 读取编号为 num 的对象，不存在返回 nil
+func (f *File) Object(num int) (any, error) {
+	if obj, ok := f.cache[num]; ok {
+		return obj, nil
+	}
+
+	entry, ok := f.xref[num]
+	if !ok {
+		return nil, nil
+	}
+
+	var obj any
+	var err error
+	if entry.inStream {
+		obj, err = f.objectInStream(entry)
+	} else {
+		lx := &lexer{data: f.data, pos: int(entry.offset), file: f}
+		obj, err = lx.indirect()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.cache[num] = obj
+	return obj, nil
+}
+
+func (f *File) objectInStream(entry xrefEntry) (any, error) {
+	v, err := f.Object(entry.stream)
+	if err != nil {
+		return nil, err
+	}
+	stm, ok := v.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: 对象流 %d 无效", ErrInvalid, entry.stream)
+	}
+	data, err := f.Decode(stm)
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := stm.Dict["N"].(int64)
+	first, _ := stm.Dict["First"].(int64)
+	if entry.index >= int(n) || int(first) > len(data) {
+		return nil, fmt.Errorf("%w: 对象流 %d 无效", ErrInvalid, entry.stream)
+	}
+
+	// 头部是 n 对 "对象编号 偏移"
+	lx := &lexer{data: data[:first]}
+	var offset int64
+	for i := 0; i <= entry.index; i++ {
+		lx.next()
+		offset, _ = lx.next().(int64)
+	}
+
+	lx = &lexer{data: data, pos: int(first + offset), file: f}
+	return lx.object()
+}
+
+// Decode 解码流数据，只支持 FlateDecode 和 PNG 预测器，其他过滤器返回 ErrUnsupportedFilter
+func (f *File) Decode(stm *Stream) ([]byte, error) {
+	filter, err := f.Resolve(stm.Dict["Filter"])
+	if err != nil {
+		return nil, err
+	}
+	if arr, ok := filter.(Array); ok {
+		if len(arr) > 1 {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedFilter, arr)
+		}
+		if len(arr) == 1 {
+			filter = arr[0]
+		} else {
+			filter = nil
+		}
+	}
+
+	switch filter {
+	case nil:
+		return stm.Data, nil
+	case Name("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFilter, filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(stm.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxStreamSize+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(data) > maxStreamSize {
+		return nil, fmt.Errorf("%w: 流解压后太大", ErrInvalid)
+	}
+
+	parms, _ := f.Resolve(stm.Dict["DecodeParms"])
+	if arr, ok := parms.(Array); ok && len(arr) > 0 {
+		parms, _ = f.Resolve(arr[0])
+	}
+	dp, _ := parms.(Dict)
+	predictor, _ := dp["Predictor"].(int64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, ok := dp["Columns"].(int64)
+	if !ok {
+		columns = 1
+	}
+	return unpredictPNG(data, int(columns))
+}
+
+// unpredictPNG 还原 PNG 预测器(每行首字节为过滤类型)，xref 流每个像素1字节
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	rowSize := columns + 1
+	out := make([]byte, 0, len(data)/rowSize*columns)
+	prev := make([]byte, columns)
+	for i := 0; i+rowSize <= len(data); i += rowSize {
+		typ, row := data[i], append([]byte(nil), data[i+1:i+rowSize]...)
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = row[j-1], prev[j-1]
+			}
+			up := prev[j]
+			switch typ {
+			case 0:
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: PNG 预测器类型 %d 无效", ErrInvalid, typ)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// keyword PDF 关键字，如 obj、R、trailer、true
+type keyword string
+
+type delim string
+
+// lexer 词法和语法解析，file 用于解析流的间接 /Length
+type lexer struct {
+	data []byte
+	pos  int
+	file *File
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (lx *lexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if isSpace(c) {
+			lx.pos++
+			continue
+		}
+		if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// next 读取下一个词：数字、名称、字符串、分隔符或关键字，结尾返回nil
+func (lx *lexer) next() any {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil
+	}
+
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		lx.pos++
+		return lx.name()
+	case c == '(':
+		lx.pos++
+		return lx.literal()
+	case c == '<':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<' {
+			lx.pos += 2
+			return delim("<<")
+		}
+		lx.pos++
+		return lx.hex()
+	case c == '>':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '>' {
+			lx.pos += 2
+			return delim(">>")
+		}
+		lx.pos++
+		return delim(">")
+	case c == '[' || c == ']' || c == '{' || c == '}' || c == ')':
+		lx.pos++
+		return delim(string(c))
+	}
+
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isSpace(lx.data[lx.pos]) && !isDelim(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	word := string(lx.data[start:lx.pos])
+	if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n
+	}
+	return keyword(word)
+}
+
+func (lx *lexer) name() Name {
+	var buf []byte
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if isSpace(c) || isDelim(c) {
+			break
+		}
+		if c == '#' && lx.pos+2 < len(lx.data) {
+			if b, err := strconv.ParseUint(string(lx.data[lx.pos+1:lx.pos+3]), 16, 8); err == nil {
+				buf = append(buf, byte(b))
+				lx.pos += 3
+				continue
+			}
+		}
+		buf = append(buf, c)
+		lx.pos++
+	}
+	return Name(buf)
+}
+
+func (lx *lexer) literal() String {
+	var buf []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return buf
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return buf
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; k++ {
+						v = v*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (lx *lexer) hex() String {
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if c := lx.data[lx.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	lx.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, len(digits)/2)
+	for i := range buf {
+		b, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		buf[i] = byte(b)
+	}
+	return buf
+}
+
+// object 解析一个对象，包括间接引用 "1 0 R" 和字典后的流
+func (lx *lexer) object() (any, error) {
+	tok := lx.next()
+	switch t := tok.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: 意外的文件结尾", ErrInvalid)
+	case int64:
+		// 可能是间接引用
+		save := lx.pos
+		if gen, ok := lx.next().(int64); ok {
+			if kw, ok := lx.next().(keyword); ok && kw == "R" {
+				return Ref{Num: int(t), Gen: int(gen)}, nil
+			}
+		}
+		lx.pos = save
+		return t, nil
+	case delim:
+		switch t {
+		case "[":
+			arr := Array{}
+			for {
+				save := lx.pos
+				if d, ok := lx.next().(delim); ok && d == "]" {
+					return arr, nil
+				}
+				lx.pos = save
+				v, err := lx.object()
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+		case "<<":
+			dict := Dict{}
+			for {
+				tok := lx.next()
+				if d, ok := tok.(delim); ok && d == ">>" {
+					break
+				}
+				key, ok := tok.(Name)
+				if !ok {
+					return nil, fmt.Errorf("%w: 字典的键无效", ErrInvalid)
+				}
+				v, err := lx.object()
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = v
+			}
+			return lx.maybeStream(dict)
+		}
+		return nil, fmt.Errorf("%w: 意外的 %s", ErrInvalid, t)
+	case keyword:
+		switch t {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: 意外的 %s", ErrInvalid, t)
+	}
+	return tok, nil
+}
+
+// maybeStream 字典后紧跟 stream 关键字时读取流数据
+func (lx *lexer) maybeStream(dict Dict) (any, error) {
+	save := lx.pos
+	lx.skipSpace()
+	if !bytes.HasPrefix(lx.data[lx.pos:], []byte("stream")) {
+		lx.pos = save
+		return dict, nil
+	}
+	lx.pos += len("stream")
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\r' {
+		lx.pos++
+	}
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+		lx.pos++
+	}
+
+	length := int64(-1)
+	switch v := dict["Length"].(type) {
+	case int64:
+		length = v
+	case Ref:
+		if lx.file != nil {
+			if n, err := lx.file.Object(v.Num); err == nil {
+				length, _ = n.(int64)
+			}
+		}
+	}
+
+	start := lx.pos
+	end := start + int(length)
+	if length < 0 || end > len(lx.data) || !bytes.Contains(lx.data[end:min(end+32, len(lx.data))], []byte("endstream")) {
+		// 长度错误时查找 endstream
+		i := bytes.Index(lx.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, fmt.Errorf("%w: 缺少 endstream", ErrInvalid)
+		}
+		end = start + i
+		for end > start && (lx.data[end-1] == '\n' || lx.data[end-1] == '\r') {
+			end--
+		}
+	}
+
+	lx.pos = end
+	lx.skipSpace()
+	lx.pos += len("endstream")
+	return &Stream{Dict: dict, Data: lx.data[start:end]}, nil
+}
+
+// indirect 解析 "num gen obj ... endobj"
+func (lx *lexer) indirect() (any, error) {
+	_, ok1 := lx.next().(int64)
+	_, ok2 := lx.next().(int64)
+	kw, ok3 := lx.next().(keyword)
+	if !ok1 || !ok2 || !ok3 || kw != "obj" {
+		return nil, fmt.Errorf("%w: 间接对象无效", ErrInvalid)
+	}
+	return lx.object()
+}
@@ -0,0 +1,292 @@
+// Package resumable 断点续传：先创建上传，再按偏移量分片追加，中断后查询已上传的大小继续，
+// 全部上传后校验 SHA-256 得到完整的文件。协议参考 tus(https://tus.io)，
+// 已上传的数据和元数据保存在本地目录，多个实例需要共享该目录
+package resumable
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTTL 上传创建后的有效期，过期未完成的上传会被清理
+const DefaultTTL = 24 * time.Hour
+
+var (
+	ErrNotFound       = errors.New("上传不存在或已过期")
+	ErrOffsetMismatch = errors.New("上传偏移量与已上传的大小不一致")
+	ErrTooLarge       = errors.New("上传的数据超过声明的大小")
+	ErrIncomplete     = errors.New("文件还没有上传完成")
+	ErrChecksum       = errors.New("文件校验和不一致")
+)
+
+var idRex = regexp.MustCompile("^[0-9a-f]{32}$")
+
+// Upload 一次上传
+type Upload struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`     // 上传类型
+	Filename  string    `json:"filename"` // 原文件名
+	Length    int64     `json:"length"`   // 文件总大小
+	Offset    int64     `json:"offset"`   // 已上传的大小，从数据文件的大小得到，不保存
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Store 上传的存储，每个上传对应目录下的 {id}.json 元数据和 {id}.part 数据文件
+type Store struct {
+	dir string
+	ttl time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*idLock // 同一个上传的追加、完成操作串行执行，没有使用时删除
+}
+
+// idLock 一个上传的锁，refs 为持有和等待的次数
+type idLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewStore 创建存储，ttl 不大于0时使用 DefaultTTL
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, ttl: ttl, locks: make(map[string]*idLock)}, nil
+}
+
+func (s *Store) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+func (s *Store) partPath(id string) string { return filepath.Join(s.dir, id+".part") }
+
+// lock 锁定一个上传，返回解锁函数；没有其他请求持有或等待时解锁后删除，
+// 不存在的 id 也不会一直占用内存
+func (s *Store) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = new(idLock)
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		s.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Create 创建上传，length 为文件总大小
+func (s *Store) Create(kind, filename string, length int64) (*Upload, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	u := &Upload{
+		ID:        hex.EncodeToString(b),
+		Kind:      kind,
+		Filename:  filepath.Base(filename),
+		Length:    length,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	part, err := os.OpenFile(s.partPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	part.Close()
+
+	meta, err := json.Marshal(u)
+	if err == nil {
+		err = os.WriteFile(s.metaPath(u.ID), meta, 0o644)
+	}
+	if err != nil {
+		os.Remove(s.partPath(u.ID))
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get 查询上传和已上传的大小
+func (s *Store) Get(id string) (*Upload, error) {
+	if !idRex.MatchString(id) {
+		return nil, ErrNotFound
+	}
+
+	meta, err := os.ReadFile(s.metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := new(Upload)
+	if err := json.Unmarshal(meta, u); err != nil {
+		return nil, err
+	}
+	if time.Now().After(u.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	info, err := os.Stat(s.partPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Offset = info.Size()
+	return u, nil
+}
+
+// Append 从 offset 开始追加 r 的数据，offset 必须等于已上传的大小；
+// 读取 r 中断时已写入的数据保留，客户端查询偏移量后继续上传
+func (s *Store) Append(id string, offset int64, r io.Reader) (*Upload, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != u.Offset {
+		return u, ErrOffsetMismatch
+	}
+
+	part, err := os.OpenFile(s.partPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	n, err := io.Copy(part, io.LimitReader(r, u.Length-u.Offset+1))
+	if u.Offset+n > u.Length {
+		// 超过声明大小的分片整个丢弃
+		part.Truncate(u.Offset)
+		return u, ErrTooLarge
+	}
+	u.Offset += n
+	return u, err
+}
+
+// Complete 确认上传完成并校验 SHA-256(十六进制，不区分大小写)，返回定位到开头的数据文件，
+// 由调用方关闭，保存后调用 Remove 删除
+func (s *Store) Complete(id, checksum string) (*Upload, *os.File, error) {
+	unlock := s.lock(id)
+	defer unlock()
+
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Offset != u.Length {
+		return u, nil, ErrIncomplete
+	}
+
+	f, err := os.Open(s.partPath(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), checksum) {
+		f.Close()
+		return u, nil, ErrChecksum
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return u, f, nil
+}
+
+// Remove 删除上传，不存在不返回错误
+func (s *Store) Remove(id string) error {
+	if !idRex.MatchString(id) {
+		return nil
+	}
+	unlock := s.lock(id)
+	defer unlock()
+
+	for _, name := range []string{s.metaPath(id), s.partPath(id)} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sweep 删除过期的上传，返回删除的个数
+func (s *Store) Sweep() (int, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".json")
+		if !idRex.MatchString(id) {
+			continue
+		}
+		if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err := s.Remove(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Schedule 每隔 interval 清理一次过期的上传，ctx 取消时退出
+func (s *Store) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.Sweep()
+		if err != nil {
+			slog.ErrorContext(ctx, "sweep resumable uploads", "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "sweep resumable uploads", "removed", n)
+		}
+	}
+}
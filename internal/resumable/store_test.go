@@ -0,0 +1,122 @@
+package resumable
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestResume(t *testing.T) {
+	s, err := NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	content := "0123456789"
+	u, err := s.Create("ebook", "../book.epub", int64(len(content)))
+	require.NoError(t, err)
+	require.Equal(t, "book.epub", u.Filename)
+
+	// 传输中断，已写入的数据保留
+	broken := io.MultiReader(strings.NewReader(content[:4]), iotest.ErrReader(errors.New("连接断开")))
+	u, err = s.Append(u.ID, 0, broken)
+	require.Error(t, err)
+	require.EqualValues(t, 4, u.Offset)
+
+	got, err := s.Get(u.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, got.Offset)
+
+	// 偏移量不一致
+	u, err = s.Append(u.ID, 0, strings.NewReader(content))
+	require.ErrorIs(t, err, ErrOffsetMismatch)
+	require.EqualValues(t, 4, u.Offset)
+
+	_, _, err = s.Complete(u.ID, checksum(content))
+	require.ErrorIs(t, err, ErrIncomplete)
+
+	u, err = s.Append(u.ID, 4, strings.NewReader(content[4:]))
+	require.NoError(t, err)
+	require.EqualValues(t, len(content), u.Offset)
+
+	_, _, err = s.Complete(u.ID, checksum("other"))
+	require.ErrorIs(t, err, ErrChecksum)
+
+	_, f, err := s.Complete(u.ID, strings.ToUpper(checksum(content)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+	require.NoError(t, f.Close())
+
+	require.NoError(t, s.Remove(u.ID))
+	_, err = s.Get(u.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, s.Remove(u.ID))
+
+	// 锁在使用后删除
+	require.Empty(t, s.locks)
+}
+
+func TestAppendTooLarge(t *testing.T) {
+	s, err := NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	u, err := s.Create("ebook", "book.epub", 5)
+	require.NoError(t, err)
+
+	// 超过声明大小的分片整个丢弃
+	u, err = s.Append(u.ID, 0, strings.NewReader("123456"))
+	require.ErrorIs(t, err, ErrTooLarge)
+	require.EqualValues(t, 0, u.Offset)
+
+	info, err := os.Stat(s.partPath(u.ID))
+	require.NoError(t, err)
+	require.EqualValues(t, 0, info.Size())
+}
+
+func TestNotFound(t *testing.T) {
+	s, err := NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	for _, id := range []string{"../etc/passwd", "00000000000000000000000000000000"} {
+		_, err := s.Append(id, 0, strings.NewReader("a"))
+		require.ErrorIs(t, err, ErrNotFound)
+		_, _, err = s.Complete(id, checksum("a"))
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Empty(t, s.locks)
+}
+
+func TestSweep(t *testing.T) {
+	s, err := NewStore(t.TempDir(), 10*time.Millisecond)
+	require.NoError(t, err)
+
+	u, err := s.Create("ebook", "book.epub", 5)
+	require.NoError(t, err)
+
+	n, err := s.Sweep()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	time.Sleep(20 * time.Millisecond)
+	n, err = s.Sweep()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	_, err = os.Stat(s.metaPath(u.ID))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(s.partPath(u.ID))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
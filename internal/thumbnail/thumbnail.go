@@ -0,0 +1,131 @@
+// Package thumbnail 生成图片的缩略图和响应式尺寸，纯Go实现，不依赖外部程序；
+// 支持读取 JPEG、PNG、GIF、WebP，输出 JPEG 或无损 WebP(VP8L，编码器见 webp.go)
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 默认生成的宽度和JPEG质量
+var DefaultWidths = []int{160, 320, 640, 1080}
+
+const DefaultQuality = 80
+
+var ErrNoWidths = errors.New("没有配置缩略图宽度")
+
+// 输出格式：FormatAuto 不透明的图片为JPEG、有透明通道的为WebP，FormatWebP 全部为WebP
+const (
+	FormatAuto = "auto"
+	FormatWebP = "webp"
+)
+
+// Options 生成选项
+type Options struct {
+	Widths  []int  // 生成的宽度，不超过原图宽度的才生成
+	Quality int    // JPEG 质量 1-100
+	Format  string // 输出格式，为空时为 FormatAuto
+}
+
+// Variant 一种尺寸的图片
+type Variant struct {
+	Width  int
+	Height int
+	Ext    string // .jpg 或 .webp
+	Data   []byte
+}
+
+// ParseWidths 解析逗号分隔的宽度，如 "160,320,640"，为空时返回 DefaultWidths
+func ParseWidths(s string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultWidths, nil
+	}
+
+	var widths []int
+	for _, part := range strings.Split(s, ",") {
+		w, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || w <= 0 || w > 4096 {
+			return nil, fmt.Errorf("缩略图宽度无效: %q", part)
+		}
+		widths = append(widths, w)
+	}
+	slices.Sort(widths)
+	return slices.Compact(widths), nil
+}
+
+// ParseFormat 解析输出格式，为空时返回 FormatAuto
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", FormatAuto:
+		return FormatAuto, nil
+	case FormatWebP:
+		return f, nil
+	}
+	return "", fmt.Errorf("缩略图格式无效: %q", s)
+}
+
+// Generate 解码 r 的图片，按 opt.Widths 等比缩小，返回原图宽度和生成的图片；
+// 按 opt.Format 输出JPEG或无损WebP，不放大比原图宽的尺寸
+func Generate(r io.Reader, opt Options) (int, []Variant, error) {
+	if len(opt.Widths) == 0 {
+		return 0, nil, ErrNoWidths
+	}
+	if opt.Quality <= 0 || opt.Quality > 100 {
+		opt.Quality = DefaultQuality
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	b := src.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return 0, nil, image.ErrFormat
+	}
+
+	webp := opt.Format == FormatWebP || !isOpaque(src)
+	var variants []Variant
+	for _, w := range opt.Widths {
+		if w >= b.Dx() {
+			continue
+		}
+		h := max(1, b.Dy()*w/b.Dx())
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+		var buf bytes.Buffer
+		v := Variant{Width: w, Height: h}
+		if webp {
+			v.Ext = ".webp"
+			err = encodeWebP(&buf, dst)
+		} else {
+			v.Ext = ".jpg"
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: opt.Quality})
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		v.Data = buf.Bytes()
+		variants = append(variants, v)
+	}
+	return b.Dx(), variants, nil
+}
+
+// isOpaque 图片是否没有透明像素
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
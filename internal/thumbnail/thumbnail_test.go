@@ -0,0 +1,110 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+func TestParseWidths(t *testing.T) {
+	widths, err := ParseWidths("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultWidths, widths)
+
+	// 排序并去重
+	widths, err = ParseWidths(" 640, 160,320,160 ")
+	require.NoError(t, err)
+	require.Equal(t, []int{160, 320, 640}, widths)
+
+	for _, s := range []string{"abc", "0", "-1", "5000", "160,,320"} {
+		_, err = ParseWidths(s)
+		require.Error(t, err, s)
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	// 400x300 不透明图片输出JPEG
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	width, variants, err := Generate(bytes.NewReader(encodePNG(t, src)), Options{Widths: []int{100, 200, 400, 800}, Quality: 90})
+	require.NoError(t, err)
+	require.Equal(t, 400, width)
+
+	// 不放大，等于原图宽度的也不生成
+	require.Len(t, variants, 2)
+	for i, w := range []int{100, 200} {
+		v := variants[i]
+		require.Equal(t, w, v.Width)
+		require.Equal(t, w*3/4, v.Height)
+		require.Equal(t, ".jpg", v.Ext)
+
+		img, err := jpeg.Decode(bytes.NewReader(v.Data))
+		require.NoError(t, err)
+		require.Equal(t, image.Pt(v.Width, v.Height), img.Bounds().Size())
+	}
+}
+
+func TestGenerateTransparent(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 10))
+	src.Set(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+
+	_, variants, err := Generate(bytes.NewReader(encodePNG(t, src)), Options{Widths: []int{30}})
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+	require.Equal(t, ".webp", variants[0].Ext)
+	require.Equal(t, 1, variants[0].Height)
+
+	img, err := webp.Decode(bytes.NewReader(variants[0].Data))
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(30, 1), img.Bounds().Size())
+}
+
+func TestGenerateWebP(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+
+	_, variants, err := Generate(bytes.NewReader(encodePNG(t, src)), Options{Widths: []int{50}, Format: FormatWebP})
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+	require.Equal(t, ".webp", variants[0].Ext)
+
+	img, err := webp.Decode(bytes.NewReader(variants[0].Data))
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(50, 25), img.Bounds().Size())
+	require.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.At(10, 10))
+}
+
+func TestParseFormat(t *testing.T) {
+	for s, want := range map[string]string{"": FormatAuto, "auto": FormatAuto, " WebP ": FormatWebP} {
+		got, err := ParseFormat(s)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := ParseFormat("png")
+	require.Error(t, err)
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	_, _, err := Generate(strings.NewReader("x"), Options{})
+	require.ErrorIs(t, err, ErrNoWidths)
+
+	_, _, err = Generate(strings.NewReader("not an image"), Options{Widths: DefaultWidths})
+	require.ErrorIs(t, err, image.ErrFormat)
+}
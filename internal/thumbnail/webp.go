@@ -0,0 +1,597 @@
+package thumbnail
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// 无损 WebP(VP8L) 编码器，按 RFC 9649 实现：减绿色变换、预测变换、LZ77 后向引用和前缀编码；
+// 不使用颜色缓存、交叉颜色变换和多组前缀码，压缩率不及 libwebp，但输出的是标准的 WebP 文件
+
+const (
+	maxWebPSize   = 1 << 14 // VP8L 宽高最大 16384
+	predictorBits = 4       // 预测变换的块大小 16x16
+	minMatch      = 3       // 后向引用的最短长度
+	maxMatch      = 4096    // 后向引用的最长长度
+	maxChain      = 8       // 每个位置最多比较的候选数
+	goodMatch     = 256     // 已找到这个长度的匹配时不再查找哈希链
+	hashBits      = 15
+	maxDistance   = 1<<20 - 120
+)
+
+var errWebPTooLarge = errors.New("WebP 图片宽高不能超过16384")
+
+// codeLengthCodeOrder 代码长度码的代码长度的写入顺序
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// 前缀码的字母表大小：绿色+长度、红、蓝、透明度、距离
+var alphabetSizes = [5]int{256 + 24, 256, 256, 256, 40}
+
+// encodeWebP 把图片编码为无损 WebP 写入 w
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width > maxWebPSize || height > maxWebPSize {
+		return errWebPTooLarge
+	}
+
+	pix := nrgbaPix(img)
+
+	bw := new(bitWriter)
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if isOpaque(img) {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3)
+
+	// 编码时按写入的顺序变换，解码时逆序还原
+	bw.write(1, 1)
+	bw.write(2, 2)
+	subtractGreen(pix)
+
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(predictorBits-2, 3)
+	modes := predict(pix, width, height)
+	writeImage(bw, modes, tiles(width), false)
+
+	bw.write(0, 1)
+	writeImage(bw, pix, width, true)
+
+	data := bw.flush()
+	size := len(data) + len(data)&1
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+size))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// nrgbaPix 非预乘透明度的 RGBA 像素
+func nrgbaPix(img image.Image) []byte {
+	b := img.Bounds()
+	pix := make([]byte, 0, 4*b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pix = append(pix, c.R, c.G, c.B, c.A)
+		}
+	}
+	return pix
+}
+
+func tiles(size int) int {
+	return (size + 1<<predictorBits - 1) >> predictorBits
+}
+
+func subtractGreen(pix []byte) {
+	for p := 0; p < len(pix); p += 4 {
+		pix[p] -= pix[p+1]
+		pix[p+2] -= pix[p+1]
+	}
+}
+
+// predict 为每个块选择残差最小的预测模式，pix 替换为残差，返回模式的子图像(模式在绿色通道)
+func predict(pix []byte, width, height int) []byte {
+	tw, th := tiles(width), tiles(height)
+	modes := make([]byte, 4*tw*th)
+	for ty := 0; ty < th; ty++ {
+		for tx := 0; tx < tw; tx++ {
+			best, bestCost := byte(0), -1
+			for mode := byte(0); mode < 14; mode++ {
+				cost := 0
+				for y := max(ty<<predictorBits, 1); y < min((ty+1)<<predictorBits, height); y++ {
+					for x := max(tx<<predictorBits, 1); x < min((tx+1)<<predictorBits, width); x++ {
+						p := 4 * (y*width + x)
+						pred := predictPixel(pix, width, p, mode)
+						for c := 0; c < 4; c++ {
+							cost += residualCost(pix[p+c] - pred[c])
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[4*(ty*tw+tx)+1] = best
+		}
+	}
+
+	// 残差需要用原始像素计算
+	res := make([]byte, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			var mode byte
+			switch {
+			case x == 0 && y == 0:
+				mode = 0
+			case y == 0:
+				mode = 1
+			case x == 0:
+				mode = 2
+			default:
+				mode = modes[4*((y>>predictorBits)*tw+(x>>predictorBits))+1]
+			}
+			pred := predictPixel(pix, width, p, mode)
+			for c := 0; c < 4; c++ {
+				res[p+c] = pix[p+c] - pred[c]
+			}
+		}
+	}
+	copy(pix, res)
+	return modes
+}
+
+// residualCost 残差按有符号数的绝对值估计编码代价
+func residualCost(d byte) int {
+	return abs(int(int8(d)))
+}
+
+// predictPixel 按模式预测 p 处的像素，L、T、TL、TR 为左、上、左上、右上的像素；
+// 最右一列的 TR 在内存中正好是当前行最左边的像素，与规范一致
+func predictPixel(pix []byte, width, p int, mode byte) [4]byte {
+	var out [4]byte
+	top := p - 4*width
+	switch mode {
+	case 0:
+		out[3] = 0xff
+		return out
+	case 1: // 第一行只有左边的像素
+		copy(out[:], pix[p-4:p])
+		return out
+	case 2: // 第一列只使用上边的像素
+		copy(out[:], pix[top:top+4])
+		return out
+	}
+
+	if mode == 11 {
+		var l, t int
+		for c := 0; c < 4; c++ {
+			tl := int(pix[top-4+c])
+			l += abs(tl - int(pix[top+c]))
+			t += abs(tl - int(pix[p-4+c]))
+		}
+		src := top
+		if l < t {
+			src = p - 4
+		}
+		copy(out[:], pix[src:src+4])
+		return out
+	}
+
+	for c := 0; c < 4; c++ {
+		l, t, tl, tr := pix[p-4+c], pix[top+c], pix[top-4+c], pix[top+4+c]
+		switch mode {
+		case 3:
+			out[c] = tr
+		case 4:
+			out[c] = tl
+		case 5:
+			out[c] = avg2(avg2(l, tr), t)
+		case 6:
+			out[c] = avg2(l, tl)
+		case 7:
+			out[c] = avg2(l, t)
+		case 8:
+			out[c] = avg2(tl, t)
+		case 9:
+			out[c] = avg2(t, tr)
+		case 10:
+			out[c] = avg2(avg2(l, tl), avg2(t, tr))
+		case 12:
+			out[c] = clamp(int(l) + int(t) - int(tl))
+		case 13:
+			a := int(avg2(l, t))
+			out[c] = clamp(a + (a-int(tl))/2)
+		}
+	}
+	return out
+}
+
+func avg2(a, b byte) byte {
+	return byte((int(a) + int(b)) / 2)
+}
+
+func clamp(x int) byte {
+	return byte(min(max(x, 0), 255))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// token 字面像素或后向引用
+type token struct {
+	pixel  [4]byte // RGBA
+	length int     // 大于0为后向引用
+	dist   int
+}
+
+// writeImage 用一组前缀码写入熵编码图像，topLevel 为主图像时需要写入没有元前缀码的标记
+func writeImage(bw *bitWriter, pix []byte, width int, topLevel bool) {
+	bw.write(0, 1) // 不使用颜色缓存
+	if topLevel {
+		bw.write(0, 1) // 只有一组前缀码
+	}
+
+	tokens := backwardRefs(pix, width)
+
+	var hist [5][]int
+	for i, n := range alphabetSizes {
+		hist[i] = make([]int, n)
+	}
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, _, _ := prefixEncode(t.length)
+			hist[0][256+code]++
+			code, _, _ = prefixEncode(t.dist + 120)
+			hist[4][code]++
+			continue
+		}
+		hist[0][t.pixel[1]]++
+		hist[1][t.pixel[0]]++
+		hist[2][t.pixel[2]]++
+		hist[3][t.pixel[3]]++
+	}
+
+	var codes [5]prefixCode
+	for i := range hist {
+		codes[i] = newPrefixCode(hist[i], 15)
+		codes[i].writeTo(bw)
+	}
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, n, extra := prefixEncode(t.length)
+			codes[0].writeSymbol(bw, 256+code)
+			bw.write(uint32(extra), uint(n))
+			code, n, extra = prefixEncode(t.dist + 120)
+			codes[4].writeSymbol(bw, code)
+			bw.write(uint32(extra), uint(n))
+			continue
+		}
+		codes[0].writeSymbol(bw, int(t.pixel[1]))
+		codes[1].writeSymbol(bw, int(t.pixel[0]))
+		codes[2].writeSymbol(bw, int(t.pixel[2]))
+		codes[3].writeSymbol(bw, int(t.pixel[3]))
+	}
+}
+
+// backwardRefs 贪心的 LZ77：比较左边、上边的像素和哈希链上的候选，取最长的匹配
+func backwardRefs(pix []byte, width int) []token {
+	n := len(pix) / 4
+	argb := make([]uint32, n)
+	for i := range argb {
+		argb[i] = binary.LittleEndian.Uint32(pix[4*i:])
+	}
+
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, n)
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			prev[i], head[h] = head[h], int32(i)
+		}
+	}
+	matchLen := func(i, j int) int {
+		l := 0
+		for l < maxMatch && i+l < n && argb[i+l] == argb[j+l] {
+			l++
+		}
+		return l
+	}
+
+	tokens := make([]token, 0, n)
+	for i := 0; i < n; {
+		bestLen, bestDist := 0, 0
+		try := func(j int) {
+			if j < 0 || i-j > maxDistance {
+				return
+			}
+			// 不可能比当前最长的匹配更长时跳过
+			if i+bestLen >= n || argb[i+bestLen] != argb[j+bestLen] {
+				return
+			}
+			if l := matchLen(i, j); l > bestLen {
+				bestLen, bestDist = l, i-j
+			}
+		}
+		try(i - 1)
+		try(i - width)
+		if i+1 < n {
+			for j, k := head[hash(i)], 0; j >= 0 && k < maxChain && bestLen < goodMatch; j, k = prev[j], k+1 {
+				try(int(j))
+			}
+		}
+
+		if bestLen < minMatch {
+			var t token
+			copy(t.pixel[:], pix[4*i:4*i+4])
+			tokens = append(tokens, t)
+			insert(i)
+			i++
+			continue
+		}
+		tokens = append(tokens, token{length: bestLen, dist: bestDist})
+		for k := 0; k < bestLen; k++ {
+			insert(i + k)
+		}
+		i += bestLen
+	}
+	return tokens
+}
+
+// prefixEncode 长度和距离的前缀码：返回前缀码、额外位数和额外位的值
+func prefixEncode(v int) (int, int, int) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	h := bits.Len(uint(d)) - 1
+	second := (d >> (h - 1)) & 1
+	extraBits := h - 1
+	return 2*h + second, extraBits, d & (1<<extraBits - 1)
+}
+
+// prefixCode 规范哈夫曼编码，codes 为按写入顺序反转后的位
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	single  bool // 只有一个符号时不占用位
+}
+
+func newPrefixCode(freq []int, limit int) prefixCode {
+	pc := prefixCode{lengths: huffmanLengths(freq, limit)}
+	pc.codes = make([]uint32, len(freq))
+
+	var used int
+	var count [16]int
+	for _, l := range pc.lengths {
+		if l > 0 {
+			used++
+			count[l]++
+		}
+	}
+	pc.single = used == 1
+
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < len(next); l++ {
+		code = (code + uint32(count[l-1])) << 1
+		next[l] = code
+	}
+	for s, l := range pc.lengths {
+		if l > 0 {
+			pc.codes[s] = bits.Reverse32(next[l]) >> (32 - l)
+			next[l]++
+		}
+	}
+	return pc
+}
+
+func (pc prefixCode) writeSymbol(bw *bitWriter, s int) {
+	if !pc.single {
+		bw.write(pc.codes[s], uint(pc.lengths[s]))
+	}
+}
+
+// writeTo 写入编码：不超过2个小于256的符号时使用简单编码，否则写入用代码长度码编码的代码长度
+func (pc prefixCode) writeTo(bw *bitWriter) {
+	var symbols []int
+	for s, l := range pc.lengths {
+		if l > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+
+	if len(symbols) == 0 {
+		// 没有使用的编码写为只有符号0的简单编码
+		symbols = []int{0}
+	}
+	if len(symbols) <= 2 && symbols[len(symbols)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			bw.write(uint32(symbols[1]), 8)
+		}
+		return
+	}
+
+	// 代码长度的游程编码：16 重复前一个长度3-6次，17 重复0 3-10次，18 重复0 11-138次
+	type rle struct{ symbol, extraBits, extra int }
+	var tokens []rle
+	for i := 0; i < len(pc.lengths); {
+		l := pc.lengths[i]
+		run := 1
+		for i+run < len(pc.lengths) && pc.lengths[i+run] == l {
+			run++
+		}
+		i += run
+
+		if l == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, rle{18, 7, n - 11})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, rle{17, 3, run - 3})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, rle{l, 0, 0})
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				tokens = append(tokens, rle{16, 2, n - 3})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, rle{l, 0, 0})
+		}
+	}
+
+	freq := make([]int, 19)
+	for _, t := range tokens {
+		freq[t.symbol]++
+	}
+	clc := newPrefixCode(freq, 7)
+
+	n := len(codeLengthCodeOrder)
+	for n > 4 && clc.lengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		bw.write(uint32(clc.lengths[s]), 3)
+	}
+	bw.write(0, 1) // 写入全部符号的代码长度
+
+	for _, t := range tokens {
+		clc.writeSymbol(bw, t.symbol)
+		bw.write(uint32(t.extra), uint(t.extraBits))
+	}
+}
+
+// huffmanLengths 计算不超过 limit 位的哈夫曼代码长度；超过时提高低频符号的频率后重新计算
+func huffmanLengths(freq []int, limit int) []int {
+	lengths := make([]int, len(freq))
+
+	type node struct {
+		weight      int
+		symbol      int // 叶子节点的符号，内部节点为 -1
+		left, right int
+	}
+	var symbols []int
+	for s, f := range freq {
+		if f > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	for minWeight := 1; ; minWeight *= 2 {
+		nodes := make([]node, 0, 2*len(symbols))
+		for _, s := range symbols {
+			nodes = append(nodes, node{weight: max(freq[s], minWeight), symbol: s})
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+
+		// 两个队列合并：叶子按权重排序，新建的内部节点权重单调递增
+		leaf, inner := 0, len(nodes)
+		pick := func() int {
+			if leaf < len(symbols) && (inner >= len(nodes) || nodes[leaf].weight <= nodes[inner].weight) {
+				leaf++
+				return leaf - 1
+			}
+			inner++
+			return inner - 1
+		}
+		for len(nodes)-len(symbols) < len(symbols)-1 {
+			a, b := pick(), pick()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, symbol: -1, left: a, right: b})
+		}
+
+		depth := make([]int, len(nodes))
+		maxDepth := 0
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if nd := nodes[i]; nd.symbol < 0 {
+				depth[nd.left], depth[nd.right] = depth[i]+1, depth[i]+1
+			} else {
+				lengths[nd.symbol] = depth[i]
+				maxDepth = max(maxDepth, depth[i])
+			}
+		}
+		if maxDepth <= limit {
+			return lengths
+		}
+	}
+}
+
+// bitWriter 按 VP8L 的要求从低位开始写入
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) flush() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
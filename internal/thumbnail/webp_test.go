@@ -0,0 +1,99 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+// 无损编码后用 x/image/webp 解码，像素必须完全一致
+func TestEncodeWebP(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	images := map[string]func(x, y int) color.NRGBA{
+		"uniform": func(x, y int) color.NRGBA { return color.NRGBA{R: 10, G: 20, B: 30, A: 255} },
+		"gradient": func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 3), G: uint8(y * 5), B: uint8(x + y), A: 255}
+		},
+		"alpha": func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x), G: 128, B: uint8(y), A: uint8(x * y)}
+		},
+		"stripes": func(x, y int) color.NRGBA {
+			if (x/3+y)%4 == 0 {
+				return color.NRGBA{R: 255, A: 255}
+			}
+			return color.NRGBA{B: 255, A: 255}
+		},
+		"noise": func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: uint8(rnd.Intn(256))}
+		},
+	}
+	sizes := []image.Point{{1, 1}, {2, 3}, {17, 1}, {1, 40}, {33, 17}, {160, 120}, {300, 7}}
+
+	for name, fill := range images {
+		for _, size := range sizes {
+			src := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					src.SetNRGBA(x, y, fill(x, y))
+				}
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, encodeWebP(&buf, src), name)
+			img, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err, "%s %v", name, size)
+			got, ok := img.(*image.NRGBA)
+			require.True(t, ok)
+			require.Equal(t, src.Rect, got.Rect)
+			require.Equal(t, src.Pix, got.Pix, "%s %v", name, size)
+		}
+	}
+}
+
+// 重复的内容通过后向引用压缩
+func TestEncodeWebPCompress(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % 97)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encodeWebP(&buf, src))
+	require.Less(t, buf.Len(), len(src.Pix)/10)
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	// 斐波那契频率的最优编码深度超过15位，需要限制长度
+	freq := make([]int, 30)
+	a, b := 1, 1
+	for i := range freq {
+		freq[i] = a
+		a, b = b, a+b
+	}
+	lengths := huffmanLengths(freq, 15)
+
+	// Kraft 不等式取等号：完整的前缀码
+	sum := 0.0
+	for _, l := range lengths {
+		require.True(t, l > 0 && l <= 15)
+		sum += 1 / float64(int(1)<<l)
+	}
+	require.Equal(t, 1.0, sum)
+}
+
+func TestPrefixEncode(t *testing.T) {
+	for v := 1; v <= 4096; v++ {
+		code, n, extra := prefixEncode(v)
+		if code < 4 {
+			require.Equal(t, v, code+1)
+			continue
+		}
+		extraBits := (code - 2) >> 1
+		require.Equal(t, extraBits, n)
+		require.Equal(t, v, (2+code&1)<<extraBits+extra+1)
+	}
+}
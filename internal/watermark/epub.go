@@ -0,0 +1,218 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 解压的单个文件最大 32MB，避免恶意文件耗尽内存
+const maxEntrySize = 32 << 20
+
+var errBadEPUB = errors.New("无效的EPUB文件")
+
+// EPUB 添加水印：OPF 的 metadata 中写入 <meta name="ebook:watermark">，
+// 清单中每个 XHTML 正文页面的 </body> 前添加页脚；其他文件原样复制
+func EPUB(dst io.Writer, src io.ReaderAt, size int64, m Mark) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	opfPath, err := epubOPFPath(zr)
+	if err != nil {
+		return err
+	}
+
+	opf, err := readEntry(zr, opfPath)
+	if err != nil {
+		return err
+	}
+
+	pages, err := epubContentPages(opf, path.Dir(opfPath))
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(dst)
+
+	// mimetype 必须是第一个文件，且不压缩
+	if f := findEntry(zr, "mimetype"); f != nil {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range zr.File {
+		var data []byte
+		switch {
+		case f.Name == "mimetype":
+			continue
+		case f.Name == opfPath:
+			data = markOPF(opf, m)
+		case pages[f.Name]:
+			page, err := readFile(f)
+			if err != nil {
+				return err
+			}
+			data = markPage(page, m)
+		default:
+			// 未修改的文件直接复制压缩后的数据
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header := f.FileHeader
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(&header)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// epubOPFPath 从 META-INF/container.xml 读取 OPF 文件路径
+func epubOPFPath(zr *zip.Reader) (string, error) {
+	data, err := readEntry(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	for _, rf := range container.Rootfiles {
+		if rf.FullPath != "" && (rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml") {
+			return rf.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("%w: 没有找到OPF文件", errBadEPUB)
+}
+
+// epubContentPages 返回清单中所有 XHTML 页面的zip路径，不包括导航文档
+func epubContentPages(opf []byte, dir string) (map[string]bool, error) {
+	var pkg struct {
+		Items []struct {
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+	}
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadEPUB, err)
+	}
+
+	pages := make(map[string]bool)
+	for _, item := range pkg.Items {
+		if item.MediaType != "application/xhtml+xml" || strings.Contains(item.Properties, "nav") {
+			continue
+		}
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		name := path.Clean(path.Join(dir, href))
+		pages[strings.TrimPrefix(name, "./")] = true
+	}
+	return pages, nil
+}
+
+// markOPF 在 </metadata> 前写入不可见的水印标记
+func markOPF(opf []byte, m Mark) []byte {
+	meta := fmt.Sprintf(`<meta name="ebook:watermark" content="%s"/>`, m.ID())
+	return insertBefore(opf, "</metadata>", meta)
+}
+
+// markPage 在 </body> 前添加页脚
+func markPage(page []byte, m Mark) []byte {
+	footer := fmt.Sprintf(
+		`<div class="ebook-watermark" style="margin-top:2em;font-size:0.6em;color:#999;text-align:center;">%s</div>`,
+		html.EscapeString(m.Text()),
+	)
+	return insertBefore(page, "</body>", footer)
+}
+
+// insertBefore 在最后一个 tag(不区分大小写、可带命名空间前缀)前插入内容，没有找到则原样返回
+func insertBefore(data []byte, tag, content string) []byte {
+	lower := bytes.ToLower(data)
+	name := strings.TrimPrefix(tag, "</")
+	i := bytes.LastIndex(lower, []byte(strings.ToLower(tag)))
+	if i < 0 {
+		// 带命名空间前缀，如 </opf:metadata>
+		j := bytes.LastIndex(lower, []byte(":"+strings.ToLower(name)))
+		if j < 0 {
+			return data
+		}
+		i = bytes.LastIndex(lower[:j], []byte("</"))
+		if i < 0 {
+			return data
+		}
+	}
+
+	out := make([]byte, 0, len(data)+len(content))
+	out = append(out, data[:i]...)
+	out = append(out, content...)
+	return append(out, data[i:]...)
+}
+
+func findEntry(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func readEntry(zr *zip.Reader, name string) ([]byte, error) {
+	f := findEntry(zr, name)
+	if f == nil {
+		return nil, fmt.Errorf("%w: 缺少 %s", errBadEPUB, name)
+	}
+	return readFile(f)
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s 太大", errBadEPUB, f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s 太大", errBadEPUB, f.Name)
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+// Package watermark 为售出的电子书添加买家水印：
+// EPUB 在每个正文页面底部添加可见的页脚，并在 OPF 元数据中写入不可见的标记；
+// PDF 以增量更新的方式在每一页底部盖上页脚文字，不改动原有内容
+package watermark
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrUnsupported = errors.New("仅支持EPUB、PDF添加水印")
+	ErrEncrypted   = errors.New("PDF已加密，无法添加水印")
+)
+
+// 支持的文件格式
+const (
+	FormatEPUB = "epub"
+	FormatPDF  = "pdf"
+)
+
+// Mark 水印内容：订单号和邮箱的哈希，不直接暴露买家邮箱
+type Mark struct {
+	OrderNo   uint64
+	EmailHash string
+}
+
+// NewMark 创建水印，邮箱去掉首尾空格、转为小写后取 sha256 的前16位
+func NewMark(orderNo uint64, email string) Mark {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return Mark{OrderNo: orderNo, EmailHash: hex.EncodeToString(sum[:])[:16]}
+}
+
+// ID 水印标识，如 EB-202401010001-1a2b3c4d5e6f7a8b，用于缓存文件名和不可见标记
+func (m Mark) ID() string {
+	return fmt.Sprintf("EB-%d-%s", m.OrderNo, m.EmailHash)
+}
+
+// Text 可见的水印文字，PDF 的基础字体不支持中文，只使用ASCII字符
+func (m Mark) Text() string {
+	return fmt.Sprintf("Licensed copy - Order %d - %s", m.OrderNo, m.EmailHash)
+}
+
+// FormatOf 根据文件名判断格式，不支持返回 ErrUnsupported
+func FormatOf(filename string) (string, error) {
+	if i := strings.IndexAny(filename, "?#"); i >= 0 {
+		filename = filename[:i]
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".epub":
+		return FormatEPUB, nil
+	case ".pdf":
+		return FormatPDF, nil
+	}
+	return "", ErrUnsupported
+}
+
+// Apply 按格式添加水印，src 为原文件，结果写入 dst
+func Apply(dst io.Writer, src io.ReaderAt, size int64, format string, m Mark) error {
+	switch format {
+	case FormatEPUB:
+		return EPUB(dst, src, size, m)
+	case FormatPDF:
+		return PDF(dst, src, size, m)
+	}
+	return ErrUnsupported
+}
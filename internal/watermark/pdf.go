@@ -0,0 +1,241 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/lightsaid/ebook/internal/pdf"
+)
+
+// PDF 文件最大 512MB，需要整个读入内存解析
+const maxPDFSize = 512 << 20
+
+const (
+	fontName = pdf.Name("EBWM")
+	fontSize = 7.0
+)
+
+// PDF 添加水印：以增量更新的方式追加修改后的页面对象，原文件内容不变，
+// 每一页的内容流前后包裹 q/Q 保存和恢复图形状态，再在页面底部居中写入水印文字
+func PDF(dst io.Writer, src io.ReaderAt, size int64, m Mark) error {
+	if size > maxPDFSize {
+		return fmt.Errorf("%w: 文件太大", pdf.ErrInvalid)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return err
+	}
+
+	f, err := pdf.Parse(data)
+	if err != nil {
+		return err
+	}
+	if f.Encrypted() {
+		return ErrEncrypted
+	}
+
+	pages, err := f.Pages()
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("%w: 没有页面", pdf.ErrInvalid)
+	}
+
+	u := &update{file: f, next: f.Size(), objects: make(map[pdf.Ref]any)}
+	font := u.add(pdf.Dict{
+		"Type":     pdf.Name("Font"),
+		"Subtype":  pdf.Name("Type1"),
+		"BaseFont": pdf.Name("Helvetica"),
+		"Encoding": pdf.Name("WinAnsiEncoding"),
+	})
+	begin := u.add(&pdf.Stream{Dict: pdf.Dict{}, Data: []byte("q\n")})
+
+	text := m.Text()
+	for _, page := range pages {
+		if err := u.stampPage(page, font, begin, text); err != nil {
+			return err
+		}
+	}
+
+	_, err = u.writeTo(dst)
+	return err
+}
+
+// update 增量更新，记录追加的对象
+type update struct {
+	file    *pdf.File
+	next    int
+	objects map[pdf.Ref]any
+	order   []pdf.Ref
+}
+
+// add 添加新对象，返回引用
+func (u *update) add(obj any) pdf.Ref {
+	ref := pdf.Ref{Num: u.next}
+	u.next++
+	u.set(ref, obj)
+	return ref
+}
+
+// set 添加或替换对象
+func (u *update) set(ref pdf.Ref, obj any) {
+	if _, ok := u.objects[ref]; !ok {
+		u.order = append(u.order, ref)
+	}
+	u.objects[ref] = obj
+}
+
+// stampPage 复制页面对象，添加水印字体和内容流
+func (u *update) stampPage(page pdf.Page, font, begin pdf.Ref, text string) error {
+	f := u.file
+
+	fonts := pdf.Dict{}
+	if v, err := f.Resolve(page.Resources["Font"]); err != nil {
+		return err
+	} else if d, ok := v.(pdf.Dict); ok {
+		for k, v := range d {
+			fonts[k] = v
+		}
+	}
+	fonts[fontName] = font
+
+	res := pdf.Dict{}
+	for k, v := range page.Resources {
+		res[k] = v
+	}
+	res["Font"] = fonts
+
+	contents := pdf.Array{begin}
+	v, err := f.Resolve(page.Dict["Contents"])
+	if err != nil {
+		return err
+	}
+	switch c := v.(type) {
+	case pdf.Array:
+		contents = append(contents, c...)
+	case *pdf.Stream:
+		contents = append(contents, page.Dict["Contents"])
+	}
+
+	// Helvetica 字符平均宽度约为 0.55em，估算文字宽度后居中
+	llx, lly, urx := page.Box[0], page.Box[1], page.Box[2]
+	x := llx + (urx-llx-float64(len(text))*fontSize*0.55)/2
+	y := lly + 12
+	stamp := fmt.Sprintf("Q\nq BT /%s %s Tf 0.5 g %s %s Td <%s> Tj ET Q\n",
+		fontName, fmtNum(fontSize), fmtNum(max(x, llx)), fmtNum(y), hex.EncodeToString([]byte(text)))
+	contents = append(contents, u.add(&pdf.Stream{Dict: pdf.Dict{}, Data: []byte(stamp)}))
+
+	dict := pdf.Dict{}
+	for k, v := range page.Dict {
+		dict[k] = v
+	}
+	dict["Resources"] = res
+	dict["Contents"] = contents
+	u.set(page.Ref, dict)
+	return nil
+}
+
+// writeTo 写入原文件和追加的对象、交叉引用表
+func (u *update) writeTo(w io.Writer) (int64, error) {
+	f := u.file
+	data := f.Data()
+
+	// 原文件直接写入，追加的部分写入 buf，偏移量需要加上原文件的长度
+	var buf bytes.Buffer
+	if last := data[len(data)-1]; last != '\n' && last != '\r' {
+		buf.WriteByte('\n')
+	}
+	base := int64(len(data))
+	n, err := w.Write(data)
+	if err != nil {
+		return int64(n), err
+	}
+
+	offsets := make(map[int]int64)
+	for _, ref := range u.order {
+		offsets[ref.Num] = base + int64(buf.Len())
+		fmt.Fprintf(&buf, "%d %d obj\n", ref.Num, ref.Gen)
+		pdf.WriteObject(&buf, u.objects[ref])
+		buf.WriteString("\nendobj\n")
+	}
+
+	trailer := pdf.Dict{"Root": f.Trailer["Root"], "Prev": f.StartXref}
+	for _, k := range []pdf.Name{"Info", "ID"} {
+		if v, ok := f.Trailer[k]; ok {
+			trailer[k] = v
+		}
+	}
+
+	xrefOffset := base + int64(buf.Len())
+	if f.XrefTable {
+		trailer["Size"] = int64(u.next)
+		buf.WriteString("xref\n")
+		u.eachSection(func(start int, refs []pdf.Ref) {
+			fmt.Fprintf(&buf, "%d %d\n", start, len(refs))
+			for _, ref := range refs {
+				fmt.Fprintf(&buf, "%010d %05d n\r\n", offsets[ref.Num], ref.Gen)
+			}
+		})
+		buf.WriteString("trailer\n")
+		pdf.WriteObject(&buf, trailer)
+		buf.WriteString("\n")
+	} else {
+		// 原文件使用 xref 流，追加一个不压缩的 xref 流，其自身也需要登记
+		self := pdf.Ref{Num: u.next}
+		u.next++
+		u.order = append(u.order, self)
+		offsets[self.Num] = xrefOffset
+
+		var index pdf.Array
+		var rows []byte
+		u.eachSection(func(start int, refs []pdf.Ref) {
+			index = append(index, int64(start), int64(len(refs)))
+			for _, ref := range refs {
+				row := make([]byte, 7)
+				row[0] = 1
+				binary.BigEndian.PutUint32(row[1:5], uint32(offsets[ref.Num]))
+				binary.BigEndian.PutUint16(row[5:7], uint16(ref.Gen))
+				rows = append(rows, row...)
+			}
+		})
+
+		trailer["Type"] = pdf.Name("XRef")
+		trailer["Size"] = int64(u.next)
+		trailer["W"] = pdf.Array{int64(1), int64(4), int64(2)}
+		trailer["Index"] = index
+		fmt.Fprintf(&buf, "%d 0 obj\n", self.Num)
+		pdf.WriteObject(&buf, &pdf.Stream{Dict: trailer, Data: rows})
+		buf.WriteString("\nendobj\n")
+	}
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	m, err := buf.WriteTo(w)
+	return base + m, err
+}
+
+// eachSection 按对象编号排序，连续的编号作为一个子段
+func (u *update) eachSection(fn func(start int, refs []pdf.Ref)) {
+	refs := slices.Clone(u.order)
+	slices.SortFunc(refs, func(a, b pdf.Ref) int { return a.Num - b.Num })
+
+	for i := 0; i < len(refs); {
+		j := i + 1
+		for j < len(refs) && refs[j].Num == refs[j-1].Num+1 {
+			j++
+		}
+		fn(refs[i].Num, refs[i:j])
+		i = j
+	}
+}
+
+// fmtNum 格式化水印的坐标、字号，最多保留两位小数
+func fmtNum(n float64) string {
+	return strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64)
+}
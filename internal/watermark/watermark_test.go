@@ -0,0 +1,189 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lightsaid/ebook/internal/pdf"
+	"github.com/stretchr/testify/require"
+)
+
+var testMark = NewMark(202401010001, " Reader@Example.com ")
+
+func TestMark(t *testing.T) {
+	require.Equal(t, NewMark(202401010001, "reader@example.com"), testMark)
+	require.Len(t, testMark.EmailHash, 16)
+	require.Equal(t, "EB-202401010001-"+testMark.EmailHash, testMark.ID())
+	require.NotContains(t, testMark.Text(), "reader@example.com")
+
+	for name, want := range map[string]string{
+		"a.epub":                 FormatEPUB,
+		"dir/A.PDF":              FormatPDF,
+		"https://x/a.epub?sig=1": FormatEPUB,
+	} {
+		got, err := FormatOf(name)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := FormatOf("a.mobi")
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+// buildEPUB 生成测试用的EPUB，files 为 OEBPS 目录下的文件
+func buildEPUB(t *testing.T, opf string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	w.Write([]byte("application/epub+zip"))
+
+	entries := map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OEBPS/content.opf": opf,
+	}
+	for name, data := range files {
+		entries["OEBPS/"+name] = data
+	}
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(data))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func readZip(t *testing.T, data []byte) (*zip.Reader, map[string]string) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(b)
+	}
+	return zr, files
+}
+
+func TestEPUB(t *testing.T) {
+	opf := `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Test</dc:title></metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="c1" href="text/chapter%201.xhtml" media-type="application/xhtml+xml"/>
+<item id="css" href="style.css" media-type="text/css"/>
+</manifest>
+<spine><itemref idref="c1"/></spine>
+</package>`
+	nav := `<html><body><nav><a href="text/chapter%201.xhtml">1</a></nav></body></html>`
+	chapter := `<html><BODY><p>hello</p></BODY></html>`
+	src := buildEPUB(t, opf, map[string]string{
+		"nav.xhtml":            nav,
+		"text/chapter 1.xhtml": chapter,
+		"style.css":            "p{}",
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, Apply(&out, bytes.NewReader(src), int64(len(src)), FormatEPUB, testMark))
+
+	zr, files := readZip(t, out.Bytes())
+	// mimetype 是第一个文件且不压缩
+	require.Equal(t, "mimetype", zr.File[0].Name)
+	require.Equal(t, zip.Store, zr.File[0].Method)
+	require.Equal(t, "application/epub+zip", files["mimetype"])
+
+	require.Contains(t, files["OEBPS/content.opf"], `<meta name="ebook:watermark" content="`+testMark.ID()+`"/></metadata>`)
+	require.Contains(t, files["OEBPS/text/chapter 1.xhtml"], testMark.Text()+"</div></BODY>")
+	require.Equal(t, nav, files["OEBPS/nav.xhtml"])
+	require.Equal(t, "p{}", files["OEBPS/style.css"])
+
+	var bad bytes.Buffer
+	err := EPUB(&bad, bytes.NewReader([]byte("not a zip")), 9, testMark)
+	require.ErrorIs(t, err, errBadEPUB)
+}
+
+// buildPDF 生成两页的测试PDF，第一页的内容是单个流，第二页是流数组
+func buildPDF(trailer string) []byte {
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R 5 0 R]/Count 2/MediaBox[0 0 200 300]>>",
+		"<</Type/Page/Parent 2 0 R/Contents 4 0 R/Resources<</Font<</F1 7 0 R>>>>>>",
+		"<</Length 8>>\nstream\n0 0 m S\n\nendstream",
+		"<</Type/Page/Parent 2 0 R/Contents[6 0 R]>>",
+		"<</Length 8>>\nstream\n1 1 m S\n\nendstream",
+		"<</Type/Font/Subtype/Type1/BaseFont/Times-Roman>>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d/Root 1 0 R%s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+func TestPDF(t *testing.T) {
+	src := buildPDF("")
+
+	var out bytes.Buffer
+	require.NoError(t, Apply(&out, bytes.NewReader(src), int64(len(src)), FormatPDF, testMark))
+	// 增量更新，原文件内容不变
+	require.True(t, bytes.HasPrefix(out.Bytes(), src))
+
+	f, err := pdf.Parse(out.Bytes())
+	require.NoError(t, err)
+	pages, err := f.Pages()
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+
+	text := hex.EncodeToString([]byte(testMark.Text()))
+	for i, page := range pages {
+		contents, ok := page.Dict["Contents"].(pdf.Array)
+		require.True(t, ok, i)
+		require.Len(t, contents, 3, i)
+
+		var all []string
+		for _, ref := range contents {
+			v, err := f.Resolve(ref)
+			require.NoError(t, err)
+			all = append(all, string(v.(*pdf.Stream).Data))
+		}
+		require.Equal(t, "q\n", all[0])
+		require.Contains(t, all[2], "<"+text+"> Tj")
+
+		fonts, err := f.Resolve(page.Resources["Font"])
+		require.NoError(t, err)
+		require.Contains(t, fonts, fontName)
+	}
+	// 保留页面原有的字体
+	fonts, err := f.Resolve(pages[0].Resources["Font"])
+	require.NoError(t, err)
+	require.Contains(t, fonts, pdf.Name("F1"))
+
+	encrypted := buildPDF("/Encrypt<<>>")
+	err = PDF(io.Discard, bytes.NewReader(encrypted), int64(len(encrypted)), testMark)
+	require.ErrorIs(t, err, ErrEncrypted)
+
+	err = PDF(io.Discard, strings.NewReader("%PDF-1.4\n"), 9, testMark)
+	require.Error(t, err)
+}
@@ -0,0 +1,10 @@
+-- 注意：已删除的数据与未删除的数据 isbn/分类名称 重复时，需要先彻底删除再回滚
+ALTER TABLE `books`
+  DROP INDEX `idx_isbn_alive`,
+  ADD UNIQUE INDEX `idx_isbn` (`isbn`),
+  DROP COLUMN `alive`;
+
+ALTER TABLE `category`
+  DROP INDEX `idx_category_name_alive`,
+  ADD UNIQUE INDEX idx_category_name (`category_name`),
+  DROP COLUMN `alive`;
@@ -0,0 +1,12 @@
+-- 唯一索引只约束未删除的数据：alive 未删除为1、已删除为NULL，
+-- 联合唯一索引中 NULL 互不冲突，因此软删除的 isbn/分类名称 可以重新创建，恢复时再校验冲突；
+-- INVISIBLE 使 select * 不返回该列
+ALTER TABLE `books`
+  ADD COLUMN `alive` TINYINT GENERATED ALWAYS AS (IF(`deleted_at` IS NULL, 1, NULL)) VIRTUAL INVISIBLE COMMENT '未删除为1，已删除为NULL',
+  DROP INDEX `idx_isbn`,
+  ADD UNIQUE INDEX `idx_isbn_alive` (`isbn`, `alive`);
+
+ALTER TABLE `category`
+  ADD COLUMN `alive` TINYINT GENERATED ALWAYS AS (IF(`deleted_at` IS NULL, 1, NULL)) VIRTUAL INVISIBLE COMMENT '未删除为1，已删除为NULL',
+  DROP INDEX `idx_category_name`,
+  ADD UNIQUE INDEX `idx_category_name_alive` (`category_name`, `alive`);
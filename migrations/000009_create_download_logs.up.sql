@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `download_logs` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `ip` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '客户端ip',
+  `user_agent` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '客户端UA',
+  `range_header` VARCHAR(128) NOT NULL DEFAULT '' COMMENT '请求的Range',
+  `status` INT NOT NULL COMMENT '响应状态码',
+  `bytes` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '发送的字节数',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_id` (`user_id`),
+  INDEX `idx_book_id` (`book_id`),
+  INDEX `idx_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `book_previews` (
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `mode` VARCHAR(16) NOT NULL DEFAULT 'chapters' COMMENT '试读方式：chapters-前N章，percent-前N%',
+  `value` INT UNSIGNED NOT NULL DEFAULT 1 COMMENT '章数或百分比',
+  `source_url` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '最近一次生成试读时的电子书文件地址',
+  `generated_at` TIMESTAMP NULL DEFAULT NULL COMMENT '最近一次生成试读的时间',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`book_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
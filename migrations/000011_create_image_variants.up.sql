@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS `image_variants` (
+  `url` VARCHAR(255) NOT NULL COMMENT '原图地址',
+  `srcset` JSON NOT NULL COMMENT '各宽度的图片地址，如 {"320w": "..."}',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`url`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
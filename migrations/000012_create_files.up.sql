@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `files` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `kind` VARCHAR(16) NOT NULL COMMENT '上传类型：cover、banner、icon、avatar、ebook',
+  `file_key` VARCHAR(255) NOT NULL COMMENT '文件标识，内容的SHA-256加扩展名，相同内容只保存一份',
+  `url` VARCHAR(255) NOT NULL COMMENT '访问地址，与图书、轮播图等保存的地址对应',
+  `parent_url` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '缩略图对应的原图地址，引用数与原图相同',
+  `ref_count` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '引用数，由回收任务统计',
+  `unreferenced_at` TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP COMMENT '引用数变为0的时间，超过保留期后删除',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_kind_key` (`kind`, `file_key`),
+  KEY `idx_url` (`url`),
+  KEY `idx_parent_url` (`parent_url`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
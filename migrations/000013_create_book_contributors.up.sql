@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `book_contributors` (
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `author_id` BIGINT UNSIGNED NOT NULL COMMENT '作者id，译者、插画师等同样保存在作者表',
+  `role` VARCHAR(16) NOT NULL DEFAULT 'author' COMMENT '角色：author-作者，translator-译者，illustrator-插画，editor-编者',
+  `sort` INT NOT NULL DEFAULT 0 COMMENT '排序，越小越靠前',
+  PRIMARY KEY (`book_id`, `author_id`, `role`),
+  INDEX `idx_author_id` (`author_id`),
+  FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`author_id`) REFERENCES `author`(`id`) ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 已有图书的 author_id 迁移为第一作者，books.author_id 保留为第一作者，兼容旧接口
+INSERT IGNORE INTO `book_contributors` (`book_id`, `author_id`, `role`, `sort`)
+SELECT `id`, `author_id`, 'author', 0 FROM `books`;
@@ -0,0 +1,12 @@
+ALTER TABLE `publisher`
+  DROP COLUMN `website`,
+  DROP COLUMN `contact`,
+  DROP COLUMN `address`,
+  DROP COLUMN `logo`;
+
+ALTER TABLE `author`
+  DROP COLUMN `aliases`,
+  DROP COLUMN `website`,
+  DROP COLUMN `nationality`,
+  DROP COLUMN `photo`,
+  DROP COLUMN `biography`;
@@ -0,0 +1,12 @@
+ALTER TABLE `author`
+  ADD COLUMN `biography` TEXT NOT NULL COMMENT '简介' AFTER `author_name`,
+  ADD COLUMN `photo` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '照片地址' AFTER `biography`,
+  ADD COLUMN `nationality` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '国籍' AFTER `photo`,
+  ADD COLUMN `website` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '个人网站' AFTER `nationality`,
+  ADD COLUMN `aliases` JSON NULL COMMENT '别名、笔名，JSON数组' AFTER `website`;
+
+ALTER TABLE `publisher`
+  ADD COLUMN `logo` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'logo地址' AFTER `publisher_name`,
+  ADD COLUMN `address` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '地址' AFTER `logo`,
+  ADD COLUMN `contact` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '联系方式，电话或邮箱' AFTER `address`,
+  ADD COLUMN `website` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '官网' AFTER `contact`;
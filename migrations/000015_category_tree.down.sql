@@ -0,0 +1,5 @@
+ALTER TABLE `category`
+  DROP INDEX `idx_path`,
+  DROP INDEX `idx_parent_sort`,
+  DROP COLUMN `path`,
+  DROP COLUMN `parent_id`;
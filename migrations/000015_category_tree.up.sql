@@ -0,0 +1,15 @@
+-- 分类树：parent_id 为0表示顶级分类；path 为祖先分类的物化路径，如 /1/5/ 表示父分类是5、祖父分类是1，顶级分类为 /；
+-- 子孙分类通过 path like '{path}{id}/%' 查询；sort 为同级分类的排序，从0开始连续
+ALTER TABLE `category`
+  ADD COLUMN `parent_id` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '父分类id，0为顶级分类' AFTER `id`,
+  ADD COLUMN `path` VARCHAR(255) NOT NULL DEFAULT '/' COMMENT '祖先分类的物化路径，如 /1/5/' AFTER `parent_id`,
+  ADD INDEX `idx_parent_sort` (`parent_id`, `sort`),
+  ADD INDEX `idx_path` (`path`);
+
+-- 已有分类都是顶级分类，按原来的 sort 重新连续编号
+UPDATE `category` c
+JOIN (
+  SELECT `id`, ROW_NUMBER() OVER (ORDER BY `sort`, `id`) - 1 AS `rn`
+  FROM `category` WHERE `deleted_at` IS NULL
+) t ON t.`id` = c.`id`
+SET c.`sort` = t.`rn`;
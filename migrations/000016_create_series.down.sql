@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `series_books`;
+DROP TABLE IF EXISTS `series`;
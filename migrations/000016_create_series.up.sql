@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS `series` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `series_name` VARCHAR(120) NOT NULL DEFAULT '' COMMENT '系列名称',
+  `description` TEXT NOT NULL COMMENT '系列简介',
+  `cover_url` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '封面图片地址',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  `deleted_at` TIMESTAMP NULL COMMENT '删除时间',
+  PRIMARY KEY (`id`),
+  INDEX `idx_series_name` (`series_name`),
+  INDEX `idx_created_at` (`created_at`),
+  INDEX `idx_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 一本书最多属于一个系列，同一系列的卷号不重复
+CREATE TABLE IF NOT EXISTS `series_books` (
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `series_id` BIGINT UNSIGNED NOT NULL COMMENT '系列id',
+  `volume` INT UNSIGNED NOT NULL COMMENT '卷号，从1开始',
+  PRIMARY KEY (`book_id`),
+  UNIQUE INDEX `uniq_series_volume` (`series_id`, `volume`),
+  FOREIGN KEY (`book_id`) REFERENCES `books`(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`series_id`) REFERENCES `series`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+ALTER TABLE `books`
+  DROP COLUMN `rating_count`,
+  DROP COLUMN `rating_avg`;
+
+DROP TABLE IF EXISTS `review_votes`;
+DROP TABLE IF EXISTS `reviews`;
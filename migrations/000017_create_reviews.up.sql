@@ -0,0 +1,37 @@
+-- 图书评价：每个用户对每本书只有一条评价，修改后重新进入待审核；
+-- status: 0-待审核，1-已通过，2-已驳回，3-已隐藏，只有已通过的评价公开并计入评分
+CREATE TABLE IF NOT EXISTS `reviews` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `rating` TINYINT UNSIGNED NOT NULL COMMENT '评分，1-5星',
+  `content` TEXT NOT NULL COMMENT '评价内容',
+  `status` TINYINT NOT NULL DEFAULT 0 COMMENT '审核状态',
+  `reason` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '驳回或隐藏的原因',
+  `helpful_count` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '有用数',
+  `moderated_at` TIMESTAMP NULL DEFAULT NULL COMMENT '审核时间',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uniq_user_book` (`user_id`, `book_id`),
+  INDEX `idx_book_status_helpful` (`book_id`, `status`, `helpful_count`),
+  INDEX `idx_book_status_created` (`book_id`, `status`, `created_at`),
+  INDEX `idx_status_created` (`status`, `created_at`),
+  FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`book_id`) REFERENCES books(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 评价的有用投票，每个用户对每条评价只能投一次
+CREATE TABLE IF NOT EXISTS `review_votes` (
+  `review_id` BIGINT UNSIGNED NOT NULL COMMENT '评价id',
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`review_id`, `user_id`),
+  FOREIGN KEY (`review_id`) REFERENCES reviews(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 已通过评价的平均分和数量，审核状态变化时重新计算
+ALTER TABLE `books`
+  ADD COLUMN `rating_avg` DECIMAL(3,2) NOT NULL DEFAULT 0 COMMENT '平均评分' AFTER `stock`,
+  ADD COLUMN `rating_count` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '评价数' AFTER `rating_avg`;
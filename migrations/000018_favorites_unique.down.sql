@@ -0,0 +1,4 @@
+ALTER TABLE `favorites`
+  DROP INDEX `uniq_user_book`,
+  DROP COLUMN `available`,
+  DROP COLUMN `price`;
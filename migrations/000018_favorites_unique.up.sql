@@ -0,0 +1,15 @@
+-- 同一用户重复收藏的只保留最早的一条
+DELETE f1 FROM `favorites` f1
+JOIN `favorites` f2 ON f1.`user_id` = f2.`user_id` AND f1.`book_id` = f2.`book_id` AND f1.`id` > f2.`id`;
+
+-- price、available 为收藏时图书的价格和是否可购买(上架，且电子书或有库存)，
+-- 用于提示收藏的图书降价或重新可购买
+ALTER TABLE `favorites`
+  ADD COLUMN `price` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT '收藏时的价格' AFTER `book_id`,
+  ADD COLUMN `available` TINYINT NOT NULL DEFAULT 1 COMMENT '收藏时是否可购买' AFTER `price`,
+  ADD UNIQUE INDEX `uniq_user_book` (`user_id`, `book_id`);
+
+UPDATE `favorites` f
+JOIN `books` b ON b.`id` = f.`book_id`
+SET f.`price` = b.`price`,
+    f.`available` = IF(b.`status` = 1 AND (b.`type` = 1 OR b.`stock` > 0), 1, 0);
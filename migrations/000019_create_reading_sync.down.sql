@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `reading_annotations`;
+DROP TABLE IF EXISTS `reading_progress`;
+DROP TABLE IF EXISTS `reading_sync_seq`;
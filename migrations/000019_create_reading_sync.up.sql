@@ -0,0 +1,47 @@
+-- 阅读同步：每个用户一个递增的同步序号，每次写入阅读进度、书签、高亮都分配新的序号，
+-- 客户端保存最后一次同步的序号(since)，只拉取之后的变更
+CREATE TABLE IF NOT EXISTS `reading_sync_seq` (
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `seq` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '最新的同步序号',
+  PRIMARY KEY (`user_id`),
+  FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 阅读进度，每个用户每本书一条；client_updated_at 为客户端修改时间(毫秒)，按最后写入为准合并
+CREATE TABLE IF NOT EXISTS `reading_progress` (
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `position` VARCHAR(1024) NOT NULL COMMENT '阅读位置，EPUB CFI 或 PDF 页码',
+  `percentage` DECIMAL(5,2) NOT NULL DEFAULT 0 COMMENT '阅读百分比',
+  `client_updated_at` BIGINT UNSIGNED NOT NULL COMMENT '客户端修改时间，毫秒时间戳',
+  `seq` BIGINT UNSIGNED NOT NULL COMMENT '同步序号',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`user_id`, `book_id`),
+  INDEX `idx_user_seq` (`user_id`, `seq`),
+  FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`book_id`) REFERENCES books(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 书签和高亮，client_id 由客户端生成，删除时保留记录(deleted=1)以便同步到其他设备
+CREATE TABLE IF NOT EXISTS `reading_annotations` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `user_id` BIGINT UNSIGNED NOT NULL COMMENT '用户id',
+  `book_id` BIGINT UNSIGNED NOT NULL COMMENT '图书id',
+  `client_id` VARCHAR(64) NOT NULL COMMENT '客户端生成的唯一id',
+  `kind` VARCHAR(16) NOT NULL COMMENT '类型：bookmark 书签，highlight 高亮',
+  `position` VARCHAR(1024) NOT NULL COMMENT '位置，EPUB CFI(高亮为范围) 或 PDF 页码',
+  `content` TEXT NOT NULL COMMENT '高亮的文字',
+  `note` TEXT NOT NULL COMMENT '笔记',
+  `color` VARCHAR(16) NOT NULL DEFAULT '' COMMENT '高亮颜色',
+  `deleted` TINYINT NOT NULL DEFAULT 0 COMMENT '是否已删除',
+  `client_updated_at` BIGINT UNSIGNED NOT NULL COMMENT '客户端修改时间，毫秒时间戳',
+  `seq` BIGINT UNSIGNED NOT NULL COMMENT '同步序号',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uniq_user_client` (`user_id`, `client_id`),
+  INDEX `idx_user_seq` (`user_id`, `seq`),
+  INDEX `idx_user_book` (`user_id`, `book_id`),
+  FOREIGN KEY (`user_id`) REFERENCES users(`id`) ON DELETE CASCADE,
+  FOREIGN KEY (`book_id`) REFERENCES books(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
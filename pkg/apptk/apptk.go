@@ -2,6 +2,7 @@ package apptk
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/lightsaid/ebook/internal/dbrepo"
 	"github.com/lightsaid/ebook/pkg/errs"
+	"github.com/lightsaid/ebook/pkg/mergepatch"
 	"github.com/lightsaid/gotk"
 )
 
@@ -28,7 +30,7 @@ func (app *AppToolkit) ReadIntParam(r *http.Request, field string) (uint64, *got
 	return uint64(id), nil
 }
 
-// ReadPageQuery 从url获取pageNum、pageSize、sortFields三个字段
+// ReadPageQuery 从url获取pageNum、pageSize、where、cursor、sortFields字段
 func (app *AppToolkit) ReadPageQuery(r *http.Request) dbrepo.Filters {
 	var filter dbrepo.Filters
 	pageNumText := r.URL.Query().Get("pageNum")
@@ -38,6 +40,21 @@ func (app *AppToolkit) ReadPageQuery(r *http.Request) dbrepo.Filters {
 	filter.PageNum, _ = strconv.Atoi(pageNumText)
 	filter.PageSize, _ = strconv.Atoi(pageSizeText)
 
+	// 查询条件，格式为 field:op:value，如：where=status:eq:1&where=price:range:100,500
+	// 字段和操作符在构建sql时校验，不合法的返回入参错误
+	for _, raw := range r.URL.Query()["where"] {
+		if raw != "" {
+			filter.Conditions = append(filter.Conditions, dbrepo.ParseCondition(raw))
+		}
+	}
+
+	// 游标分页，传了cursor参数即使用游标分页代替页码分页，首页传空值：cursor=
+	// 之后使用返回的 metadata.nextCursor/prevCursor 翻页
+	if cursor, ok := r.URL.Query()["cursor"]; ok {
+		filter.UseCursor = true
+		filter.Cursor = cursor[0]
+	}
+
 	// 需要支持两种传参数方式：仅仅适合少量数组元素传递，url长度有限制
 	// 1: sortFields=field1&sortFields=field2&sortFields=field3
 	// 2: sortFields=field1,field2,field3
@@ -73,6 +90,28 @@ func (app *AppToolkit) ReadPageQuery(r *http.Request) dbrepo.Filters {
 	return filter
 }
 
+// SetETag 设置ETag响应头，值为数据的版本号，配合 If-Match 实现乐观锁
+func (app *AppToolkit) SetETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// ReadIfMatch 读取 If-Match 请求头中的版本号，支持 "1"、W/"1" 格式，没有或格式不对返回false
+func (app *AppToolkit) ReadIfMatch(r *http.Request) (int, bool) {
+	etag := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	if etag == "" {
+		return 0, false
+	}
+	text, err := strconv.Unquote(etag)
+	if err != nil {
+		text = etag
+	}
+	version, err := strconv.Atoi(text)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
 // ReadJSON 读取body参数绑定到dst上
 func (app *AppToolkit) ReadJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
 	err := gotk.ReadJSON(w, r, dst)
@@ -106,6 +145,31 @@ func (app *AppToolkit) ReadJSONAndCheck(w http.ResponseWriter, r *http.Request,
 	}
 }
 
+// ReadMergePatchAndCheck 读取 JSON Merge Patch(RFC 7396) 请求体，合并到 original 后绑定到 dst 上并校验，
+// original 一般是从数据库查出的数据，dst 是与之同类型的零值指针，只校验合并后的结果
+func (app *AppToolkit) ReadMergePatchAndCheck(w http.ResponseWriter, r *http.Request, original any, dst gotk.Verifiyer) bool {
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return false
+	}
+
+	if err := mergepatch.ApplyTo(original, patch, dst); err != nil {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(err.Error()))
+		return false
+	}
+
+	slog.InfoContext(r.Context(), "arg", slog.Any("arg", dst))
+
+	// 校验
+	if v, ok := gotk.DoVerifiy(dst); !ok {
+		app.FAIL(w, r, errs.ErrBadRequest.WithMessage(v.GetOne()))
+		return false
+	}
+
+	return true
+}
+
 // FAIL 写入请求失败的方法，data数据返回null
 func (app *AppToolkit) FAIL(w http.ResponseWriter, r *http.Request, a *gotk.ApiError) {
 	if a == nil {
@@ -115,6 +179,15 @@ func (app *AppToolkit) FAIL(w http.ResponseWriter, r *http.Request, a *gotk.ApiE
 	app.write(w, r, a, nil)
 }
 
+// FAILWithData 写入请求失败的方法，同时返回数据，比如版本冲突时返回最新的数据
+func (app *AppToolkit) FAILWithData(w http.ResponseWriter, r *http.Request, a *gotk.ApiError, data any) {
+	if a == nil {
+		slog.DebugContext(r.Context(), "AppToolkit.FAILWithData 请提供 *gotk.ApiError")
+		a = errs.ErrServerError
+	}
+	app.write(w, r, a, data)
+}
+
 // SUCC 写入请求成功的方法
 func (app *AppToolkit) SUCC(w http.ResponseWriter, r *http.Request, data any) {
 	app.write(w, r, errs.ErrOK, data)
@@ -14,6 +14,11 @@ var (
 	ErrNotFound            = gotk.NewApiError(http.StatusNotFound, "10404", "查无此数据")
 	ErrMethodNotAllowed    = gotk.NewApiError(http.StatusMethodNotAllowed, "10405", "请求方法不支持")
 	ErrRecordExists        = gotk.NewApiError(http.StatusConflict, "10409", "数据已存在")
+	ErrRecordReferenced    = gotk.NewApiError(http.StatusConflict, "12409", "数据仍被引用，无法彻底删除")
+	ErrOffsetConflict      = gotk.NewApiError(http.StatusConflict, "13409", "上传偏移量不一致，请查询已上传的大小后继续")
+	ErrVersionConflict     = gotk.NewApiError(http.StatusConflict, "11409", "数据已被他人修改，请刷新后重试")
+	ErrEntityTooLarge      = gotk.NewApiError(http.StatusRequestEntityTooLarge, "10413", "上传文件太大")
+	ErrUnsupportedMedia    = gotk.NewApiError(http.StatusUnsupportedMediaType, "10415", "不支持的文件类型")
 	ErrUnprocessableEntity = gotk.NewApiError(http.StatusUnprocessableEntity, "10422", "请求无法处理")
 	ErrTooManyRequests     = gotk.NewApiError(http.StatusTooManyRequests, "10429", "请求繁忙")
 	ErrServerError         = gotk.NewApiError(http.StatusInternalServerError, "10500", "请求错误，请稍后重试！")
@@ -0,0 +1,84 @@
+// Package mergepatch 实现 JSON Merge Patch (RFC 7396)，用于 PATCH 请求的部分更新
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNotObject patch 不是JSON对象，RFC 7396 允许整体替换，但资源更新只接受对象
+var ErrNotObject = errors.New("merge patch 必须是JSON对象")
+
+// Apply 将 patch 合并到 doc 上，返回合并后的JSON；
+// patch 中值为 null 的字段会被删除，对象递归合并，其他值直接替换
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 merge patch: %w", err)
+	}
+	if _, ok := p.(map[string]any); !ok {
+		return nil, ErrNotObject
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// ApplyTo 将 original 编码为JSON，合并 patch 后解码到 dst 上，dst 应该是零值的指针；
+// patch 中出现 original 没有的字段返回错误
+func ApplyTo(original any, patch []byte, dst any) error {
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	merged, err := Apply(doc, patch)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
+
+// decode 解码JSON，使用 json.Number 避免大整数丢失精度
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// merge RFC 7396 MergePatch 算法
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = merge(t[key], value)
+	}
+
+	return t
+}
@@ -0,0 +1,66 @@
+package mergepatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 7396 Appendix A 的示例，patch 不是对象的示例按设计返回 ErrNotObject
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+		wantErr          error
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `["a","b"]`, patch: `["c","d"]`, wantErr: ErrNotObject},
+		{doc: `{"a":"b"}`, patch: `["c"]`, wantErr: ErrNotObject},
+		{doc: `{"a":"foo"}`, patch: `null`, wantErr: ErrNotObject},
+		{doc: `{"a":"foo"}`, patch: `"bar"`, wantErr: ErrNotObject},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+
+		// 大整数不丢失精度
+		{doc: `{"id":1}`, patch: `{"id":18446744073709551615}`, want: `{"id":18446744073709551615}`},
+	}
+
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if tt.wantErr != nil {
+			require.ErrorIs(t, err, tt.wantErr, tt.patch)
+			continue
+		}
+		require.NoError(t, err, tt.patch)
+		require.JSONEq(t, tt.want, string(got), tt.patch)
+	}
+
+	_, err := Apply([]byte(`{}`), []byte(`{"a":`))
+	require.Error(t, err)
+}
+
+func TestApplyTo(t *testing.T) {
+	type item struct {
+		Name  string   `json:"name"`
+		Price int      `json:"price"`
+		Tags  []string `json:"tags"`
+	}
+
+	original := item{Name: "三体", Price: 100, Tags: []string{"科幻"}}
+
+	var dst item
+	err := ApplyTo(original, []byte(`{"price":80,"tags":null}`), &dst)
+	require.NoError(t, err)
+	require.Equal(t, item{Name: "三体", Price: 80}, dst)
+
+	// original 没有的字段
+	err = ApplyTo(original, []byte(`{"stock":1}`), new(item))
+	require.Error(t, err)
+}